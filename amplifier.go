@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Amplifier is a solid-state amplifier that is told the band over its
+// serial remote control port.
+type Amplifier interface {
+	SetBand(band string, freq float64) error
+}
+
+// AmplifierStandby is implemented by amplifiers that can be taken off line
+// remotely, so they pass the rig's drive straight through while their band
+// relays switch.
+type AmplifierStandby interface {
+	Operating() (bool, error)
+	SetOperate(on bool) error
+}
+
+// amplifierDriver creates an amplifier driver talking to a port, opened at
+// the amplifier's default baud rate unless one is given.
+type amplifierDriver struct {
+	baud   int
+	create func(port *outputPort) Amplifier
+}
+
+// amplifierDrivers are the amplifier drivers by name.
+var amplifierDrivers = map[string]amplifierDriver{
+	"kpa500": {38400, func(port *outputPort) Amplifier { return &kpa500{port: port} }},
+	"spe":    {115200, func(port *outputPort) Amplifier { return &speExpert{port: port, sleep: time.Sleep} }},
+	"acom":   {9600, func(port *outputPort) Amplifier { return &acom{port: port} }},
+}
+
+// AmplifierSink switches an amplifier to the band of one radio. With an
+// amplifier that can be taken off line, it is put in standby first and
+// brought back on line after the switching delay. Before anything switches
+// the rig is returned to receive, so the relays never switch under power.
+type AmplifierSink struct {
+	amp   Amplifier
+	port  *outputPort
+	radio string
+	delay time.Duration
+	// tx is the radio's transmit state, if its backend reports it.
+	tx TXController
+
+	// mu keeps band changes and the tuner's standby from talking to the
+	// amplifier at once.
+	mu    sync.Mutex
+	sleep func(time.Duration)
+}
+
+// NewAmplifierSink opens the amplifier for an --amplifier option of the
+// form DRIVER=PORT, following the radio with label. A baud of 0 uses the
+// amplifier's default.
+func NewAmplifierSink(spec string, baud int, delay time.Duration, radio string) (*AmplifierSink, error) {
+	name, addr, ok := strings.Cut(spec, "=")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("invalid amplifier %q, want DRIVER=PORT, e.g. kpa500=/dev/ttyUSB0", spec)
+	}
+	driver, ok := amplifierDrivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown amplifier driver %q (want kpa500, spe or acom)", name)
+	}
+	if baud == 0 {
+		baud = driver.baud
+	}
+	port := newOutputPort(addr, baud)
+	s := &AmplifierSink{amp: driver.create(port), port: port, radio: radio, delay: delay, sleep: time.Sleep}
+	if dryRun {
+		return s, nil
+	}
+	if err := port.Open(); err != nil {
+		return nil, fmt.Errorf("amplifier: %v", err)
+	}
+	return s, nil
+}
+
+func (s *AmplifierSink) Name() string { return "amplifier" }
+
+func (s *AmplifierSink) Wants(ev Event) bool {
+	switch ev.Type {
+	case EventInitialBand, EventBandChange:
+		return ev.Radio == s.radio
+	}
+	return false
+}
+
+func (s *AmplifierSink) Handle(ev Event) error {
+	if dryRun {
+		dryRunf("would switch the amplifier on %s to %s", s.port.addr, ev.Band)
+		return nil
+	}
+
+	// A band the amplifier doesn't have is refused before anything is
+	// switched, leaving it on line on the old band.
+	if checker, ok := s.amp.(amplifierBandChecker); ok {
+		if err := checker.checkBand(ev.Band); err != nil {
+			return err
+		}
+	}
+	if err := s.receive(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	operating, err := s.goStandby()
+	if err != nil {
+		return err
+	}
+	// If the band fails to switch the amplifier stays in standby, passing
+	// the drive through, rather than going on line on a band it may not be
+	// set to.
+	if err := s.amp.SetBand(ev.Band, ev.Freq); err != nil {
+		if operating {
+			return fmt.Errorf("%v; the amplifier was left in standby", err)
+		}
+		return err
+	}
+	if s.delay > 0 {
+		s.sleep(s.delay)
+	}
+	if operating {
+		return s.amp.(AmplifierStandby).SetOperate(true)
+	}
+	return nil
+}
+
+// goStandby takes an amplifier that is on line off line, reporting whether
+// it was on line; s.mu is held.
+func (s *AmplifierSink) goStandby() (bool, error) {
+	standby, ok := s.amp.(AmplifierStandby)
+	if !ok {
+		return false, nil
+	}
+	operating, err := standby.Operating()
+	if err != nil || !operating {
+		return false, err
+	}
+	return true, standby.SetOperate(false)
+}
+
+// standby takes the amplifier off line for the tuner, reporting whether it
+// was on line.
+func (s *AmplifierSink) standby() (bool, error) {
+	if s == nil || dryRun {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.goStandby()
+}
+
+// operate brings the amplifier back on line after standby.
+func (s *AmplifierSink) operate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.amp.(AmplifierStandby).SetOperate(true)
+}
+
+// amplifierRXChecks is how many times the rig is told to stop
+// transmitting, amplifierRXWait apart, before a band change is refused.
+const (
+	amplifierRXChecks = 5
+	amplifierRXWait   = 100 * time.Millisecond
+)
+
+// receive returns the rig to receive, aborting a transmission or tune
+// carrier, and confirms it is there, so the amplifier's relays never
+// switch under drive.
+func (s *AmplifierSink) receive() error {
+	if s.tx == nil {
+		return nil
+	}
+	state, err := s.tx.GetTRXState()
+	for i := 0; err == nil && state != "RX"; i++ {
+		if i == amplifierRXChecks {
+			return fmt.Errorf("the rig is still in %s, not switching the amplifier", state)
+		}
+		if i > 0 {
+			s.sleep(amplifierRXWait)
+		}
+		if err := s.tx.AbortTX(); err != nil {
+			return fmt.Errorf("failed to stop transmitting before switching the amplifier: %v", err)
+		}
+		state, err = s.tx.GetTRXState()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the transmit state before switching the amplifier: %v", err)
+	}
+	return nil
+}
+
+// amplifierBandChecker is implemented by drivers that know which bands
+// their amplifier has.
+type amplifierBandChecker interface {
+	checkBand(band string) error
+}
+
+// kpa500 drives an Elecraft KPA500 with its ^-prefixed ASCII commands:
+// "^BNnn;" selects a band, numbered as by the Elecraft BN command, and
+// "^OSn;" sets or, as "^OS;", reads standby (0) or operate (1).
+type kpa500 struct {
+	port *outputPort
+}
+
+func (a *kpa500) checkBand(band string) error {
+	if _, ok := elecraftBands[band]; !ok {
+		return fmt.Errorf("the KPA500 has no %s band", band)
+	}
+	return nil
+}
+
+func (a *kpa500) SetBand(band string, freq float64) error {
+	if err := a.checkBand(band); err != nil {
+		return err
+	}
+	return a.port.Write([]byte(fmt.Sprintf("^BN%02d;", elecraftBands[band])))
+}
+
+func (a *kpa500) Operating() (bool, error) {
+	if err := a.port.Write([]byte("^OS;")); err != nil {
+		return false, err
+	}
+	reply := make([]byte, 5)
+	if err := a.port.ReadFull(reply); err != nil {
+		return false, err
+	}
+	if !bytes.HasPrefix(reply, []byte("^OS")) || reply[4] != ';' {
+		return false, fmt.Errorf("unexpected KPA500 reply %q", reply)
+	}
+	return reply[3] == '1', nil
+}
+
+func (a *kpa500) SetOperate(on bool) error {
+	state := 0
+	if on {
+		state = 1
+	}
+	return a.port.Write([]byte(fmt.Sprintf("^OS%d;", state)))
+}
+
+// SPE Expert key codes, sent as if the front panel key was pressed, and
+// the status request.
+const (
+	speBandDown = 0x02
+	speBandUp   = 0x03
+	speOperate  = 0x0d
+	speStatus   = 0x90
+)
+
+// speExpert drives an SPE Expert amplifier. Its protocol emulates the
+// front panel keys, so the band is stepped with BAND- and BAND+ until the
+// status reports the one wanted, and OPERATE toggles standby.
+type speExpert struct {
+	port  *outputPort
+	sleep func(time.Duration)
+}
+
+// speKeyDelay is the time the amplifier is given to act on a key.
+const speKeyDelay = 100 * time.Millisecond
+
+// press sends a command packet: three sync bytes, the length, the command
+// and its checksum.
+func (a *speExpert) press(cmd byte) error {
+	return a.port.Write([]byte{0x55, 0x55, 0x55, 0x01, cmd, cmd})
+}
+
+// status returns the comma-separated fields of the amplifier's status,
+// e.g. the band code in field 6 and "O" for operate in field 2.
+func (a *speExpert) status() ([]string, error) {
+	if err := a.press(speStatus); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if err := a.port.ReadFull(header); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:3], []byte{0xaa, 0xaa, 0xaa}) {
+		return nil, fmt.Errorf("unexpected SPE status header % x", header)
+	}
+	// The fields are followed by a two byte checksum and CR LF.
+	data := make([]byte, int(header[3])+4)
+	if err := a.port.ReadFull(data); err != nil {
+		return nil, err
+	}
+	fields := strings.Split(string(data[:header[3]]), ",")
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("unexpected SPE status %q", data)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+func (a *speExpert) checkBand(band string) error {
+	if _, ok := elecraftBands[band]; !ok {
+		return fmt.Errorf("the SPE amplifier has no %s band", band)
+	}
+	return nil
+}
+
+func (a *speExpert) SetBand(band string, freq float64) error {
+	if err := a.checkBand(band); err != nil {
+		return err
+	}
+	want := elecraftBands[band]
+	for range elecraftBands {
+		fields, err := a.status()
+		if err != nil {
+			return err
+		}
+		current, err := strconv.Atoi(fields[6])
+		if err != nil {
+			return fmt.Errorf("unexpected SPE band %q", fields[6])
+		}
+		switch {
+		case current == want:
+			return nil
+		case current < want:
+			err = a.press(speBandUp)
+		default:
+			err = a.press(speBandDown)
+		}
+		if err != nil {
+			return err
+		}
+		a.sleep(speKeyDelay)
+	}
+	return fmt.Errorf("the SPE amplifier didn't reach %s", band)
+}
+
+func (a *speExpert) Operating() (bool, error) {
+	fields, err := a.status()
+	if err != nil {
+		return false, err
+	}
+	return fields[2] == "O", nil
+}
+
+func (a *speExpert) SetOperate(on bool) error {
+	operating, err := a.Operating()
+	if err != nil || operating == on {
+		return err
+	}
+	return a.press(speOperate)
+}
+
+// acom drives ACOM S-series amplifiers through their CAT input set to
+// Kenwood, which follows the "FAnnnnnnnnnnn;" frequency reports a Kenwood
+// rig would send. The input has no standby command.
+type acom struct {
+	port *outputPort
+}
+
+func (a *acom) SetBand(band string, freq float64) error {
+	msg, _ := encodeBandData("kenwood", band, freq)
+	return a.port.Write(msg)
+}
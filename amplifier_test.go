@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeKPA500 records commands and answers standby queries.
+type fakeKPA500 struct {
+	sent      bytes.Buffer
+	reply     bytes.Buffer
+	operating bool
+}
+
+func (a *fakeKPA500) Write(data []byte) (int, error) {
+	a.sent.Write(data)
+	switch string(data) {
+	case "^OS;":
+		state := 0
+		if a.operating {
+			state = 1
+		}
+		fmt.Fprintf(&a.reply, "^OS%d;", state)
+	case "^OS0;", "^OS1;":
+		a.operating = data[3] == '1'
+	}
+	return len(data), nil
+}
+
+func (a *fakeKPA500) Read(p []byte) (int, error) { return a.reply.Read(p) }
+func (a *fakeKPA500) Close() error               { return nil }
+
+func testPort(conn io.ReadWriteCloser) *outputPort {
+	return &outputPort{addr: "/dev/ttyUSB3", open: func() (io.ReadWriteCloser, error) { return conn, nil }}
+}
+
+func TestAmplifierSink(t *testing.T) {
+	amp := &fakeKPA500{operating: true}
+	tx := &fakeTX{state: "TX"}
+	var slept time.Duration
+	s := &AmplifierSink{amp: &kpa500{port: testPort(amp)}, port: testPort(amp), radio: "A", delay: 200 * time.Millisecond, tx: tx,
+		sleep: func(d time.Duration) { slept += d }}
+
+	for _, ev := range []Event{
+		{Type: EventBandChange, Radio: "B", Band: "40m"},
+		{Type: EventOutOfBand, Radio: "A", Band: "unknown"},
+		{Type: EventBandChange, Radio: "A", Band: "20m"},
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := amp.sent.String(); got != "^OS;^OS0;^BN05;^OS1;" {
+		t.Errorf("sent %q", got)
+	}
+	if tx.aborted != 1 || slept != 200*time.Millisecond {
+		t.Errorf("aborted %d transmissions and waited %v", tx.aborted, slept)
+	}
+
+	// An amplifier left in standby stays there.
+	amp.operating = false
+	amp.sent.Reset()
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "6m"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := amp.sent.String(); got != "^OS;^BN10;" {
+		t.Errorf("sent %q in standby", got)
+	}
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "2m"}); err == nil {
+		t.Error("switched the KPA500 to 2m")
+	}
+
+	// A band the KPA500 doesn't have leaves it on line.
+	amp.operating = true
+	amp.sent.Reset()
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "2m"}); err == nil || amp.sent.Len() != 0 {
+		t.Errorf("2m = %v, sent %q", err, amp.sent.String())
+	}
+}
+
+// stuckTX is a rig that stays in its state however often it is aborted.
+type stuckTX struct {
+	state   string
+	aborted int
+}
+
+func (f *stuckTX) GetTRXState() (string, error) { return f.state, nil }
+func (f *stuckTX) AbortTX() error               { f.aborted++; return nil }
+
+func TestAmplifierSinkTune(t *testing.T) {
+	// A tune carrier is dropped before the amplifier is touched.
+	amp := &fakeKPA500{operating: true}
+	tx := &fakeTX{state: "TUNE"}
+	s := &AmplifierSink{amp: &kpa500{port: testPort(amp)}, port: testPort(amp), radio: "A", tx: tx, sleep: func(time.Duration) {}}
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "40m"}); err != nil {
+		t.Fatal(err)
+	}
+	if tx.aborted != 1 || amp.sent.String() != "^OS;^OS0;^BN03;^OS1;" {
+		t.Errorf("aborted %d, sent %q", tx.aborted, amp.sent.String())
+	}
+
+	// One that won't drop refuses the band change without switching.
+	stuck := &stuckTX{state: "TUNE"}
+	s.tx = stuck
+	amp.sent.Reset()
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "20m"}); err == nil {
+		t.Error("switched with the rig still tuning")
+	}
+	if stuck.aborted != amplifierRXChecks || amp.sent.Len() != 0 || !amp.operating {
+		t.Errorf("aborted %d, sent %q, operating %v", stuck.aborted, amp.sent.String(), amp.operating)
+	}
+}
+
+// fakeSPE emulates an SPE Expert's keys and status.
+type fakeSPE struct {
+	band      int
+	operating bool
+	keys      []byte
+	reply     bytes.Buffer
+}
+
+func (a *fakeSPE) Write(data []byte) (int, error) {
+	if len(data) != 6 || !bytes.HasPrefix(data, []byte{0x55, 0x55, 0x55, 0x01}) || data[4] != data[5] {
+		return 0, fmt.Errorf("bad packet % x", data)
+	}
+	switch cmd := data[4]; cmd {
+	case speStatus:
+		state := "S"
+		if a.operating {
+			state = "O"
+		}
+		status := fmt.Sprintf(",13K,%s,R,A,1,%02d,1a,0r,L,0000, 0.00, 0.00, 0.0, 0.0, 33,000,000,N,N,", state, a.band)
+		a.reply.Write([]byte{0xaa, 0xaa, 0xaa, byte(len(status))})
+		a.reply.WriteString(status + "\x00\x00\r\n")
+	default:
+		a.keys = append(a.keys, cmd)
+		switch cmd {
+		case speBandUp:
+			a.band++
+		case speBandDown:
+			a.band--
+		case speOperate:
+			a.operating = !a.operating
+		}
+	}
+	return len(data), nil
+}
+
+func (a *fakeSPE) Read(p []byte) (int, error) { return a.reply.Read(p) }
+func (a *fakeSPE) Close() error               { return nil }
+
+func TestSPEExpert(t *testing.T) {
+	amp := &fakeSPE{band: 3, operating: true}
+	spe := &speExpert{port: testPort(amp), sleep: func(time.Duration) {}}
+	s := &AmplifierSink{amp: spe, port: spe.port, sleep: func(time.Duration) {}}
+
+	if err := s.Handle(Event{Type: EventBandChange, Band: "15m"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{speOperate, speBandUp, speBandUp, speBandUp, speBandUp, speOperate}
+	if !bytes.Equal(amp.keys, want) || amp.band != 7 || !amp.operating {
+		t.Errorf("pressed % x, now on band %d, operating %v", amp.keys, amp.band, amp.operating)
+	}
+
+	amp.keys = nil
+	if err := spe.SetBand("160m", 1840000); err != nil || amp.band != 0 || len(amp.keys) != 7 {
+		t.Errorf("SetBand(160m) = %v, pressed % x", err, amp.keys)
+	}
+}
+
+func TestNewAmplifierSink(t *testing.T) {
+	for _, spec := range []string{"kpa500", "kpa500=", "alpha=/dev/ttyUSB0"} {
+		if _, err := NewAmplifierSink(spec, 0, 0, ""); err == nil {
+			t.Errorf("NewAmplifierSink(%q) succeeded", spec)
+		}
+	}
+
+	port := &fakePort{}
+	a := &acom{port: testPort(port)}
+	if err := a.SetBand("40m", 7074000); err != nil || port.String() != "FA00007074000;" {
+		t.Errorf("ACOM sent %q, %v", port.String(), err)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AntennaSwitch is a networked antenna switch.
+type AntennaSwitch interface {
+	Select(sel AntennaSelection) error
+}
+
+// AntennaSelection connects a radio port of a switch, numbered from 1, to
+// an antenna for a band.
+type AntennaSelection struct {
+	Radio   string
+	Port    int
+	Band    string
+	Antenna int
+}
+
+// AntennaSwitchConfig configures an antenna switch driver.
+type AntennaSwitchConfig struct {
+	Addr string
+	// Command is the command template of the tcp and udp drivers.
+	Command string
+}
+
+// antennaDrivers create the antenna switch drivers by name; supporting
+// another switch is a matter of adding its driver here.
+var antennaDrivers = map[string]func(cfg AntennaSwitchConfig) (AntennaSwitch, error){
+	"4o3a": newAntennaGenius,
+	"tcp":  newTemplateSwitch("tcp"),
+	"udp":  newTemplateSwitch("udp"),
+}
+
+// NewAntennaSwitch creates the switch for a --antenna-switch option of the
+// form DRIVER=ADDR.
+func NewAntennaSwitch(spec, command string) (AntennaSwitch, error) {
+	name, addr, ok := strings.Cut(spec, "=")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("invalid antenna switch %q, want DRIVER=ADDR, e.g. 4o3a=192.168.1.50", spec)
+	}
+	driver, ok := antennaDrivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown antenna switch driver %q (want 4o3a, tcp or udp)", name)
+	}
+	return driver(AntennaSwitchConfig{Addr: addr, Command: command})
+}
+
+// AntennaChoice is the antenna selected on a band, for one radio or, with
+// Radio empty, every radio.
+type AntennaChoice struct {
+	Radio   string
+	Band    string
+	Antenna int
+}
+
+// ParseAntennaChoice parses an --antenna option of the form
+// [LABEL:]BAND=ANTENNA, e.g. "20m=2" or "B:40m=3".
+func ParseAntennaChoice(spec string) (AntennaChoice, error) {
+	var c AntennaChoice
+	rest := spec
+	if label, r, ok := strings.Cut(spec, ":"); ok {
+		c.Radio, rest = label, r
+	}
+	band, value, ok := strings.Cut(rest, "=")
+	n, err := strconv.Atoi(value)
+	if !ok || err != nil || n < 0 {
+		return c, fmt.Errorf("invalid antenna %q, want [LABEL:]BAND=ANTENNA, e.g. 20m=2", spec)
+	}
+	if !isBand(band) {
+		return c, fmt.Errorf("antenna %q: unknown band %s", spec, band)
+	}
+	c.Band, c.Antenna = band, n
+	return c, nil
+}
+
+// AntennaSink selects the antenna for the band each radio changes to. The
+// radios are on the switch's ports in order, the first on port 1.
+type AntennaSink struct {
+	sw      AntennaSwitch
+	addr    string
+	radios  []string
+	choices []AntennaChoice
+}
+
+// NewAntennaSink selects antennas on sw for the radios with the given
+// labels.
+func NewAntennaSink(sw AntennaSwitch, addr string, radios []string, choices []AntennaChoice) *AntennaSink {
+	return &AntennaSink{sw: sw, addr: addr, radios: radios, choices: choices}
+}
+
+func (s *AntennaSink) Name() string { return "antenna-switch" }
+
+func (s *AntennaSink) Wants(ev Event) bool {
+	if ev.Type != EventBandChange && ev.Type != EventInitialBand {
+		return false
+	}
+	_, ok := s.selection(ev)
+	return ok
+}
+
+// selection returns the antenna for an event's radio and band, a choice
+// for the radio taking precedence over one for every radio.
+func (s *AntennaSink) selection(ev Event) (AntennaSelection, bool) {
+	sel := AntennaSelection{Radio: ev.Radio, Band: ev.Band}
+	for i, r := range s.radios {
+		if r == ev.Radio {
+			sel.Port = i + 1
+		}
+	}
+	if sel.Port == 0 {
+		return sel, false
+	}
+	ok := false
+	for _, c := range s.choices {
+		if c.Band == ev.Band && (c.Radio == ev.Radio || (c.Radio == "" && !ok)) {
+			sel.Antenna, ok = c.Antenna, true
+		}
+	}
+	return sel, ok
+}
+
+func (s *AntennaSink) Handle(ev Event) error {
+	sel, _ := s.selection(ev)
+	if dryRun {
+		dryRunf("would select antenna %d on port %d of %s for %s", sel.Antenna, sel.Port, s.addr, sel.Band)
+		return nil
+	}
+	return s.sw.Select(sel)
+}
+
+// antennaGenius drives a 4O3A Antenna Genius over its TCP API, sending
+// "C<seq>|port set <port> rxant=<n> txant=<n>" and checking the
+// "R<seq>|<code>|..." reply.
+type antennaGenius struct {
+	addr string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+}
+
+// antennaGeniusPort is the Antenna Genius API port.
+const antennaGeniusPort = "9007"
+
+func newAntennaGenius(cfg AntennaSwitchConfig) (AntennaSwitch, error) {
+	addr := cfg.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, antennaGeniusPort)
+	}
+	return &antennaGenius{addr: addr}, nil
+}
+
+func (g *antennaGenius) Select(sel AntennaSelection) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		conn, err := net.DialTimeout(tcpNetwork(), g.addr, dialTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to connect to Antenna Genius %s: %v", g.addr, err)
+		}
+		g.conn = conn
+		g.reader = bufio.NewReader(conn)
+		// The switch greets each client with its version, e.g. "V4.0.22 AG".
+		conn.SetDeadline(time.Now().Add(requestTimeout))
+		if _, err := g.reader.ReadString('\n'); err != nil {
+			g.close()
+			return fmt.Errorf("no greeting from Antenna Genius %s: %v", g.addr, err)
+		}
+	}
+
+	g.seq++
+	err := g.command(fmt.Sprintf("port set %d rxant=%d txant=%d", sel.Port, sel.Antenna, sel.Antenna))
+	if err != nil {
+		g.close()
+	}
+	return err
+}
+
+func (g *antennaGenius) command(cmd string) error {
+	g.conn.SetDeadline(time.Now().Add(requestTimeout))
+	if _, err := fmt.Fprintf(g.conn, "C%d|%s\r\n", g.seq, cmd); err != nil {
+		return fmt.Errorf("failed to send to Antenna Genius: %v", err)
+	}
+	prefix := "R" + strconv.Itoa(g.seq) + "|"
+	for {
+		line, err := g.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read Antenna Genius reply: %v", err)
+		}
+		// Status messages may arrive ahead of the reply.
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		code, message, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, prefix)), "|")
+		if n, err := strconv.ParseUint(code, 16, 32); err != nil || n != 0 {
+			return fmt.Errorf("Antenna Genius refused %q: %s %s", cmd, code, message)
+		}
+		return nil
+	}
+}
+
+func (g *antennaGenius) close() {
+	g.conn.Close()
+	g.conn = nil
+}
+
+// templateSwitch sends a command template over TCP or UDP, for switches
+// without a driver of their own. The template's {port}, {antenna},
+// {band} and {radio} are replaced, and \r and \n stand for line endings.
+type templateSwitch struct {
+	network string
+	port    *outputPort
+	addr    string
+	command string
+}
+
+func newTemplateSwitch(network string) func(cfg AntennaSwitchConfig) (AntennaSwitch, error) {
+	return func(cfg AntennaSwitchConfig) (AntennaSwitch, error) {
+		if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+			return nil, fmt.Errorf("invalid %s antenna switch address %q, want host:port", network, cfg.Addr)
+		}
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("the %s antenna switch driver needs --antenna-switch-command", network)
+		}
+		s := &templateSwitch{network: network, addr: cfg.Addr, command: cfg.Command}
+		if network == "tcp" {
+			s.port = newOutputPort(cfg.Addr, 0)
+		}
+		return s, nil
+	}
+}
+
+func (s *templateSwitch) Select(sel AntennaSelection) error {
+	msg := strings.NewReplacer(
+		`\r`, "\r", `\n`, "\n",
+		"{port}", strconv.Itoa(sel.Port),
+		"{antenna}", strconv.Itoa(sel.Antenna),
+		"{band}", sel.Band,
+		"{radio}", sel.Radio,
+	).Replace(s.command)
+	return s.send(msg)
+}
+
+func (s *templateSwitch) send(msg string) error {
+	if s.port != nil {
+		return s.port.Write([]byte(msg))
+	}
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to send to antenna switch %s: %v", s.addr, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("failed to send to antenna switch %s: %v", s.addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeAntennaGenius answers the Antenna Genius API, refusing antenna 9,
+// and records the commands it receives.
+func fakeAntennaGenius(t *testing.T) (string, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	commands := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprint(conn, "V4.0.22 AG\r\n")
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					seq, cmd, _ := strings.Cut(strings.TrimPrefix(scanner.Text(), "C"), "|")
+					commands <- cmd
+					fmt.Fprint(conn, "S0|port 2 auto=1\r\n")
+					if strings.Contains(cmd, "rxant=9") {
+						fmt.Fprintf(conn, "R%s|31|invalid antenna\r\n", seq)
+						continue
+					}
+					fmt.Fprintf(conn, "R%s|0|\r\n", seq)
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestAntennaGenius(t *testing.T) {
+	addr, commands := fakeAntennaGenius(t)
+	sw, err := NewAntennaSwitch("4o3a="+addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.Select(AntennaSelection{Port: 2, Band: "20m", Antenna: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-commands; cmd != "port set 2 rxant=3 txant=3" {
+		t.Errorf("command = %q", cmd)
+	}
+	if err := sw.Select(AntennaSelection{Port: 1, Antenna: 9}); err == nil || !strings.Contains(err.Error(), "invalid antenna") {
+		t.Errorf("refused selection returned %v", err)
+	}
+	// The connection is dropped after a failure and made again.
+	<-commands
+	if err := sw.Select(AntennaSelection{Port: 1, Antenna: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewAntennaSwitch("acme=10.0.0.1", ""); err == nil {
+		t.Error("unknown driver accepted")
+	}
+	if _, err := NewAntennaSwitch("udp=10.0.0.1:12090", ""); err == nil {
+		t.Error("udp driver accepted without a command")
+	}
+}
+
+func TestTemplateSwitch(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sw, err := NewAntennaSwitch("udp="+conn.LocalAddr().String(), `SW{port},{antenna} {band}\r`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Select(AntennaSelection{Port: 1, Band: "40m", Antenna: 2}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "SW1,2 40m\r" {
+		t.Errorf("received %q, %v", buf[:n], err)
+	}
+}
+
+type fakeAntennaSwitch struct {
+	selected []AntennaSelection
+}
+
+func (f *fakeAntennaSwitch) Select(sel AntennaSelection) error {
+	f.selected = append(f.selected, sel)
+	return nil
+}
+
+func TestAntennaSink(t *testing.T) {
+	var choices []AntennaChoice
+	for _, spec := range []string{"B:20m=4", "20m=2", "40m=1"} {
+		c, err := ParseAntennaChoice(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		choices = append(choices, c)
+	}
+	for _, spec := range []string{"20m", "20m=x", "11m=1"} {
+		if _, err := ParseAntennaChoice(spec); err == nil {
+			t.Errorf("ParseAntennaChoice(%q) succeeded", spec)
+		}
+	}
+
+	sw := &fakeAntennaSwitch{}
+	s := NewAntennaSink(sw, "192.168.1.50", []string{"A", "B"}, choices)
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Radio: "A", Band: "20m"},
+		{Type: EventInitialBand, Radio: "B", Band: "20m"},
+		{Type: EventBandChange, Radio: "B", Band: "40m"},
+		{Type: EventBandChange, Radio: "A", Band: "15m"}, // no antenna
+		{Type: EventBandChange, Radio: "C", Band: "40m"}, // not on the switch
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []AntennaSelection{
+		{Radio: "A", Port: 1, Band: "20m", Antenna: 2},
+		{Radio: "B", Port: 2, Band: "20m", Antenna: 4},
+		{Radio: "B", Port: 2, Band: "40m", Antenna: 1},
+	}
+	if len(sw.selected) != len(want) {
+		t.Fatalf("selected %+v, want %+v", sw.selected, want)
+	}
+	for i := range want {
+		if sw.selected[i] != want[i] {
+			t.Errorf("selection %d = %+v, want %+v", i, sw.selected[i], want[i])
+		}
+	}
+}
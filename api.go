@@ -0,0 +1,335 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API serves the daemon's status and control endpoints to local clients
+// such as the get, set and rules subcommands.
+type API struct {
+	monitors []*Monitor
+	// rigs are the backends changes are made through, by radio label.
+	rigs map[string]Backend
+	// privileges, if set, refuses tuning outside the operator's privileges.
+	privileges *Privileges
+	// region, if set, follows the ITU region with --region auto.
+	region *RegionSelector
+	// exchange is the contest exchange context set with `exchange set`.
+	exchange *Exchange
+	// token, if set, must be sent as a bearer token to the TCP address.
+	token string
+	// rollout, if set, accepts bundles pushed with `fldigi-cmd push`.
+	rollout *Rollout
+	// dispatcher, if set, receives the test events of /api/hooks/test.
+	dispatcher *Dispatcher
+	// presets are the frequency memories `fldigi-cmd goto` tunes to.
+	presets *Presets
+
+	// maxTX is the limit on tx and tune requests that give no
+	// max_seconds, or 0 for none.
+	maxTX time.Duration
+	// trxLimits force radios back to receive once a tx or tune request's
+	// max_seconds are up.
+	trxMu     sync.Mutex
+	trxLimits map[string]*time.Timer
+}
+
+// Register adds the API endpoints to the server at addr, each both at its
+// own path, used by the subcommands, and under /api/ for other software.
+func (a *API) Register(addr string) {
+	for _, e := range []struct {
+		pattern, api string
+		handler      http.HandlerFunc
+	}{
+		{"/status", "/api/status", a.handleStatus},
+		{"/frequency", "/api/set/frequency", a.handleFrequency},
+		{"/rules", "/api/rules", a.handleRules},
+		{"/region", "/api/region", a.handleRegion},
+		{"/exchange", "/api/exchange", a.handleExchange},
+		{"/bundle", "/api/bundle", a.handleBundle},
+		{"/rollout", "/api/rollout", a.handleRollout},
+		{"/presets", "/api/presets", a.handlePresets},
+		{"/trx", "/api/trx", a.handleTRX},
+		{"/receiver", "/api/receiver", a.handleReceiver},
+	} {
+		a.Handle(addr, e.pattern, e.handler)
+		a.Handle(addr, e.api, e.handler)
+	}
+	a.Handle(addr, "/api/hooks/test", http.HandlerFunc(a.handleHookTest))
+}
+
+// Handle registers a handler on an API address, requiring the token on TCP
+// addresses; the Unix-domain socket is only open to the current user.
+// Changes from other sites' pages are refused on every address.
+func (a *API) Handle(addr, pattern string, handler http.Handler) {
+	if a.token != "" && !strings.HasPrefix(addr, "unix:") {
+		handler = requireToken(a.token, handler)
+	}
+	handleHTTP(addr, pattern, refuseCrossOrigin(auditChanges(addr, handler)))
+}
+
+// statusRecorder remembers the status a handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// auditChanges logs every request other than GET and HEAD, with where it
+// came from and how it was answered, so changes made through the API, the
+// CLI's included, can be traced.
+func auditChanges(addr string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(rec, r)
+		query := r.URL.Query()
+		query.Del("token")
+		from := r.RemoteAddr
+		if strings.HasPrefix(addr, "unix:") {
+			from = "the local socket"
+		}
+		target := r.URL.Path
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		log.Printf("API: %s %s from %s: %d %s", r.Method, target, from, rec.status, http.StatusText(rec.status))
+	})
+}
+
+// sameOrigin reports whether a request comes from no page or one served by
+// the daemon itself, judged by its Origin header.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// refuseCrossOrigin refuses requests other than GET and HEAD from other
+// sites' pages: a browser sends them to localhost for any page the operator
+// opens, so without it a page could tune or key the rig.
+func refuseCrossOrigin(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requireToken refuses requests without "Authorization: Bearer <token>".
+func requireToken(token string, handler http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or invalid API token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// tokenFromQuery lets a client that can't set headers, such as a browser
+// opening a WebSocket, give the API token as ?token=.
+func tokenFromQuery(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// handleStatus returns the latest status of every radio.
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := make([]MonitorStatus, len(a.monitors))
+	for i, m := range a.monitors {
+		status[i] = m.Status()
+	}
+	writeJSON(w, status)
+}
+
+// handleFrequency tunes a radio: POST /frequency?freq=14074000[&radio=B].
+func (a *API) handleFrequency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	freq, err := strconv.ParseFloat(r.URL.Query().Get("freq"), 64)
+	if err != nil || freq <= 0 {
+		http.Error(w, "invalid freq", http.StatusBadRequest)
+		return
+	}
+	if code, err := a.tune(r.URL.Query().Get("radio"), freq); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	writeJSON(w, map[string]float64{"freq": freq})
+}
+
+// tune tunes a radio, returning the HTTP status of a failure.
+func (a *API) tune(radio string, freq float64) (int, error) {
+	rig, ok := a.rigs[radio]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("unknown radio")
+	}
+	if freq <= 0 {
+		return http.StatusBadRequest, fmt.Errorf("invalid freq")
+	}
+	if a.privileges != nil && !a.privileges.Allows(freq) {
+		return http.StatusForbidden, fmt.Errorf("%.6f MHz is outside %s privileges", freq/1000000, a.privileges.Name)
+	}
+	if err := rig.SetFrequency(freq); err != nil {
+		return http.StatusBadGateway, err
+	}
+	return http.StatusOK, nil
+}
+
+// setMode changes a radio's mode, returning the HTTP status of a failure.
+func (a *API) setMode(radio, mode string) (int, error) {
+	rig, ok := a.rigs[radio]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("unknown radio")
+	}
+	if mode == "" {
+		return http.StatusBadRequest, fmt.Errorf("invalid mode")
+	}
+	setter, ok := rig.(ModeSetter)
+	if !ok {
+		return http.StatusNotImplemented, fmt.Errorf("the %s backend can't change the mode", rig.Name())
+	}
+	if err := setter.SetMode(mode); err != nil {
+		return http.StatusBadGateway, err
+	}
+	return http.StatusOK, nil
+}
+
+// sendText transmits text on a radio, returning the HTTP status of a
+// failure.
+func (a *API) sendText(radio, text string) (int, error) {
+	rig, ok := a.rigs[radio]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("unknown radio")
+	}
+	if text == "" {
+		return http.StatusBadRequest, fmt.Errorf("no text to send")
+	}
+	sender, ok := rig.(TextSender)
+	if !ok {
+		return http.StatusNotImplemented, fmt.Errorf("the %s backend can't transmit text", rig.Name())
+	}
+	if err := sender.SendText(text); err != nil {
+		return http.StatusBadGateway, err
+	}
+	return http.StatusOK, nil
+}
+
+// handleRules returns whether each radio's rules currently match.
+func (a *API) handleRules(w http.ResponseWriter, r *http.Request) {
+	rules := map[string][]RuleStatus{}
+	for _, m := range a.monitors {
+		if m.rules != nil {
+			rules[m.radio] = m.rules.Status()
+		}
+	}
+	writeJSON(w, rules)
+}
+
+// handleRegion returns the active and pending ITU regions; POST
+// /region?accept=1 switches to the pending one.
+func (a *API) handleRegion(w http.ResponseWriter, r *http.Request) {
+	if a.region == nil {
+		http.Error(w, "region selection is not enabled (use --region auto)", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Query().Get("accept") != "" {
+		if _, err := a.region.Accept(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+	writeJSON(w, a.region.Status())
+}
+
+// handleHookTest emits a made-up event so hooks can be tried without
+// changing band: POST /api/hooks/test?type=band-change&band=20m, with
+// optional freq, mode, radio, call and prev_band. It returns the event and
+// the sinks it was passed to; their results follow as hook-failed events.
+func (a *API) handleHookTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.dispatcher == nil {
+		http.Error(w, "hooks can't be tested", http.StatusNotFound)
+		return
+	}
+	r.ParseForm()
+	ev := Event{
+		Type:     r.Form.Get("type"),
+		Band:     r.Form.Get("band"),
+		PrevBand: r.Form.Get("prev_band"),
+		Mode:     r.Form.Get("mode"),
+		Radio:    r.Form.Get("radio"),
+		Call:     strings.ToUpper(r.Form.Get("call")),
+	}
+	if ev.Type == "" {
+		ev.Type = EventBandChange
+	}
+	if v := r.Form.Get("freq"); v != "" {
+		freq, err := parseFrequency(v)
+		if err != nil {
+			http.Error(w, "invalid freq", http.StatusBadRequest)
+			return
+		}
+		ev.Freq = freq
+		if ev.Band == "" {
+			ev.Band = frequencyToBand(freq)
+		}
+		if seg, ok := findSegment(freq); ok {
+			ev.Segment = seg.Name
+		}
+	}
+	ev.Time = time.Now()
+	sinks := a.dispatcher.Wanting(ev)
+	a.dispatcher.Emit(ev)
+	writeJSON(w, map[string]interface{}{"event": ev, "sinks": sinks})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// defaultAPISocket is where the daemon listens for local clients: the
+// user's runtime directory if there is one, otherwise the temp directory.
+func defaultAPISocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("fldigi-cmd-%d.sock", os.Getuid()))
+	}
+	return filepath.Join(dir, "fldigi-cmd.sock")
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIOverUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	l, err := listenUnix(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v", info.Mode(), err)
+	}
+	if _, err := listenUnix(socket); err == nil {
+		t.Error("expected an error while another daemon is listening")
+	}
+
+	m, _ := newTestMonitor()
+	m.radio = "A"
+	m.rules = NewRuleEngine([]*Rule{{Name: "20m-amp", When: RuleCondition{Bands: []string{"20m"}}}}, m.metrics, m.dispatcher)
+	m.rules.run = func(RuleAction, Event) error { return nil }
+	m.observe(14074000, time.Now())
+	m.setStatus(14074000, nil)
+
+	rig := &fakeBackend{name: "fake"}
+	api := &API{monitors: []*Monitor{m}, rigs: map[string]Backend{"A": rig}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", api.handleStatus)
+	mux.HandleFunc("/frequency", api.handleFrequency)
+	mux.HandleFunc("/rules", api.handleRules)
+	go http.Serve(l, mux)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	connect := addAPIFlags(fs)
+	fs.Parse([]string{"--api-socket", socket})
+	client := connect()
+
+	var status []MonitorStatus
+	if err := client.do(http.MethodGet, "/status", nil, &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 || formatStatus(status[0], "") != "A: 20m 14.074000 MHz 20m-FT8" {
+		t.Errorf("status = %+v", status)
+	}
+
+	var result map[string]float64
+	if err := client.do(http.MethodPost, "/frequency", url.Values{"radio": {"A"}, "freq": {"7074000"}}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(rig.sets) != 1 || rig.sets[0] != 7074000 {
+		t.Errorf("sets = %v", rig.sets)
+	}
+	if err := client.do(http.MethodPost, "/frequency", url.Values{"radio": {"B"}, "freq": {"7074000"}}, &result); err == nil {
+		t.Error("expected an error for an unknown radio")
+	}
+
+	// Safety checks only the daemon knows about apply to CLI changes.
+	api.privileges, _ = LoadLicenseProfile("technician")
+	if err := client.do(http.MethodPost, "/frequency", url.Values{"radio": {"A"}, "freq": {"14074000"}}, &result); err == nil {
+		t.Error("expected tuning outside privileges to be refused")
+	}
+	if len(rig.sets) != 1 {
+		t.Errorf("sets = %v", rig.sets)
+	}
+
+	var rules map[string][]RuleStatus
+	if err := client.do(http.MethodGet, "/rules", nil, &rules); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules["A"]; len(got) != 1 || got[0].Name != "20m-amp" || !got[0].Active {
+		t.Errorf("rules = %+v", rules)
+	}
+}
+
+func TestParseFrequency(t *testing.T) {
+	tests := map[string]float64{
+		"14074000": 14074000,
+		"14074k":   14074000,
+		"14.074M":  14074000,
+		"7.074MHz": 7074000,
+	}
+	for in, want := range tests {
+		if got, err := parseFrequency(in); err != nil || got != want {
+			t.Errorf("parseFrequency(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "-5"} {
+		if _, err := parseFrequency(in); err == nil {
+			t.Errorf("parseFrequency(%q) succeeded", in)
+		}
+	}
+}
+
+func TestViaDaemonFallsBack(t *testing.T) {
+	var direct bool
+	err := viaDaemon(false, func() error {
+		return &noDaemonError{addr: "test.sock", err: os.ErrNotExist}
+	}, func() error {
+		direct = true
+		return nil
+	})
+	if err != nil || !direct {
+		t.Errorf("viaDaemon() = %v, direct %v", err, direct)
+	}
+
+	// A daemon that answers with an error is not bypassed.
+	direct = false
+	refused := viaDaemon(false, func() error { return os.ErrPermission }, func() error {
+		direct = true
+		return nil
+	})
+	if refused == nil || direct {
+		t.Errorf("viaDaemon() = %v, direct %v", refused, direct)
+	}
+}
+
+func TestDirectFlagsCheckPrivileges(t *testing.T) {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	_, _, allowed := addDirectFlags(fs)
+	fs.Parse([]string{"--license", "technician"})
+	if err := allowed(28400000); err != nil {
+		t.Errorf("allowed(28.4 MHz) = %v", err)
+	}
+	if err := allowed(14074000); err == nil || !strings.Contains(err.Error(), "outside technician privileges") {
+		t.Errorf("allowed(14.074 MHz) = %v, want outside privileges", err)
+	}
+}
+
+func TestDirectFlagsRejectDaemonOnlyOptions(t *testing.T) {
+	t.Setenv("FLDIGI_IP_VERSION", "4")
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	_, connect, _ := addDirectFlags(fs)
+	fs.Parse([]string{"--backend", "nosuch"})
+	if _, err := connect(); err == nil || strings.Contains(err.Error(), "FLDIGI_IP_VERSION") {
+		t.Errorf("connect() = %v, want the backend error", err)
+	}
+
+	for name, value := range map[string]string{"FLDIGI_URL": "https://shack:17362/RPC2", "FLDIGI_TLS_CA": "ca.pem", "FLDIGI_IP_VERSION": "6"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := connect(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("connect() = %v, want %s rejected", err, name)
+			}
+		})
+	}
+}
+
+func TestAPIClientNoDaemon(t *testing.T) {
+	// Nothing listens on a closed port, so the CLI may go direct.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+	client := &apiClient{http: http.DefaultClient, base: "http://" + addr, addr: addr}
+	var noDaemon *noDaemonError
+	if err := client.do(http.MethodPost, "/frequency", nil, nil); !errors.As(err, &noDaemon) {
+		t.Errorf("refused connection = %v", err)
+	}
+
+	// A daemon that drops the connection may have made the change.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer srv.Close()
+	client = &apiClient{http: http.DefaultClient, base: srv.URL, addr: srv.Listener.Addr().String()}
+	if err := client.do(http.MethodPost, "/frequency", nil, nil); err == nil || errors.As(err, &noDaemon) {
+		t.Errorf("dropped connection = %v", err)
+	}
+}
+
+func TestAuditChanges(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	handler := auditChanges("127.0.0.1:7365", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "outside privileges", http.StatusForbidden)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/status", nil))
+	if logged.Len() != 0 {
+		t.Errorf("GET logged %q", logged.String())
+	}
+	req := httptest.NewRequest(http.MethodPost, "/frequency?freq=7300000&token=secret", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got := logged.String(); !strings.Contains(got, "API: POST /frequency?freq=7300000 from "+req.RemoteAddr+": 403 Forbidden") || strings.Contains(got, "secret") {
+		t.Errorf("logged %q", got)
+	}
+}
+
+func TestQueryRig(t *testing.T) {
+	status, err := queryRig(&txBackend{fakeBackend: fakeBackend{freq: 14074000, mode: "BPSK31"}, fakeTX: fakeTX{state: "TX"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Band != "20m" || status.Segment == "" || status.Mode != "BPSK31" || status.TX != "TX" {
+		t.Errorf("queryRig() = %+v", status)
+	}
+
+	// Backends without a TX state leave it out.
+	status, _ = queryRig(&fakeBackend{freq: 7074000})
+	if status.Band != "40m" || status.TX != "" {
+		t.Errorf("queryRig() = %+v", status)
+	}
+	if _, err := queryRig(&fakeBackend{err: os.ErrDeadlineExceeded}); err == nil {
+		t.Error("queryRig() succeeded with an unreachable rig")
+	}
+}
+
+func TestAPIHookTest(t *testing.T) {
+	bands := &bandSink{}
+	dispatcher := NewDispatcher(NewMetrics(), bands, &notifySink{channel: &NotifyChannel{Name: "mail", Events: []string{EventVerifyFailed}}})
+	api := &API{dispatcher: dispatcher}
+
+	rec := httptest.NewRecorder()
+	api.handleHookTest(rec, httptest.NewRequest(http.MethodPost, "/api/hooks/test?freq=14.074M&radio=A", nil))
+	var result struct {
+		Event Event    `json:"event"`
+		Sinks []string `json:"sinks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	ev := result.Event
+	if ev.Type != EventBandChange || ev.Band != "20m" || ev.Segment != "20m-FT8" || ev.Freq != 14074000 || ev.Radio != "A" {
+		t.Errorf("event = %+v", ev)
+	}
+	if len(result.Sinks) != 1 || result.Sinks[0] != "bands" {
+		t.Errorf("sinks = %v, want just bands", result.Sinks)
+	}
+	if got := bands.got(); len(got) != 1 || got[0] != "A20m" {
+		t.Errorf("bands sink got %v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleHookTest(rec, httptest.NewRequest(http.MethodGet, "/api/hooks/test", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET = %d", rec.Code)
+	}
+}
+
+func TestRefuseCrossOrigin(t *testing.T) {
+	calls := 0
+	handler := refuseCrossOrigin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls++ }))
+	for _, tc := range []struct {
+		method, origin string
+		ok             bool
+	}{
+		{http.MethodPost, "", true},
+		{http.MethodPost, "http://127.0.0.1:7365", true},
+		{http.MethodPost, "https://evil.example", false},
+		{http.MethodPost, "null", false},
+		{http.MethodDelete, "https://evil.example", false},
+		{http.MethodGet, "https://evil.example", true},
+	} {
+		calls = 0
+		r := httptest.NewRequest(tc.method, "http://127.0.0.1:7365/trx?state=tx", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if ok := calls == 1; ok != tc.ok || (!ok && w.Code != http.StatusForbidden) {
+			t.Errorf("%s from %q: handled %v, status %d", tc.method, tc.origin, ok, w.Code)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// aprsToCall is the destination of fldigi-cmd's packets, from the block
+// APRS sets aside for experimental software.
+const aprsToCall = "APZFDC"
+
+// maxAPRSStatus is the longest status text APRS allows without a timestamp.
+const maxAPRSStatus = 62
+
+// aprsSink sends an APRS-IS status packet with the band and frequency when
+// the band changes, so the operating frequency shows on maps such as
+// aprs.fi.
+type aprsSink struct {
+	server   string
+	call     string
+	passcode string
+	comment  string
+}
+
+func newAPRSSink(server, call, passcode, comment string) (*aprsSink, error) {
+	if passcode == "" {
+		return nil, fmt.Errorf("--aprs-passcode is required with --aprs-call")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return nil, fmt.Errorf("invalid --aprs-server %q, want host:port", server)
+	}
+	return &aprsSink{server: server, call: strings.ToUpper(call), passcode: passcode, comment: comment}, nil
+}
+
+func (s *aprsSink) Name() string { return "aprs" }
+
+func (s *aprsSink) Wants(ev Event) bool {
+	return ev.Type == EventBandChange || ev.Type == EventInitialBand
+}
+
+// packet is the status packet for ev.
+func (s *aprsSink) packet(ev Event) string {
+	status := strings.Join(strings.Fields(expandTemplate(s.comment, ev)), " ")
+	if len(status) > maxAPRSStatus {
+		status = status[:maxAPRSStatus]
+	}
+	return s.call + ">" + aprsToCall + ",TCPIP*:>" + status
+}
+
+// Handle logs in to APRS-IS, sends the packet and disconnects; status
+// changes are too rare to keep a connection open for.
+func (s *aprsSink) Handle(ev Event) error {
+	packet := s.packet(ev)
+	if dryRun {
+		dryRunf("would send to APRS-IS %s: %s", s.server, packet)
+		return nil
+	}
+	conn, err := net.DialTimeout(tcpNetwork(), s.server, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to APRS-IS: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(20 * time.Second))
+
+	fmt.Fprintf(conn, "user %s pass %s vers fldigi-cmd 1.0\r\n", s.call, s.passcode)
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to log in to APRS-IS: %v", err)
+		}
+		// The server greets with a comment line, then answers the login.
+		if strings.HasPrefix(line, "# logresp") {
+			if strings.Contains(line, "unverified") {
+				return fmt.Errorf("APRS-IS didn't accept the passcode for %s", s.call)
+			}
+			break
+		}
+	}
+	if _, err := fmt.Fprintf(conn, "%s\r\n", packet); err != nil {
+		return fmt.Errorf("failed to send APRS packet: %v", err)
+	}
+	fmt.Printf("Sent APRS status: %s\n", packet)
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAPRSPacket(t *testing.T) {
+	s, err := newAPRSSink("127.0.0.1:14580", "n0call-10", "12345", "QRV {band}  {freq_mhz} MHz {mode}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.packet(Event{Type: EventBandChange, Band: "20m", Freq: 14070000, Mode: "USB"})
+	if want := "N0CALL-10>APZFDC,TCPIP*:>QRV 20m 14.070000 MHz USB"; got != want {
+		t.Errorf("packet = %q, want %q", got, want)
+	}
+
+	s.comment = strings.Repeat("x", 100)
+	if status := strings.SplitN(s.packet(Event{}), ":>", 2)[1]; len(status) != maxAPRSStatus {
+		t.Errorf("status of %d characters wasn't truncated to %d", len(status), maxAPRSStatus)
+	}
+}
+
+func TestNewAPRSSinkRequiresPasscode(t *testing.T) {
+	if _, err := newAPRSSink("rotate.aprs2.net:14580", "N0CALL-10", "", "{band}"); err == nil {
+		t.Error("no error without a passcode")
+	}
+	if _, err := newAPRSSink("rotate.aprs2.net", "N0CALL-10", "12345", "{band}"); err == nil {
+		t.Error("no error for a server without a port")
+	}
+}
+
+// fakeAPRSIS accepts one connection, answers the login with logresp and
+// returns the lines it received.
+func fakeAPRSIS(t *testing.T, logresp string) (string, chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("# aprsc 2.1.14\r\n"))
+		reader := bufio.NewReader(conn)
+		login, _ := reader.ReadString('\n')
+		conn.Write([]byte(logresp + "\r\n"))
+		packet, _ := reader.ReadString('\n')
+		got <- []string{login, packet}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestAPRSSend(t *testing.T) {
+	addr, got := fakeAPRSIS(t, "# logresp N0CALL-10 verified, server T2TEST")
+	s, err := newAPRSSink(addr, "N0CALL-10", "12345", "QRV {band}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Handle(Event{Type: EventBandChange, Band: "40m"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := <-got
+	if lines[0] != "user N0CALL-10 pass 12345 vers fldigi-cmd 1.0\r\n" {
+		t.Errorf("login = %q", lines[0])
+	}
+	if lines[1] != "N0CALL-10>APZFDC,TCPIP*:>QRV 40m\r\n" {
+		t.Errorf("packet = %q", lines[1])
+	}
+}
+
+func TestAPRSUnverified(t *testing.T) {
+	addr, _ := fakeAPRSIS(t, "# logresp N0CALL-10 unverified, server T2TEST")
+	s, err := newAPRSSink(addr, "N0CALL-10", "1", "QRV {band}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Handle(Event{Type: EventBandChange, Band: "40m"}); err == nil {
+		t.Error("no error when the passcode wasn't accepted")
+	}
+}
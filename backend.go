@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeouts for talking to rigs, set with --timeout and --dial-timeout.
+// requestTimeout bounds each request or command, including connecting;
+// dialTimeout bounds connecting alone.
+var (
+	requestTimeout = 10 * time.Second
+	dialTimeout    = 30 * time.Second
+)
+
+// ipVersion selects the IP version of XML-RPC and text socket connections
+// with --ip-version: "4" (the default), "6" or "auto" for either.
+var ipVersion = "4"
+
+// tcpNetwork returns the network to dial for ipVersion.
+func tcpNetwork() string {
+	switch ipVersion {
+	case "6":
+		return "tcp6"
+	case "auto":
+		return "tcp"
+	}
+	return "tcp4"
+}
+
+// Backend is a source of rig state the monitor can poll and control.
+type Backend interface {
+	Name() string
+	GetFrequency() (float64, error)
+	GetMode() (string, error)
+	SetFrequency(freq float64) error
+}
+
+// defaultPorts are the usual listening ports of each backend.
+var defaultPorts = map[string]int{
+	"fldigi":  7362,
+	"flrig":   12345,
+	"n1mm":    12060,
+	"rigctld": 4532,
+	"wsjtx":   2237,
+}
+
+// NewBackend creates the named backend. port 0 selects the backend's
+// default port.
+func NewBackend(name, host string, port int) (Backend, error) {
+	if _, ok := defaultPorts[name]; !ok {
+		return nil, fmt.Errorf("unknown backend %q (want fldigi, flrig, rigctld, wsjtx or n1mm)", name)
+	}
+	if port == 0 {
+		port = defaultPorts[name]
+	}
+
+	switch name {
+	case "flrig":
+		return NewFlrigClient(host, port), nil
+	case "rigctld":
+		return NewRigctldClient(host, port), nil
+	case "wsjtx":
+		return NewWSJTXListener(host, port)
+	case "n1mm":
+		return NewN1MMListener(host, port)
+	default:
+		return NewFldigiClient(host, port), nil
+	}
+}
+
+// NewBackendURL creates the named XML-RPC backend for an endpoint URL
+// rather than a host and port; see NewFldigiClientURL.
+func NewBackendURL(name, endpoint string) (Backend, error) {
+	switch name {
+	case "fldigi":
+		return NewFldigiClientURL(endpoint)
+	case "flrig":
+		return NewFlrigClientURL(endpoint)
+	}
+	return nil, fmt.Errorf("backend %s can't be reached by URL (only fldigi and flrig can)", name)
+}
+
+// isURL reports whether an address is an endpoint URL rather than
+// host[:port].
+func isURL(addr string) bool {
+	return strings.Contains(addr, "://")
+}
+
+func (fc *FldigiClient) Name() string { return "fldigi" }
+
+// TXController is implemented by backends that report and control the
+// transmit state.
+type TXController interface {
+	GetTRXState() (string, error)
+	AbortTX() error
+}
+
+// ModeSetter is implemented by backends that can change the mode.
+type ModeSetter interface {
+	SetMode(mode string) error
+}
+
+// TextSender is implemented by backends that can transmit text.
+type TextSender interface {
+	SendText(text string) error
+}
+
+// SplitReader is implemented by backends that report whether the rig is
+// operating split and, if so, the frequency of its TX VFO.
+type SplitReader interface {
+	GetSplit() (split bool, txFreq float64, err error)
+}
+
+// PowerSetter is implemented by backends that can set the rig's output
+// power, as a percentage of its full output.
+type PowerSetter interface {
+	SetPower(percent float64) error
+}
+
+// LevelSetter is implemented by backends that can set rig levels by their
+// Hamlib names, such as "ATT" and "PREAMP" in dB.
+type LevelSetter interface {
+	SetLevel(name string, value float64) error
+}
+
+// PowerReader is implemented by backends that can read back the output
+// power, as a percentage of full output.
+type PowerReader interface {
+	GetPower() (percent float64, err error)
+}
+
+// LevelReader is implemented by backends that can read back rig levels.
+type LevelReader interface {
+	GetLevel(name string) (float64, error)
+}
+
+// CATSender is implemented by backends that can pass a raw CAT command
+// through to the rig.
+type CATSender interface {
+	SendCAT(cmd string) error
+}
+
+// splitHostPort splits "host" or "host:port"; a missing port is returned as
+// 0 so the backend default applies.
+func splitHostPort(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0, nil
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", p)
+	}
+	return host, port, nil
+}
+
+// listenUDP opens a socket for a broadcast-listening backend, joining the
+// group if host is a multicast address so other applications can share the
+// broadcast.
+func listenUDP(app, host string, port int) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s address: %v", app, err)
+	}
+
+	var conn *net.UDPConn
+	if addr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, addr)
+	} else {
+		conn, err = net.ListenUDP("udp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for %s on %s: %v", app, addr, err)
+	}
+	return conn, nil
+}
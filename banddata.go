@@ -0,0 +1,91 @@
+package main
+
+import "fmt"
+
+// yaesuBCD are the band codes Yaesu rigs put on their BCD band data lines.
+var yaesuBCD = map[string]byte{
+	"160m": 1, "80m": 2, "40m": 3, "30m": 4, "20m": 5,
+	"17m": 6, "15m": 7, "12m": 8, "10m": 9, "6m": 10,
+}
+
+// elecraftBands are the band numbers of the Elecraft BN command.
+var elecraftBands = map[string]int{
+	"160m": 0, "80m": 1, "60m": 2, "40m": 3, "30m": 4, "20m": 5,
+	"17m": 6, "15m": 7, "12m": 8, "10m": 9, "6m": 10,
+}
+
+// encodeBandData returns what a band decoder is sent for a band and
+// frequency in the given format:
+//
+//   - bcd: one byte holding the Yaesu BCD band code, 0 outside the HF and
+//     6m bands
+//   - kenwood: the Kenwood/Elecraft VFO A frequency command, e.g.
+//     "FA00014074000;", which amplifiers and decoders watching CAT follow
+//   - elecraft: the Elecraft band number command, e.g. "BN05;", or nothing
+//     outside the bands it numbers
+func encodeBandData(format, band string, freq float64) ([]byte, error) {
+	switch format {
+	case "bcd":
+		return []byte{yaesuBCD[band]}, nil
+	case "kenwood":
+		return []byte(fmt.Sprintf("FA%011.0f;", freq)), nil
+	case "elecraft":
+		n, ok := elecraftBands[band]
+		if !ok {
+			return nil, nil
+		}
+		return []byte(fmt.Sprintf("BN%02d;", n)), nil
+	}
+	return nil, fmt.Errorf("unknown band data format %q (want bcd, kenwood or elecraft)", format)
+}
+
+// BandDataSink writes band changes of one radio to a serial port, so
+// hardware band decoders, amplifiers and antenna switches follow the rig
+// without CAT access of their own.
+type BandDataSink struct {
+	port   *outputPort
+	format string
+	radio  string
+}
+
+// NewBandDataSink opens the serial port, or TCP serial server, at addr for
+// band data about the radio with label.
+func NewBandDataSink(addr string, baud int, format, radio string) (*BandDataSink, error) {
+	if _, err := encodeBandData(format, "", 0); err != nil {
+		return nil, err
+	}
+	s := &BandDataSink{port: newOutputPort(addr, baud), format: format, radio: radio}
+	if dryRun {
+		return s, nil
+	}
+	if err := s.port.Open(); err != nil {
+		return nil, fmt.Errorf("band data: %v", err)
+	}
+	return s, nil
+}
+
+func (s *BandDataSink) Name() string { return "band-data" }
+
+func (s *BandDataSink) Wants(ev Event) bool {
+	switch ev.Type {
+	case EventInitialBand, EventBandChange, EventOutOfBand:
+		return ev.Radio == s.radio
+	}
+	return false
+}
+
+func (s *BandDataSink) Handle(ev Event) error {
+	band := ev.Band
+	if ev.Type == EventOutOfBand {
+		band = "unknown"
+	}
+	data, err := encodeBandData(s.format, band, ev.Freq)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	if dryRun {
+		dryRunf("would write %q to %s", data, s.port.addr)
+		return nil
+	}
+	return s.port.Write(data)
+}
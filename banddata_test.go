@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestEncodeBandData(t *testing.T) {
+	for _, c := range []struct {
+		format, band string
+		freq         float64
+		want         string
+	}{
+		{"bcd", "20m", 14074000, "\x05"},
+		{"bcd", "6m", 50313000, "\x0a"},
+		{"bcd", "2m", 144174000, "\x00"},
+		{"bcd", "unknown", 30500000, "\x00"},
+		{"kenwood", "20m", 14074000, "FA00014074000;"},
+		{"kenwood", "2m", 144174000, "FA00144174000;"},
+		{"elecraft", "60m", 5357000, "BN02;"},
+		{"elecraft", "2m", 144174000, ""},
+	} {
+		got, err := encodeBandData(c.format, c.band, c.freq)
+		if err != nil || string(got) != c.want {
+			t.Errorf("encodeBandData(%s, %s) = %q, %v; want %q", c.format, c.band, got, err, c.want)
+		}
+	}
+	if _, err := NewBandDataSink("/dev/null", 9600, "icom", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+// fakePort is a serial port that fails writes while broken.
+type fakePort struct {
+	bytes.Buffer
+	broken bool
+	closed bool
+}
+
+func (p *fakePort) Write(data []byte) (int, error) {
+	if p.broken {
+		return 0, fmt.Errorf("device not configured")
+	}
+	return p.Buffer.Write(data)
+}
+
+func (p *fakePort) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestBandDataSink(t *testing.T) {
+	port := &fakePort{}
+	opened := 0
+	s := &BandDataSink{port: &outputPort{addr: "/dev/ttyUSB0", open: func() (io.ReadWriteCloser, error) {
+		opened++
+		return port, nil
+	}}, format: "elecraft", radio: "A"}
+
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Radio: "A", Band: "40m"},
+		{Type: EventBandChange, Radio: "B", Band: "20m"},
+		{Type: EventSegmentChange, Radio: "A", Band: "40m", Segment: "40m-CW"},
+		{Type: EventBandChange, Radio: "A", Band: "20m"},
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := port.String(); got != "BN03;BN05;" {
+		t.Errorf("wrote %q, want BN03;BN05;", got)
+	}
+
+	// A failed write closes the port; the next change reopens it.
+	port.broken = true
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "15m"}); err == nil || !port.closed {
+		t.Errorf("failed write: %v, closed %v", err, port.closed)
+	}
+	port.broken = false
+	if err := s.Handle(Event{Type: EventBandChange, Radio: "A", Band: "10m"}); err != nil || opened != 2 {
+		t.Errorf("after reopening: %v, opened %d times", err, opened)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"fldigi-cmd/sdk"
+)
+
+//go:embed bands.txt
+var bandPlanData string
+
+type BandRange = sdk.BandRange
+
+type Segment = sdk.Segment
+
+// planMu guards the band plan, which a region change replaces at runtime.
+var planMu sync.RWMutex
+var plan *sdk.BandPlan
+var bandPlan []BandRange
+var segments []Segment
+
+func init() {
+	loadBandPlan()
+}
+
+func loadBandPlan() {
+	setBandPlan(sdk.ParseBandPlan(bandPlanData))
+}
+
+// loadBandPlanFile replaces the built-in band plan with the one in path,
+// which region changes then adjust in its place.
+func loadBandPlanFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	p := sdk.ParseBandPlan(string(data))
+	if len(p.Bands) == 0 {
+		return fmt.Errorf("no bands in %s", path)
+	}
+	bandPlanData = string(data)
+	setBandPlan(p)
+	return nil
+}
+
+func setBandPlan(p *sdk.BandPlan) {
+	planMu.Lock()
+	defer planMu.Unlock()
+	plan = p
+	bandPlan = plan.Bands
+	segments = plan.Segments
+}
+
+func frequencyToBand(freq float64) string {
+	planMu.RLock()
+	defer planMu.RUnlock()
+	return plan.Band(freq)
+}
+
+// findSegment returns the first segment containing freq.
+func findSegment(freq float64) (Segment, bool) {
+	planMu.RLock()
+	defer planMu.RUnlock()
+	return plan.Segment(freq)
+}
+
+// frequencyToSegment returns the name of the first segment containing freq,
+// or "" if it is outside all segments.
+func frequencyToSegment(freq float64) string {
+	seg, _ := findSegment(freq)
+	return seg.Name
+}
+
+// isBand reports whether name is a band in the band plan.
+func isBand(name string) bool {
+	planMu.RLock()
+	defer planMu.RUnlock()
+	for _, band := range plan.Bands {
+		if band.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentByName returns the segment, or failing that the band, with the
+// given name as a range.
+func segmentByName(name string) (BandRange, bool) {
+	planMu.RLock()
+	defer planMu.RUnlock()
+	for _, seg := range plan.Segments {
+		if strings.EqualFold(seg.Name, name) {
+			return BandRange{Name: seg.Name, StartMHz: seg.StartMHz, EndMHz: seg.EndMHz}, true
+		}
+	}
+	for _, band := range plan.Bands {
+		if strings.EqualFold(band.Name, name) {
+			return band, true
+		}
+	}
+	return BandRange{}, false
+}
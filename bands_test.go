@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFrequencyToSegment(t *testing.T) {
+	testCases := map[float64]string{
+		14025000: "20m-CW",
+		14070000: "20m-PSK",  // windows take precedence over wider segments
+		14074000: "20m-FT8",  // segment start is inclusive
+		14077000: "20m-data", // segment end is exclusive
+		14080500: "20m-FT4",
+		14150000: "20m-phone",
+		7125000:  "40m-phone",
+		1840000:  "160m-FT8",
+		1850000:  "", // no CW/data/phone segments defined on 160m
+		50100000: "",
+	}
+
+	for freq, expected := range testCases {
+		result := frequencyToSegment(freq)
+		if result != expected {
+			t.Errorf("frequencyToSegment(%.0f) = %q; want %q", freq, result, expected)
+		}
+	}
+}
+
+func TestSegmentsBelongToBands(t *testing.T) {
+	for _, seg := range segments {
+		if seg.Band == "unknown" {
+			t.Errorf("Segment %s is outside every band", seg.Name)
+		}
+		if seg.StartMHz >= seg.EndMHz {
+			t.Errorf("Segment %s has invalid frequency range: %.4f >= %.4f", seg.Name, seg.StartMHz, seg.EndMHz)
+		}
+	}
+}
+
+func TestSegmentModeHints(t *testing.T) {
+	seg, ok := findSegment(7074000)
+	if !ok || seg.Mode != "FT8" {
+		t.Errorf("findSegment(7074000) = %+v, %v; want the 40m FT8 window", seg, ok)
+	}
+
+	seg, ok = findSegment(7030000)
+	if !ok || seg.Mode != "" {
+		t.Errorf("findSegment(7030000) = %+v, %v; want a segment without a mode hint", seg, ok)
+	}
+}
+
+func TestSegmentBandwidthLimits(t *testing.T) {
+	testCases := map[float64]float64{
+		14030000: 500,  // 20m-CW
+		14072000: 500,  // 20m-PSK window
+		14100000: 2800, // 20m-data
+		14074000: 0,    // 20m-FT8 window has no limit
+		14200000: 0,    // phone
+	}
+
+	for freq, expected := range testCases {
+		seg, _ := findSegment(freq)
+		if seg.MaxBandwidth != expected {
+			t.Errorf("segment %s at %.0f has max bandwidth %.0f; want %.0f", seg.Name, freq, seg.MaxBandwidth, expected)
+		}
+	}
+}
+
+func TestModeBandwidth(t *testing.T) {
+	if bw, ok := modeBandwidth("bpsk31"); !ok || bw != 62 {
+		t.Errorf("modeBandwidth(bpsk31) = %.0f, %v", bw, ok)
+	}
+	if _, ok := modeBandwidth("NOSUCHMODE"); ok {
+		t.Error("unknown mode found in catalog")
+	}
+}
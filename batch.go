@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// batchStep is a command of a batch script, or a repeat block of them.
+type batchStep struct {
+	line    int
+	command string
+	// repeat is how many times a block runs, 0 for forever; body is the
+	// block's steps.
+	repeat int
+	body   []batchStep
+}
+
+// batch runs a script's commands: those of the REPL, plus waits.
+type batch struct {
+	repl  *repl
+	out   io.Writer
+	sleep func(time.Duration)
+	// rxPoll is how often `wait rx` checks the TX state.
+	rxPoll time.Duration
+}
+
+// runBatch implements `fldigi-cmd run`: run a script of commands against
+// fldigi, for beacons and automated test sequences.
+func runBatch(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var fldigiAddr string
+	fs.StringVar(&fldigiAddr, "fldigi", "127.0.0.1:7362", "fldigi XML-RPC address")
+	fs.BoolVar(&dryRun, "dry-run", false, "print each command instead of running it, and run repeat blocks once")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd run [options] SCRIPT|-\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	in := os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	steps, err := parseBatch(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	host, port, err := splitHostPort(fldigiAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --fldigi %q: %v\n", fldigiAddr, err)
+		return 2
+	}
+	if port == 0 {
+		port = defaultPorts["fldigi"]
+	}
+	fldigi := NewFldigiClient(host, port)
+
+	// Stopping the script mustn't leave fldigi sending what it queued.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if !dryRun {
+			fldigi.AbortTX()
+		}
+		os.Exit(130)
+	}()
+
+	b := &batch{repl: &repl{fldigi: fldigi}, out: os.Stdout, sleep: time.Sleep, rxPoll: 500 * time.Millisecond}
+	if err := b.run(steps); err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseBatch reads a script: one command per line, # comments, and
+// `repeat [N]` ... `end` blocks, which may nest and repeat forever
+// without N.
+func parseBatch(r io.Reader) ([]batchStep, error) {
+	type block struct {
+		step  batchStep
+		steps []batchStep
+	}
+	stack := []*block{{}}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		switch fields[0] {
+		case "repeat":
+			times := 0
+			if len(fields) > 2 {
+				return nil, fmt.Errorf("line %d: want repeat [N]", n)
+			}
+			if len(fields) == 2 {
+				var err error
+				if times, err = strconv.Atoi(fields[1]); err != nil || times < 1 {
+					return nil, fmt.Errorf("line %d: invalid repeat count %q", n, fields[1])
+				}
+			}
+			stack = append(stack, &block{step: batchStep{line: n, repeat: times}})
+		case "end":
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("line %d: end without repeat", n)
+			}
+			top := stack[len(stack)-1]
+			if len(top.steps) == 0 {
+				return nil, fmt.Errorf("line %d: empty repeat block", top.step.line)
+			}
+			stack = stack[:len(stack)-1]
+			top.step.body = top.steps
+			parent := stack[len(stack)-1]
+			parent.steps = append(parent.steps, top.step)
+		default:
+			if err := checkBatchCommand(fields); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			top := stack[len(stack)-1]
+			top.steps = append(top.steps, batchStep{line: n, command: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("line %d: repeat without end", stack[len(stack)-1].step.line)
+	}
+	return stack[0].steps, nil
+}
+
+// checkBatchCommand catches mistakes before anything is transmitted.
+func checkBatchCommand(fields []string) error {
+	if fields[0] == "set" && len(fields) > 1 {
+		fields = fields[1:]
+	}
+	switch fields[0] {
+	case "wait":
+		if len(fields) != 2 {
+			return fmt.Errorf("want wait DURATION or wait rx")
+		}
+		if fields[1] == "rx" {
+			return nil
+		}
+		if _, err := time.ParseDuration(fields[1]); err != nil {
+			return fmt.Errorf("invalid wait %q, want a duration such as 30s or rx", fields[1])
+		}
+		return nil
+	case "freq":
+		if len(fields) > 1 {
+			_, err := parseFrequency(strings.Join(fields[1:], " "))
+			return err
+		}
+	}
+	for _, c := range replCommands {
+		if c.name == fields[0] {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown command %q", fields[0])
+}
+
+// run runs steps in order, stopping at the first that fails; quit stops
+// the script with io.EOF.
+func (b *batch) run(steps []batchStep) error {
+	for _, step := range steps {
+		if step.body != nil {
+			for i := 0; step.repeat == 0 || i < step.repeat; i++ {
+				if err := b.run(step.body); err != nil {
+					return err
+				}
+				if dryRun {
+					break
+				}
+			}
+			continue
+		}
+		if err := b.exec(step.command); err == io.EOF {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("line %d: %s: %v", step.line, step.command, err)
+		}
+	}
+	return nil
+}
+
+// exec runs one command. "set freq" and "set mode" are accepted as well
+// as "freq" and "mode", as the set subcommand takes them.
+func (b *batch) exec(command string) error {
+	command = strings.TrimPrefix(command, "set ")
+	if dryRun {
+		dryRunf("would run: %s", command)
+		return nil
+	}
+	fields := strings.Fields(command)
+	if fields[0] != "wait" {
+		return b.repl.exec(command, b.out)
+	}
+	if fields[1] != "rx" {
+		d, _ := time.ParseDuration(fields[1])
+		b.sleep(d)
+		return nil
+	}
+	// wait rx waits for what was sent to finish transmitting, giving
+	// fldigi a moment to start first.
+	for {
+		b.sleep(b.rxPoll)
+		state, err := b.repl.fldigi.GetTRXState()
+		if err != nil {
+			return err
+		}
+		if state == "RX" {
+			return nil
+		}
+	}
+}
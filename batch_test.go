@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBatch(t *testing.T) {
+	steps, err := parseBatch(strings.NewReader(`# beacon
+set freq 14.0709M
+mode BPSK31
+repeat 3
+  send VVV DE N0CALL BEACON
+  wait rx
+  repeat
+    wait 10m
+  end
+end
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 3 || steps[0].command != "set freq 14.0709M" || steps[0].line != 2 {
+		t.Fatalf("steps = %+v", steps)
+	}
+	block := steps[2]
+	if block.repeat != 3 || len(block.body) != 3 || block.body[2].repeat != 0 || block.body[2].body[0].command != "wait 10m" {
+		t.Errorf("repeat block = %+v", block)
+	}
+
+	for _, script := range []string{
+		"repeat 2\nsend x\n",
+		"end\n",
+		"repeat 0\nsend x\nend\n",
+		"repeat\nend\n",
+		"wait soon\n",
+		"freq 14.07Q\n",
+		"transmit now\n",
+	} {
+		if _, err := parseBatch(strings.NewReader(script)); err == nil {
+			t.Errorf("no error parsing %q", script)
+		}
+	}
+}
+
+func TestBatchRun(t *testing.T) {
+	client, calls := fakeFldigiRPC(t, map[string]string{"main.get_trx_state": "<string>RX</string>"})
+	var slept []time.Duration
+	b := &batch{repl: &repl{fldigi: client}, out: io.Discard, sleep: func(d time.Duration) { slept = append(slept, d) }, rxPoll: time.Second}
+
+	steps, err := parseBatch(strings.NewReader("set freq 7.0709M\nrepeat 2\nsend CQ\nwait rx\nwait 5s\nend\nquit\nsend never\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.run(steps); err != io.EOF {
+		t.Fatalf("run = %v, want io.EOF from quit", err)
+	}
+
+	var methods []string
+	for _, call := range *calls {
+		method := call[strings.Index(call, "<methodName>")+len("<methodName>") : strings.Index(call, "</methodName>")]
+		methods = append(methods, method)
+	}
+	want := "main.set_frequency text.add_tx main.tx main.get_trx_state text.add_tx main.tx main.get_trx_state"
+	if got := strings.Join(methods, " "); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+	if len(slept) != 4 || slept[1] != 5*time.Second {
+		t.Errorf("slept %v", slept)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Calibration corrects a rig's frequency readout, for SDRs and older rigs
+// whose reference oscillator is known to be off: the true frequency is the
+// reading scaled by PPM and then shifted by Hz.
+type Calibration struct {
+	Radio string
+	PPM   float64
+	Hz    float64
+}
+
+// ParseCalibration parses a --calibrate option of the form
+// [LABEL:]CORRECTION, with the correction in Hz, e.g. "-120" or "-120Hz",
+// or in parts per million, e.g. "2.5ppm".
+func ParseCalibration(spec string) (Calibration, error) {
+	var c Calibration
+	value := spec
+	if label, v, ok := strings.Cut(spec, ":"); ok {
+		c.Radio, value = label, v
+	}
+	var err error
+	switch lower := strings.ToLower(value); {
+	case strings.HasSuffix(lower, "ppm"):
+		c.PPM, err = strconv.ParseFloat(strings.TrimSpace(value[:len(value)-3]), 64)
+	default:
+		c.Hz, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(lower, "hz")), 64)
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid calibration %q, want [LABEL:]CORRECTION in Hz or ppm, e.g. -120Hz or 2.5ppm", spec)
+	}
+	return c, nil
+}
+
+// calibrationFor returns the calibration of the radio with label, a
+// labelled one taking precedence over one for every radio.
+func calibrationFor(all []Calibration, label string) (Calibration, bool) {
+	var found Calibration
+	ok := false
+	for _, c := range all {
+		if c.Radio == label || (c.Radio == "" && !ok) {
+			found, ok = c, true
+		}
+	}
+	return found, ok
+}
+
+// True returns the true frequency of a rig reading.
+func (c Calibration) True(freq float64) float64 {
+	return freq*(1+c.PPM/1000000) + c.Hz
+}
+
+// Rig returns the rig frequency, to the nearest Hz, that reads as the true
+// frequency freq.
+func (c Calibration) Rig(freq float64) float64 {
+	return math.Round((freq - c.Hz) / (1 + c.PPM/1000000))
+}
+
+// calibratedBackend corrects the frequencies read from and written to a
+// rig, so bands, segment edges and spots use the true frequency.
+type calibratedBackend struct {
+	Backend
+	calibration Calibration
+}
+
+func (b *calibratedBackend) GetFrequency() (float64, error) {
+	freq, err := b.Backend.GetFrequency()
+	if err != nil {
+		return 0, err
+	}
+	return b.calibration.True(freq), nil
+}
+
+func (b *calibratedBackend) SetFrequency(freq float64) error {
+	return b.Backend.SetFrequency(b.calibration.Rig(freq))
+}
+
+func (b *calibratedBackend) SetMode(mode string) error {
+	m, ok := b.Backend.(ModeSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't change the mode", b.Name())
+	}
+	return m.SetMode(mode)
+}
+
+func (b *calibratedBackend) SendText(text string) error {
+	s, ok := b.Backend.(TextSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't transmit text", b.Name())
+	}
+	return s.SendText(text)
+}
+
+func (b *calibratedBackend) GetSplit() (bool, float64, error) {
+	s, ok := b.Backend.(SplitReader)
+	if !ok {
+		return false, 0, fmt.Errorf("the %s backend does not report split operation", b.Name())
+	}
+	split, freq, err := s.GetSplit()
+	if err != nil || !split {
+		return split, freq, err
+	}
+	return true, b.calibration.True(freq), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParseCalibration(t *testing.T) {
+	for spec, want := range map[string]Calibration{
+		"-120":     {Hz: -120},
+		"-120Hz":   {Hz: -120},
+		"B:2.5ppm": {Radio: "B", PPM: 2.5},
+		"A:+35 Hz": {Radio: "A", Hz: 35},
+		"-0.8 PPM": {PPM: -0.8},
+	} {
+		if got, err := ParseCalibration(spec); err != nil || got != want {
+			t.Errorf("ParseCalibration(%q) = %+v, %v; want %+v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "fast", "B:ppm"} {
+		if _, err := ParseCalibration(spec); err == nil {
+			t.Errorf("ParseCalibration(%q) succeeded", spec)
+		}
+	}
+
+	all := []Calibration{{Hz: -50}, {Radio: "B", PPM: 1}}
+	if c, ok := calibrationFor(all, "B"); !ok || c.PPM != 1 {
+		t.Errorf("calibration for B = %+v, %v", c, ok)
+	}
+	if c, ok := calibrationFor(all, "A"); !ok || c.Hz != -50 {
+		t.Errorf("calibration for A = %+v, %v", c, ok)
+	}
+	if _, ok := calibrationFor(all[1:], "A"); ok {
+		t.Error("found a calibration for an uncalibrated radio")
+	}
+}
+
+func TestCalibratedBackend(t *testing.T) {
+	// The rig reads 200 Hz low at 14 MHz: a band edge reading of 13.9998
+	// MHz is really in 20m.
+	rig := &fakeBackend{freq: 13999900}
+	b := &calibratedBackend{Backend: rig, calibration: Calibration{PPM: 14.29}}
+	freq, err := b.GetFrequency()
+	if err != nil || frequencyToBand(freq) != "20m" {
+		t.Errorf("GetFrequency() = %v, %v; want 20m", freq, err)
+	}
+
+	if err := b.SetFrequency(14074000); err != nil || rig.freq != 14073799 {
+		t.Errorf("SetFrequency(14074000) tuned the rig to %v, %v", rig.freq, err)
+	}
+	if freq, _ := b.GetFrequency(); freq < 14073999 || freq > 14074001 {
+		t.Errorf("read back %v after tuning to 14074000", freq)
+	}
+
+	b.calibration = Calibration{Hz: -120}
+	b.SetFrequency(7074000)
+	if rig.freq != 7074120 {
+		t.Errorf("SetFrequency(7074000) tuned the rig to %v", rig.freq)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Exit codes of `fldigi-cmd check`, following the Nagios plugin convention
+// that Icinga and most station monitoring systems understand.
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+var checkStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// checkRadio grades a radio's status by how long ago the rig last answered
+// and returns its result and a short description.
+func checkRadio(s MonitorStatus, now time.Time, warning, critical time.Duration) (int, string) {
+	prefix := ""
+	if s.Radio != "" {
+		prefix = s.Radio + ": "
+	}
+	if s.Standby {
+		return checkOK, prefix + "standby"
+	}
+	if s.LastPoll.IsZero() {
+		if s.Error != "" {
+			return checkCritical, prefix + "rig not answering: " + s.Error
+		}
+		return checkWarning, prefix + "not polled yet"
+	}
+
+	age := now.Sub(s.LastPoll).Round(time.Second)
+	code := checkOK
+	switch {
+	case age >= critical:
+		code = checkCritical
+	case age >= warning || s.Error != "":
+		code = checkWarning
+	}
+	if s.Error != "" {
+		return code, fmt.Sprintf("%srig not answering for %v: %s", prefix, age, s.Error)
+	}
+	return code, fmt.Sprintf("%s%s %.6f MHz, polled %v ago", prefix, s.Band, s.Freq/1000000, age)
+}
+
+// checkPerfData formats a radio's poll age as Nagios performance data.
+func checkPerfData(s MonitorStatus, now time.Time, warning, critical time.Duration) string {
+	if s.Standby || s.LastPoll.IsZero() {
+		return ""
+	}
+	label := "age"
+	if s.Radio != "" {
+		label += "_" + s.Radio
+	}
+	return fmt.Sprintf("'%s'=%.0fs;%.0f;%.0f", label, now.Sub(s.LastPoll).Seconds(), warning.Seconds(), critical.Seconds())
+}
+
+// runCheck implements `fldigi-cmd check`: a Nagios/Icinga plugin reporting
+// whether the daemon is polling the rig, with one line of status and the
+// plugin exit code.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	connect := addAPIFlags(fs)
+	forceDirect, connectRig, _ := addDirectFlags(fs)
+	var radio string
+	var warning, critical time.Duration
+	fs.StringVar(&radio, "radio", "", "only check the radio with this label")
+	fs.DurationVar(&warning, "warning", 30*time.Second, "warn when the rig last answered this long ago")
+	fs.DurationVar(&critical, "critical", 2*time.Minute, "critical when the rig last answered this long ago")
+	if err := fs.Parse(args); err != nil {
+		return checkUnknown
+	}
+
+	report := func(code int, text string) int {
+		fmt.Printf("FLDIGI-CMD %s - %s\n", checkStates[code], text)
+		return code
+	}
+
+	var status []MonitorStatus
+	if *forceDirect {
+		// Without a daemon there is no poll history, only whether the rig
+		// answers now.
+		s, err := directStatus(connectRig)
+		if err != nil {
+			return report(checkCritical, "rig not answering: "+err.Error())
+		}
+		s.LastPoll = s.Time
+		status = []MonitorStatus{s}
+	} else if err := connect().do(http.MethodGet, "/status", nil, &status); err != nil {
+		var noDaemon *noDaemonError
+		if errors.As(err, &noDaemon) {
+			return report(checkCritical, "daemon not running: "+err.Error())
+		}
+		return report(checkUnknown, err.Error())
+	}
+
+	now := time.Now()
+	worst := checkOK
+	var texts, perf []string
+	for _, s := range status {
+		if radio != "" && s.Radio != radio {
+			continue
+		}
+		code, text := checkRadio(s, now, warning, critical)
+		if code > worst {
+			worst = code
+		}
+		texts = append(texts, text)
+		if p := checkPerfData(s, now, warning, critical); p != "" {
+			perf = append(perf, p)
+		}
+	}
+	if len(texts) == 0 {
+		return report(checkUnknown, fmt.Sprintf("no radio %q", radio))
+	}
+
+	line := strings.Join(texts, "; ")
+	if len(perf) > 0 {
+		line += " | " + strings.Join(perf, " ")
+	}
+	return report(worst, line)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRadio(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		status MonitorStatus
+		code   int
+		text   string
+	}{
+		{MonitorStatus{Band: "20m", Freq: 14074000, LastPoll: now.Add(-2 * time.Second)}, checkOK, "20m 14.074000 MHz, polled 2s ago"},
+		{MonitorStatus{Radio: "B", Band: "40m", Freq: 7074000, LastPoll: now.Add(-45 * time.Second)}, checkWarning, "B: 40m 7.074000 MHz, polled 45s ago"},
+		{MonitorStatus{Error: "connection refused", LastPoll: now.Add(-5 * time.Second)}, checkWarning, "rig not answering for 5s: connection refused"},
+		{MonitorStatus{Error: "connection refused", LastPoll: now.Add(-3 * time.Minute)}, checkCritical, "rig not answering for 3m0s"},
+		{MonitorStatus{Error: "connection refused"}, checkCritical, "rig not answering: connection refused"},
+		{MonitorStatus{}, checkWarning, "not polled yet"},
+		{MonitorStatus{Radio: "A", Standby: true}, checkOK, "A: standby"},
+	} {
+		code, text := checkRadio(tc.status, now, 30*time.Second, 2*time.Minute)
+		if code != tc.code || !strings.Contains(text, tc.text) {
+			t.Errorf("checkRadio(%+v) = %s %q, want %s %q", tc.status, checkStates[code], text, checkStates[tc.code], tc.text)
+		}
+	}
+
+	perf := checkPerfData(MonitorStatus{Radio: "A", LastPoll: now.Add(-2 * time.Second)}, now, 30*time.Second, 2*time.Minute)
+	if perf != "'age_A'=2s;30;120" {
+		t.Errorf("checkPerfData() = %q", perf)
+	}
+}
+
+func TestMonitorStatusKeepsLastPoll(t *testing.T) {
+	m := &Monitor{}
+	m.setStatus(14074000, nil)
+	polled := m.Status().LastPoll
+	if polled.IsZero() {
+		t.Fatal("successful poll not recorded")
+	}
+	m.setStatus(0, errors.New("connection refused"))
+	if s := m.Status(); s.LastPoll != polled || s.Error == "" {
+		t.Errorf("status after a failed poll = %+v, want the last poll kept", s)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// civFrame returns the CI-V transceive frame in which a rig at address
+// from announces its frequency to every listener: FE FE 00 from 00, the
+// frequency in Hz as five BCD bytes least significant first, then FD.
+func civFrame(from byte, freq float64) []byte {
+	frame := []byte{0xfe, 0xfe, 0x00, from, 0x00}
+	n := uint64(math.Round(freq))
+	for i := 0; i < 5; i++ {
+		frame = append(frame, byte(n%10)|byte(n/10%10)<<4)
+		n /= 100
+	}
+	return append(frame, 0xfd)
+}
+
+// parseCIVAddress parses a CI-V address in hex, e.g. "94" or "0x94".
+func parseCIVAddress(s string) (byte, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 8)
+	if err != nil || n == 0 || n >= 0xe0 {
+		return 0, fmt.Errorf("invalid CI-V address %q, want a hex address such as 94", s)
+	}
+	return byte(n), nil
+}
+
+// CIVOutput rebroadcasts the rig's frequency as CI-V transceive frames, so
+// amplifiers and tuners that listen for an Icom rig on the CI-V bus track a
+// station driven through fldigi.
+type CIVOutput struct {
+	port    *outputPort
+	address byte
+	last    float64
+}
+
+// NewCIVOutput opens the serial port, or TCP serial server, at addr for
+// frames sent as the rig at address.
+func NewCIVOutput(addr string, baud int, address byte) (*CIVOutput, error) {
+	c := &CIVOutput{port: newOutputPort(addr, baud), address: address}
+	if dryRun {
+		return c, nil
+	}
+	if err := c.port.Open(); err != nil {
+		return nil, fmt.Errorf("CI-V: %v", err)
+	}
+	return c, nil
+}
+
+// Send broadcasts the frequency when it has moved since the last frame;
+// after a failure it is sent again on the next poll.
+func (c *CIVOutput) Send(freq float64) {
+	if math.Abs(freq-c.last) < 1 {
+		return
+	}
+	frame := civFrame(c.address, freq)
+	if dryRun {
+		dryRunf("would send CI-V frame % X to %s", frame, c.port.addr)
+		c.last = freq
+		return
+	}
+	if err := c.port.Write(frame); err != nil {
+		log.Printf("Error rebroadcasting CI-V frequency: %v", err)
+		c.last = 0
+		return
+	}
+	c.last = freq
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCIVFrame(t *testing.T) {
+	want := []byte{0xfe, 0xfe, 0x00, 0x94, 0x00, 0x00, 0x40, 0x07, 0x14, 0x00, 0xfd}
+	if got := civFrame(0x94, 14074000); !bytes.Equal(got, want) {
+		t.Errorf("civFrame(14.074 MHz) = % X, want % X", got, want)
+	}
+	if got := civFrame(0x94, 1296123456); !bytes.Equal(got[5:10], []byte{0x56, 0x34, 0x12, 0x96, 0x12}) {
+		t.Errorf("civFrame(1296.123456 MHz) = % X", got)
+	}
+
+	if a, err := parseCIVAddress("0x94"); err != nil || a != 0x94 {
+		t.Errorf("parseCIVAddress(0x94) = %X, %v", a, err)
+	}
+	for _, s := range []string{"", "zz", "100", "00", "E0"} {
+		if _, err := parseCIVAddress(s); err == nil {
+			t.Errorf("parseCIVAddress(%q) succeeded", s)
+		}
+	}
+}
+
+func TestCIVOutput(t *testing.T) {
+	port := &fakePort{}
+	c := &CIVOutput{port: &outputPort{addr: "/dev/ttyUSB0", open: func() (io.ReadWriteCloser, error) { return port, nil }}, address: 0x94}
+
+	c.Send(14074000)
+	c.Send(14074000) // unchanged
+	c.Send(14074500)
+	if got := port.Len(); got != 22 {
+		t.Errorf("wrote %d bytes, want two frames", got)
+	}
+
+	port.broken = true
+	c.Send(7074000)
+	port.broken = false
+	c.Send(7074000) // resent after the failure
+	if got := port.Len(); got != 33 {
+		t.Errorf("wrote %d bytes, want a third frame", got)
+	}
+}
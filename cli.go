@@ -0,0 +1,587 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAPIAddr is where the daemon serves the local API over TCP.
+const defaultAPIAddr = "127.0.0.1:7365"
+
+// subcommands talk to a running daemon over its local API.
+var subcommands = map[string]func(args []string) int{
+	"tail":           runTail,
+	"get":            runGet,
+	"set":            runSet,
+	"rules":          runRules,
+	"region":         runRegion,
+	"bundle":         runBundle,
+	"occupancy":      runOccupancy,
+	"exchange":       runExchange,
+	"push":           runPush,
+	"status":         runStatus,
+	"support-bundle": runSupportBundle,
+	"check":          runCheck,
+	"history":        runHistory,
+	"stats":          runStats,
+	"sessions":       runSessions,
+	"spot":           runSpot,
+	"tui":            runTUI,
+	"repl":           runREPL,
+	"run":            runBatch,
+	"goto":           runGoto,
+	"scan":           runScan,
+	"tx":             runTRX("tx"),
+	"rx":             runTRX("rx"),
+	"tune":           runTRX("tune"),
+	"abort":          runTRX("abort"),
+}
+
+// apiClient connects to the daemon's local API, preferring the Unix-domain
+// socket when it exists.
+type apiClient struct {
+	http *http.Client
+	base string
+	addr string
+}
+
+// addAPIFlags registers the flags that choose how to reach the daemon and
+// returns a function creating the client once they are parsed.
+func addAPIFlags(fs *flag.FlagSet) func() *apiClient {
+	var addr, socket, token string
+	fs.StringVar(&addr, "api-addr", defaultAPIAddr, "TCP address of the daemon's local API")
+	fs.StringVar(&socket, "api-socket", defaultAPISocket(), "Unix-domain socket of the daemon's local API")
+	fs.StringVar(&token, "api-token", os.Getenv("FLDIGI_API_TOKEN"), "token for a daemon started with --api-token")
+
+	return func() *apiClient {
+		if _, err := os.Stat(socket); socket != "" && err == nil {
+			transport := &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			}
+			return &apiClient{http: &http.Client{Transport: transport}, base: "http://fldigi-cmd", addr: socket}
+		}
+		client := http.DefaultClient
+		if token != "" {
+			client = &http.Client{Transport: bearerTransport{token: token}}
+		}
+		return &apiClient{http: client, base: "http://" + addr, addr: addr}
+	}
+}
+
+// bearerTransport sends the token a daemon started with --api-token
+// requires on its TCP address.
+type bearerTransport struct {
+	token string
+}
+
+func (t bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// noDaemonError reports that no daemon answered, as opposed to the daemon
+// rejecting a request.
+type noDaemonError struct {
+	addr string
+	err  error
+}
+
+func (e *noDaemonError) Error() string {
+	return fmt.Sprintf("failed to reach the daemon at %s: %v", e.addr, e.err)
+}
+
+// directOnlyDaemonOptions are daemon options for reaching the rig that
+// talking to it directly doesn't support. Set in the environment the daemon
+// shares, they would otherwise be ignored and the wrong rig reached.
+var directOnlyDaemonOptions = []string{"url", "tls-ca", "tls-cert", "tls-key", "ip-version"}
+
+// addDirectFlags registers the flags for reaching the rig directly when no
+// daemon is running. It returns a function that creates the backend and one
+// that refuses a frequency outside the --license or --allowed-segments
+// privileges, which the daemon would otherwise have checked.
+func addDirectFlags(fs *flag.FlagSet) (direct *bool, connect func() (Backend, error), allowed func(freq float64) error) {
+	var backendName, host, license, allowedSegments string
+	var port int
+	direct = fs.Bool("direct", false, "talk to the rig directly even if a daemon is running")
+	fs.StringVar(&backendName, "backend", "fldigi", "rig backend to use without a daemon: fldigi, flrig or rigctld")
+	fs.StringVar(&host, "host", "127.0.0.1", "backend host to use without a daemon")
+	fs.IntVar(&port, "port", 0, "backend port to use without a daemon (0 for the backend default)")
+	fs.StringVar(&license, "license", os.Getenv("FLDIGI_LICENSE"), "US license class whose privileges to check without a daemon (default $FLDIGI_LICENSE)")
+	fs.StringVar(&allowedSegments, "allowed-segments", os.Getenv("FLDIGI_ALLOWED_SEGMENTS"), "allowed segments file whose privileges to check without a daemon (default $FLDIGI_ALLOWED_SEGMENTS)")
+
+	connect = func() (Backend, error) {
+		for _, name := range directOnlyDaemonOptions {
+			v := os.Getenv(envName(name))
+			if v == "" || name == "ip-version" && v == "4" {
+				continue
+			}
+			return nil, fmt.Errorf("%s is set, but --%s isn't supported without a daemon; start the daemon or unset it and give --host and --port", envName(name), name)
+		}
+		return NewBackend(backendName, host, port)
+	}
+	allowed = func(freq float64) error {
+		var privileges *Privileges
+		var err error
+		switch {
+		case license != "" && allowedSegments != "":
+			return fmt.Errorf("--license and --allowed-segments are mutually exclusive")
+		case license != "":
+			privileges, err = LoadLicenseProfile(license)
+		case allowedSegments != "":
+			privileges, err = LoadAllowedSegments(allowedSegments)
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !privileges.Allows(freq) {
+			return fmt.Errorf("%.6f MHz is outside %s privileges", freq/1000000, privileges.Name)
+		}
+		return nil
+	}
+	return direct, connect, allowed
+}
+
+// viaDaemon runs daemon, falling back to direct when no daemon answers (or
+// --direct is set) so commands also work without one.
+func viaDaemon(forceDirect bool, daemon func() error, direct func() error) error {
+	if !forceDirect {
+		err := daemon()
+		var noDaemon *noDaemonError
+		if !errors.As(err, &noDaemon) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "No daemon found, talking to the rig directly\n")
+	}
+	return direct()
+}
+
+// do sends a request to the daemon and decodes its JSON response into v.
+func (c *apiClient) do(method, path string, query url.Values, v interface{}) error {
+	return c.doBody(method, path, query, nil, v)
+}
+
+// doBody is do with a request body.
+func (c *apiClient) doBody(method, path string, query url.Values, body io.Reader, v interface{}) error {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// Only a refused connection or a missing socket means no daemon
+		// runs; a daemon that times out or drops the connection may still
+		// have made the change, so it isn't repeated directly.
+		if errors.Is(err, errConnRefused) || errors.Is(err, os.ErrNotExist) {
+			return &noDaemonError{addr: c.addr, err: err}
+		}
+		return fmt.Errorf("failed to reach the daemon at %s: %v", c.addr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("daemon returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from daemon: %v", err)
+	}
+	return nil
+}
+
+// runGet implements `fldigi-cmd get [freq|band|segment|mode]`: print the
+// daemon's view of each radio, or a single field of it. The receiver
+// settings, such as `get afc`, are read from fldigi.
+func runGet(args []string) int {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	forceDirect, connectRig, _ := addDirectFlags(fs)
+	var radio string
+	fs.StringVar(&radio, "radio", "", "only show the radio with this label")
+	fs.Parse(args)
+
+	field := fs.Arg(0)
+	switch {
+	case field == "", field == "freq", field == "band", field == "segment", field == "mode":
+	case field == "receiver" || isReceiverSetting(field):
+		return getReceiver(connect, *forceDirect, connectRig, radio, field)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown field %q (want freq, band, segment, mode, receiver or a receiver setting: %s)\n", field, strings.Join(receiverSettingNames, ", "))
+		return 2
+	}
+
+	var status []MonitorStatus
+	err := viaDaemon(*forceDirect, func() error {
+		return connect().do(http.MethodGet, "/status", nil, &status)
+	}, func() error {
+		s, err := directStatus(connectRig)
+		status = []MonitorStatus{s}
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	for _, s := range status {
+		if radio != "" && s.Radio != radio {
+			continue
+		}
+		fmt.Println(formatStatus(s, field))
+	}
+	return 0
+}
+
+// getReceiver prints one receiver setting, or with field "receiver" all of
+// them.
+func getReceiver(connect func() *apiClient, forceDirect bool, connectRig func() (Backend, error), radio, field string) int {
+	query := url.Values{}
+	if radio != "" {
+		query.Set("radio", radio)
+	}
+	var settings ReceiverSettings
+	err := viaDaemon(forceDirect, func() error {
+		return connect().do(http.MethodGet, "/receiver", query, &settings)
+	}, func() error {
+		rig, err := directReceiver(connectRig)
+		if err == nil {
+			settings, err = rig.GetReceiver()
+		}
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if field == "receiver" {
+		fmt.Println(settings)
+	} else {
+		fmt.Println(settings.Get(field))
+	}
+	return 0
+}
+
+// directReceiver connects to the rig itself for its receiver settings.
+func directReceiver(connectRig func() (Backend, error)) (receiverRig, error) {
+	b, err := connectRig()
+	if err != nil {
+		return nil, err
+	}
+	rig, ok := b.(receiverRig)
+	if !ok {
+		return nil, fmt.Errorf("the %s backend has no receiver settings", b.Name())
+	}
+	return rig, nil
+}
+
+// directStatus reads a status from the rig itself.
+func directStatus(connectRig func() (Backend, error)) (MonitorStatus, error) {
+	rig, err := connectRig()
+	if err != nil {
+		return MonitorStatus{}, err
+	}
+	freq, err := rig.GetFrequency()
+	if err != nil {
+		return MonitorStatus{}, err
+	}
+
+	status := MonitorStatus{Time: time.Now(), Freq: freq, Band: frequencyToBand(freq)}
+	if seg, ok := findSegment(freq); ok {
+		status.Segment = seg.Name
+	}
+	if mode, err := rig.GetMode(); err == nil {
+		status.Mode = mode
+	}
+	return status, nil
+}
+
+// formatStatus renders one field of a status, or a summary line such as
+// "20m 14.074000 MHz 20m-FT8 BPSK31" prefixed by the radio label.
+func formatStatus(s MonitorStatus, field string) string {
+	freq := strconv.FormatFloat(s.Freq, 'f', 0, 64)
+	switch field {
+	case "freq":
+		return freq
+	case "band":
+		return s.Band
+	case "segment":
+		return s.Segment
+	case "mode":
+		return s.Mode
+	}
+
+	var parts []string
+	if s.Radio != "" {
+		parts = append(parts, s.Radio+":")
+	}
+	switch {
+	case s.Error != "":
+		parts = append(parts, "error: "+s.Error)
+	case s.Standby:
+		parts = append(parts, "standby", s.Band)
+	default:
+		parts = append(parts, s.Band, fmt.Sprintf("%.6f MHz", s.Freq/1000000), s.Segment, s.Mode)
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// Exit codes of `fldigi-cmd status`, for scripts and monitoring checks.
+const (
+	statusOK          = 0
+	statusUnreachable = 1
+	statusOutOfBand   = 3
+)
+
+// rigStatus is what `fldigi-cmd status` reports about the rig.
+type rigStatus struct {
+	Freq    float64 `json:"freq,omitempty"`
+	Band    string  `json:"band,omitempty"`
+	Segment string  `json:"segment,omitempty"`
+	Mode    string  `json:"mode,omitempty"`
+	// TX is "RX", "TX" or "TUNE" on backends that report it.
+	TX    string `json:"tx,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// queryRig reads the rig's state once.
+func queryRig(rig Backend) (rigStatus, error) {
+	freq, err := rig.GetFrequency()
+	if err != nil {
+		return rigStatus{}, err
+	}
+	status := rigStatus{Freq: freq, Band: frequencyToBand(freq)}
+	if seg, ok := findSegment(freq); ok {
+		status.Segment = seg.Name
+	}
+	if mode, err := rig.GetMode(); err == nil {
+		status.Mode = mode
+	}
+	if tx, ok := rig.(TXController); ok {
+		if state, err := tx.GetTRXState(); err == nil {
+			status.TX = state
+		}
+	}
+	return status, nil
+}
+
+// runStatus implements `fldigi-cmd status [--format text|json]`: query the
+// rig directly, print its state and exit 0, 1 if it didn't answer or 3 if
+// it is tuned outside every band.
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	var backendName, host, format string
+	var port int
+	var timeout time.Duration
+	fs.StringVar(&backendName, "backend", "fldigi", "rig backend: fldigi, flrig or rigctld")
+	fs.StringVar(&host, "host", "127.0.0.1", "backend host")
+	fs.IntVar(&port, "port", 0, "backend port (0 for the backend default)")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.DurationVar(&timeout, "timeout", 5*time.Second, "time to wait for the rig to answer")
+	fs.Parse(args)
+
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want text or json)\n", format)
+		return 2
+	}
+	requestTimeout = timeout
+
+	code := statusOK
+	rig, err := NewBackend(backendName, host, port)
+	var status rigStatus
+	if err == nil {
+		status, err = queryRig(rig)
+	}
+	switch {
+	case err != nil:
+		status.Error, code = err.Error(), statusUnreachable
+	case status.Band == "unknown":
+		code = statusOutOfBand
+	}
+
+	if format == "json" {
+		data, _ := json.Marshal(status)
+		fmt.Println(string(data))
+		return code
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return code
+	}
+	fmt.Println(strings.Join(strings.Fields(fmt.Sprintf("%s %.6f MHz %s %s %s", status.Band, status.Freq/1000000, status.Segment, status.Mode, status.TX)), " "))
+	return code
+}
+
+// runSet implements `fldigi-cmd set freq <frequency>`: tune the rig through
+// the daemon's connection. `set afc on` and the like change a receiver
+// setting.
+func runSet(args []string) int {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	forceDirect, connectRig, allowed := addDirectFlags(fs)
+	var radio string
+	fs.StringVar(&radio, "radio", "", "label of the radio to tune")
+	fs.Parse(args)
+
+	if fs.NArg() == 2 && isReceiverSetting(fs.Arg(0)) {
+		return setReceiver(connect, *forceDirect, connectRig, radio, fs.Arg(0), fs.Arg(1))
+	}
+	if fs.NArg() != 2 || fs.Arg(0) != "freq" {
+		fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd set [--radio label] freq <frequency>\n")
+		fmt.Fprintf(os.Stderr, "       fldigi-cmd set [--radio label] squelch|afc|rsid|txid on|off\n")
+		fmt.Fprintf(os.Stderr, "       fldigi-cmd set [--radio label] squelch-level <0-100>\n")
+		return 2
+	}
+	freq, err := parseFrequency(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	query := url.Values{"freq": {strconv.FormatFloat(freq, 'f', -1, 64)}}
+	if radio != "" {
+		query.Set("radio", radio)
+	}
+	var result map[string]float64
+	err = viaDaemon(*forceDirect, func() error {
+		return connect().do(http.MethodPost, "/frequency", query, &result)
+	}, func() error {
+		if err := allowed(freq); err != nil {
+			return err
+		}
+		rig, err := connectRig()
+		if err != nil {
+			return err
+		}
+		return rig.SetFrequency(freq)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// setReceiver changes one receiver setting.
+func setReceiver(connect func() *apiClient, forceDirect bool, connectRig func() (Backend, error), radio, name, value string) int {
+	settings, err := ParseReceiverSettings(name + "=" + value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	query := url.Values{name: {value}}
+	if radio != "" {
+		query.Set("radio", radio)
+	}
+	var result ReceiverSettings
+	err = viaDaemon(forceDirect, func() error {
+		return connect().do(http.MethodPost, "/receiver", query, &result)
+	}, func() error {
+		rig, err := directReceiver(connectRig)
+		if err == nil {
+			err = rig.SetReceiver(settings)
+		}
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseFrequency parses a frequency in Hz, or in kHz or MHz with a k or M
+// suffix, e.g. "14074000", "14074k" or "14.074M".
+func parseFrequency(s string) (float64, error) {
+	mult := 1.0
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(s, "Hz"), "hz")
+	switch {
+	case strings.HasSuffix(trimmed, "M"):
+		mult, trimmed = 1000000, strings.TrimSuffix(trimmed, "M")
+	case strings.HasSuffix(trimmed, "k"):
+		mult, trimmed = 1000, strings.TrimSuffix(trimmed, "k")
+	}
+
+	v, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid frequency %q", s)
+	}
+	return v * mult, nil
+}
+
+// runRules implements `fldigi-cmd rules`: list each rule and whether its
+// conditions currently match.
+func runRules(args []string) int {
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	fs.Parse(args)
+
+	var rules map[string][]RuleStatus
+	if err := connect().do(http.MethodGet, "/rules", nil, &rules); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	for _, radio := range sortedKeys(rules) {
+		for _, r := range rules[radio] {
+			state := "inactive"
+			if r.Active {
+				state = "active"
+			}
+			if radio != "" {
+				fmt.Printf("%s: ", radio)
+			}
+			fmt.Printf("%s %s\n", r.Name, state)
+		}
+	}
+	return 0
+}
+
+// runRegion implements `fldigi-cmd region [accept]`: show the daemon's ITU
+// region, or accept the change it detected.
+func runRegion(args []string) int {
+	fs := flag.NewFlagSet("region", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	fs.Parse(args)
+
+	method, query := http.MethodGet, url.Values(nil)
+	switch fs.Arg(0) {
+	case "":
+	case "accept":
+		method, query = http.MethodPost, url.Values{"accept": {"1"}}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: usage: fldigi-cmd region [accept]\n")
+		return 2
+	}
+
+	var status RegionStatus
+	if err := connect().do(method, "/region", query, &status); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("ITU region %s\n", status.Active)
+	if status.Pending != "" {
+		fmt.Printf("Region %s detected; run \"fldigi-cmd region accept\" to switch\n", status.Pending)
+	}
+	return 0
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FldigiClient struct {
+	url    string
+	client *http.Client
+	cache  *RPCCache
+
+	// user and password, if set, are sent with every request using HTTP
+	// basic auth, for servers behind an authenticating reverse proxy.
+	user     string
+	password string
+}
+
+type MethodCall struct {
+	XMLName xml.Name `xml:"methodCall"`
+	Method  string   `xml:"methodName"`
+	Params  *Params  `xml:"params,omitempty"`
+}
+
+type Params struct {
+	Params []Param `xml:"param"`
+}
+
+type Param struct {
+	Value Value `xml:"value"`
+}
+
+type Value struct {
+	String  string       `xml:"string,omitempty"`
+	Double  string       `xml:"double,omitempty"`
+	Int     string       `xml:"i4,omitempty"`
+	Integer string       `xml:"int,omitempty"`
+	Boolean string       `xml:"boolean,omitempty"`
+	Base64  string       `xml:"base64,omitempty"`
+	Array   *ArrayValue  `xml:"array,omitempty"`
+	Struct  *StructValue `xml:"struct,omitempty"`
+	Content string       `xml:",chardata"`
+}
+
+type ArrayValue struct {
+	Values []Value `xml:"data>value"`
+}
+
+type StructValue struct {
+	Members []Member `xml:"member"`
+}
+
+// Text returns the scalar content of the value regardless of its XML-RPC type.
+func (v Value) Text() string {
+	for _, s := range []string{v.String, v.Double, v.Int, v.Integer, v.Boolean} {
+		if s != "" {
+			return s
+		}
+	}
+	if v.Base64 != "" {
+		data, err := base64.StdEncoding.DecodeString(v.Base64)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return strings.TrimSpace(v.Content)
+}
+
+type MethodResponse struct {
+	XMLName xml.Name `xml:"methodResponse"`
+	Params  *Params  `xml:"params,omitempty"`
+	Fault   *Fault   `xml:"fault,omitempty"`
+}
+
+type Fault struct {
+	Value struct {
+		Struct []Member `xml:"struct>member"`
+	} `xml:"value"`
+}
+
+type Member struct {
+	Name  string `xml:"name"`
+	Value Value  `xml:"value"`
+}
+
+func NewFldigiClient(host string, port int) *FldigiClient {
+	url := "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/RPC2"
+
+	return newFldigiClient(url, dialTCP)
+}
+
+// dialTCP dials over the IP version chosen with --ip-version, IPv4 by
+// default.
+func dialTCP(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+	if network == "tcp" {
+		network = tcpNetwork()
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// NewFldigiClientURL creates a client for an XML-RPC endpoint given as a
+// URL, such as a port forwarded over SSH or a reverse proxy. http and https
+// URLs may carry user:password@ for basic auth and default to the /RPC2
+// path; unix:///path/to.sock reaches a server or proxy on a Unix-domain
+// socket.
+func NewFldigiClientURL(endpoint string) (*FldigiClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint URL: %v", err)
+	}
+
+	dial := dialTCP
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint URL %q: no host", endpoint)
+		}
+	case "unix":
+		socket := u.Path
+		if socket == "" {
+			return nil, fmt.Errorf("invalid endpoint URL %q: no socket path", endpoint)
+		}
+		dial = func(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", socket)
+		}
+		u.Scheme, u.Host, u.Path = "http", "unix", ""
+	default:
+		return nil, fmt.Errorf("invalid endpoint URL %q: scheme must be http, https or unix", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/RPC2"
+	}
+
+	fc := newFldigiClient("", dial)
+	if u.User != nil {
+		fc.user = u.User.Username()
+		fc.password, _ = u.User.Password()
+		u.User = nil
+	}
+	fc.url = u.String()
+	return fc, nil
+}
+
+func newFldigiClient(url string, dial func(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error)) *FldigiClient {
+	transport := &http.Transport{TLSClientConfig: rpcTLS}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := &net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		return dial(ctx, d, network, addr)
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   requestTimeout,
+	}
+
+	return &FldigiClient{
+		url:    url,
+		client: client,
+	}
+}
+
+// post sends a method call to fldigi and returns the raw response body.
+func (fc *FldigiClient) post(call MethodCall) ([]byte, error) {
+	xmlData, err := xml.Marshal(call)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal XML: %v", err)
+	}
+	start := time.Now()
+	body, err := fc.exchange(call.Method, string(xmlData), xmlData)
+	rpcTrace.record(fc.url, string(xmlData), string(body), time.Since(start), err)
+	return body, err
+}
+
+// exchange posts a request body to fldigi, serving read-only methods from
+// the cache when one is configured. key identifies the call for caching.
+func (fc *FldigiClient) exchange(method, key string, xmlData []byte) ([]byte, error) {
+	if fc.cache != nil && isCacheable(method) {
+		if body, ok := fc.cache.Get(key); ok {
+			return body, nil
+		}
+	}
+
+	// Each request carries its own deadline, so a poll never waits on fldigi
+	// for longer than the client timeout.
+	ctx := context.Background()
+	if fc.client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fc.client.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fc.url, bytes.NewBuffer(xmlData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if fc.user != "" {
+		req.SetBasicAuth(fc.user, fc.password)
+	}
+	resp, err := fc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if fc.cache != nil {
+		if !isReadOnly(method) {
+			// Anything may have changed on the fldigi side.
+			fc.cache.Clear()
+		} else if isCacheable(method) && !bytes.Contains(body, []byte("<fault>")) {
+			fc.cache.Put(key, body)
+		}
+	}
+	return body, nil
+}
+
+// Call invokes an XML-RPC method and returns its first result value.
+func (fc *FldigiClient) Call(method string, params ...Value) (Value, error) {
+	call := MethodCall{Method: method}
+	if len(params) > 0 {
+		call.Params = &Params{}
+		for _, p := range params {
+			call.Params.Params = append(call.Params.Params, Param{Value: p})
+		}
+	}
+
+	body, err := fc.post(call)
+	if err != nil {
+		return Value{}, err
+	}
+
+	var response MethodResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return Value{}, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if response.Fault != nil {
+		return Value{}, fmt.Errorf("XML-RPC fault occurred. Response: %s", string(body))
+	}
+
+	if response.Params == nil || len(response.Params.Params) == 0 {
+		return Value{}, fmt.Errorf("no data in %s response", method)
+	}
+
+	return response.Params.Params[0].Value, nil
+}
+
+func (fc *FldigiClient) ListMethods() error {
+	body, err := fc.post(MethodCall{Method: "system.listMethods"})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Available methods:\n%s\n", string(body))
+	return nil
+}
+
+func (fc *FldigiClient) GetFrequency() (float64, error) {
+	value, err := fc.Call("rig.get_vfo")
+	if err != nil {
+		return 0, err
+	}
+
+	freqStr := value.Text()
+	if freqStr == "" {
+		return 0, fmt.Errorf("empty frequency response")
+	}
+
+	freq, err := strconv.ParseFloat(freqStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse frequency '%s': %v", freqStr, err)
+	}
+	return freq, nil
+}
+
+// GetRXData returns receive text decoded since the previous call.
+func (fc *FldigiClient) GetRXData() (string, error) {
+	value, err := fc.Call("rx.get_data")
+	if err != nil {
+		return "", err
+	}
+	return value.Text(), nil
+}
+
+// GetCarrier returns the modem's audio carrier frequency in Hz.
+func (fc *FldigiClient) GetCarrier() (float64, error) {
+	value, err := fc.Call("modem.get_carrier")
+	if err != nil {
+		return 0, err
+	}
+
+	carrier, err := strconv.ParseFloat(value.Text(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse carrier '%s': %v", value.Text(), err)
+	}
+	return carrier, nil
+}
+
+// SetCarrier moves the modem's audio carrier to freq Hz.
+func (fc *FldigiClient) SetCarrier(freq float64) error {
+	_, err := fc.Call("modem.set_carrier", Value{Int: strconv.Itoa(int(math.Round(freq)))})
+	return err
+}
+
+// GetQuality returns the modem's signal quality, 0 to 100.
+func (fc *FldigiClient) GetQuality() (float64, error) {
+	value, err := fc.Call("modem.get_quality")
+	if err != nil {
+		return 0, err
+	}
+
+	quality, err := strconv.ParseFloat(value.Text(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse quality '%s': %v", value.Text(), err)
+	}
+	return quality, nil
+}
+
+// GetSquelchLevel returns the signal quality at which fldigi's squelch
+// opens, 0 to 100.
+func (fc *FldigiClient) GetSquelchLevel() (float64, error) {
+	value, err := fc.Call("main.get_squelch_level")
+	if err != nil {
+		return 0, err
+	}
+
+	level, err := strconv.ParseFloat(value.Text(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse squelch level '%s': %v", value.Text(), err)
+	}
+	return level, nil
+}
+
+// getBool calls a method returning one of fldigi's on/off settings.
+func (fc *FldigiClient) getBool(method string) (*bool, error) {
+	value, err := fc.Call(method)
+	if err != nil {
+		return nil, err
+	}
+	on, err := strconv.ParseBool(value.Text())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s result '%s': %v", method, value.Text(), err)
+	}
+	return &on, nil
+}
+
+// GetReceiver returns fldigi's squelch, AFC, RxID and TxID settings.
+func (fc *FldigiClient) GetReceiver() (ReceiverSettings, error) {
+	var s ReceiverSettings
+	var err error
+	if s.Squelch, err = fc.getBool("main.get_squelch"); err != nil {
+		return s, err
+	}
+	level, err := fc.GetSquelchLevel()
+	if err != nil {
+		return s, err
+	}
+	s.SquelchLevel = &level
+	if s.AFC, err = fc.getBool("main.get_afc"); err != nil {
+		return s, err
+	}
+	if s.RSID, err = fc.getBool("main.get_rsid"); err != nil {
+		return s, err
+	}
+	s.TXID, err = fc.getBool("main.get_txid")
+	return s, err
+}
+
+// SetReceiver changes the receive settings that are set in s.
+func (fc *FldigiClient) SetReceiver(s ReceiverSettings) error {
+	for _, b := range []struct {
+		method string
+		on     *bool
+	}{
+		{"main.set_squelch", s.Squelch},
+		{"main.set_afc", s.AFC},
+		{"main.set_rsid", s.RSID},
+		{"main.set_txid", s.TXID},
+	} {
+		if b.on == nil {
+			continue
+		}
+		value := "0"
+		if *b.on {
+			value = "1"
+		}
+		if _, err := fc.Call(b.method, Value{Boolean: value}); err != nil {
+			return err
+		}
+	}
+	if s.SquelchLevel != nil {
+		_, err := fc.Call("main.set_squelch_level", Value{Double: strconv.FormatFloat(*s.SquelchLevel, 'f', -1, 64)})
+		return err
+	}
+	return nil
+}
+
+// GetSideband returns the rig sideband, "USB" or "LSB".
+func (fc *FldigiClient) GetSideband() (string, error) {
+	value, err := fc.Call("main.get_sideband")
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(value.Text()), nil
+}
+
+// GetSignalFrequency returns the RF frequency of the modem signal, i.e. the
+// VFO frequency shifted by the audio carrier on the current sideband.
+func (fc *FldigiClient) GetSignalFrequency() (float64, error) {
+	vfo, err := fc.GetFrequency()
+	if err != nil {
+		return 0, err
+	}
+
+	carrier, err := fc.GetCarrier()
+	if err != nil {
+		return 0, err
+	}
+
+	sideband, err := fc.GetSideband()
+	if err != nil {
+		return 0, err
+	}
+
+	return signalFrequency(vfo, carrier, sideband), nil
+}
+
+func signalFrequency(vfo, carrier float64, sideband string) float64 {
+	if sideband == "LSB" {
+		return vfo - carrier
+	}
+	return vfo + carrier
+}
+
+// GetMode returns the name of the current modem, e.g. "BPSK31".
+func (fc *FldigiClient) GetMode() (string, error) {
+	value, err := fc.Call("modem.get_name")
+	if err != nil {
+		return "", err
+	}
+	return value.Text(), nil
+}
+
+// SetFrequency tunes the rig to freq Hz.
+func (fc *FldigiClient) SetFrequency(freq float64) error {
+	_, err := fc.Call("main.set_frequency", Value{Double: strconv.FormatFloat(freq, 'f', -1, 64)})
+	return err
+}
+
+// SetMode switches fldigi to the modem named mode, such as "BPSK31".
+func (fc *FldigiClient) SetMode(mode string) error {
+	_, err := fc.Call("modem.set_by_name", Value{String: mode})
+	return err
+}
+
+// SendText queues text and transmits it, returning to receive at the end.
+func (fc *FldigiClient) SendText(text string) error {
+	if _, err := fc.Call("text.add_tx", Value{String: text + "^r"}); err != nil {
+		return err
+	}
+	_, err := fc.Call("main.tx")
+	return err
+}
+
+// GetTRXState returns fldigi's transmit state: "RX", "TX" or "TUNE".
+func (fc *FldigiClient) GetTRXState() (string, error) {
+	value, err := fc.Call("main.get_trx_state")
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(value.Text()), nil
+}
+
+// Transmit switches fldigi to transmit, sending any queued text.
+func (fc *FldigiClient) Transmit() error {
+	_, err := fc.Call("main.tx")
+	return err
+}
+
+// Receive switches fldigi back to receive once queued text is sent.
+func (fc *FldigiClient) Receive() error {
+	_, err := fc.Call("main.rx")
+	return err
+}
+
+// Tune keys fldigi's unmodulated tune carrier until AbortTX.
+func (fc *FldigiClient) Tune() error {
+	_, err := fc.Call("main.tune")
+	return err
+}
+
+// AbortTX aborts any transmission in progress and returns to receive.
+func (fc *FldigiClient) AbortTX() error {
+	_, err := fc.Call("main.abort")
+	return err
+}
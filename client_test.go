@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignalFrequency(t *testing.T) {
+	testCases := []struct {
+		vfo      float64
+		carrier  float64
+		sideband string
+		expected float64
+	}{
+		{14070000, 1500, "USB", 14071500},
+		{7035000, 1000, "LSB", 7034000},
+		{14349000, 1500, "USB", 14350500}, // pushed above the 20m edge
+		{3500500, 1000, "LSB", 3499500},   // pushed below the 80m edge
+	}
+
+	for _, tc := range testCases {
+		result := signalFrequency(tc.vfo, tc.carrier, tc.sideband)
+		if result != tc.expected {
+			t.Errorf("signalFrequency(%.0f, %.0f, %s) = %.0f; want %.0f", tc.vfo, tc.carrier, tc.sideband, result, tc.expected)
+		}
+	}
+}
+
+func TestFldigiSendText(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, string(body))
+		w.Write([]byte(`<?xml version="1.0"?><methodResponse><params><param><value><string></string></value></param></params></methodResponse>`))
+	}))
+	defer server.Close()
+
+	client := NewFldigiClient("127.0.0.1", 0)
+	client.url = server.URL
+	if err := client.SendText("CQ CQ DE N0CALL K"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || !strings.Contains(calls[0], "text.add_tx") || !strings.Contains(calls[0], "CQ CQ DE N0CALL K^r") || !strings.Contains(calls[1], "main.tx") {
+		t.Errorf("calls = %q", calls)
+	}
+}
+
+func TestFldigiClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	defer func(d time.Duration) { requestTimeout = d }(requestTimeout)
+	requestTimeout = 50 * time.Millisecond
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	client := NewFldigiClient(host, port)
+
+	start := time.Now()
+	if _, err := client.GetFrequency(); err == nil {
+		t.Fatal("GetFrequency() succeeded against a stalled server")
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("GetFrequency() took %v with a 50ms timeout", elapsed)
+	}
+}
+
+func TestFldigiClientIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<methodResponse><params><param><value><string>14074000</string></value></param></params></methodResponse>`))
+	}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	defer func(v string) { ipVersion = v }(ipVersion)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ipVersion = "4"
+	if _, err := NewFldigiClient("::1", port).GetFrequency(); err == nil {
+		t.Error("IPv4-only client reached an IPv6 server")
+	}
+	for _, v := range []string{"6", "auto"} {
+		ipVersion = v
+		if freq, err := NewFldigiClient("::1", port).GetFrequency(); err != nil || freq != 14074000 {
+			t.Errorf("--ip-version %s: GetFrequency() = %v, %v", v, freq, err)
+		}
+	}
+}
+
+func TestFldigiClientURL(t *testing.T) {
+	var gotPath, gotUser, gotPassword string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUser, gotPassword, _ = r.BasicAuth()
+		w.Write([]byte(`<methodResponse><params><param><value><string>14074000</string></value></param></params></methodResponse>`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	endpoint := strings.Replace(server.URL, "http://", "http://op:s3cret@", 1)
+	client, err := NewFldigiClientURL(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freq, err := client.GetFrequency(); err != nil || freq != 14074000 {
+		t.Fatalf("GetFrequency() = %v, %v", freq, err)
+	}
+	if gotPath != "/RPC2" || gotUser != "op" || gotPassword != "s3cret" {
+		t.Errorf("request path %q, auth %q:%q", gotPath, gotUser, gotPassword)
+	}
+
+	socket := filepath.Join(t.TempDir(), "fldigi.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("no Unix-domain sockets: %v", err)
+	}
+	unixServer := httptest.NewUnstartedServer(handler)
+	unixServer.Listener = ln
+	unixServer.Start()
+	defer unixServer.Close()
+
+	client, err = NewFldigiClientURL("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freq, err := client.GetFrequency(); err != nil || freq != 14074000 {
+		t.Errorf("GetFrequency() over a Unix-domain socket = %v, %v", freq, err)
+	}
+
+	for _, bad := range []string{"ftp://host/RPC2", "http:///RPC2", "unix://"} {
+		if _, err := NewFldigiClientURL(bad); err == nil {
+			t.Errorf("NewFldigiClientURL(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFldigiReceiver(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, string(body))
+		value := "<boolean>1</boolean>"
+		if strings.Contains(string(body), "main.get_squelch_level") {
+			value = "<double>30</double>"
+		}
+		w.Write([]byte(`<methodResponse><params><param><value>` + value + `</value></param></params></methodResponse>`))
+	}))
+	defer server.Close()
+
+	client := NewFldigiClient("127.0.0.1", 0)
+	client.url = server.URL
+	s, err := client.GetReceiver()
+	if err != nil || s.String() != "squelch=on squelch-level=30 afc=on rsid=on txid=on" {
+		t.Fatalf("GetReceiver() = %v, %v", s, err)
+	}
+
+	calls = nil
+	settings, _ := ParseReceiverSettings("afc=off,squelch-level=20")
+	if err := client.SetReceiver(settings); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || !strings.Contains(calls[0], "main.set_afc") || !strings.Contains(calls[0], "<boolean>0</boolean>") ||
+		!strings.Contains(calls[1], "main.set_squelch_level") || !strings.Contains(calls[1], "<double>20</double>") {
+		t.Errorf("calls = %q", calls)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cloudlogRefresh is how often the radio is reported to Cloudlog while its
+// frequency and mode stay the same, so the logbook still sees it as live.
+const cloudlogRefresh = 5 * time.Minute
+
+// Cloudlog reports each radio's frequency and mode to the radio API of a
+// Cloudlog or Wavelog logbook, which pre-fills them on its QSO form.
+type Cloudlog struct {
+	endpoint string
+	key      string
+	name     string
+	api      *API
+	client   *http.Client
+
+	sent    map[string]cloudlogRadio
+	lastErr string
+}
+
+// cloudlogRadio is what was last reported for a radio, and when.
+type cloudlogRadio struct {
+	freq float64
+	mode string
+	at   time.Time
+}
+
+// NewCloudlog returns a reporter for the logbook at base, the address of a
+// Cloudlog or Wavelog install. Radios appear in it as name, followed by the
+// radio label when there are several.
+func NewCloudlog(base, key, name string, api *API) (*Cloudlog, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid --cloudlog %q, want the http(s) address of Cloudlog or Wavelog", base)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("--cloudlog-api-key is required with --cloudlog")
+	}
+	endpoint := strings.TrimSuffix(base, "/")
+	if !strings.HasSuffix(endpoint, "/api/radio") {
+		endpoint += "/api/radio"
+	}
+	return &Cloudlog{endpoint: endpoint, key: key, name: name, api: api, client: &http.Client{Timeout: 10 * time.Second}, sent: map[string]cloudlogRadio{}}, nil
+}
+
+// Run checks every radio each interval and reports those whose frequency
+// or mode has changed.
+func (c *Cloudlog) Run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		for _, m := range c.api.monitors {
+			c.update(m.Status(), time.Now())
+		}
+	}
+}
+
+// update reports a radio's status if it differs from what was last sent or
+// cloudlogRefresh has passed.
+func (c *Cloudlog) update(s MonitorStatus, now time.Time) {
+	if s.Time.IsZero() || s.Standby || s.Error != "" || s.Freq == 0 {
+		return
+	}
+	last, ok := c.sent[s.Radio]
+	if ok && last.freq == s.Freq && last.mode == s.Mode && now.Sub(last.at) < cloudlogRefresh {
+		return
+	}
+	name := c.name
+	if s.Radio != "" && len(c.api.monitors) > 1 {
+		name += " " + s.Radio
+	}
+	if err := c.send(name, s.Freq, s.Mode, now); err != nil {
+		// The same failure is logged once rather than on every check.
+		if err.Error() != c.lastErr {
+			log.Printf("Error updating Cloudlog: %v", err)
+			c.lastErr = err.Error()
+		}
+		return
+	}
+	c.lastErr = ""
+	c.sent[s.Radio] = cloudlogRadio{freq: s.Freq, mode: s.Mode, at: now}
+}
+
+func (c *Cloudlog) send(radio string, freq float64, mode string, now time.Time) error {
+	if dryRun {
+		dryRunf("would report %s at %.0f Hz %s to %s", radio, freq, mode, c.endpoint)
+		return nil
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"key":       c.key,
+		"radio":     radio,
+		"frequency": int64(freq),
+		"mode":      mode,
+		"timestamp": now.UTC().Format("2006/01/02 15:04"),
+	})
+	resp, err := c.client.Post(c.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	// Cloudlog answers a bad key with 200 and a failed status.
+	var result struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(data, &result)
+	if resp.StatusCode >= 300 || result.Status == "failed" {
+		msg := result.Reason
+		if msg == "" {
+			msg = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("%s returned %s: %s", c.endpoint, resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCloudlogReportsChanges(t *testing.T) {
+	var posts []map[string]interface{}
+	reply := `{"status":"success"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.php/api/radio" {
+			t.Errorf("posted to %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		posts = append(posts, body)
+		w.Write([]byte(reply))
+	}))
+	defer srv.Close()
+
+	c, err := NewCloudlog(srv.URL+"/index.php/", "cl12345", "fldigi-cmd", &API{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	status := MonitorStatus{Time: now, Freq: 14074000, Band: "20m", Mode: "USB"}
+	c.update(status, now)
+	c.update(status, now.Add(time.Second))
+	if len(posts) != 1 || posts[0]["key"] != "cl12345" || posts[0]["frequency"] != 14074000.0 || posts[0]["mode"] != "USB" || posts[0]["timestamp"] != "2026/03/01 12:00" || posts[0]["radio"] != "fldigi-cmd" {
+		t.Fatalf("posts = %v", posts)
+	}
+
+	// A rejected update is tried again on the next check.
+	reply = `{"status":"failed","reason":"missing api key"}`
+	status.Mode = "CW"
+	c.update(status, now.Add(2*time.Second))
+	reply = `{"status":"success"}`
+	c.update(status, now.Add(3*time.Second))
+	c.update(status, now.Add(4*time.Second))
+	if len(posts) != 3 || posts[2]["mode"] != "CW" {
+		t.Errorf("posts = %v", posts)
+	}
+
+	c.update(status, now.Add(4*time.Second+cloudlogRefresh))
+	if len(posts) != 4 {
+		t.Errorf("an unchanged radio wasn't refreshed: %d posts", len(posts))
+	}
+
+	if _, err := NewCloudlog(srv.URL, "", "fldigi-cmd", &API{}); err == nil {
+		t.Error("NewCloudlog accepted an empty API key")
+	}
+}
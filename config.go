@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envName returns the environment variable that sets a flag, e.g.
+// FLDIGI_API_ADDR for --api-addr.
+func envName(flagName string) string {
+	return "FLDIGI_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyConfig fills in the flags not given on the command line, first from
+// FLDIGI_* environment variables and then from the JSON config file named
+// by *configPath, so flags take precedence over the environment and the
+// environment over the file. Repeatable flags take a comma-separated list
+// from the environment, or for specFlags, whose values contain commas, a
+// list of lines, and an array in the file.
+func applyConfig(fs *flag.FlagSet, configPath *string, getenv func(string) string) error {
+	// Short aliases share their long flag's value, so a value already set
+	// under either name is left alone.
+	given := map[flag.Value]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Value] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v := getenv(envName(f.Name))
+		if err != nil || len(f.Name) == 1 || v == "" || given[f.Value] {
+			return
+		}
+		values := []string{v}
+		switch f.Value.(type) {
+		case *radioFlag:
+			values = strings.Split(v, ",")
+		case *specFlag:
+			values = splitSpecs(v)
+		}
+		for _, v := range values {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("invalid %s %q: %v", envName(f.Name), v, setErr)
+				return
+			}
+		}
+		given[f.Value] = true
+	})
+	if err != nil || *configPath == "" {
+		return err
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f := fs.Lookup(key)
+		if f == nil || key == "config" {
+			return fmt.Errorf("config file: unknown option %q", key)
+		}
+		if given[f.Value] {
+			continue
+		}
+
+		values, ok := config[key].([]interface{})
+		if !repeatable(f.Value) || !ok {
+			values = []interface{}{config[key]}
+		}
+		for _, v := range values {
+			var s string
+			switch v := v.(type) {
+			case string:
+				s = v
+			case float64:
+				s = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				s = strconv.FormatBool(v)
+			default:
+				return fmt.Errorf("config file: invalid value for %q", key)
+			}
+			if err := f.Value.Set(s); err != nil {
+				return fmt.Errorf("config file: invalid %s %q: %v", key, s, err)
+			}
+		}
+		given[f.Value] = true
+	}
+	return nil
+}
+
+// specFlag collects a repeated option whose values contain commas, such as
+// a cron entry or a list of settings.
+type specFlag []string
+
+func (f *specFlag) String() string { return strings.Join(*f, "; ") }
+
+func (f *specFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// splitSpecs splits a specFlag list from the environment into lines.
+// Semicolons aren't separators since they end CAT commands.
+func splitSpecs(list string) []string {
+	var specs []string
+	for _, spec := range strings.Split(list, "\n") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// repeatable reports whether v collects a repeated option.
+func repeatable(v flag.Value) bool {
+	switch v.(type) {
+	case *radioFlag, *specFlag:
+		return true
+	}
+	return false
+}
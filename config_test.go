@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "fldigi-cmd.json")
+	os.WriteFile(config, []byte(`{"host": "file-host", "port": 7363, "interval": "2s", "verify": true, "radio": ["A=a:7362", "B=b:7362"], "command": "./file.sh"}`), 0644)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var configPath, host, command string
+	var port int
+	var interval time.Duration
+	var verify bool
+	var radios radioFlag
+	fs.StringVar(&configPath, "config", "", "")
+	fs.StringVar(&host, "h", "127.0.0.1", "")
+	fs.StringVar(&host, "host", "127.0.0.1", "")
+	fs.IntVar(&port, "port", 0, "")
+	fs.DurationVar(&interval, "interval", 5*time.Second, "")
+	fs.BoolVar(&verify, "verify", false, "")
+	fs.Var(&radios, "radio", "")
+	fs.StringVar(&command, "command", "", "")
+	fs.Parse([]string{"-h", "flag-host"})
+
+	env := map[string]string{
+		"FLDIGI_CONFIG":  config,
+		"FLDIGI_HOST":    "env-host",
+		"FLDIGI_PORT":    "7364",
+		"FLDIGI_COMMAND": "./env.sh",
+	}
+	if err := applyConfig(fs, &configPath, func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+
+	if host != "flag-host" || port != 7364 || command != "./env.sh" {
+		t.Errorf("host %q, port %d, command %q; want the flag over the environment over the file", host, port, command)
+	}
+	if interval != 2*time.Second || !verify || strings.Join(radios, " ") != "A=a:7362 B=b:7362" {
+		t.Errorf("interval %v, verify %v, radios %v; want values from the file", interval, verify, radios)
+	}
+}
+
+func TestApplyConfigSpecLists(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var configPath string
+	var schedule, power, modeDefaults specFlag
+	fs.StringVar(&configPath, "config", "", "")
+	fs.Var(&schedule, "schedule", "")
+	fs.Var(&power, "power-profile", "")
+	fs.Var(&modeDefaults, "mode-defaults", "")
+	env := map[string]string{
+		"FLDIGI_SCHEDULE":      "0 19 * * mon,wed 3.573M\n 0 21 * * * 7.074M\n",
+		"FLDIGI_POWER_PROFILE": "6m=25,att=0\n2m=10%,cat=EX0301;",
+		"FLDIGI_MODE_DEFAULTS": "BPSK31:squelch=on,afc=off",
+	}
+	if err := applyConfig(fs, &configPath, func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 2 || schedule[0] != "0 19 * * mon,wed 3.573M" || schedule[1] != "0 21 * * * 7.074M" {
+		t.Errorf("schedule %q, want two entries keeping their commas", schedule)
+	}
+	if len(power) != 2 || power[0] != "6m=25,att=0" || power[1] != "2m=10%,cat=EX0301;" {
+		t.Errorf("power profiles %q, want two keeping commas and semicolons", power)
+	}
+	if len(modeDefaults) != 1 || modeDefaults[0] != "BPSK31:squelch=on,afc=off" {
+		t.Errorf("mode defaults %q, want one", modeDefaults)
+	}
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		config, env, want string
+	}{
+		{`{"hots": "x"}`, "", `unknown option "hots"`},
+		{`{"port": "seven"}`, "", "invalid port"},
+		{`{"port": [1]}`, "", `invalid value for "port"`},
+		{`{}`, "seven", "invalid FLDIGI_PORT"},
+	} {
+		path := filepath.Join(dir, "config.json")
+		os.WriteFile(path, []byte(tc.config), 0644)
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var host string
+		var port int
+		fs.StringVar(&host, "host", "", "")
+		fs.IntVar(&port, "port", 0, "")
+		err := applyConfig(fs, &path, func(k string) string {
+			if k == "FLDIGI_PORT" {
+				return tc.env
+			}
+			return ""
+		})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("config %s, env %q: err = %v, want %q", tc.config, tc.env, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes retry delays that double from Min up to Max, with
+// jitter so that several monitors don't retry in lockstep.
+type Backoff struct {
+	Min, Max time.Duration
+
+	attempt int
+	jitter  func() float64
+}
+
+// Next returns the delay before the next retry: a random duration between
+// half and all of the current step.
+func (b *Backoff) Next() time.Duration {
+	max := b.Max
+	if max < b.Min {
+		max = b.Min
+	}
+	d := b.Min
+	for i := 0; i < b.attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	b.attempt++
+
+	jitter := b.jitter
+	if jitter == nil {
+		jitter = rand.Float64
+	}
+	return d/2 + time.Duration(jitter()*float64(d/2))
+}
+
+// Reset starts the next series of retries from Min again.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// connState is the monitor's view of its link to the rig.
+type connState int
+
+const (
+	connUnknown connState = iota
+	connConnected
+	connDisconnected
+)
+
+// connectionLost records a failed poll, emitting a disconnected event on
+// the transition, and returns the delay before the next attempt.
+func (m *Monitor) connectionLost(err error, now time.Time) time.Duration {
+	if m.conn != connDisconnected {
+		m.logf("Lost connection to %s: %v", m.backendName(), err)
+		m.conn = connDisconnected
+		m.lostAt = now
+		m.emit(Event{Type: EventDisconnected, Time: now, Backend: m.backendName(), Error: err.Error()})
+	}
+	if m.backoff == nil {
+		return m.interval
+	}
+	delay := m.backoff.Next()
+	m.logf("Reconnecting in %v", delay.Round(time.Millisecond))
+	return delay
+}
+
+// connectionRestored records a successful poll, emitting a connected event
+// on the transition, with the downtime when the link had been lost.
+func (m *Monitor) connectionRestored(now time.Time) {
+	if m.conn == connConnected {
+		return
+	}
+	ev := Event{Type: EventConnected, Time: now, Backend: m.backendName()}
+	if m.conn == connDisconnected {
+		downtime := now.Sub(m.lostAt)
+		m.printf("Reconnected to %s after %v\n", m.backendName(), downtime.Round(time.Second))
+		ev.Downtime = downtime.Seconds()
+	}
+	m.conn = connConnected
+	if m.backoff != nil {
+		m.backoff.Reset()
+	}
+	m.emit(ev)
+}
+
+func (m *Monitor) backendName() string {
+	if m.backend == nil {
+		return "rig"
+	}
+	return m.backend.Name()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := &Backoff{Min: time.Second, Max: 5 * time.Second, jitter: func() float64 { return 1 }}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := b.Next(); got != w {
+			t.Errorf("attempt %d: delay = %v; want %v", i, got, w)
+		}
+	}
+
+	b.Reset()
+	b.jitter = func() float64 { return 0 }
+	if got := b.Next(); got != 500*time.Millisecond {
+		t.Errorf("delay after reset with no jitter = %v; want 500ms", got)
+	}
+}
+
+func TestMonitorConnectionEvents(t *testing.T) {
+	m, sink := newTestMonitor()
+	m.interval = time.Second
+	m.backoff = &Backoff{Min: time.Second, Max: time.Minute, jitter: func() float64 { return 1 }}
+	m.backend = &fakeBackend{name: "fldigi"}
+
+	readings := []error{nil, errors.New("connection refused"), errors.New("connection refused"), nil}
+	var delays []time.Duration
+	for _, err := range readings {
+		err := err
+		m.getFrequency = func() (float64, error) { return 14070000, err }
+		delays = append(delays, m.poll())
+	}
+
+	var conn []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventConnected || ev.Type == EventDisconnected {
+			conn = append(conn, ev)
+		}
+	}
+	if len(conn) != 3 || conn[0].Type != EventConnected || conn[1].Type != EventDisconnected || conn[2].Type != EventConnected {
+		t.Fatalf("connection events = %+v", conn)
+	}
+	if conn[1].Error != "connection refused" || conn[1].Backend != "fldigi" {
+		t.Errorf("disconnected event = %+v", conn[1])
+	}
+
+	want := []time.Duration{time.Second, time.Second, 2 * time.Second, time.Second}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Errorf("delays = %v; want %v", delays, want)
+			break
+		}
+	}
+}
+
+func TestConnectionHooks(t *testing.T) {
+	var posted []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev Event
+		json.NewDecoder(r.Body).Decode(&ev)
+		posted = append(posted, ev)
+	}))
+	defer server.Close()
+
+	m, _ := newTestMonitor()
+	m.backend = &fakeBackend{name: "fldigi"}
+	m.dispatcher.Add(&webhookSink{name: "reconnect-webhook", url: server.URL, event: EventConnected})
+
+	start := time.Now()
+	m.connectionRestored(start) // startup: not a restoration
+	m.connectionLost(errors.New("timeout"), start)
+	m.connectionRestored(start.Add(90 * time.Second))
+
+	if len(posted) != 1 || posted[0].Downtime != 90 || posted[0].Backend != "fldigi" {
+		t.Fatalf("posted = %+v", posted)
+	}
+	if got, want := commandArgs(posted[0]), []string{"fldigi", "90"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("commandArgs(connected) = %v; want %v", got, want)
+	}
+	lost := Event{Type: EventDisconnected, Backend: "fldigi", Error: "timeout"}
+	if got, want := commandArgs(lost), []string{"fldigi", "timeout"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("commandArgs(disconnected) = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cooldownSink keeps a hook from running again until its cooldown has passed
+// since it last ran for the same radio. Events arriving sooner wait, each
+// replacing the one before, and when the longest of their cooldowns is up
+// only the latest runs, so tuning quickly across several bands switches
+// relays once.
+type cooldownSink struct {
+	Sink
+	dispatcher *Dispatcher
+	cooldown   time.Duration
+	// bands overrides cooldown for events on particular bands.
+	bands map[string]time.Duration
+	// queue, if set, is the hook queue the sink runs under: waiting events
+	// count as its work for Wait, and run on its workers when due.
+	queue *HookQueue
+
+	mu     sync.Mutex
+	radios map[string]*cooldownState
+}
+
+type cooldownState struct {
+	run     sync.Mutex
+	lastRun time.Time
+	pending *Event
+	due     time.Time // when pending may run
+	timer   *time.Timer
+	// runner runs the hook once the cooldown is up.
+	runner Sink
+}
+
+// cooldownRunner runs a waiting event's hook, one at a time per radio.
+type cooldownRunner struct {
+	Sink
+	st *cooldownState
+}
+
+func (r *cooldownRunner) Handle(ev Event) error {
+	r.st.run.Lock()
+	defer r.st.run.Unlock()
+	return r.Sink.Handle(ev)
+}
+
+func newCooldownSink(d *Dispatcher, s Sink, cooldown time.Duration, bands map[string]time.Duration) *cooldownSink {
+	return &cooldownSink{Sink: s, dispatcher: d, cooldown: cooldown, bands: bands, radios: map[string]*cooldownState{}}
+}
+
+// cooldownFor returns how long after the hook last ran it may run for ev.
+func (s *cooldownSink) cooldownFor(ev Event) time.Duration {
+	if d, ok := s.bands[ev.Band]; ok {
+		return d
+	}
+	return s.cooldown
+}
+
+func (s *cooldownSink) Handle(ev Event) error {
+	s.mu.Lock()
+	st, ok := s.radios[ev.Radio]
+	if !ok {
+		st = &cooldownState{}
+		st.runner = &cooldownRunner{Sink: s.Sink, st: st}
+		s.radios[ev.Radio] = st
+	}
+	due := st.lastRun.Add(s.cooldownFor(ev))
+	if st.pending != nil || time.Now().Before(due) {
+		if s.queue != nil {
+			s.queue.hold(ev)
+			if st.pending != nil {
+				s.queue.release(*st.pending)
+			}
+		}
+		st.pending = &ev
+		if due.After(st.due) {
+			st.due = due
+		}
+		if st.timer == nil {
+			st.timer = time.AfterFunc(time.Until(st.due), func() { s.fire(st) })
+		}
+		s.mu.Unlock()
+		return nil
+	}
+	st.lastRun = time.Now()
+	s.mu.Unlock()
+
+	return st.runner.Handle(ev)
+}
+
+// fire runs the latest event that waited for the cooldown, on the hook
+// queue's workers if there are any, or waits longer if a later event's
+// cooldown isn't up yet.
+func (s *cooldownSink) fire(st *cooldownState) {
+	s.mu.Lock()
+	if wait := time.Until(st.due); wait > 0 {
+		st.timer = time.AfterFunc(wait, func() { s.fire(st) })
+		s.mu.Unlock()
+		return
+	}
+	ev := *st.pending
+	st.pending = nil
+	st.timer = nil
+	st.lastRun = time.Now()
+	s.mu.Unlock()
+
+	if s.queue != nil {
+		s.queue.enqueue(st.runner, ev)
+		s.queue.release(ev)
+		return
+	}
+	s.dispatcher.handle(st.runner, ev)
+}
+
+// parseBandCooldowns parses --hook-band-cooldown values of the form
+// band=duration, e.g. 160m=10s.
+func parseBandCooldowns(specs []string) (map[string]time.Duration, error) {
+	bands := map[string]time.Duration{}
+	for _, spec := range specs {
+		band, value, ok := strings.Cut(spec, "=")
+		if !ok || band == "" {
+			return nil, fmt.Errorf("invalid band cooldown %q, want band=duration", spec)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid band cooldown %q, want band=duration", spec)
+		}
+		bands[band] = d
+	}
+	return bands, nil
+}
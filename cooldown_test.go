@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bandSink records the bands of the events it handles.
+type bandSink struct {
+	mu    sync.Mutex
+	bands []string
+}
+
+func (s *bandSink) Name() string        { return "bands" }
+func (s *bandSink) Wants(ev Event) bool { return ev.Type == EventBandChange }
+func (s *bandSink) Handle(ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bands = append(s.bands, ev.Radio+ev.Band)
+	return nil
+}
+
+func (s *bandSink) got() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bands...)
+}
+
+func TestCooldownRunsLatestEventOfBurst(t *testing.T) {
+	sink := &bandSink{}
+	dispatcher := NewDispatcher(NewMetrics())
+	dispatcher.Add(newCooldownSink(dispatcher, sink, 100*time.Millisecond, nil))
+
+	for _, band := range []string{"20m", "17m", "15m", "12m"} {
+		dispatcher.Emit(Event{Type: EventBandChange, Band: band})
+	}
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "40m", Radio: "B"})
+	if got := sink.got(); !reflect.DeepEqual(got, []string{"20m", "B40m"}) {
+		t.Fatalf("ran %v before the cooldown, want the first event of each radio", got)
+	}
+	waitFor(t, func() bool { return len(sink.got()) == 3 })
+	time.Sleep(150 * time.Millisecond)
+	if got := sink.got(); !reflect.DeepEqual(got, []string{"20m", "B40m", "12m"}) {
+		t.Errorf("ran %v, want only the last event of the burst after the cooldown", got)
+	}
+}
+
+func TestCooldownPerBand(t *testing.T) {
+	bands, err := parseBandCooldowns([]string{"160m=1h", "6m=0s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &bandSink{}
+	dispatcher := NewDispatcher(NewMetrics())
+	dispatcher.Add(newCooldownSink(dispatcher, sink, time.Hour, bands))
+
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "20m"})
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "6m"})
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "160m"})
+	if got := sink.got(); !reflect.DeepEqual(got, []string{"20m", "6m"}) {
+		t.Errorf("ran %v", got)
+	}
+
+	for _, spec := range []string{"160m", "=5s", "160m=soon", "160m=-1s"} {
+		if _, err := parseBandCooldowns([]string{spec}); err == nil {
+			t.Errorf("parseBandCooldowns(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestCooldownLongestPendingCooldown(t *testing.T) {
+	sink := &bandSink{}
+	dispatcher := NewDispatcher(NewMetrics())
+	dispatcher.Add(newCooldownSink(dispatcher, sink, 50*time.Millisecond, map[string]time.Duration{"160m": 300 * time.Millisecond}))
+
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "20m"})
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "40m"})
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "160m"})
+	time.Sleep(150 * time.Millisecond)
+	if got := sink.got(); !reflect.DeepEqual(got, []string{"20m"}) {
+		t.Errorf("ran %v before 160m's cooldown was up", got)
+	}
+	waitFor(t, func() bool { return len(sink.got()) == 2 })
+	if got := sink.got(); got[1] != "160m" {
+		t.Errorf("ran %v", got)
+	}
+}
+
+func TestCooldownRunsOnHookQueue(t *testing.T) {
+	sink := &bandSink{}
+	dispatcher := NewDispatcher(NewMetrics())
+	q := NewHookQueue(dispatcher, 1, 10, false)
+	cooldown := newCooldownSink(dispatcher, sink, 100*time.Millisecond, nil)
+	cooldown.queue = q
+	dispatcher.Add(q.Wrap(cooldown))
+
+	first := Event{Type: EventBandChange, Band: "20m", Time: time.Now()}
+	dispatcher.Emit(first)
+	q.Wait(first)
+	second := Event{Type: EventBandChange, Band: "15m", Time: first.Time.Add(time.Second)}
+	dispatcher.Emit(second)
+
+	// Waiting on the event covers its time in the cooldown.
+	start := time.Now()
+	q.Wait(second)
+	if got := sink.got(); !reflect.DeepEqual(got, []string{"20m", "15m"}) || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("ran %v after waiting %v", got, time.Since(start))
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dashboardEvents is how many recent events the dashboard lists.
+const dashboardEvents = 50
+
+// Dashboard serves a web page showing the station for the shack: each
+// radio's frequency, band, mode and TX state, the recent events and how
+// the hooks have fared, updated live over server-sent events. Unlike the
+// public status page it shows everything, so it is for your own network.
+type Dashboard struct {
+	api     *API
+	stream  *EventStream
+	metrics *Metrics
+
+	mu sync.Mutex
+	tx map[string]bool
+}
+
+// DashboardStatus is the state of the station the dashboard shows.
+type DashboardStatus struct {
+	Radios []DashboardRadio `json:"radios"`
+	Hooks  []SinkResult     `json:"hooks"`
+}
+
+// DashboardRadio is a radio's status and whether it is transmitting.
+type DashboardRadio struct {
+	MonitorStatus
+	TX bool `json:"tx"`
+}
+
+func NewDashboard(api *API, stream *EventStream, metrics *Metrics) *Dashboard {
+	return &Dashboard{api: api, stream: stream, metrics: metrics, tx: map[string]bool{}}
+}
+
+// Run polls the TX state of each radio every interval.
+func (d *Dashboard) Run(interval time.Duration) {
+	for {
+		d.pollTX()
+		time.Sleep(interval)
+	}
+}
+
+func (d *Dashboard) pollTX() {
+	tx := map[string]bool{}
+	for _, m := range d.api.monitors {
+		if c, ok := m.backend.(TXController); ok {
+			state, err := c.GetTRXState()
+			tx[m.radio] = err == nil && state != "RX"
+		}
+	}
+	d.mu.Lock()
+	d.tx = tx
+	d.mu.Unlock()
+}
+
+// Status returns the current state of the station.
+func (d *Dashboard) Status() DashboardStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := DashboardStatus{Radios: []DashboardRadio{}, Hooks: d.metrics.SinkResults()}
+	for _, m := range d.api.monitors {
+		s := m.Status()
+		status.Radios = append(status.Radios, DashboardRadio{MonitorStatus: s, TX: d.tx[s.Radio]})
+	}
+	return status
+}
+
+// ServeHTTP serves the page at /, the station's state at /status.json and
+// the live updates at /live.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/", "/index.html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardPage))
+	case "/status.json":
+		writeJSON(w, d.Status())
+	case "/live":
+		d.serveLive(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveLive streams a "status" event whenever the state of the station
+// changes, checked every second, and each event as it happens, starting
+// with the recent ones.
+func (d *Dashboard) serveLive(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ch := d.stream.subscribe(dashboardEvents)
+	defer d.stream.unsubscribe(ch)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var last []byte
+	sendStatus := func() {
+		data, err := json.Marshal(d.Status())
+		if err != nil || string(data) == string(last) {
+			return
+		}
+		last = data
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	sendStatus()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			sendStatus()
+		case ev := <-ch:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>fldigi-cmd</title>
+<style>
+body { font-family: sans-serif; margin: 1em; background: #111; color: #eee; }
+h2 { font-size: 1em; color: #aaa; margin-top: 1.5em; }
+.radio { display: inline-block; border: 1px solid #444; border-radius: .4em; padding: .6em 1em; margin: 0 1em 1em 0; min-width: 14em; }
+.freq { font-size: 2.4em; font-family: monospace; }
+.tx { color: #fff; background: #c00; padding: 0 .4em; border-radius: .2em; }
+.error, .failed { color: #f66; }
+.dim { color: #888; }
+table { border-collapse: collapse; }
+td, th { text-align: left; padding: .1em 1em .1em 0; vertical-align: top; }
+#events td:first-child { font-family: monospace; white-space: nowrap; }
+#state { float: right; }
+</style>
+</head>
+<body>
+<span id="state" class="dim">connecting</span>
+<div id="radios"></div>
+<h2>Recent events</h2>
+<table id="events"></table>
+<h2>Hooks</h2>
+<table id="hooks"><tr><th>Hook</th><th>Runs</th><th>Failed</th></tr></table>
+<script>
+function el(tag, text, cls) {
+  var e = document.createElement(tag);
+  if (text !== undefined) e.textContent = text;
+  if (cls) e.className = cls;
+  return e;
+}
+
+function showStatus(s) {
+  var radios = document.getElementById("radios");
+  radios.replaceChildren();
+  s.radios.forEach(function (r) {
+    var div = el("div", undefined, "radio");
+    var head = el("div", r.radio ? "Radio " + r.radio : "", "dim");
+    if (r.tx) head.append(" ", el("span", "TX", "tx"));
+    div.append(head);
+    if (r.error) {
+      div.append(el("div", r.error, "error"));
+    } else if (r.standby) {
+      div.append(el("div", "standby", "dim"));
+    } else {
+      div.append(el("div", r.freq ? (r.freq / 1e6).toFixed(6) : "-", "freq"));
+      div.append(el("div", [r.band || "out of band", r.mode, r.segment].filter(Boolean).join("  ")));
+    }
+    radios.append(div);
+  });
+
+  var hooks = document.getElementById("hooks");
+  while (hooks.rows.length > 1) hooks.deleteRow(1);
+  s.hooks.forEach(function (h) {
+    var row = hooks.insertRow();
+    row.append(el("td", h.sink), el("td", h.runs), el("td", h.failed, h.failed ? "failed" : ""));
+  });
+}
+
+function showEvent(ev) {
+  var events = document.getElementById("events");
+  var row = events.insertRow(0);
+  var fields = [];
+  for (var k in ev) {
+    if (k !== "type" && k !== "time") fields.push(k + "=" + (typeof ev[k] === "object" ? JSON.stringify(ev[k]) : ev[k]));
+  }
+  var failed = ev.type === "hook-failed" || ev.type === "hook-timeout";
+  row.append(el("td", new Date(ev.time).toLocaleTimeString()), el("td", ev.type, failed ? "failed" : ""), el("td", fields.join(" "), "dim"));
+  while (events.rows.length > 50) events.deleteRow(-1);
+}
+
+var live = new EventSource("live");
+live.addEventListener("status", function (e) { showStatus(JSON.parse(e.data)); });
+live.onmessage = function (e) { showEvent(JSON.parse(e.data)); };
+live.onopen = function () {
+  document.getElementById("events").replaceChildren();
+  document.getElementById("state").textContent = "live";
+};
+live.onerror = function () { document.getElementById("state").textContent = "reconnecting"; };
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardStatus(t *testing.T) {
+	m, _ := newTestMonitor()
+	m.backend = &txBackend{fakeTX: fakeTX{state: "TX"}}
+	m.currentBand, m.currentMode = "20m", "BPSK31"
+	m.setStatus(14070987, nil)
+	metrics := NewMetrics()
+	metrics.Sink("band-command", nil, time.Millisecond)
+	metrics.Sink("band-command", errors.New("exit status 1"), time.Millisecond)
+
+	d := NewDashboard(&API{monitors: []*Monitor{m}}, NewEventStream(10), metrics)
+	d.pollTX()
+	status := d.Status()
+	if r := status.Radios[0]; !r.TX || r.Freq != 14070987 || r.Band != "20m" || r.Mode != "BPSK31" {
+		t.Errorf("radio = %+v, want transmitting on 14070987 Hz 20m BPSK31", r)
+	}
+	if len(status.Hooks) != 1 || status.Hooks[0] != (SinkResult{Sink: "band-command", Runs: 2, Failed: 1}) {
+		t.Errorf("hooks = %+v", status.Hooks)
+	}
+}
+
+func TestDashboardLive(t *testing.T) {
+	m, _ := newTestMonitor()
+	m.currentBand = "40m"
+	m.setStatus(7074000, nil)
+	stream := NewEventStream(10)
+	stream.Handle(Event{Type: EventInitialBand, Band: "40m"})
+	d := NewDashboard(&API{monitors: []*Monitor{m}}, stream, NewMetrics())
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	next := func() string {
+		for lines.Scan() {
+			if line := lines.Text(); line != "" {
+				return line
+			}
+		}
+		return ""
+	}
+
+	if line := next(); line != "event: status" {
+		t.Fatalf("first line = %q, want the status", line)
+	}
+	if line := next(); !strings.Contains(line, `"freq":7074000`) {
+		t.Errorf("status = %q", line)
+	}
+	if line := next(); !strings.HasPrefix(line, "data: ") || !strings.Contains(line, `"type":"initial-band"`) {
+		t.Errorf("replayed event = %q", line)
+	}
+	stream.Handle(Event{Type: EventBandChange, Band: "20m"})
+	if line := next(); !strings.Contains(line, `"type":"band-change"`) {
+		t.Errorf("live event = %q", line)
+	}
+
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status.json", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// BandDebouncer suppresses band flapping by only confirming a band once it
+// has been read on enough consecutive polls and held for a minimum dwell time.
+type BandDebouncer struct {
+	Readings int
+	MinDwell time.Duration
+
+	candidate string
+	count     int
+	since     time.Time
+}
+
+// Update records a band reading and reports whether the band is stable
+// enough to act on.
+func (d *BandDebouncer) Update(band string, now time.Time) bool {
+	if band != d.candidate {
+		d.candidate = band
+		d.count = 0
+		d.since = now
+	}
+	d.count++
+
+	if d.count < d.Readings {
+		return false
+	}
+	return now.Sub(d.since) >= d.MinDwell
+}
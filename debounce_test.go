@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandDebouncerReadings(t *testing.T) {
+	d := &BandDebouncer{Readings: 3}
+	now := time.Now()
+
+	readings := []struct {
+		band string
+		want bool
+	}{
+		{"20m", false},
+		{"20m", false},
+		{"20m", true},
+		{"17m", false}, // transient read across a band edge
+		{"20m", false},
+		{"20m", false},
+		{"20m", true},
+		{"20m", true},
+	}
+
+	for i, r := range readings {
+		if got := d.Update(r.band, now); got != r.want {
+			t.Errorf("reading %d (%s): Update() = %v; want %v", i, r.band, got, r.want)
+		}
+	}
+}
+
+func TestBandDebouncerMinDwell(t *testing.T) {
+	d := &BandDebouncer{Readings: 1, MinDwell: 10 * time.Second}
+	start := time.Now()
+
+	if d.Update("40m", start) {
+		t.Error("band confirmed before dwell time elapsed")
+	}
+	if d.Update("40m", start.Add(5*time.Second)) {
+		t.Error("band confirmed before dwell time elapsed")
+	}
+	if !d.Update("40m", start.Add(10*time.Second)) {
+		t.Error("band not confirmed after dwell time elapsed")
+	}
+}
+
+func TestBandDebouncerDefault(t *testing.T) {
+	d := &BandDebouncer{}
+	if !d.Update("20m", time.Now()) {
+		t.Error("zero-value debouncer should confirm every reading")
+	}
+}
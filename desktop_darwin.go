@@ -0,0 +1,24 @@
+package main
+
+// desktopNotifyCommand returns the program, arguments and environment that
+// show a notification in Notification Center.
+func desktopNotifyCommand(title, body string) (string, []string, []string) {
+	return "osascript", []string{
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, body,
+	}, nil
+}
+
+// soundCommand returns the program, arguments and environment that play a
+// sound file.
+func soundCommand(path string) (string, []string, []string) {
+	return "afplay", []string{path}, nil
+}
+
+// speechCommand returns the program, arguments and environment that speak
+// text aloud.
+func speechCommand(text string) (string, []string, []string) {
+	return "say", []string{text}, nil
+}
@@ -0,0 +1,29 @@
+//go:build !darwin && !windows
+
+package main
+
+import "os/exec"
+
+// desktopNotifyCommand returns the program, arguments and environment that
+// show a notification through the desktop's notification daemon.
+func desktopNotifyCommand(title, body string) (string, []string, []string) {
+	return "notify-send", []string{"--app-name=fldigi-cmd", "--", title, body}, nil
+}
+
+// soundCommand returns the program, arguments and environment that play a
+// sound file: through PulseAudio or PipeWire if it is running, else ALSA.
+func soundCommand(path string) (string, []string, []string) {
+	if _, err := exec.LookPath("paplay"); err == nil {
+		return "paplay", []string{path}, nil
+	}
+	return "aplay", []string{"-q", path}, nil
+}
+
+// speechCommand returns the program, arguments and environment that speak
+// text aloud, with eSpeak NG or the older eSpeak.
+func speechCommand(text string) (string, []string, []string) {
+	if _, err := exec.LookPath("espeak-ng"); err == nil {
+		return "espeak-ng", []string{"--", text}, nil
+	}
+	return "espeak", []string{"--", text}, nil
+}
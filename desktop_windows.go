@@ -0,0 +1,37 @@
+package main
+
+// The notification and sound are passed to PowerShell in the environment,
+// which it can't mistake for script.
+const (
+	windowsToastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:FLDIGI_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:FLDIGI_NOTIFY_BODY)) > $null
+$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+	windowsSoundScript  = `(New-Object Media.SoundPlayer $env:FLDIGI_SOUND).PlaySync()`
+	windowsSpeechScript = `Add-Type -AssemblyName System.Speech
+(New-Object System.Speech.Synthesis.SpeechSynthesizer).Speak($env:FLDIGI_SPEAK_TEXT)`
+)
+
+// desktopNotifyCommand returns the program, arguments and environment that
+// show a toast notification.
+func desktopNotifyCommand(title, body string) (string, []string, []string) {
+	return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", windowsToastScript},
+		[]string{"FLDIGI_NOTIFY_TITLE=" + title, "FLDIGI_NOTIFY_BODY=" + body}
+}
+
+// soundCommand returns the program, arguments and environment that play a
+// WAV file.
+func soundCommand(path string) (string, []string, []string) {
+	return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", windowsSoundScript},
+		[]string{"FLDIGI_SOUND=" + path}
+}
+
+// speechCommand returns the program, arguments and environment that speak
+// text aloud.
+func speechCommand(text string) (string, []string, []string) {
+	return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", windowsSpeechScript},
+		[]string{"FLDIGI_SPEAK_TEXT=" + text}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DiscoveredServer is an fldigi XML-RPC server found on the network.
+type DiscoveredServer struct {
+	Host    string
+	Port    int
+	Version string
+}
+
+// discoverFldigi probes localhost and every host on the local /24 subnets
+// for an fldigi XML-RPC server on port, confirming each open port with an
+// fldigi.version call. Servers are returned sorted by address.
+func discoverFldigi(port int, timeout time.Duration) []DiscoveredServer {
+	hosts := append([]string{"127.0.0.1"}, localSubnetHosts()...)
+
+	var mu sync.Mutex
+	var found []DiscoveredServer
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 64)
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if server, ok := probeFldigi(host, port, timeout); ok {
+				mu.Lock()
+				found = append(found, server)
+				mu.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := net.ParseIP(found[i].Host).To4(), net.ParseIP(found[j].Host).To4()
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return found
+}
+
+func probeFldigi(host string, port int, timeout time.Duration) (DiscoveredServer, bool) {
+	conn, err := net.DialTimeout("tcp4", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return DiscoveredServer{}, false
+	}
+	conn.Close()
+
+	client := NewFldigiClient(host, port)
+	client.client.Timeout = 2 * time.Second
+	value, err := client.Call("fldigi.version")
+	if err != nil {
+		return DiscoveredServer{}, false
+	}
+	return DiscoveredServer{Host: host, Port: port, Version: value.Text()}, true
+}
+
+// localSubnetHosts lists the other addresses on the /24 around each of the
+// machine's IPv4 addresses. Larger subnets are limited to the /24 so the
+// probe stays quick.
+func localSubnetHosts() []string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var hosts []string
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP.To4()
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+
+		for i := 1; i < 255; i++ {
+			host := net.IPv4(ip[0], ip[1], ip[2], byte(i)).String()
+			if !seen[host] && !ip.Equal(net.ParseIP(host)) {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+// printDiscovered lists discovered servers for --discover.
+func printDiscovered(servers []DiscoveredServer) {
+	if len(servers) == 0 {
+		fmt.Println("No fldigi XML-RPC servers found")
+		return
+	}
+	for _, s := range servers {
+		fmt.Printf("%s:%d\tfldigi %s\n", s.Host, s.Port, s.Version)
+	}
+}
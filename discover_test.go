@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestProbeFldigi(t *testing.T) {
+	fldigi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0"?><methodResponse><params><param><value>4.1.23</value></param></params></methodResponse>`)
+	}))
+	defer fldigi.Close()
+
+	_, p, _ := net.SplitHostPort(fldigi.Listener.Addr().String())
+	port, _ := strconv.Atoi(p)
+
+	server, ok := probeFldigi("127.0.0.1", port, time.Second)
+	if !ok || server.Version != "4.1.23" || server.Port != port {
+		t.Errorf("probeFldigi() = %+v, %v", server, ok)
+	}
+
+	// An open port that doesn't speak XML-RPC isn't reported.
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	_, p, _ = net.SplitHostPort(other.Listener.Addr().String())
+	port, _ = strconv.Atoi(p)
+	if _, ok := probeFldigi("127.0.0.1", port, time.Second); ok {
+		t.Error("non-fldigi server reported")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dryRun, set with --dry-run, reports the commands, webhooks, MQTT messages,
+// notifications and rig changes that automation would make instead of
+// making them. Polling and event detection run as normal.
+var dryRun bool
+
+// dryRunf reports an action skipped because of --dry-run.
+func dryRunf(format string, args ...interface{}) {
+	fmt.Printf(T("Dry run: ")+format+"\n", args...)
+}
+
+// quoteArgs formats a command line for a dry-run report.
+func quoteArgs(command string, args []string) string {
+	parts := []string{command}
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'\\$") {
+			a = strconv.Quote(a)
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
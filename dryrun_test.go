@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRunSkipsOutputs(t *testing.T) {
+	defer func() { dryRun = false }()
+	dryRun = true
+
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer server.Close()
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	ev := Event{Type: EventBandChange, Band: "20m"}
+	actions := []RuleAction{
+		{Command: "touch", Args: []string{marker}},
+		{Webhook: server.URL},
+		{MQTT: &MQTTMessage{Broker: "127.0.0.1:1", Topic: "rig/band", Payload: "{band}"}},
+	}
+	for _, a := range actions {
+		if err := runRuleAction(a, ev); err != nil {
+			t.Errorf("runRuleAction(%+v) = %v", a, err)
+		}
+	}
+	if err := sendEmail(&EmailConfig{SMTP: "127.0.0.1:1", From: "a@example.com", To: []string{"b@example.com"}}, "subject", "body"); err != nil {
+		t.Errorf("sendEmail() = %v", err)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("command ran during a dry run")
+	}
+	if hits != 0 {
+		t.Errorf("webhook posted %d times during a dry run", hits)
+	}
+}
+
+func TestDryRunLeavesRigAlone(t *testing.T) {
+	defer func() { dryRun = false }()
+	dryRun = true
+
+	backend := &fakeBackend{name: "flrig"}
+	f := &Follower{backend: backend, offset: -1500}
+	f.Follow(14074000)
+	if len(backend.sets) != 0 {
+		t.Errorf("follower tuned %v during a dry run", backend.sets)
+	}
+
+	m, _ := newTestMonitor()
+	tx := &fakeTX{state: "TX"}
+	m.checkBandwidth, m.inhibit, m.currentMode = true, tx, "MFSK64"
+	m.checkSegmentBandwidth("20m", 14020000, m.lastChange)
+	if tx.aborted != 0 {
+		t.Error("transmission aborted during a dry run")
+	}
+}
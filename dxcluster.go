@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dxSpotRepeat is how long a spot of the same station on the same band is
+// suppressed for; clusters pass on every spotter's report of it.
+const dxSpotRepeat = 10 * time.Minute
+
+// dxSpotLine matches a cluster spot such as
+// "DX de W3LPL:     14025.0  K1ABC        CW 599 up 1         1234Z".
+var dxSpotLine = regexp.MustCompile(`^DX de ([A-Za-z0-9/#-]+):?\s+(\d+(?:\.\d+)?)\s+([A-Za-z0-9/]+)\s+(.*?)\s*(\d{4})Z`)
+
+// dxSpotModes are the modes recognized in spot comments.
+var dxSpotModes = map[string]bool{
+	"CW": true, "SSB": true, "USB": true, "LSB": true, "AM": true, "FM": true,
+	"FT8": true, "FT4": true, "JS8": true, "RTTY": true, "PSK": true, "PSK31": true,
+	"BPSK31": true, "PSK63": true, "OLIVIA": true, "MFSK": true, "SSTV": true,
+	"JT65": true, "JT9": true, "Q65": true, "MSK144": true, "DIGI": true, "DATA": true,
+}
+
+// DXCluster is a DX cluster telnet client that emits the spots on the
+// bands, and optionally in the modes, the station's radios are using.
+type DXCluster struct {
+	addr       string
+	call       string
+	filter     string // "mode", "band" or "none"
+	api        *API
+	dispatcher *Dispatcher
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewDXCluster(addr, call, filter string, api *API, dispatcher *Dispatcher) (*DXCluster, error) {
+	if call == "" {
+		return nil, fmt.Errorf("--dx-cluster-call is required with --dx-cluster")
+	}
+	if filter != "mode" && filter != "band" && filter != "none" {
+		return nil, fmt.Errorf("--dx-cluster-filter must be mode, band or none")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid --dx-cluster %q, want host:port", addr)
+	}
+	return &DXCluster{addr: addr, call: strings.ToUpper(call), filter: filter, api: api, dispatcher: dispatcher, seen: map[string]time.Time{}}, nil
+}
+
+// Run stays connected to the cluster, reconnecting with a growing delay
+// when the connection fails.
+func (c *DXCluster) Run() {
+	delay := 5 * time.Second
+	for {
+		start := time.Now()
+		err := c.session()
+		log.Printf("DX cluster %s: %v", c.addr, err)
+		if time.Since(start) > time.Minute {
+			delay = 5 * time.Second
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > 5*time.Minute {
+			delay = 5 * time.Minute
+		}
+	}
+}
+
+// session logs in and reads spots until the connection fails.
+func (c *DXCluster) session() error {
+	conn, err := net.DialTimeout(tcpNetwork(), c.addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Clusters prompt for a callsign without ending the line; wait for
+	// the prompt, or a few seconds if it never comes.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var greeting []byte
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		greeting = append(greeting, buf[:n]...)
+		lower := strings.ToLower(string(greeting))
+		if err != nil || strings.Contains(lower, "login") || strings.Contains(lower, "call") {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	if _, err := fmt.Fprintf(conn, "%s\r\n", c.call); err != nil {
+		return err
+	}
+	fmt.Printf("Connected to DX cluster %s as %s\n", c.addr, c.call)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if ev, ok := parseDXSpot(scanner.Text()); ok {
+			c.spot(ev, time.Now())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed")
+}
+
+// parseDXSpot parses a spot line into a dx-spot event. The mode is taken
+// from the comment or, failing that, the band plan segment's mode.
+func parseDXSpot(line string) (Event, bool) {
+	m := dxSpotLine.FindStringSubmatch(strings.TrimSpace(strings.ToValidUTF8(line, "")))
+	if m == nil {
+		return Event{}, false
+	}
+	khz, err := strconv.ParseFloat(m[2], 64)
+	if err != nil || khz <= 0 {
+		return Event{}, false
+	}
+	freq := khz * 1000
+	ev := Event{Type: EventDXSpot, Spotter: strings.ToUpper(m[1]), Freq: freq, Call: strings.ToUpper(m[3]), Comment: m[4], Band: frequencyToBand(freq)}
+	for _, word := range strings.Fields(strings.ToUpper(m[4])) {
+		if dxSpotModes[word] {
+			ev.Mode = word
+			break
+		}
+	}
+	if ev.Mode == "" {
+		if seg, ok := findSegment(freq); ok {
+			ev.Mode = seg.Mode
+		}
+	}
+	return ev, ev.Band != "unknown"
+}
+
+// modeClass groups modes into cw, phone and data; "" for an unknown mode.
+func modeClass(mode string) string {
+	switch mode = strings.ToUpper(mode); mode {
+	case "":
+		return ""
+	case "CW", "CWR", "CW-R", "CW-U", "CW-L":
+		return "cw"
+	case "SSB", "USB", "LSB", "AM", "FM", "PHONE":
+		return "phone"
+	}
+	return "data"
+}
+
+// spot emits ev unless it is filtered out or a repeat. Spots in the
+// current band are tagged with the radio using it.
+func (c *DXCluster) spot(ev Event, now time.Time) {
+	if c.filter != "none" {
+		matched := false
+		for _, m := range c.api.monitors {
+			s := m.Status()
+			if s.Band != ev.Band || s.Standby {
+				continue
+			}
+			spotClass, radioClass := modeClass(ev.Mode), modeClass(s.Mode)
+			if c.filter == "mode" && spotClass != "" && radioClass != "" && spotClass != radioClass {
+				continue
+			}
+			ev.Radio = s.Radio
+			matched = true
+			break
+		}
+		if !matched {
+			return
+		}
+	}
+
+	key := ev.Call + " " + ev.Band
+	c.mu.Lock()
+	for k, t := range c.seen {
+		if now.Sub(t) >= dxSpotRepeat {
+			delete(c.seen, k)
+		}
+	}
+	_, repeat := c.seen[key]
+	if !repeat {
+		c.seen[key] = now
+	}
+	c.mu.Unlock()
+	if repeat {
+		return
+	}
+
+	ev.Time = now
+	fmt.Printf("DX spot: %s on %.1f kHz %s (de %s) %s\n", ev.Call, ev.Freq/1000, ev.Mode, ev.Spotter, ev.Comment)
+	c.dispatcher.Emit(ev)
+}
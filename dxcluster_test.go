@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDXSpot(t *testing.T) {
+	ev, ok := parseDXSpot("DX de W3LPL:     14025.0  k1abc        CW 599 up 1                 1234Z")
+	if !ok || ev.Call != "K1ABC" || ev.Spotter != "W3LPL" || ev.Freq != 14025000 || ev.Band != "20m" || ev.Mode != "CW" || ev.Comment != "CW 599 up 1" {
+		t.Errorf("parseDXSpot() = %+v, %v", ev, ok)
+	}
+	// Without a mode in the comment, the segment's mode is used.
+	if ev, ok := parseDXSpot("DX de DL1ABC-#:   7074.0  JA1XYZ       -12 dB                     0815Z"); !ok || ev.Mode != "FT8" || ev.Spotter != "DL1ABC-#" {
+		t.Errorf("parseDXSpot(FT8) = %+v, %v", ev, ok)
+	}
+	for _, line := range []string{"W3LPL de KB1ABC 14-Mar-2026 1234Z dxspider >", "DX de W3LPL:  116000.0  K1ABC  1234Z", "To ALL de K1ABC: hello"} {
+		if _, ok := parseDXSpot(line); ok {
+			t.Errorf("parseDXSpot(%q) succeeded", line)
+		}
+	}
+}
+
+func TestDXClusterFilter(t *testing.T) {
+	now := time.Now()
+	radio := &Monitor{radio: "A", status: MonitorStatus{Radio: "A", Time: now, Band: "20m", Mode: "CW"}}
+	sink := &captureSink{}
+	c, err := NewDXCluster("127.0.0.1:7300", "n0call", "mode", &API{monitors: []*Monitor{radio}}, NewDispatcher(NewMetrics(), sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spot := range []Event{
+		{Call: "K1ABC", Band: "20m", Mode: "CW"},
+		{Call: "K1ABC", Band: "20m", Mode: "CW"}, // repeat
+		{Call: "K2ABC", Band: "20m", Mode: "FT8"},
+		{Call: "K3ABC", Band: "40m", Mode: "CW"},
+		{Call: "K4ABC", Band: "20m"},
+	} {
+		spot.Type = EventDXSpot
+		c.spot(spot, now)
+	}
+	var calls []string
+	for _, ev := range sink.events {
+		calls = append(calls, ev.Radio+":"+ev.Call)
+	}
+	if strings.Join(calls, " ") != "A:K1ABC A:K4ABC" {
+		t.Errorf("spots = %v", calls)
+	}
+	c.spot(Event{Type: EventDXSpot, Call: "K1ABC", Band: "20m", Mode: "CW"}, now.Add(dxSpotRepeat))
+	if len(sink.events) != 3 {
+		t.Errorf("a spot wasn't reported again after %v", dxSpotRepeat)
+	}
+}
+
+func TestDXClusterLogin(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("Welcome to the test cluster\r\nlogin: "))
+		call, _ := bufio.NewReader(conn).ReadString('\n')
+		got <- call
+		conn.Write([]byte("DX de W3LPL:     14025.0  K1ABC        CW                          1234Z\r\n"))
+	}()
+
+	sink := &captureSink{}
+	c, err := NewDXCluster(ln.Addr().String(), "n0call", "none", &API{}, NewDispatcher(NewMetrics(), sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.session()
+	if call := <-got; call != "N0CALL\r\n" {
+		t.Errorf("logged in with %q", call)
+	}
+	if len(sink.events) != 1 || sink.events[0].Call != "K1ABC" {
+		t.Errorf("events = %+v", sink.events)
+	}
+}
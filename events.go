@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"fldigi-cmd/sdk"
+)
+
+// Event types emitted by the monitor.
+const (
+	EventInitialBand      = sdk.EventInitialBand
+	EventBandChange       = sdk.EventBandChange
+	EventSegmentChange    = sdk.EventSegmentChange
+	EventPrivilegeWarning = sdk.EventPrivilegeWarning
+	EventBandwidthWarning = sdk.EventBandwidthWarning
+	EventRuleFired        = sdk.EventRuleFired
+	EventWatch            = sdk.EventWatch
+	EventRPCCall          = sdk.EventRPCCall
+	EventBackendChange    = sdk.EventBackendChange
+	EventVerifyFailed     = sdk.EventVerifyFailed
+	EventConnected        = sdk.EventConnected
+	EventDisconnected     = sdk.EventDisconnected
+	EventLocationChanged  = sdk.EventLocationChanged
+	EventRegionDetected   = sdk.EventRegionDetected
+	EventRegionChanged    = sdk.EventRegionChanged
+	EventExchangeChanged  = sdk.EventExchangeChanged
+	EventHookTimeout      = sdk.EventHookTimeout
+	EventHookFailed       = sdk.EventHookFailed
+	EventShutdown         = sdk.EventShutdown
+	EventOutOfBand        = sdk.EventOutOfBand
+	EventModeChange       = sdk.EventModeChange
+	EventQSOLogged        = sdk.EventQSOLogged
+	EventDXSpot           = sdk.EventDXSpot
+	EventTXStart          = sdk.EventTXStart
+	EventTXStop           = sdk.EventTXStop
+	EventScheduleFired    = sdk.EventScheduleFired
+	EventHopStart         = sdk.EventHopStart
+	EventHopDone          = sdk.EventHopDone
+	EventSplitWarning     = sdk.EventSplitWarning
+)
+
+// Event describes something the monitor observed. It is defined in the sdk
+// package so event consumers share the same type.
+type Event = sdk.Event
+
+// Sink is an output that reacts to events.
+type Sink interface {
+	Name() string
+	// Wants reports whether the sink acts on the event.
+	Wants(ev Event) bool
+	Handle(ev Event) error
+}
+
+// Dispatcher delivers events to every registered sink. Events are delivered
+// one at a time so sinks need not be safe for concurrent use; events emitted
+// during delivery, e.g. by a sink, are queued and delivered afterwards.
+type Dispatcher struct {
+	mu          sync.Mutex
+	sinks       []Sink
+	metrics     *Metrics
+	queue       []Event
+	dispatching bool
+
+	// exchange, if set, is attached to every event.
+	exchange *Exchange
+}
+
+func NewDispatcher(metrics *Metrics, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{sinks: sinks, metrics: metrics}
+}
+
+func (d *Dispatcher) Add(s Sink) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sinks = append(d.sinks, s)
+}
+
+// Wanting returns the names of the sinks that act on ev.
+func (d *Dispatcher) Wanting(ev Event) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := []string{}
+	for _, s := range d.sinks {
+		if s.Wants(ev) {
+			names = append(names, s.Name())
+		}
+	}
+	return names
+}
+
+func (d *Dispatcher) Emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Exchange == nil {
+		ev.Exchange = d.exchangeFields()
+	}
+
+	d.mu.Lock()
+	d.queue = append(d.queue, ev)
+	if d.dispatching {
+		d.mu.Unlock()
+		return
+	}
+
+	d.dispatching = true
+	for len(d.queue) > 0 {
+		ev := d.queue[0]
+		d.queue = d.queue[1:]
+		sinks := d.sinks
+		d.mu.Unlock()
+		d.deliver(ev, sinks)
+		d.mu.Lock()
+	}
+	d.dispatching = false
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) deliver(ev Event, sinks []Sink) {
+	d.metrics.Event(ev.Type)
+
+	for _, s := range sinks {
+		if !s.Wants(ev) {
+			continue
+		}
+		// Queued hooks are timed when a worker runs them.
+		if q, ok := s.(*queuedSink); ok {
+			q.queue.enqueue(q.Sink, ev)
+			continue
+		}
+		d.handle(s, ev)
+	}
+}
+
+// handle passes an event to a sink, recording how it went.
+func (d *Dispatcher) handle(s Sink, ev Event) {
+	start := time.Now()
+	err := s.Handle(ev)
+	d.metrics.Sink(s.Name(), err, time.Since(start))
+	if err != nil {
+		log.Printf("Error in %s sink: %v", s.Name(), err)
+	}
+	d.reportHookError(ev, err)
+}
+
+// reportHookError emits a hook-timeout event if err is a command run for ev
+// that was killed for running too long, or a hook-failed event if the
+// command failed. A hook run for either event that fails itself isn't
+// reported again, so a broken notification command can't loop.
+func (d *Dispatcher) reportHookError(ev Event, err error) {
+	if d == nil || err == nil || ev.Type == EventHookTimeout || ev.Type == EventHookFailed {
+		return
+	}
+	var timeout *hookTimeoutError
+	var failed *hookError
+	switch {
+	case errors.As(err, &timeout):
+		d.Emit(Event{Type: EventHookTimeout, Radio: ev.Radio, Rule: ev.Rule, Command: timeout.command, Error: err.Error()})
+	case errors.As(err, &failed):
+		d.Emit(Event{Type: EventHookFailed, Radio: ev.Radio, Rule: ev.Rule, Command: failed.command, ExitCode: failed.exitCode, Output: failed.output, Error: err.Error()})
+	}
+}
+
+// commandSink runs an external command for one event type, passing the new
+// band, or the new segment for segment changes. With shell set, command is
+// instead a shell command line with event placeholders such as {band}. With
+// initial set it also runs for the band detected at startup.
+type commandSink struct {
+	name    string
+	command string
+	event   string
+	shell   bool
+	initial bool
+}
+
+func (s *commandSink) Name() string { return s.name }
+
+func (s *commandSink) Wants(ev Event) bool {
+	if s.initial && ev.Type == EventInitialBand {
+		return true
+	}
+	return ev.Type == s.event && hookWants(ev)
+}
+
+// hookWants filters out events of a hook's type that it shouldn't run for:
+// leaving every segment, and the first connection at startup, which isn't a
+// restored one.
+func hookWants(ev Event) bool {
+	switch ev.Type {
+	case EventSegmentChange:
+		return ev.Segment != ""
+	case EventConnected:
+		return ev.Downtime > 0
+	}
+	return true
+}
+
+// Handle runs the command, retrying up to hookRetries times if it fails.
+// A command killed for running too long isn't retried.
+func (s *commandSink) Handle(ev Event) error {
+	command, args, env := s.command, commandArgs(ev), commandEnv(ev)
+	if s.shell {
+		var values []string
+		var err error
+		if command, args, values, err = shellCommand(s.command, ev); err != nil {
+			return fmt.Errorf("%s: %v", s.name, err)
+		}
+		env = append(env, values...)
+	}
+	var input []byte
+	if hookStdinJSON {
+		input, _ = json.Marshal(ev)
+		input = append(input, '\n')
+	}
+	backoff := hookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := runExternalCommandInput(env, input, command, args...)
+		var failed *hookError
+		if !errors.As(err, &failed) || attempt >= hookRetries {
+			return err
+		}
+		log.Printf("%s failed (%v); retrying in %v", s.name, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// commandEnv returns extra environment variables for an external command:
+// FLDIGI_CMD_RADIO is set to the radio label when several are monitored,
+// and FLDIGI_CMD_EXCHANGE_<FIELD> to each exchange field.
+func commandEnv(ev Event) []string {
+	var env []string
+	if ev.Radio != "" {
+		env = append(env, "FLDIGI_CMD_RADIO="+ev.Radio)
+	}
+	for k, v := range ev.Exchange {
+		env = append(env, "FLDIGI_CMD_EXCHANGE_"+strings.ToUpper(k)+"="+v)
+	}
+	return env
+}
+
+// expandTemplateEnv expands the placeholders in a command line to
+// references, written by ref, to environment variables FLDIGI_CMD_VALUE_1,
+// FLDIGI_CMD_VALUE_2 and so on holding their values, so that the shell never
+// parses a value. An empty value is written as "". A value with control
+// characters, such as a line break, is refused.
+func expandTemplateEnv(line string, ev Event, ref func(name string) string) (string, []string, error) {
+	var env []string
+	var err error
+	line = expandTemplateQuoted(line, ev, func(v string) string {
+		if strings.IndexFunc(v, unicode.IsControl) >= 0 {
+			err = fmt.Errorf("refusing to pass %q, which has control characters, to the shell", v)
+		}
+		if v == "" {
+			return `""`
+		}
+		name := "FLDIGI_CMD_VALUE_" + strconv.Itoa(len(env)+1)
+		env = append(env, name+"="+v)
+		return ref(name)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return line, env, nil
+}
+
+// commandArgs returns the arguments passed to an external command for an
+// event.
+func commandArgs(ev Event) []string {
+	switch ev.Type {
+	case EventSegmentChange:
+		if ev.SegmentMode != "" {
+			return []string{ev.Segment, ev.SegmentMode}
+		}
+		return []string{ev.Segment}
+	case EventBandwidthWarning:
+		return []string{ev.Segment, ev.Mode}
+	case EventPrivilegeWarning:
+		return []string{ev.Band, strconv.FormatFloat(ev.Freq, 'f', 0, 64)}
+	case EventDisconnected:
+		return []string{ev.Backend, ev.Error}
+	case EventConnected:
+		return []string{ev.Backend, strconv.FormatFloat(ev.Downtime, 'f', 0, 64)}
+	case EventOutOfBand:
+		return []string{strconv.FormatFloat(ev.Freq, 'f', 0, 64), ev.PrevBand}
+	case EventHopStart, EventHopDone:
+		return []string{ev.Band, strconv.FormatFloat(ev.Freq, 'f', 0, 64), ev.Mode}
+	case EventSplitWarning:
+		return []string{ev.TXBand, strconv.FormatFloat(ev.TXFreq, 'f', 0, 64), ev.Band}
+	default:
+		return []string{ev.Band}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDispatcherQueuesNestedEvents(t *testing.T) {
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics())
+	dispatcher.Add(&emittingSink{dispatcher: dispatcher})
+	dispatcher.Add(sink)
+
+	dispatcher.Emit(Event{Type: EventBandChange})
+
+	got := sink.types()
+	if len(got) != 2 || got[0] != EventBandChange || got[1] != EventVerifyFailed {
+		t.Errorf("events = %v", got)
+	}
+}
+
+// emittingSink emits a verify-failed event while handling a band change, as
+// a script retuning the rig might.
+type emittingSink struct {
+	dispatcher *Dispatcher
+}
+
+func (s *emittingSink) Name() string        { return "emitting" }
+func (s *emittingSink) Wants(ev Event) bool { return ev.Type == EventBandChange }
+func (s *emittingSink) Handle(ev Event) error {
+	s.dispatcher.Emit(Event{Type: EventVerifyFailed})
+	return nil
+}
+
+func TestHookTimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no process groups")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+	hook := filepath.Join(dir, "hang.sh")
+	os.WriteFile(hook, []byte("#!/bin/sh\nsleep 30 &\necho $! > "+pidFile+"\nwait\n"), 0755)
+
+	defer func(timeout time.Duration) { hookTimeout = timeout }(hookTimeout)
+	hookTimeout = time.Second
+
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), &commandSink{name: "command", command: hook, event: EventBandChange}, sink)
+	start := time.Now()
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "20m", Radio: "A"})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("hook ran for %v", elapsed)
+	}
+
+	got := sink.events
+	if len(got) != 2 || got[1].Type != EventHookTimeout || got[1].Command != hook || got[1].Radio != "A" {
+		t.Fatalf("events = %+v", got)
+	}
+	data, _ := os.ReadFile(pidFile)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if pid == 0 {
+		t.Fatal("hook didn't start its child process")
+	}
+	// The killed child stays a zombie until init reaps it, and may take a
+	// moment to get there.
+	p, _ := os.FindProcess(pid)
+	gone := func() bool {
+		stat, _ := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		return p.Signal(syscall.Signal(0)) != nil || strings.Contains(string(stat), ") Z ")
+	}
+	for deadline := time.Now().Add(2 * time.Second); !gone() && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+	}
+	if !gone() {
+		p.Kill()
+		t.Errorf("child process %d left running", pid)
+	}
+}
+
+func TestHookRetriesThenReportsFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script hook")
+	}
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	hook := filepath.Join(dir, "switch.sh")
+	os.WriteFile(hook, []byte("#!/bin/sh\necho run >> "+runs+"\necho \"controller unreachable for $1\" >&2\nexit 3\n"), 0755)
+
+	defer func(retries int, backoff time.Duration) { hookRetries, hookRetryBackoff = retries, backoff }(hookRetries, hookRetryBackoff)
+	hookRetries, hookRetryBackoff = 2, 10*time.Millisecond
+
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), &commandSink{name: "command", command: hook, event: EventBandChange}, sink)
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "40m", Radio: "A"})
+
+	data, _ := os.ReadFile(runs)
+	if n := strings.Count(string(data), "run"); n != 3 {
+		t.Errorf("hook ran %d times, want 3", n)
+	}
+	got := sink.events
+	if len(got) != 2 || got[1].Type != EventHookFailed {
+		t.Fatalf("events = %+v", got)
+	}
+	if ev := got[1]; ev.Command != hook || ev.ExitCode != 3 || ev.Output != "controller unreachable for 40m\n" || ev.Radio != "A" {
+		t.Errorf("hook-failed event = %+v", ev)
+	}
+}
+
+func TestHookRetrySucceeds(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script hook")
+	}
+	dir := t.TempDir()
+	flag := filepath.Join(dir, "tried")
+	hook := filepath.Join(dir, "switch.sh")
+	os.WriteFile(hook, []byte("#!/bin/sh\n[ -e "+flag+" ] && exit 0\ntouch "+flag+"\nexit 1\n"), 0755)
+
+	defer func(retries int, backoff time.Duration) { hookRetries, hookRetryBackoff = retries, backoff }(hookRetries, hookRetryBackoff)
+	hookRetries, hookRetryBackoff = 1, 10*time.Millisecond
+
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), &commandSink{name: "command", command: hook, event: EventBandChange}, sink)
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "40m"})
+	if len(sink.events) != 1 {
+		t.Errorf("events = %+v, want no hook-failed", sink.events)
+	}
+}
+
+func TestShellCommandQuotesValues(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX shell quoting")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	line := "printf '%s|%s|%s\\n' {band} {radio} {freq_mhz} > " + shellQuote(out) + " && echo done >> " + shellQuote(out)
+
+	sink := &commandSink{name: "command", command: line, event: EventBandChange, shell: true}
+	radio := `it's $(touch ` + filepath.Join(dir, "pwned") + `) "A" %PATH% ^&` + "\n; touch " + filepath.Join(dir, "pwned")
+	if err := sink.Handle(Event{Type: EventBandChange, Band: "20m", Radio: radio, Freq: 14074000}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if want := "20m|" + radio + "|14.074000\ndone\n"; string(data) != want {
+		t.Errorf("output = %q, want %q", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Error("substituted value was run by the shell")
+	}
+}
+
+func TestExpandTemplateEnvKeepsValuesOffTheLine(t *testing.T) {
+	ref := func(name string) string { return `"!` + name + `!"` }
+	hostile := `A" & calc & echo "%PATH% ^& !x!`
+	line, env, err := expandTemplateEnv("tool {band} {radio} {mode} > log.txt", Event{Band: "20m", Radio: hostile}, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `tool "!FLDIGI_CMD_VALUE_1!" "!FLDIGI_CMD_VALUE_2!" "" > log.txt`; line != want {
+		t.Errorf("line = %q, want %q", line, want)
+	}
+	if want := []string{"FLDIGI_CMD_VALUE_1=20m", "FLDIGI_CMD_VALUE_2=" + hostile}; !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %q, want %q", env, want)
+	}
+
+	for _, radio := range []string{"A\r\nshutdown /s", "A\x00", "A\x1b[2J"} {
+		if _, _, err := expandTemplateEnv("tool {radio}", Event{Radio: radio}, ref); err == nil {
+			t.Errorf("expandTemplateEnv() passed %q", radio)
+		}
+	}
+}
+
+func TestHookStdinJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script hook")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "event.json")
+	hook := filepath.Join(dir, "hook.sh")
+	os.WriteFile(hook, []byte("#!/bin/sh\ncat > "+out+"\n"), 0755)
+
+	defer func(v bool) { hookStdinJSON = v }(hookStdinJSON)
+	hookStdinJSON = true
+
+	sink := &commandSink{name: "command", command: hook, event: EventBandChange}
+	when := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := sink.Handle(Event{Type: EventBandChange, Time: when, Radio: "A", Band: "20m", PrevBand: "40m", Freq: 14074000, Mode: "USB"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	var got Event
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("stdin %q: %v", data, err)
+	}
+	if got.Type != EventBandChange || !got.Time.Equal(when) || got.Radio != "A" || got.Band != "20m" || got.PrevBand != "40m" || got.Freq != 14074000 || got.Mode != "USB" {
+		t.Errorf("stdin event = %+v", got)
+	}
+}
+
+func TestCommandSinkInitialBand(t *testing.T) {
+	initial := Event{Type: EventInitialBand, Band: "20m"}
+	if (&commandSink{event: EventBandChange}).Wants(initial) {
+		t.Error("band change hook wants the initial band by default")
+	}
+	if !(&commandSink{event: EventBandChange, initial: true}).Wants(initial) {
+		t.Error("band change hook with initial set doesn't want the initial band")
+	}
+	if (&commandSink{event: EventSegmentChange, initial: true}).Wants(Event{Type: EventBandChange, Band: "20m"}) {
+		t.Error("segment hook wants band changes")
+	}
+}
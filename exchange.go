@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Exchange is the operator's contest exchange context, such as the county
+// a QSO party rover is operating from, set on the fly with
+// `fldigi-cmd exchange set`. It is attached to every event and kept in a
+// file so it survives restarts.
+type Exchange struct {
+	// path is the JSON file the fields are saved to; dir, if set, gets one
+	// text file per field for fldigi's <FILE:...> macro to insert.
+	path       string
+	dir        string
+	dispatcher *Dispatcher
+
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+// exchangeKey matches the field names an exchange may use, e.g. county.
+var exchangeKey = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// NewExchange loads the exchange saved at path, if there is one.
+func NewExchange(path, dir string, dispatcher *Dispatcher) (*Exchange, error) {
+	x := &Exchange{path: path, dir: dir, dispatcher: dispatcher, fields: map[string]string{}}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, &x.fields); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read exchange: %v", err)
+		}
+	}
+	if err := x.writeMacros(nil); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Fields returns a copy of the exchange, or nil if it is empty.
+func (x *Exchange) Fields() map[string]string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.copyFields()
+}
+
+func (x *Exchange) copyFields() map[string]string {
+	if len(x.fields) == 0 {
+		return nil
+	}
+	fields := make(map[string]string, len(x.fields))
+	for k, v := range x.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// Update sets the given fields, removing those set to "", saves the
+// exchange and emits an exchange-changed event.
+func (x *Exchange) Update(set map[string]string) error {
+	for k := range set {
+		if !exchangeKey.MatchString(k) {
+			return fmt.Errorf("invalid exchange field %q", k)
+		}
+	}
+
+	x.mu.Lock()
+	var removed []string
+	for k, v := range set {
+		if v == "" {
+			if _, ok := x.fields[k]; ok {
+				removed = append(removed, k)
+			}
+			delete(x.fields, k)
+		} else {
+			x.fields[k] = v
+		}
+	}
+	fields := x.copyFields()
+	err := x.save(fields, removed)
+	x.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if x.dispatcher != nil {
+		x.dispatcher.Emit(Event{Type: EventExchangeChanged, Exchange: fields})
+	}
+	return nil
+}
+
+// Clear removes every field.
+func (x *Exchange) Clear() error {
+	set := map[string]string{}
+	for k := range x.Fields() {
+		set[k] = ""
+	}
+	return x.Update(set)
+}
+
+func (x *Exchange) save(fields map[string]string, removed []string) error {
+	if x.path != "" {
+		data, err := json.MarshalIndent(fields, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(x.path, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to save exchange: %v", err)
+		}
+	}
+	return x.writeMacros(removed)
+}
+
+// writeMacros writes each field to dir/<field>.txt, without a trailing
+// newline so the macro inserts just the value, and removes the files of
+// removed fields.
+func (x *Exchange) writeMacros(removed []string) error {
+	if x.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(x.dir, 0755); err != nil {
+		return err
+	}
+	for k, v := range x.fields {
+		if err := os.WriteFile(filepath.Join(x.dir, k+".txt"), []byte(v), 0644); err != nil {
+			return fmt.Errorf("failed to write exchange macro: %v", err)
+		}
+	}
+	for _, k := range removed {
+		os.Remove(filepath.Join(x.dir, k+".txt"))
+	}
+	return nil
+}
+
+// exchangeFields returns the current exchange for stamping on an event.
+func (d *Dispatcher) exchangeFields() map[string]string {
+	if d == nil || d.exchange == nil {
+		return nil
+	}
+	return d.exchange.Fields()
+}
+
+// formatExchange formats the fields as key=value pairs in key order.
+func formatExchange(fields map[string]string) string {
+	var pairs []string
+	for _, k := range sortedKeys(fields) {
+		pairs = append(pairs, k+"="+fields[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// handleExchange returns the exchange; POST /exchange?county=ABC sets
+// fields (an empty value removes one) and DELETE clears it.
+func (a *API) handleExchange(w http.ResponseWriter, r *http.Request) {
+	if a.exchange == nil {
+		http.Error(w, "exchange context is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		set := map[string]string{}
+		for k, v := range r.URL.Query() {
+			set[k] = v[len(v)-1]
+		}
+		err = a.exchange.Update(set)
+	case http.MethodDelete:
+		err = a.exchange.Clear()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := a.exchange.Fields()
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	writeJSON(w, fields)
+}
+
+// runExchange implements `fldigi-cmd exchange [set k=v...|unset k...|clear]`.
+func runExchange(args []string) int {
+	fs := flag.NewFlagSet("exchange", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	fs.Parse(args)
+
+	method, query := http.MethodGet, url.Values{}
+	switch fs.Arg(0) {
+	case "":
+	case "set":
+		method = http.MethodPost
+		for _, arg := range fs.Args()[1:] {
+			k, v, ok := strings.Cut(arg, "=")
+			if !ok || v == "" {
+				fmt.Fprintf(os.Stderr, "Error: invalid field %q (want name=value)\n", arg)
+				return 2
+			}
+			query.Set(k, v)
+		}
+	case "unset":
+		method = http.MethodPost
+		for _, k := range fs.Args()[1:] {
+			query.Set(k, "")
+		}
+	case "clear":
+		method = http.MethodDelete
+	default:
+		fmt.Fprintf(os.Stderr, "Error: usage: fldigi-cmd exchange [set name=value...|unset name...|clear]\n")
+		return 2
+	}
+	if method == http.MethodPost && len(query) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no fields given\n")
+		return 2
+	}
+
+	var fields map[string]string
+	if err := connect().do(method, "/exchange", query, &fields); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(fields) == 0 {
+		fmt.Println("Exchange is empty")
+		return 0
+	}
+	fmt.Println(formatExchange(fields))
+	return 0
+}
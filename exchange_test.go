@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExchangeUpdate(t *testing.T) {
+	dir := t.TempDir()
+	path, macros := filepath.Join(dir, "exchange.json"), filepath.Join(dir, "macros")
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), sink)
+
+	x, err := NewExchange(path, macros, dispatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.exchange = x
+	if err := x.Update(map[string]string{"county": "MIDD/MONM", "section": "NNJ"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Update(map[string]string{"section": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Update(map[string]string{"County": "X"}); err == nil {
+		t.Error("accepted an invalid field name")
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(macros, "county.txt")); string(data) != "MIDD/MONM" {
+		t.Errorf("county.txt = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(macros, "section.txt")); err == nil {
+		t.Error("section.txt not removed with the field")
+	}
+
+	if len(sink.events) != 2 || sink.events[1].Type != EventExchangeChanged || formatExchange(sink.events[1].Exchange) != "county=MIDD/MONM" {
+		t.Fatalf("events = %+v", sink.events)
+	}
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "20m"})
+	if got := sink.events[2].Exchange["county"]; got != "MIDD/MONM" {
+		t.Errorf("band change exchange county = %q", got)
+	}
+
+	reloaded, err := NewExchange(path, "", nil)
+	if err != nil || formatExchange(reloaded.Fields()) != "county=MIDD/MONM" {
+		t.Errorf("reloaded exchange = %v, %v", reloaded.Fields(), err)
+	}
+}
+
+func TestExchangeInHooksAndTemplates(t *testing.T) {
+	ev := Event{Type: EventBandChange, Band: "40m", Exchange: map[string]string{"county": "ESSX", "section": "NNJ"}}
+
+	if got := expandTemplate("{band} {exchange.county} {exchange.missing}|{exchange}", ev); got != "40m ESSX |county=ESSX section=NNJ" {
+		t.Errorf("expandTemplate() = %q", got)
+	}
+	// Substituted text, here a logged comment, isn't expanded again.
+	ev.Call = "x;id"
+	ev.QSO = map[string]string{"COMMENT": "{call} {exchange.county}"}
+	if got := expandTemplateQuoted("logger {qso.comment} {call} {unknown}", ev, func(v string) string { return "'" + v + "'" }); got != "logger '{call} {exchange.county}' 'x;id' {unknown}" {
+		t.Errorf("expandTemplateQuoted() = %q", got)
+	}
+	env := strings.Join(commandEnv(ev), " ")
+	if !strings.Contains(env, "FLDIGI_CMD_EXCHANGE_COUNTY=ESSX") || !strings.Contains(env, "FLDIGI_CMD_EXCHANGE_SECTION=NNJ") {
+		t.Errorf("commandEnv() = %q", env)
+	}
+}
+
+func TestAPIExchange(t *testing.T) {
+	x, _ := NewExchange("", "", nil)
+	api := &API{exchange: x}
+
+	for _, tc := range []struct {
+		method, query, want string
+	}{
+		{http.MethodPost, "county=ESSX&section=NNJ", `{"county":"ESSX","section":"NNJ"}`},
+		{http.MethodPost, "section=", `{"county":"ESSX"}`},
+		{http.MethodGet, "", `{"county":"ESSX"}`},
+		{http.MethodDelete, "", `{}`},
+	} {
+		rec := httptest.NewRecorder()
+		api.handleExchange(rec, httptest.NewRequest(tc.method, "/exchange?"+tc.query, nil))
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != tc.want {
+			t.Errorf("%s /exchange?%s = %d %s, want %s", tc.method, tc.query, rec.Code, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// FailoverBackend polls a list of backends in priority order and uses the
+// first that responds, so the monitor keeps working when e.g. fldigi is
+// closed and flrig or rigctld is still running. It returns to a
+// higher-priority backend as soon as that responds again.
+type FailoverBackend struct {
+	backends []Backend
+
+	// onChange is called when the backend in use changes.
+	onChange func(from, to string)
+
+	mu     sync.Mutex
+	active int
+}
+
+// NewFailoverBackend creates the named backends, highest priority first,
+// all on host at their default ports.
+func NewFailoverBackend(names []string, host string) (*FailoverBackend, error) {
+	f := &FailoverBackend{active: -1}
+	for _, name := range names {
+		backend, err := NewBackend(strings.TrimSpace(name), host, 0)
+		if err != nil {
+			return nil, err
+		}
+		f.backends = append(f.backends, backend)
+	}
+	return f, nil
+}
+
+// Name returns the name of the backend in use, or of the primary before
+// any has responded.
+func (f *FailoverBackend) Name() string {
+	return f.current().Name()
+}
+
+func (f *FailoverBackend) current() Backend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active < 0 {
+		return f.backends[0]
+	}
+	return f.backends[f.active]
+}
+
+// GetFrequency reads the frequency from the first backend that responds and
+// makes it the backend in use.
+func (f *FailoverBackend) GetFrequency() (float64, error) {
+	var errs []string
+	for i, backend := range f.backends {
+		freq, err := backend.GetFrequency()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", backend.Name(), err))
+			continue
+		}
+		f.use(i)
+		return freq, nil
+	}
+	return 0, fmt.Errorf("no backend responded (%s)", strings.Join(errs, "; "))
+}
+
+func (f *FailoverBackend) use(i int) {
+	f.mu.Lock()
+	prev := f.active
+	f.active = i
+	f.mu.Unlock()
+
+	if prev == i || prev < 0 {
+		return
+	}
+
+	from, to := f.backends[prev].Name(), f.backends[i].Name()
+	log.Printf("Backend changed from %s to %s", from, to)
+	if f.onChange != nil {
+		f.onChange(from, to)
+	}
+}
+
+func (f *FailoverBackend) GetMode() (string, error) {
+	return f.current().GetMode()
+}
+
+func (f *FailoverBackend) SetFrequency(freq float64) error {
+	return f.current().SetFrequency(freq)
+}
+
+func (f *FailoverBackend) GetTRXState() (string, error) {
+	backend := f.current()
+	tx, ok := backend.(TXController)
+	if !ok {
+		return "", fmt.Errorf("the %s backend does not report the TX state", backend.Name())
+	}
+	return tx.GetTRXState()
+}
+
+func (f *FailoverBackend) AbortTX() error {
+	backend := f.current()
+	tx, ok := backend.(TXController)
+	if !ok {
+		return fmt.Errorf("the %s backend can't abort transmissions", backend.Name())
+	}
+	return tx.AbortTX()
+}
+
+func (f *FailoverBackend) SetMode(mode string) error {
+	backend := f.current()
+	m, ok := backend.(ModeSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't change the mode", backend.Name())
+	}
+	return m.SetMode(mode)
+}
+
+func (f *FailoverBackend) SendText(text string) error {
+	backend := f.current()
+	s, ok := backend.(TextSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't transmit text", backend.Name())
+	}
+	return s.SendText(text)
+}
+
+func (f *FailoverBackend) GetSplit() (bool, float64, error) {
+	backend := f.current()
+	s, ok := backend.(SplitReader)
+	if !ok {
+		return false, 0, fmt.Errorf("the %s backend does not report split operation", backend.Name())
+	}
+	return s.GetSplit()
+}
+
+func (f *FailoverBackend) SetPower(percent float64) error {
+	backend := f.current()
+	p, ok := backend.(PowerSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't set the power", backend.Name())
+	}
+	return p.SetPower(percent)
+}
+
+func (f *FailoverBackend) SetLevel(name string, value float64) error {
+	backend := f.current()
+	l, ok := backend.(LevelSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't set rig levels", backend.Name())
+	}
+	return l.SetLevel(name, value)
+}
+
+func (f *FailoverBackend) SendCAT(cmd string) error {
+	backend := f.current()
+	c, ok := backend.(CATSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't send CAT commands", backend.Name())
+	}
+	return c.SendCAT(cmd)
+}
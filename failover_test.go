@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFailoverBackend(t *testing.T) {
+	fldigi := &fakeBackend{name: "fldigi", freq: 14074000}
+	rigctld := &fakeBackend{name: "rigctld", freq: 14074010}
+	f := &FailoverBackend{backends: []Backend{fldigi, rigctld}, active: -1}
+
+	var changes []string
+	f.onChange = func(from, to string) { changes = append(changes, from+">"+to) }
+
+	if freq, err := f.GetFrequency(); err != nil || freq != 14074000 {
+		t.Fatalf("GetFrequency() = %v, %v", freq, err)
+	}
+
+	// fldigi is closed: rigctld takes over and receives set commands.
+	fldigi.err = errors.New("connection refused")
+	if freq, err := f.GetFrequency(); err != nil || freq != 14074010 {
+		t.Fatalf("GetFrequency() = %v, %v", freq, err)
+	}
+	if f.Name() != "rigctld" {
+		t.Errorf("Name() = %q", f.Name())
+	}
+	f.SetFrequency(7074000)
+	if len(rigctld.sets) != 1 || len(fldigi.sets) != 0 {
+		t.Errorf("set went to the wrong backend: fldigi %v, rigctld %v", fldigi.sets, rigctld.sets)
+	}
+
+	// Both down, then fldigi returns.
+	rigctld.err = errors.New("connection refused")
+	if _, err := f.GetFrequency(); err == nil {
+		t.Error("expected an error with every backend down")
+	}
+	fldigi.err = nil
+	f.GetFrequency()
+
+	if len(changes) != 2 || changes[0] != "fldigi>rigctld" || changes[1] != "rigctld>fldigi" {
+		t.Errorf("changes = %v", changes)
+	}
+
+	// The TX state is only available from backends that report it.
+	if _, err := f.GetTRXState(); err == nil {
+		t.Error("expected an error from a backend without TX control")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FindClearAction configures a find_clear rule action: the audio carrier
+// range searched, in Hz, and how long each spot is listened to.
+type FindClearAction struct {
+	MinCarrier float64 `json:"min_carrier,omitempty"` // default 500
+	MaxCarrier float64 `json:"max_carrier,omitempty"` // default 2500
+	Step       float64 `json:"step,omitempty"`        // default 100
+	Dwell      string  `json:"dwell,omitempty"`       // default "1s"
+}
+
+// clearRig is what finding a clear spot needs from fldigi.
+type clearRig interface {
+	occupancyRig
+	SetCarrier(freq float64) error
+}
+
+// ClearFinder moves fldigi's carrier to the least occupied spot in the
+// audio passband, staying within the current segment and, if set, the
+// operator's privileges.
+type ClearFinder struct {
+	rig        clearRig
+	privileges *Privileges
+	sleep      func(time.Duration)
+}
+
+func NewClearFinder(rig clearRig, privileges *Privileges) *ClearFinder {
+	return &ClearFinder{rig: rig, privileges: privileges, sleep: time.Sleep}
+}
+
+// settings returns the action's search range, step and dwell, filling in
+// the defaults, or an error if they can't make a search.
+func (a FindClearAction) settings() (min, max, step float64, dwell time.Duration, err error) {
+	min, max, step = a.MinCarrier, a.MaxCarrier, a.Step
+	if min == 0 {
+		min = 500
+	}
+	if max == 0 {
+		max = 2500
+	}
+	if step == 0 {
+		step = 100
+	}
+	if step < 0 {
+		return 0, 0, 0, 0, fmt.Errorf("invalid find_clear step %v, want more than 0", a.Step)
+	}
+	if min < 0 || min >= max {
+		return 0, 0, 0, 0, fmt.Errorf("invalid find_clear carrier range %v-%v Hz", min, max)
+	}
+	dwell = time.Second
+	if a.Dwell != "" {
+		if dwell, err = time.ParseDuration(a.Dwell); err != nil || dwell < 0 {
+			return 0, 0, 0, 0, fmt.Errorf("invalid find_clear dwell %q", a.Dwell)
+		}
+	}
+	return min, max, step, dwell, nil
+}
+
+// Find listens on each candidate carrier and settles on the quietest,
+// preferring the one closest to the current carrier on a tie. It returns
+// the chosen carrier.
+func (f *ClearFinder) Find(a FindClearAction) (float64, error) {
+	min, max, step, dwell, err := a.settings()
+	if err != nil {
+		return 0, err
+	}
+
+	if dryRun {
+		dryRunf("would look for a clear carrier between %.0f and %.0f Hz", min, max)
+		return f.rig.GetCarrier()
+	}
+
+	vfo, err := f.rig.GetFrequency()
+	if err != nil {
+		return 0, err
+	}
+	carrier, err := f.rig.GetCarrier()
+	if err != nil {
+		return 0, err
+	}
+	sideband, err := f.rig.GetSideband()
+	if err != nil {
+		return 0, err
+	}
+	seg, inSegment := findSegment(signalFrequency(vfo, carrier, sideband))
+
+	scanner := NewOccupancyScanner(f.rig, step, dwell)
+	scanner.sleep = f.sleep
+
+	best, found := carrier, false
+	var bestSlice Slice
+	for c := min; c <= max; c += step {
+		signal := signalFrequency(vfo, c, sideband)
+		if inSegment && (signal < seg.StartMHz*1000000 || signal >= seg.EndMHz*1000000) {
+			continue
+		}
+		if f.privileges != nil && !f.privileges.Allows(signal) {
+			continue
+		}
+
+		if err := f.rig.SetCarrier(c); err != nil {
+			return 0, err
+		}
+		var slice Slice
+		if err := scanner.measure(&slice); err != nil {
+			f.rig.SetCarrier(carrier)
+			return 0, err
+		}
+		if !found || quieter(slice, bestSlice) || (!quieter(bestSlice, slice) && math.Abs(c-carrier) < math.Abs(best-carrier)) {
+			best, bestSlice, found = c, slice, true
+		}
+	}
+
+	if !found {
+		f.rig.SetCarrier(carrier)
+		return 0, fmt.Errorf("no carrier between %.0f and %.0f Hz is within policy limits", min, max)
+	}
+	if err := f.rig.SetCarrier(best); err != nil {
+		return 0, err
+	}
+	fmt.Printf("find-clear: carrier %.0f -> %.0f Hz (%.4f MHz, quality %.0f, %d chars)\n",
+		carrier, best, signalFrequency(vfo, best, sideband)/1000000, bestSlice.Quality, bestSlice.Chars)
+	return best, nil
+}
+
+// quieter reports whether a is less occupied than b.
+func quieter(a, b Slice) bool {
+	if a.Quality != b.Quality {
+		return a.Quality < b.Quality
+	}
+	return a.Chars < b.Chars
+}
+
+// runAction runs rule actions, handling find_clear itself.
+func (f *ClearFinder) runAction(a RuleAction, ev Event) error {
+	if a.FindClear != nil {
+		_, err := f.Find(*a.FindClear)
+		return err
+	}
+	return runRuleAction(a, ev)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClearRig struct {
+	fakeOccupancyRig
+}
+
+func (r *fakeClearRig) SetCarrier(freq float64) error {
+	r.carrier = freq
+	return nil
+}
+
+func TestClearFinder(t *testing.T) {
+	// 14.0700-14.0720 is busy; the 20m-PSK segment ends at 14.073.
+	rig := &fakeClearRig{fakeOccupancyRig{
+		vfo: 14069000, carrier: 1500, sideband: "USB",
+		signals: [][2]float64{{14070000, 14072000}},
+		text:    [][2]float64{{14072000, 14072500}},
+	}}
+	f := NewClearFinder(rig, nil)
+	f.sleep = func(time.Duration) {}
+
+	carrier, err := f.Find(FindClearAction{MinCarrier: 1000, MaxCarrier: 4000, Step: 250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1000-2750 Hz land on the strong signal and 3000-3250 Hz decode text;
+	// 3500 and 3750 Hz are clear, and 4000 Hz would leave 20m-PSK.
+	if carrier != 3500 {
+		t.Errorf("carrier = %.0f; want 3500", carrier)
+	}
+	if rig.carrier != carrier {
+		t.Errorf("rig carrier = %.0f; want %.0f", rig.carrier, carrier)
+	}
+}
+
+func TestClearFinderPolicy(t *testing.T) {
+	rig := &fakeClearRig{fakeOccupancyRig{vfo: 14069000, carrier: 1500, sideband: "USB"}}
+	f := NewClearFinder(rig, &Privileges{Name: "test", Ranges: []BandRange{{StartMHz: 14.0, EndMHz: 14.0705}}})
+	f.sleep = func(time.Duration) {}
+
+	carrier, err := f.Find(FindClearAction{MinCarrier: 500, MaxCarrier: 2500, Step: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Everything is quiet, so the finder stays nearest to 1500 Hz within the
+	// privileges: 14.0705 MHz, the top of the range.
+	if carrier != 1500 {
+		t.Errorf("carrier = %.0f; want 1500", carrier)
+	}
+
+	f.privileges = &Privileges{Name: "none", Ranges: []BandRange{{StartMHz: 7, EndMHz: 7.3}}}
+	if _, err := f.Find(FindClearAction{}); err == nil {
+		t.Error("Find() outside privileges succeeded")
+	}
+	if rig.carrier != 1500 {
+		t.Errorf("carrier left at %.0f after failing", rig.carrier)
+	}
+}
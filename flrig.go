@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FlrigClient talks to flrig's XML-RPC server, which uses different method
+// names from fldigi but the same transport.
+type FlrigClient struct {
+	rpc *FldigiClient
+}
+
+func NewFlrigClient(host string, port int) *FlrigClient {
+	return &FlrigClient{rpc: NewFldigiClient(host, port)}
+}
+
+// NewFlrigClientURL creates a client for flrig at an endpoint URL, as
+// accepted by NewFldigiClientURL.
+func NewFlrigClientURL(endpoint string) (*FlrigClient, error) {
+	rpc, err := NewFldigiClientURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &FlrigClient{rpc: rpc}, nil
+}
+
+func (c *FlrigClient) Name() string { return "flrig" }
+
+func (c *FlrigClient) GetFrequency() (float64, error) {
+	return c.vfo("rig.get_vfoA")
+}
+
+func (c *FlrigClient) vfo(method string) (float64, error) {
+	value, err := c.rpc.Call(method)
+	if err != nil {
+		return 0, err
+	}
+
+	freqStr := value.Text()
+	if freqStr == "" {
+		return 0, fmt.Errorf("empty frequency response")
+	}
+
+	freq, err := strconv.ParseFloat(freqStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse frequency '%s': %v", freqStr, err)
+	}
+	return freq, nil
+}
+
+// GetMode returns the rig mode as named by flrig, e.g. "USB-D".
+func (c *FlrigClient) GetMode() (string, error) {
+	value, err := c.rpc.Call("rig.get_mode")
+	if err != nil {
+		return "", err
+	}
+	return value.Text(), nil
+}
+
+func (c *FlrigClient) SetFrequency(freq float64) error {
+	_, err := c.rpc.Call("rig.set_vfoA", Value{Double: strconv.FormatFloat(freq, 'f', -1, 64)})
+	return err
+}
+
+// SetMode sets the rig's mode, such as "USB", as flrig names it.
+func (c *FlrigClient) SetMode(mode string) error {
+	_, err := c.rpc.Call("rig.set_mode", Value{String: mode})
+	return err
+}
+
+// GetTRXState maps flrig's PTT state to fldigi's "RX"/"TX" names.
+func (c *FlrigClient) GetTRXState() (string, error) {
+	value, err := c.rpc.Call("rig.get_ptt")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(value.Text()) == "1" {
+		return "TX", nil
+	}
+	return "RX", nil
+}
+
+func (c *FlrigClient) AbortTX() error {
+	_, err := c.rpc.Call("rig.set_ptt", Value{Int: "0"})
+	return err
+}
+
+// GetSplit reports flrig's split state, transmitting on VFO B when split.
+func (c *FlrigClient) GetSplit() (bool, float64, error) {
+	value, err := c.rpc.Call("rig.get_split")
+	if err != nil {
+		return false, 0, err
+	}
+	if strings.TrimSpace(value.Text()) != "1" {
+		return false, 0, nil
+	}
+	freq, err := c.vfo("rig.get_vfoB")
+	if err != nil {
+		return false, 0, err
+	}
+	return true, freq, nil
+}
+
+// maxPower returns the rig's full output in watts as flrig reports it.
+func (c *FlrigClient) maxPower() (float64, error) {
+	value, err := c.rpc.Call("rig.get_maxpwr")
+	if err != nil {
+		return 0, err
+	}
+	full, err := strconv.ParseFloat(strings.TrimSpace(value.Text()), 64)
+	if err != nil || full <= 0 {
+		return 0, fmt.Errorf("invalid maximum power '%s'", value.Text())
+	}
+	return full, nil
+}
+
+// SetPower sets the output power in watts, scaled from percent by the
+// rig's maximum.
+func (c *FlrigClient) SetPower(percent float64) error {
+	full, err := c.maxPower()
+	if err != nil {
+		return err
+	}
+	watts := int(math.Round(full * percent / 100))
+	_, err = c.rpc.Call("rig.set_power", Value{Int: strconv.Itoa(watts)})
+	return err
+}
+
+// GetPower reads the output power back as a percentage of the maximum.
+func (c *FlrigClient) GetPower() (float64, error) {
+	full, err := c.maxPower()
+	if err != nil {
+		return 0, err
+	}
+	value, err := c.rpc.Call("rig.get_power")
+	if err != nil {
+		return 0, err
+	}
+	watts, err := strconv.ParseFloat(strings.TrimSpace(value.Text()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid power '%s'", value.Text())
+	}
+	return watts / full * 100, nil
+}
+
+// SendCAT sends a raw CAT command string, in flrig's own notation, to the
+// rig.
+func (c *FlrigClient) SendCAT(cmd string) error {
+	_, err := c.rpc.Call("rig.cat_string", Value{String: cmd})
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlrigClient(t *testing.T) {
+	var calls []string
+	flrig := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, string(body))
+		var value string
+		switch {
+		case strings.Contains(string(body), "rig.get_vfoA"):
+			value = "<string>14074000</string>"
+		case strings.Contains(string(body), "rig.get_mode"):
+			value = "<string>USB-D</string>"
+		case strings.Contains(string(body), "rig.get_ptt"), strings.Contains(string(body), "rig.get_split"):
+			value = "<int>1</int>"
+		case strings.Contains(string(body), "rig.get_maxpwr"):
+			value = "<int>200</int>"
+		case strings.Contains(string(body), "rig.get_power"):
+			value = "<int>50</int>"
+		case strings.Contains(string(body), "rig.get_vfoB"):
+			value = "<string>7200000</string>"
+		default:
+			value = "<string></string>"
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?><methodResponse><params><param><value>%s</value></param></params></methodResponse>`, value)
+	}))
+	defer flrig.Close()
+
+	client := NewFlrigClient("127.0.0.1", 0)
+	client.rpc.url = flrig.URL
+
+	freq, err := client.GetFrequency()
+	if err != nil || freq != 14074000 {
+		t.Fatalf("GetFrequency() = %v, %v", freq, err)
+	}
+	if mode, err := client.GetMode(); err != nil || mode != "USB-D" {
+		t.Errorf("GetMode() = %q, %v", mode, err)
+	}
+	if state, err := client.GetTRXState(); err != nil || state != "TX" {
+		t.Errorf("GetTRXState() = %q, %v", state, err)
+	}
+	if split, tx, err := client.GetSplit(); err != nil || !split || tx != 7200000 {
+		t.Errorf("GetSplit() = %v, %v, %v", split, tx, err)
+	}
+	if err := client.SetFrequency(7074000); err != nil {
+		t.Fatal(err)
+	}
+	if last := calls[len(calls)-1]; !strings.Contains(last, "rig.set_vfoA") || !strings.Contains(last, "7074000") {
+		t.Errorf("unexpected set request %q", last)
+	}
+	if err := client.SetMode("CW"); err != nil {
+		t.Fatal(err)
+	}
+	if last := calls[len(calls)-1]; !strings.Contains(last, "rig.set_mode") || !strings.Contains(last, "<string>CW</string>") {
+		t.Errorf("unexpected mode request %q", last)
+	}
+	if err := client.SetPower(25); err != nil {
+		t.Fatal(err)
+	}
+	if last := calls[len(calls)-1]; !strings.Contains(last, "rig.set_power") || !strings.Contains(last, "<i4>50</i4>") {
+		t.Errorf("unexpected power request %q", last)
+	}
+	if power, err := client.GetPower(); err != nil || power != 25 {
+		t.Errorf("GetPower() = %v, %v", power, err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"math"
+)
+
+// Follower keeps a second receiver tuned to the primary rig's frequency
+// plus a fixed offset.
+type Follower struct {
+	backend Backend
+	offset  float64
+	last    float64
+}
+
+// NewFollower connects to the receiver at addr ("host" or "host:port")
+// using the named backend.
+func NewFollower(backendName, addr string, offset float64) (*Follower, error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := NewBackend(backendName, host, port)
+	if err != nil {
+		return nil, err
+	}
+	return &Follower{backend: backend, offset: offset}, nil
+}
+
+// Follow retunes the follower when the primary frequency has moved.
+func (f *Follower) Follow(freq float64) {
+	target := freq + f.offset
+	if math.Abs(target-f.last) < 1 {
+		return
+	}
+
+	if dryRun {
+		dryRunf("would tune the %s follower to %.0f Hz", f.backend.Name(), target)
+		f.last = target
+		return
+	}
+	if err := f.backend.SetFrequency(target); err != nil {
+		log.Printf("Error retuning %s follower: %v", f.backend.Name(), err)
+		return
+	}
+	f.last = target
+}
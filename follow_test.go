@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+type fakeBackend struct {
+	name string
+	freq float64
+	mode string
+	err  error
+	sets []float64
+}
+
+func (b *fakeBackend) Name() string                   { return b.name }
+func (b *fakeBackend) GetFrequency() (float64, error) { return b.freq, b.err }
+func (b *fakeBackend) GetMode() (string, error)       { return b.mode, b.err }
+func (b *fakeBackend) SetFrequency(freq float64) error {
+	b.sets = append(b.sets, freq)
+	b.freq = freq
+	return nil
+}
+
+func TestFollowerOffset(t *testing.T) {
+	backend := &fakeBackend{}
+	f := &Follower{backend: backend, offset: -1500}
+
+	f.Follow(14074000)
+	f.Follow(14074000) // unchanged: no retune
+	f.Follow(14075000)
+
+	if len(backend.sets) != 2 || backend.sets[0] != 14072500 || backend.sets[1] != 14073500 {
+		t.Errorf("follower tuned to %v; want [14072500 14073500]", backend.sets)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gpioRoot is the sysfs GPIO interface, available on the Raspberry Pi and
+// other Linux boards without any library.
+var gpioRoot = "/sys/class/gpio"
+
+// gpioChipBase returns the sysfs number of the first line of a GPIO chip,
+// which newer kernels no longer start at 0. chip is a label such as
+// "pinctrl-bcm2711" or a name such as "gpiochip512"; without one the only
+// chip, or else the only one labelled "pinctrl-*", the SoC's pins on a
+// Raspberry Pi, is used, since expanders and other chips can sit below them.
+func gpioChipBase(chip string) (int, error) {
+	dirs, err := filepath.Glob(filepath.Join(gpioRoot, "gpiochip*"))
+	if err != nil || len(dirs) == 0 {
+		return 0, fmt.Errorf("no GPIO chips in %s", gpioRoot)
+	}
+	var found []string
+	var labels []string
+	for _, dir := range dirs {
+		data, _ := os.ReadFile(filepath.Join(dir, "label"))
+		label := strings.TrimSpace(string(data))
+		labels = append(labels, fmt.Sprintf("%s (%s)", filepath.Base(dir), label))
+		switch {
+		case chip != "":
+			if chip == label || chip == filepath.Base(dir) {
+				found = append(found, dir)
+			}
+		case len(dirs) == 1 || strings.HasPrefix(label, "pinctrl-"):
+			found = append(found, dir)
+		}
+	}
+	switch {
+	case len(found) == 0 && chip != "":
+		return 0, fmt.Errorf("no GPIO chip %s, found %s", chip, strings.Join(labels, ", "))
+	case len(found) != 1:
+		return 0, fmt.Errorf("can't tell which GPIO chip to use, set --gpio-chip to one of %s", strings.Join(labels, ", "))
+	}
+	data, err := os.ReadFile(filepath.Join(found[0], "base"))
+	if err != nil {
+		return 0, err
+	}
+	base, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid base of GPIO chip %s: %v", filepath.Base(found[0]), err)
+	}
+	return base, nil
+}
+
+// exportGPIO makes a pin, numbered from base, an output through sysfs,
+// starting high or low. Setting the direction to "high" or "low" rather than
+// "out" switches the pin to its level without glitching through low first.
+func exportGPIO(base, pin int, high bool) error {
+	n := strconv.Itoa(base + pin)
+	dir := filepath.Join(gpioRoot, "gpio"+n)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.WriteFile(filepath.Join(gpioRoot, "export"), []byte(n), 0); err != nil {
+			return fmt.Errorf("failed to export GPIO %d: %v", pin, err)
+		}
+	}
+	direction := "low"
+	if high {
+		direction = "high"
+	}
+	// udev may take a moment to make a newly exported pin writable.
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.WriteFile(filepath.Join(dir, "direction"), []byte(direction), 0); err == nil {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("failed to make GPIO %d an output: %v", pin, err)
+}
+
+func writeGPIO(base, pin int, high bool) error {
+	value := "0"
+	if high {
+		value = "1"
+	}
+	path := filepath.Join(gpioRoot, "gpio"+strconv.Itoa(base+pin), "value")
+	if err := os.WriteFile(path, []byte(value), 0); err != nil {
+		return fmt.Errorf("failed to set GPIO %d: %v", pin, err)
+	}
+	return nil
+}
+
+// parseGPIOPins parses a comma-separated list of BCM pin numbers.
+func parseGPIOPins(list string) ([]int, error) {
+	var pins []int
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GPIO pin %q", p)
+		}
+		pins = append(pins, n)
+	}
+	return pins, nil
+}
+
+// GPIOSink switches relays on GPIO pins for the band of one radio, so a
+// Raspberry Pi can drive an antenna switch or filter board directly. With
+// bcd the pins, least significant first, carry the band's code; with
+// onehot each band raises its own pin.
+type GPIOSink struct {
+	radio     string
+	chip      string // label or name of the GPIO chip, "" to find it
+	pins      []int
+	bands     map[string][]int // pins active on each band
+	activeLow bool
+	// delay is the time between releasing one band's relays and operating
+	// the next band's, so a switch never connects two at once.
+	delay time.Duration
+
+	on    map[int]bool
+	write func(pin int, high bool) error
+	sleep func(time.Duration)
+}
+
+// NewGPIOSink maps bands to pins: in bcd mode pins carry each band's Yaesu
+// BCD code or its BAND=N code from specs; in onehot mode specs give each
+// band's pin as BAND=PIN and pins is unused.
+func NewGPIOSink(mode string, pins []int, specs []string, activeLow bool, delay time.Duration, radio string) (*GPIOSink, error) {
+	s := &GPIOSink{radio: radio, bands: map[string][]int{}, activeLow: activeLow, delay: delay, on: map[int]bool{}, sleep: time.Sleep}
+	values := map[string]int{}
+	if mode == "bcd" {
+		for band, code := range yaesuBCD {
+			values[band] = int(code)
+		}
+	}
+	for _, spec := range specs {
+		band, value, ok := strings.Cut(spec, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GPIO band %q, want BAND=N", spec)
+		}
+		if !isBand(band) {
+			return nil, fmt.Errorf("GPIO band %q: unknown band %s", spec, band)
+		}
+		values[band] = n
+	}
+
+	switch mode {
+	case "bcd":
+		if len(pins) == 0 {
+			return nil, fmt.Errorf("BCD GPIO output needs --gpio-pins")
+		}
+		s.pins = pins
+		for band, code := range values {
+			if code >= 1<<len(pins) {
+				return nil, fmt.Errorf("GPIO code %d for %s doesn't fit in %d pins", code, band, len(pins))
+			}
+			for bit, pin := range pins {
+				if code&(1<<bit) != 0 {
+					s.bands[band] = append(s.bands[band], pin)
+				}
+			}
+		}
+	case "onehot":
+		if len(values) == 0 {
+			return nil, fmt.Errorf("one-hot GPIO output needs a --gpio-band BAND=PIN for each band")
+		}
+		seen := map[int]bool{}
+		for band, pin := range values {
+			s.bands[band] = []int{pin}
+			if !seen[pin] {
+				seen[pin] = true
+				s.pins = append(s.pins, pin)
+			}
+		}
+		sort.Ints(s.pins)
+	default:
+		return nil, fmt.Errorf("unknown GPIO mode %q (want bcd or onehot)", mode)
+	}
+	return s, nil
+}
+
+// Open exports the pins through sysfs and releases every relay. Each pin
+// starts at its released level, so no relay operates, not even briefly.
+func (s *GPIOSink) Open() error {
+	base, err := gpioChipBase(s.chip)
+	if err != nil {
+		return err
+	}
+	for _, pin := range s.pins {
+		if err := exportGPIO(base, pin, s.activeLow); err != nil {
+			return err
+		}
+	}
+	s.write = func(pin int, high bool) error { return writeGPIO(base, pin, high) }
+	for _, pin := range s.pins {
+		if err := s.set(pin, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GPIOSink) Name() string { return "gpio" }
+
+func (s *GPIOSink) Wants(ev Event) bool {
+	switch ev.Type {
+	case EventInitialBand, EventBandChange, EventOutOfBand:
+		return ev.Radio == s.radio
+	}
+	return false
+}
+
+// Handle releases the relays of the previous band, waits for the switching
+// delay, then operates those of the new one. Outside every mapped band all
+// relays are released.
+func (s *GPIOSink) Handle(ev Event) error {
+	want := map[int]bool{}
+	if ev.Type != EventOutOfBand {
+		for _, pin := range s.bands[ev.Band] {
+			want[pin] = true
+		}
+	}
+	if dryRun {
+		dryRunf("would set GPIO pins %s for %s", s.describe(want), ev.Band)
+		return nil
+	}
+
+	released := false
+	for _, pin := range s.pins {
+		if s.on[pin] && !want[pin] {
+			if err := s.set(pin, false); err != nil {
+				return err
+			}
+			released = true
+		}
+	}
+	if released && s.delay > 0 {
+		s.sleep(s.delay)
+	}
+	for _, pin := range s.pins {
+		if want[pin] && !s.on[pin] {
+			if err := s.set(pin, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// set operates or releases the relay on pin.
+func (s *GPIOSink) set(pin int, on bool) error {
+	if err := s.write(pin, on != s.activeLow); err != nil {
+		return err
+	}
+	s.on[pin] = on
+	return nil
+}
+
+// describe lists the pins and whether each is to be on, e.g. "17=1 27=0".
+func (s *GPIOSink) describe(want map[int]bool) string {
+	var parts []string
+	for _, pin := range s.pins {
+		v := 0
+		if want[pin] {
+			v = 1
+		}
+		parts = append(parts, fmt.Sprintf("%d=%d", pin, v))
+	}
+	return strings.Join(parts, " ")
+}
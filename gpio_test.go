@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeGPIO is a sysfs GPIO tree whose pins, numbered from base 512, are
+// already exported.
+func fakeGPIO(t *testing.T, pins ...int) string {
+	t.Helper()
+	root := t.TempDir()
+	old := gpioRoot
+	gpioRoot = root
+	t.Cleanup(func() { gpioRoot = old })
+
+	os.MkdirAll(filepath.Join(root, "gpiochip512"), 0755)
+	os.WriteFile(filepath.Join(root, "gpiochip512", "base"), []byte("512\n"), 0644)
+	for _, pin := range pins {
+		os.MkdirAll(filepath.Join(root, "gpio"+strconv.Itoa(512+pin)), 0755)
+	}
+	return root
+}
+
+func gpioValue(root string, pin int) string {
+	data, _ := os.ReadFile(filepath.Join(root, "gpio"+strconv.Itoa(512+pin), "value"))
+	return string(data)
+}
+
+func TestGPIOSinkBCD(t *testing.T) {
+	root := fakeGPIO(t, 17, 27, 22, 23)
+	s, err := NewGPIOSink("bcd", []int{17, 27, 22, 23}, []string{"2m=11"}, false, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if dir, _ := os.ReadFile(filepath.Join(root, "gpio529", "direction")); string(dir) != "low" {
+		t.Errorf("direction = %q, want low", dir)
+	}
+
+	// 20m is BCD 5: pins 17 and 22.
+	if err := s.Handle(Event{Type: EventBandChange, Band: "20m"}); err != nil {
+		t.Fatal(err)
+	}
+	got := gpioValue(root, 17) + gpioValue(root, 27) + gpioValue(root, 22) + gpioValue(root, 23)
+	if got != "1010" {
+		t.Errorf("20m pins = %s, want 1010", got)
+	}
+	s.Handle(Event{Type: EventBandChange, Band: "2m"})
+	got = gpioValue(root, 17) + gpioValue(root, 27) + gpioValue(root, 22) + gpioValue(root, 23)
+	if got != "1101" {
+		t.Errorf("2m pins = %s, want 1101", got)
+	}
+
+	if pins, err := parseGPIOPins("17, 27,22"); err != nil || len(pins) != 3 || pins[1] != 27 {
+		t.Errorf("parseGPIOPins() = %v, %v", pins, err)
+	}
+	if _, err := parseGPIOPins("17,GPIO27"); err == nil {
+		t.Error("parseGPIOPins accepted a pin name")
+	}
+
+	for _, c := range []struct {
+		mode  string
+		pins  []int
+		specs []string
+	}{
+		{"bcd", nil, nil},
+		{"bcd", []int{17, 27}, nil}, // 6m's code 10 needs four pins
+		{"onehot", nil, nil},
+		{"gray", []int{17}, nil},
+		{"onehot", nil, []string{"11m=4"}},
+	} {
+		if _, err := NewGPIOSink(c.mode, c.pins, c.specs, false, 0, ""); err == nil {
+			t.Errorf("NewGPIOSink(%s, %v, %v) succeeded", c.mode, c.pins, c.specs)
+		}
+	}
+}
+
+func TestGPIOSinkOneHot(t *testing.T) {
+	root := fakeGPIO(t, 5, 6)
+	s, err := NewGPIOSink("onehot", nil, []string{"40m=5", "20m=6"}, true, 20*time.Millisecond, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept []time.Duration
+	s.sleep = func(d time.Duration) { slept = append(slept, d) }
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	// Active low: released relays are high, and start so.
+	for _, pin := range []string{"gpio517", "gpio518"} {
+		if dir, _ := os.ReadFile(filepath.Join(root, pin, "direction")); string(dir) != "high" {
+			t.Errorf("%s direction = %q, want high", pin, dir)
+		}
+	}
+	if gpioValue(root, 5) != "1" || gpioValue(root, 6) != "1" {
+		t.Errorf("pins after opening = %s%s, want 11", gpioValue(root, 5), gpioValue(root, 6))
+	}
+
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Radio: "A", Band: "40m"},
+		{Type: EventBandChange, Radio: "B", Band: "20m"},
+		{Type: EventBandChange, Radio: "A", Band: "20m"},
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if gpioValue(root, 5) != "1" || gpioValue(root, 6) != "0" {
+		t.Errorf("20m pins = %s%s, want 10", gpioValue(root, 5), gpioValue(root, 6))
+	}
+	if len(slept) != 1 {
+		t.Errorf("slept %v, want one switching delay", slept)
+	}
+
+	s.Handle(Event{Type: EventOutOfBand, Radio: "A"})
+	if gpioValue(root, 6) != "1" {
+		t.Error("relay still operated out of band")
+	}
+}
+
+func TestGPIOChipBase(t *testing.T) {
+	root := fakeGPIO(t)
+	os.WriteFile(filepath.Join(root, "gpiochip512", "label"), []byte("pinctrl-bcm2711\n"), 0644)
+	// An expander below the SoC's pins isn't picked for having the lowest base.
+	os.MkdirAll(filepath.Join(root, "gpiochip0"), 0755)
+	os.WriteFile(filepath.Join(root, "gpiochip0", "base"), []byte("0\n"), 0644)
+	os.WriteFile(filepath.Join(root, "gpiochip0", "label"), []byte("mcp23017\n"), 0644)
+
+	for chip, want := range map[string]int{"": 512, "mcp23017": 0, "gpiochip512": 512} {
+		if base, err := gpioChipBase(chip); err != nil || base != want {
+			t.Errorf("gpioChipBase(%q) = %d, %v; want %d", chip, base, err, want)
+		}
+	}
+	if _, err := gpioChipBase("pinctrl-rp1"); err == nil {
+		t.Error("gpioChipBase found a missing chip")
+	}
+
+	// With no pinctrl chip there's no telling which to use.
+	os.WriteFile(filepath.Join(root, "gpiochip512", "label"), []byte("pca9555\n"), 0644)
+	if _, err := gpioChipBase(""); err == nil {
+		t.Error("gpioChipBase picked one of two unknown chips")
+	}
+}
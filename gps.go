@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"fldigi-cmd/sdk"
+)
+
+// GPSReader follows the position reported by a gpsd daemon.
+type GPSReader struct {
+	addr string
+
+	mu       sync.Mutex
+	lat, lon float64
+	fix      bool
+}
+
+func NewGPSReader(addr string) *GPSReader {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "2947")
+	}
+	return &GPSReader{addr: addr}
+}
+
+// Run reads position reports, reconnecting whenever gpsd goes away.
+func (g *GPSReader) Run() {
+	for {
+		if err := g.read(); err != nil {
+			log.Printf("Error reading gpsd at %s: %v", g.addr, err)
+		}
+		g.mu.Lock()
+		g.fix = false
+		g.mu.Unlock()
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (g *GPSReader) read() error {
+	conn, err := net.DialTimeout("tcp", g.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, `?WATCH={"enable":true,"json":true}`+"\n"); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		lat, lon, fix, ok := parseTPV(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		g.mu.Lock()
+		g.lat, g.lon, g.fix = lat, lon, fix
+		g.mu.Unlock()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed")
+}
+
+// Position returns the latest fix, if there is one.
+func (g *GPSReader) Position() (lat, lon float64, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lat, g.lon, g.fix
+}
+
+// parseTPV decodes a gpsd time-position-velocity report. Other report
+// classes return ok false; a TPV without a 2D or 3D fix returns fix false.
+func parseTPV(line []byte) (lat, lon float64, fix, ok bool) {
+	var tpv struct {
+		Class string   `json:"class"`
+		Mode  int      `json:"mode"`
+		Lat   *float64 `json:"lat"`
+		Lon   *float64 `json:"lon"`
+	}
+	if err := json.Unmarshal(line, &tpv); err != nil || tpv.Class != "TPV" {
+		return 0, 0, false, false
+	}
+	if tpv.Mode < 2 || tpv.Lat == nil || tpv.Lon == nil {
+		return 0, 0, false, true
+	}
+	return *tpv.Lat, *tpv.Lon, true, true
+}
+
+// Geofence is a named circular area, e.g. the home QTH or a portable site.
+type Geofence struct {
+	Name     string  `json:"name"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	RadiusKm float64 `json:"radius_km"`
+}
+
+// Contains reports whether a position lies within the fence.
+func (f Geofence) Contains(lat, lon float64) bool {
+	return sdk.Distance(f.Lat, f.Lon, lat, lon) <= f.RadiusKm
+}
+
+// locate returns the first fence containing the position, or "" outside
+// every fence.
+func locate(fences []Geofence, lat, lon float64) string {
+	for _, f := range fences {
+		if f.Contains(lat, lon) {
+			return f.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTPV(t *testing.T) {
+	lat, lon, fix, ok := parseTPV([]byte(`{"class":"TPV","mode":3,"lat":51.4779,"lon":-0.0015}`))
+	if !ok || !fix || lat != 51.4779 || lon != -0.0015 {
+		t.Errorf("parseTPV = %v, %v, %v, %v", lat, lon, fix, ok)
+	}
+	if _, _, fix, ok := parseTPV([]byte(`{"class":"TPV","mode":1}`)); !ok || fix {
+		t.Errorf("TPV without fix: fix = %v, ok = %v", fix, ok)
+	}
+	if _, _, _, ok := parseTPV([]byte(`{"class":"SKY"}`)); ok {
+		t.Error("SKY report parsed as TPV")
+	}
+}
+
+func TestGPSReader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		if !strings.HasPrefix(line, "?WATCH=") {
+			return
+		}
+		conn.Write([]byte(`{"class":"VERSION"}` + "\n" + `{"class":"TPV","mode":2,"lat":45.5,"lon":-73.6}` + "\n"))
+		time.Sleep(time.Second)
+	}()
+
+	g := NewGPSReader(ln.Addr().String())
+	go g.read()
+	for i := 0; i < 50; i++ {
+		if lat, lon, ok := g.Position(); ok {
+			if lat != 45.5 || lon != -73.6 {
+				t.Errorf("Position() = %v, %v", lat, lon)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no fix received")
+}
+
+func TestGeofences(t *testing.T) {
+	fences := []Geofence{
+		{Name: "home", Lat: 51.5007, Lon: -0.1246, RadiusKm: 1},
+		{Name: "uk", Lat: 54, Lon: -2, RadiusKm: 500},
+	}
+	if got := locate(fences, 51.5033, -0.1196); got != "home" { // ~0.5 km away
+		t.Errorf("locate(near home) = %q", got)
+	}
+	if got := locate(fences, 53.4808, -2.2426); got != "uk" {
+		t.Errorf("locate(Manchester) = %q", got)
+	}
+	if got := locate(fences, 48.8566, 2.3522); got != "" {
+		t.Errorf("locate(Paris) = %q", got)
+	}
+}
+
+func TestMonitorLocationChanged(t *testing.T) {
+	m, sink := newTestMonitor()
+	m.fences = []Geofence{{Name: "home", Lat: 51.5, Lon: -0.12, RadiusKm: 5}}
+	m.rules = NewRuleEngine([]*Rule{{Name: "portable", When: RuleCondition{Locations: []string{""}}}}, m.metrics, m.dispatcher)
+	m.rules.run = func(a RuleAction, ev Event) error { return nil }
+
+	now := time.Now()
+	for _, pos := range [][2]float64{{51.5, -0.12}, {51.501, -0.121}, {50.8, -1.1}} {
+		pos := pos
+		m.position = func() (float64, float64, bool) { return pos[0], pos[1], true }
+		m.updateLocation(now)
+		m.observe(14070000, now)
+	}
+
+	var moves []Event
+	fired := 0
+	for _, ev := range sink.events {
+		switch ev.Type {
+		case EventLocationChanged:
+			moves = append(moves, ev)
+		case EventRuleFired:
+			fired++
+		}
+	}
+	if len(moves) != 2 || moves[0].Location != "home" || moves[1].Location != "" || moves[1].PrevLocation != "home" {
+		t.Errorf("location events = %+v", moves)
+	}
+	if fired != 1 {
+		t.Errorf("portable rule fired %d times; want 1", fired)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// grpcService is the path prefix of the Station service's methods, from
+// sdk/station.proto.
+const grpcService = "/fldigicmd.v1.Station/"
+
+// gRPC status codes.
+const (
+	grpcOK               = 0
+	grpcInvalidArgument  = 3
+	grpcNotFound         = 5
+	grpcPermissionDenied = 7
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+// maxGRPCMessage bounds request messages, which are all small.
+const maxGRPCMessage = 64 << 10
+
+// GRPCServer serves the Station service of sdk/station.proto, for
+// automation that wants clients generated in Go, TypeScript and the like.
+// It speaks gRPC itself rather than depend on grpc-go, and since Go's HTTP
+// server only offers HTTP/2 over TLS, it needs a certificate.
+type GRPCServer struct {
+	api    *API
+	stream *EventStream
+	// token, if set, must be sent as "authorization: Bearer <token>".
+	token string
+}
+
+func NewGRPCServer(api *API, stream *EventStream, token string) *GRPCServer {
+	return &GRPCServer{api: api, stream: stream, token: token}
+}
+
+// Serve serves the service on addr over TLS until it fails.
+func (g *GRPCServer) Serve(addr, certFile, keyFile string) error {
+	srv := &http.Server{Addr: addr, Handler: g}
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
+
+func (g *GRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC over HTTP/2 only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	code, msg := g.call(w, r)
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", grpcEncodeMessage(msg))
+	}
+}
+
+// call runs the method of a request, writing its responses, and returns
+// the gRPC status.
+func (g *GRPCServer) call(w http.ResponseWriter, r *http.Request) (int, string) {
+	if g.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+g.token)) != 1 {
+		return grpcUnauthenticated, "missing or invalid API token"
+	}
+	method, ok := strings.CutPrefix(r.URL.Path, grpcService)
+	if !ok {
+		return grpcUnimplemented, "unknown service"
+	}
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return grpcInvalidArgument, err.Error()
+	}
+	fields, err := parseProto(req)
+	if err != nil {
+		return grpcInvalidArgument, err.Error()
+	}
+
+	var resp protoMessage
+	status := http.StatusOK
+	switch method {
+	case "GetStatus":
+		for _, m := range g.api.monitors {
+			resp.putMessage(1, statusMessage(m.Status()))
+		}
+	case "StreamEvents":
+		return g.streamEvents(w, r, fields)
+	case "SetFrequency":
+		var radio string
+		var freq float64
+		for _, f := range fields {
+			switch f.num {
+			case 1:
+				radio = f.str()
+			case 2:
+				freq = f.double()
+			}
+		}
+		status, err = g.api.tune(radio, freq)
+		resp.putDouble(1, freq)
+	case "SetMode":
+		var radio, mode string
+		for _, f := range fields {
+			switch f.num {
+			case 1:
+				radio = f.str()
+			case 2:
+				mode = f.str()
+			}
+		}
+		status, err = g.api.setMode(radio, mode)
+		resp.putString(1, mode)
+	case "SendText":
+		var radio, text string
+		for _, f := range fields {
+			switch f.num {
+			case 1:
+				radio = f.str()
+			case 2:
+				text = f.str()
+			}
+		}
+		status, err = g.api.sendText(radio, text)
+	default:
+		return grpcUnimplemented, "unknown method " + method
+	}
+	if err != nil {
+		return grpcCode(status), err.Error()
+	}
+	if err := writeGRPCMessage(w, resp); err != nil {
+		return grpcUnavailable, err.Error()
+	}
+	return grpcOK, ""
+}
+
+// streamEvents sends events until the client goes away, starting with the
+// last replay recent ones and keeping to the requested types.
+func (g *GRPCServer) streamEvents(w http.ResponseWriter, r *http.Request, fields []protoField) (int, string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return grpcInternal, "streaming unsupported"
+	}
+	replay := 0
+	types := map[string]bool{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			replay = int(int32(f.value))
+		case 2:
+			types[f.str()] = true
+		}
+	}
+	if replay < 0 {
+		replay = 0
+	}
+	ch := g.stream.subscribe(replay)
+	defer g.stream.unsubscribe(ch)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return grpcOK, ""
+		case ev := <-ch:
+			if len(types) > 0 && !types[ev.Type] {
+				continue
+			}
+			if err := writeGRPCMessage(w, eventMessage(ev)); err != nil {
+				return grpcUnavailable, err.Error()
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// grpcCode is the gRPC status for the HTTP status of an API failure.
+func grpcCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInvalidArgument
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcNotFound
+	case http.StatusNotImplemented:
+		return grpcUnimplemented
+	case http.StatusBadGateway:
+		return grpcUnavailable
+	}
+	return grpcInternal
+}
+
+// grpcEncodeMessage percent-encodes a status message as gRPC requires.
+func grpcEncodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c >= 0x20 && c <= 0x7E && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// readGRPCMessage reads a length-prefixed message.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, fmt.Errorf("failed to read gRPC message: %v", err)
+	}
+	if head[0] != 0 {
+		return nil, fmt.Errorf("compressed gRPC messages are unsupported")
+	}
+	n := binary.BigEndian.Uint32(head[1:])
+	if n > maxGRPCMessage {
+		return nil, fmt.Errorf("gRPC message of %d bytes is too large", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("failed to read gRPC message: %v", err)
+	}
+	return msg, nil
+}
+
+// writeGRPCMessage writes an uncompressed, length-prefixed message.
+func writeGRPCMessage(w io.Writer, msg protoMessage) error {
+	frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// statusMessage encodes a radio's status as a RadioStatus.
+func statusMessage(s MonitorStatus) protoMessage {
+	var m protoMessage
+	m.putString(1, s.Radio)
+	m.putTime(2, s.Time)
+	m.putDouble(3, s.Freq)
+	m.putString(4, s.Band)
+	m.putString(5, s.Segment)
+	m.putString(6, s.Mode)
+	m.putBool(7, s.Standby)
+	m.putString(8, s.Error)
+	m.putTime(9, s.LastPoll)
+	m.putDouble(10, s.TXFreq)
+	m.putString(11, s.TXBand)
+	return m
+}
+
+// eventMessage encodes an event as an Event, numbering the fields in the
+// order of the Event struct.
+func eventMessage(ev Event) protoMessage {
+	var m protoMessage
+	m.putString(1, ev.Type)
+	m.putTime(2, ev.Time)
+	m.putString(3, ev.Radio)
+	m.putString(4, ev.Band)
+	m.putString(5, ev.PrevBand)
+	m.putDouble(6, ev.Freq)
+	m.putString(7, ev.Segment)
+	m.putString(8, ev.PrevSegment)
+	m.putString(9, ev.SegmentMode)
+	m.putString(10, ev.Mode)
+	m.putString(11, ev.PrevMode)
+	m.putDouble(12, ev.Bandwidth)
+	m.putString(13, ev.Rule)
+	m.putString(14, ev.Call)
+	m.putString(15, ev.Method)
+	for _, note := range ev.Notes {
+		m.putBytes(16, []byte(note))
+	}
+	m.putString(17, ev.Backend)
+	m.putString(18, ev.PrevBackend)
+	m.putDouble(19, ev.ReadBack)
+	m.putString(20, ev.Error)
+	m.putDouble(21, ev.Downtime)
+	m.putString(22, ev.Location)
+	m.putString(23, ev.PrevLocation)
+	m.putDouble(24, ev.Lat)
+	m.putDouble(25, ev.Lon)
+	m.putString(26, ev.Region)
+	m.putString(27, ev.PrevRegion)
+	m.putMap(28, ev.Exchange)
+	m.putString(29, ev.Command)
+	m.putInt(30, int64(ev.ExitCode))
+	m.putString(31, ev.Output)
+	m.putMap(32, ev.QSO)
+	m.putString(33, ev.Spotter)
+	m.putString(34, ev.Comment)
+	m.putString(35, ev.TXBand)
+	m.putDouble(36, ev.TXFreq)
+	return m
+}
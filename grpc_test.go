@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// grpcCall makes a unary call, returning the response message and the
+// grpc-status trailer.
+func grpcCall(t *testing.T, srv *httptest.Server, method, token string, req protoMessage) ([]protoField, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writeGRPCMessage(&body, req)
+	r, _ := http.NewRequest(http.MethodPost, srv.URL+grpcService+method, &body)
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("served over HTTP/%d", resp.ProtoMajor)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	var fields []protoField
+	if len(data) > 0 {
+		msg, err := readGRPCMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fields, err = parseProto(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fields, resp.Trailer.Get("Grpc-Status")
+}
+
+func newGRPCTestServer(t *testing.T, g *GRPCServer) *httptest.Server {
+	srv := httptest.NewUnstartedServer(g)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGRPCServer(t *testing.T) {
+	m, _ := newTestMonitor()
+	m.radio = "A"
+	m.observe(14074000, time.Now())
+	m.setStatus(14074000, nil)
+	rig := &fakeBackend{name: "fake"}
+	api := &API{monitors: []*Monitor{m}, rigs: map[string]Backend{"A": rig}}
+	srv := newGRPCTestServer(t, NewGRPCServer(api, NewEventStream(10), "secret"))
+
+	if _, status := grpcCall(t, srv, "GetStatus", "", nil); status != "16" {
+		t.Errorf("grpc-status without the token = %q", status)
+	}
+
+	fields, status := grpcCall(t, srv, "GetStatus", "secret", nil)
+	if status != "0" || len(fields) != 1 {
+		t.Fatalf("GetStatus = %+v, status %q", fields, status)
+	}
+	radio, _ := parseProto(fields[0].bytes)
+	got := map[int]protoField{}
+	for _, f := range radio {
+		got[f.num] = f
+	}
+	if got[1].str() != "A" || got[3].double() != 14074000 || got[4].str() != "20m" {
+		t.Errorf("RadioStatus = %+v", radio)
+	}
+
+	var req protoMessage
+	req.putString(1, "A")
+	req.putDouble(2, 7074000)
+	if fields, status := grpcCall(t, srv, "SetFrequency", "secret", req); status != "0" || len(fields) != 1 || fields[0].double() != 7074000 {
+		t.Errorf("SetFrequency = %+v, status %q", fields, status)
+	}
+	if len(rig.sets) != 1 || rig.sets[0] != 7074000 {
+		t.Errorf("sets = %v", rig.sets)
+	}
+
+	req = nil
+	req.putString(1, "B")
+	req.putDouble(2, 7074000)
+	if _, status := grpcCall(t, srv, "SetFrequency", "secret", req); status != "5" {
+		t.Errorf("grpc-status for an unknown radio = %q", status)
+	}
+
+	req = nil
+	req.putString(1, "A")
+	req.putString(2, "CW")
+	if _, status := grpcCall(t, srv, "SetMode", "secret", req); status != "12" {
+		t.Errorf("grpc-status for a backend that can't set the mode = %q", status)
+	}
+	if _, status := grpcCall(t, srv, "Reboot", "secret", nil); status != "12" {
+		t.Errorf("grpc-status for an unknown method = %q", status)
+	}
+}
+
+func TestGRPCStreamEvents(t *testing.T) {
+	stream := NewEventStream(10)
+	stream.Handle(Event{Type: EventModeChange, Mode: "CW"})
+	stream.Handle(Event{Type: EventBandChange, Band: "40m", Exchange: map[string]string{"zone": "5"}})
+	srv := newGRPCTestServer(t, NewGRPCServer(&API{}, stream, ""))
+
+	var req protoMessage
+	req.putInt(1, 10)
+	req.putString(2, EventBandChange)
+	var body bytes.Buffer
+	writeGRPCMessage(&body, req)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+grpcService+"StreamEvents", &body)
+	r.Header.Set("Content-Type", "application/grpc")
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	msg, err := readGRPCMessage(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := parseProto(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]protoField{}
+	for _, f := range fields {
+		got[f.num] = f
+	}
+	if got[1].str() != EventBandChange || got[4].str() != "40m" {
+		t.Errorf("first event = %+v", fields)
+	}
+	if entry, _ := parseProto(got[28].bytes); len(entry) != 2 || entry[0].str() != "zone" || entry[1].str() != "5" {
+		t.Errorf("exchange = %+v", entry)
+	}
+}
+
+func TestGRPCRequiresHTTP2(t *testing.T) {
+	srv := httptest.NewServer(NewGRPCServer(&API{}, NewEventStream(10), ""))
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+grpcService+"GetStatus", "application/grpc", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("status = %s", resp.Status)
+	}
+}
+
+func TestGRPCEncodeMessage(t *testing.T) {
+	if got := grpcEncodeMessage("100% done\n"); got != "100%25 done%0A" {
+		t.Errorf("grpcEncodeMessage = %q", got)
+	}
+}
+
+// TestStationProtoEvent checks sdk/station.proto's Event has a field for each
+// field of the Event struct, in the same order eventMessage numbers them.
+func TestStationProtoEvent(t *testing.T) {
+	proto, err := os.ReadFile("sdk/station.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := regexp.MustCompile(`(?s)message Event \{(.*?)\n\}`).FindSubmatch(proto)
+	if message == nil {
+		t.Fatal("no Event message")
+	}
+	var names []string
+	for _, m := range regexp.MustCompile(`(\w+) = (\d+);`).FindAllSubmatch(message[1], -1) {
+		names = append(names, string(m[1]))
+	}
+	var want []string
+	typ := reflect.TypeOf(Event{})
+	for i := 0; i < typ.NumField(); i++ {
+		want = append(want, strings.Split(typ.Field(i).Tag.Get("json"), ",")[0])
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Event fields in station.proto = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// HAState is the monitor state replicated between the primary and the
+// standby so that a takeover, or a primary restarting after one, doesn't
+// re-fire hooks, rules or warnings for what was already handled.
+type HAState struct {
+	Node    string    `json:"node"`
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Band    string    `json:"band"`
+	Segment string    `json:"segment"`
+	// Rules are the names of the rules whose conditions matched.
+	Rules          []string `json:"rules,omitempty"`
+	OutOfBand      bool     `json:"out_of_band,omitempty"`
+	OutOfPrivilege bool     `json:"out_of_privilege,omitempty"`
+	// Hello is sent by a starting primary, asking the standby for the state
+	// it last saw or took over with.
+	Hello bool `json:"hello,omitempty"`
+}
+
+// haResumeWait is how long a starting primary waits for the standby's
+// state before starting afresh.
+const haResumeWait = 2 * time.Second
+
+// haMaxSkew is how far a heartbeat's time may be from the receiver's clock,
+// so that one recorded earlier can't be replayed later.
+const haMaxSkew = 30 * time.Second
+
+// HANode implements a primary/standby pair. The primary sends its state as
+// a UDP heartbeat after every poll; the standby stays passive while
+// heartbeats arrive and takes over when they stop for longer than timeout.
+// While active the standby sends its own heartbeats back to the address the
+// primary's came from, and it answers a restarted primary's hello with its
+// state. Heartbeats carry an HMAC-SHA256 of their state, including its node,
+// sequence number and time, under a key both nodes share, and any other
+// datagram is ignored.
+type HANode struct {
+	role    string
+	timeout time.Duration
+	key     []byte
+	peer    *net.UDPAddr
+	conn    *net.UDPConn
+
+	mu            sync.Mutex
+	seq           uint64
+	lastHeartbeat time.Time
+	// state is the latest state from the other node or, on an active
+	// standby, its own.
+	state  HAState
+	active bool
+	// hello is the time of the latest hello answered, so that one can't
+	// be replayed.
+	hello time.Time
+}
+
+func NewHANode(role, listen, peer, key string, timeout time.Duration) (*HANode, error) {
+	if key == "" {
+		return nil, fmt.Errorf("--ha-key is required to sign the heartbeats")
+	}
+	h := &HANode{role: role, timeout: timeout, key: []byte(key)}
+
+	switch role {
+	case "primary":
+		if peer == "" {
+			return nil, fmt.Errorf("--ha-peer is required for the primary")
+		}
+		addr, err := net.ResolveUDPAddr("udp", peer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --ha-peer: %v", err)
+		}
+		h.peer = addr
+		h.conn, err = net.ListenUDP("udp", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open heartbeat socket: %v", err)
+		}
+		h.active = true
+	case "standby":
+		addr, err := net.ResolveUDPAddr("udp", listen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --ha-listen: %v", err)
+		}
+		h.conn, err = net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for heartbeats: %v", err)
+		}
+		// Give a running primary the chance to announce itself before
+		// taking over.
+		h.lastHeartbeat = time.Now()
+	default:
+		return nil, fmt.Errorf("unknown HA role %q (want primary or standby)", role)
+	}
+	go h.receive()
+
+	return h, nil
+}
+
+func (h *HANode) receive() {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := h.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Printf("Error reading heartbeat: %v", err)
+			return
+		}
+
+		// Datagrams that aren't signed heartbeats are dropped without
+		// logging, so they can't flood the log either.
+		state, err := h.open(buf[:n])
+		if err != nil {
+			continue
+		}
+		// A heartbeat from a node no newer than the last one is a replay.
+		h.mu.Lock()
+		stale := !state.Hello && state.Node == h.state.Node && state.Seq <= h.state.Seq
+		if h.role == "primary" {
+			// The standby's state, kept for Resume.
+			if !state.Hello && !stale {
+				h.state = state
+			}
+			h.mu.Unlock()
+			continue
+		}
+
+		switch {
+		case state.Hello:
+			if !state.Time.After(h.hello) {
+				h.mu.Unlock()
+				continue
+			}
+			h.hello = state.Time
+			h.reply(h.state, from)
+		case stale:
+			h.mu.Unlock()
+			continue
+		default:
+			h.state = state
+		}
+		h.peer = from
+		h.lastHeartbeat = time.Now()
+		if h.active {
+			fmt.Printf("Primary %s is back, returning to standby\n", state.Node)
+			h.active = false
+		}
+		h.mu.Unlock()
+	}
+}
+
+// reply sends a restarted primary the state to resume from, if there is
+// one; h.mu is held.
+func (h *HANode) reply(state HAState, to *net.UDPAddr) {
+	if state.Seq == 0 {
+		return
+	}
+	data, err := h.seal(state)
+	if err != nil {
+		return
+	}
+	if _, err := h.conn.WriteToUDP(data, to); err != nil {
+		log.Printf("Error answering primary: %v", err)
+	}
+}
+
+// Active reports whether this node should poll fldigi and fire hooks,
+// promoting a standby whose primary has gone quiet.
+func (h *HANode) Active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.role == "primary" || h.active {
+		return true
+	}
+
+	if time.Since(h.lastHeartbeat) > h.timeout {
+		fmt.Printf("No heartbeat from primary for %v, taking over\n", h.timeout)
+		h.active = true
+	}
+	return h.active
+}
+
+// State returns the most recent state replicated from the other node.
+func (h *HANode) State() HAState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+// Resume asks the standby for the state it last replicated or took over
+// with, waiting up to wait, so a restarted primary carries on from it.
+func (h *HANode) Resume(wait time.Duration) (HAState, bool) {
+	if h.role != "primary" {
+		return HAState{}, false
+	}
+	data, _ := h.seal(HAState{Node: h.conn.LocalAddr().String(), Time: time.Now(), Hello: true})
+	if _, err := h.conn.WriteToUDP(data, h.peer); err != nil {
+		log.Printf("Error sending heartbeat: %v", err)
+		return HAState{}, false
+	}
+	for deadline := time.Now().Add(wait); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if state := h.State(); state.Seq > 0 {
+			return state, true
+		}
+	}
+	return HAState{}, false
+}
+
+// Publish sends the current state to the other node: from the primary to
+// the standby, or from an active standby back to the primary.
+func (h *HANode) Publish(state HAState) {
+	h.mu.Lock()
+	if h.peer == nil {
+		h.mu.Unlock()
+		return
+	}
+	h.seq++
+	state.Node, state.Seq, state.Time = h.conn.LocalAddr().String(), h.seq, time.Now()
+	if h.role == "standby" {
+		h.state = state
+	}
+	peer := h.peer
+	h.mu.Unlock()
+
+	data, err := h.seal(state)
+	if err != nil {
+		return
+	}
+	if _, err := h.conn.WriteToUDP(data, peer); err != nil {
+		log.Printf("Error sending heartbeat: %v", err)
+	}
+}
+
+// seal encodes a heartbeat: the HMAC-SHA256 of the state's JSON followed
+// by the JSON.
+func (h *HANode) seal(state HAState) ([]byte, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(data)
+	return append(mac.Sum(nil), data...), nil
+}
+
+// open decodes a heartbeat sealed with the shared key, refusing one whose
+// signature doesn't match or whose time is more than haMaxSkew off.
+func (h *HANode) open(packet []byte) (HAState, error) {
+	if len(packet) < sha256.Size {
+		return HAState{}, errors.New("heartbeat too short")
+	}
+	sum, data := packet[:sha256.Size], packet[sha256.Size:]
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(data)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return HAState{}, errors.New("heartbeat signature doesn't match")
+	}
+	var state HAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return HAState{}, err
+	}
+	if skew := time.Since(state.Time); skew > haMaxSkew || skew < -haMaxSkew {
+		return HAState{}, fmt.Errorf("heartbeat time %v is more than %v off", state.Time, haMaxSkew)
+	}
+	return state, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHAStandbyTakeover(t *testing.T) {
+	standby, err := NewHANode("standby", "127.0.0.1:0", "", "secret", 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := NewHANode("primary", "", standby.conn.LocalAddr().String(), "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary.Publish(HAState{Band: "20m", Segment: "20m-FT8", Rules: []string{"ft8"}, OutOfPrivilege: true})
+
+	deadline := time.Now().Add(time.Second)
+	for standby.State().Band != "20m" {
+		if time.Now().After(deadline) {
+			t.Fatal("standby did not receive heartbeat")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if standby.Active() {
+		t.Error("standby active while primary is sending heartbeats")
+	}
+	if state := standby.State(); state.Segment != "20m-FT8" || strings.Join(state.Rules, ",") != "ft8" || !state.OutOfPrivilege {
+		t.Errorf("replicated state = %+v", state)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if !standby.Active() {
+		t.Error("standby did not take over after heartbeats stopped")
+	}
+
+	// The active standby's heartbeats go back to the primary.
+	standby.Publish(HAState{Band: "17m"})
+	deadline = time.Now().Add(time.Second)
+	for primary.State().Band != "17m" {
+		if time.Now().After(deadline) {
+			t.Fatal("primary did not receive the standby's heartbeat")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A returning primary takes control back.
+	primary.Publish(HAState{Band: "40m"})
+	deadline = time.Now().Add(time.Second)
+	for standby.Active() {
+		if time.Now().After(deadline) {
+			t.Fatal("standby did not step down for returning primary")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHAPrimaryResumes(t *testing.T) {
+	standby, err := NewHANode("standby", "127.0.0.1:0", "", "secret", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !standby.Active() {
+		t.Fatal("standby did not take over")
+	}
+
+	// A primary starting with no state to resume from starts afresh.
+	primary, err := NewHANode("primary", "", standby.conn.LocalAddr().String(), "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := primary.Resume(100 * time.Millisecond); ok {
+		t.Error("resumed from a standby with no state")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	standby.Active()
+	standby.Publish(HAState{Band: "15m", Segment: "15m-data", Rules: []string{"dx"}, OutOfBand: true})
+
+	// A restarted primary picks up where the standby left off, and the
+	// standby steps down for it.
+	restarted, err := NewHANode("primary", "", standby.conn.LocalAddr().String(), "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, ok := restarted.Resume(time.Second)
+	if !ok || state.Band != "15m" || state.Segment != "15m-data" || strings.Join(state.Rules, ",") != "dx" || !state.OutOfBand {
+		t.Errorf("resumed %v with %+v", ok, state)
+	}
+	if standby.Active() {
+		t.Error("standby still active after the primary's hello")
+	}
+}
+
+func TestHAIgnoresUnsignedAndReplayedHeartbeats(t *testing.T) {
+	standby, err := NewHANode("standby", "127.0.0.1:0", "", "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.DialUDP("udp", nil, standby.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	send := func(data []byte) {
+		conn.Write(data)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	forger := &HANode{key: []byte("guess")}
+	signer := &HANode{key: []byte("secret")}
+	unsigned, _ := json.Marshal(HAState{Node: "evil", Seq: 1, Time: time.Now(), Band: "6m"})
+	forged, _ := forger.seal(HAState{Node: "evil", Seq: 1, Time: time.Now(), Band: "6m"})
+	old, _ := signer.seal(HAState{Node: "old", Seq: 1, Time: time.Now().Add(-time.Hour), Band: "6m"})
+	for _, data := range [][]byte{unsigned, forged, old} {
+		send(data)
+	}
+	if state := standby.State(); state.Band != "" {
+		t.Fatalf("standby accepted %+v", state)
+	}
+
+	first, _ := signer.seal(HAState{Node: "primary", Seq: 1, Time: time.Now(), Band: "20m"})
+	second, _ := signer.seal(HAState{Node: "primary", Seq: 2, Time: time.Now(), Band: "40m"})
+	send(first)
+	send(second)
+	send(first)
+	if state := standby.State(); state.Band != "40m" || state.Seq != 2 {
+		t.Errorf("state after a replayed heartbeat = %+v", state)
+	}
+
+	if _, err := NewHANode("standby", "127.0.0.1:0", "", "", time.Second); err == nil {
+		t.Error("NewHANode() accepted no key")
+	}
+}
+
+func TestMonitorRestoresHAState(t *testing.T) {
+	m, sink := newTestMonitor()
+	rules := []*Rule{{Name: "20m", When: RuleCondition{Bands: []string{"20m"}}}}
+	m.rules = NewRuleEngine(rules, m.metrics, m.dispatcher)
+	m.observe(14074000, time.Now())
+	state := m.haState()
+	if state.Band != "20m" || strings.Join(state.Rules, ",") != "20m" {
+		t.Fatalf("state = %+v", state)
+	}
+
+	// A monitor carrying on from the state fires nothing for the same band.
+	m, sink = newTestMonitor()
+	m.rules = NewRuleEngine(cloneRules(rules), m.metrics, m.dispatcher)
+	m.restoreHA(state)
+	m.observe(14074000, time.Now())
+	if got := sink.types(); len(got) != 0 {
+		t.Errorf("events after restoring = %v", got)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyEvents are the event types recorded in the --history database: those
+// that say where the station was and when, and, with --tx-events, when it
+// transmitted.
+var historyEvents = map[string]bool{
+	EventInitialBand:   true,
+	EventBandChange:    true,
+	EventSegmentChange: true,
+	EventModeChange:    true,
+	EventOutOfBand:     true,
+	EventConnected:     true,
+	EventDisconnected:  true,
+	EventShutdown:      true,
+	EventTXStart:       true,
+	EventTXStop:        true,
+}
+
+// historySchema creates the events table of the --history database. The
+// columns hold what the history is queried by; data holds the whole event
+// as JSON.
+const historySchema = `PRAGMA journal_mode=WAL;
+CREATE TABLE IF NOT EXISTS events (
+	id INTEGER PRIMARY KEY,
+	time TEXT NOT NULL,
+	type TEXT NOT NULL,
+	radio TEXT NOT NULL,
+	band TEXT NOT NULL,
+	segment TEXT NOT NULL,
+	mode TEXT NOT NULL,
+	freq REAL NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_time ON events (time);
+`
+
+// sqliteHeader starts every SQLite database file.
+const sqliteHeader = "SQLite format 3\x00"
+
+// historySink records events in an SQLite database, one row per event, so
+// the history survives restarts and can be read while the daemon runs.
+// fldigi-cmd is built with the Go standard library alone, so the database
+// is written through the sqlite3 command-line shell.
+type historySink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newHistorySink(path string) (*historySink, error) {
+	if err := checkSQLiteFile(path); err != nil {
+		return nil, fmt.Errorf("failed to open history: %v", err)
+	}
+	if _, err := runSQLite(path, false, historySchema); err != nil {
+		return nil, fmt.Errorf("failed to open history: %v", err)
+	}
+	return &historySink{path: path}, nil
+}
+
+func (s *historySink) Name() string        { return "history" }
+func (s *historySink) Wants(ev Event) bool { return historyEvents[ev.Type] }
+
+func (s *historySink) Handle(ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	insert := fmt.Sprintf("INSERT INTO events (time, type, radio, band, segment, mode, freq, data) VALUES (%s, %s, %s, %s, %s, %s, %v, %s);\n",
+		sqlQuote(ev.Time.UTC().Format(time.RFC3339Nano)), sqlQuote(ev.Type), sqlQuote(ev.Radio), sqlQuote(ev.Band),
+		sqlQuote(ev.Segment), sqlQuote(ev.Mode), strconv.FormatFloat(ev.Freq, 'f', -1, 64), sqlQuote(string(data)))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = runSQLite(s.path, false, insert)
+	return err
+}
+
+// readHistory reads the recorded events in the order they were recorded.
+// Rows whose event doesn't parse are skipped and counted.
+func readHistory(path string) (events []Event, skipped int, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, 0, fmt.Errorf("failed to open history: %v", err)
+	}
+	if err := checkSQLiteFile(path); err != nil {
+		return nil, 0, fmt.Errorf("failed to open history: %v", err)
+	}
+	out, err := runSQLite(path, true, "SELECT data FROM events ORDER BY id;\n")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read history: %v", err)
+	}
+	var rows []struct {
+		Data string `json:"data"`
+	}
+	if len(bytes.TrimSpace(out)) > 0 {
+		if err := json.Unmarshal(out, &rows); err != nil {
+			return nil, 0, fmt.Errorf("failed to read history: %v", err)
+		}
+	}
+	for _, row := range rows {
+		var ev Event
+		if err := json.Unmarshal([]byte(row.Data), &ev); err != nil || ev.Type == "" {
+			skipped++
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, skipped, nil
+}
+
+// checkSQLiteFile refuses a file at path that isn't an SQLite database, so
+// that another file given by mistake isn't overwritten. A missing or empty
+// file is fine.
+func checkSQLiteFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header := make([]byte, len(sqliteHeader))
+	n, _ := io.ReadFull(f, header)
+	if n > 0 && string(header[:n]) != sqliteHeader {
+		return fmt.Errorf("%s is not an SQLite database", path)
+	}
+	return nil
+}
+
+// runSQLite runs sql against the database at path with the sqlite3 shell,
+// waiting up to 5 seconds for a lock held by another reader or writer, and
+// returns the output, as JSON when reading.
+func runSQLite(path string, read bool, sql string) ([]byte, error) {
+	args := []string{"-batch", "-bail"}
+	if read {
+		args = append(args, "-readonly", "-json")
+	}
+	if strings.HasPrefix(path, "-") {
+		path = "./" + path
+	}
+	cmd := exec.Command("sqlite3", append(args, path)...)
+	cmd.Stdin = strings.NewReader(".timeout 5000\n" + sql)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("sqlite3: %s", msg)
+		}
+		return nil, fmt.Errorf("sqlite3: %v", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// sqlQuote returns s as an SQL string literal. NUL bytes, which would end
+// the statement early, are dropped.
+func sqlQuote(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// bandSpan is a stretch of time a radio spent on one band in one mode.
+type bandSpan struct {
+	Radio string
+	Band  string
+	Mode  string
+	Start time.Time
+	End   time.Time
+	// Freq is the last frequency seen during the span.
+	Freq float64
+	// QSY is set when the span began with a change of band.
+	QSY bool
+}
+
+// bandSpans works out from recorded events where each radio was and for
+// how long. A span still open at the end of the events runs until until.
+func bandSpans(events []Event, until time.Time) []bandSpan {
+	type radioState struct {
+		band, mode string
+		freq       float64
+		outOfBand  bool
+		offline    bool
+		open       *bandSpan
+	}
+	var spans []bandSpan
+	radios := map[string]*radioState{}
+	closeSpan := func(st *radioState, at time.Time) {
+		if st.open != nil {
+			st.open.End = at
+			spans = append(spans, *st.open)
+			st.open = nil
+		}
+	}
+	// restart ends the radio's span and begins another if it is on a band.
+	restart := func(radio string, st *radioState, at time.Time, qsy bool) {
+		closeSpan(st, at)
+		if st.band != "" && !st.outOfBand && !st.offline {
+			st.open = &bandSpan{Radio: radio, Band: st.band, Mode: st.mode, Start: at, Freq: st.freq, QSY: qsy}
+		}
+	}
+
+	for _, ev := range events {
+		st, ok := radios[ev.Radio]
+		if !ok {
+			st = &radioState{}
+			radios[ev.Radio] = st
+		}
+		if ev.Freq != 0 && ev.Type != EventOutOfBand {
+			st.freq = ev.Freq
+		}
+		switch ev.Type {
+		case EventSegmentChange, EventTXStart, EventTXStop:
+			if st.open != nil {
+				st.open.Freq = st.freq
+			}
+		case EventInitialBand, EventBandChange:
+			st.band, st.mode = ev.Band, ev.Mode
+			st.outOfBand, st.offline = false, false
+			restart(ev.Radio, st, ev.Time, ev.Type == EventBandChange && ev.Band != ev.PrevBand)
+		case EventModeChange:
+			st.mode = ev.Mode
+			restart(ev.Radio, st, ev.Time, false)
+		case EventOutOfBand:
+			st.outOfBand = true
+			closeSpan(st, ev.Time)
+		case EventDisconnected:
+			st.offline = true
+			closeSpan(st, ev.Time)
+		case EventConnected:
+			// The monitor remembers its band while the rig is away, so
+			// a restored connection carries on where it left off.
+			if st.offline {
+				st.offline = false
+				restart(ev.Radio, st, ev.Time, false)
+			}
+		case EventShutdown:
+			if st.open != nil {
+				st.open.Freq = st.freq
+			}
+			closeSpan(st, ev.Time)
+			st.band, st.mode = "", ""
+		}
+	}
+	for _, st := range radios {
+		closeSpan(st, until)
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	return spans
+}
+
+// clipSpans trims spans to the time from from to to; a zero bound is open.
+func clipSpans(spans []bandSpan, from, to time.Time) []bandSpan {
+	var clipped []bandSpan
+	for _, sp := range spans {
+		if !from.IsZero() && sp.Start.Before(from) {
+			sp.Start, sp.QSY = from, false
+		}
+		if !to.IsZero() && sp.End.After(to) {
+			sp.End = to
+		}
+		if sp.End.After(sp.Start) {
+			clipped = append(clipped, sp)
+		}
+	}
+	return clipped
+}
+
+// parseSince parses a --since value: a duration back from now such as 24h,
+// a date such as 2026-03-01, or an RFC 3339 time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want a duration such as 24h, a date such as 2026-03-01 or an RFC 3339 time", s)
+}
+
+// addHistoryFlags adds the options shared by the history subcommands.
+func addHistoryFlags(fs *flag.FlagSet) (path, radio, since *string) {
+	path = fs.String("history", os.Getenv("FLDIGI_HISTORY"), "SQLite database written by the daemon's --history option")
+	radio = fs.String("radio", "", "only show the radio with this label")
+	since = fs.String("since", "", "only show events since a duration ago, e.g. 24h, a date or an RFC 3339 time")
+	return path, radio, since
+}
+
+// loadHistory reads the history database, keeping only one radio's events if
+// radio is set.
+func loadHistory(path, radio string) ([]Event, error) {
+	if path == "" {
+		return nil, fmt.Errorf("--history or FLDIGI_HISTORY is required")
+	}
+	events, skipped, err := readHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %d unreadable events in %s\n", skipped, path)
+	}
+	if radio == "" {
+		return events, nil
+	}
+	var filtered []Event
+	for _, ev := range events {
+		if ev.Radio == radio {
+			filtered = append(filtered, ev)
+		}
+	}
+	return filtered, nil
+}
+
+// runHistory implements `fldigi-cmd history`: print the recorded events.
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	path, radio, since := addHistoryFlags(fs)
+	var lines int
+	var asJSON bool
+	fs.IntVar(&lines, "n", 0, "only show the last n events (0 for all)")
+	fs.BoolVar(&asJSON, "json", false, "print events as JSON lines")
+	fs.Parse(args)
+
+	from, err := parseSince(*since, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	all, err := loadHistory(*path, *radio)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	var events []Event
+	for _, ev := range all {
+		if !ev.Time.Before(from) {
+			events = append(events, ev)
+		}
+	}
+	if lines > 0 && len(events) > lines {
+		events = events[len(events)-lines:]
+	}
+	for _, ev := range events {
+		if asJSON {
+			fmt.Println(formatEvent(ev, true))
+		} else {
+			fmt.Println(ev.Time.Local().Format("2006-01-02 ") + formatEvent(ev, false))
+		}
+	}
+	return 0
+}
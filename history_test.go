@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistorySinkRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	path := filepath.Join(t.TempDir(), "history.db")
+	sink, err := newHistorySink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, ev := range []Event{
+		{Type: EventBandChange, Time: when, Band: "20m", PrevBand: "40m", Freq: 14074000, Mode: "USB"},
+		{Type: EventRuleFired, Time: when, Rule: "ignored"},
+		{Type: EventModeChange, Time: when, Radio: "O'Brien's; DROP TABLE events; --", Mode: "CW"},
+	} {
+		if sink.Wants(ev) {
+			sink.Handle(ev)
+		}
+	}
+	// A row whose event doesn't parse is skipped.
+	if _, err := runSQLite(path, false, `INSERT INTO events (time, type, radio, band, segment, mode, freq, data) VALUES ('', '', '', '', '', '', 0, '{"type":"band-ch');`); err != nil {
+		t.Fatal(err)
+	}
+
+	events, skipped, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 || len(events) != 2 || events[0].Band != "20m" || !events[0].Time.Equal(when) || events[1].Radio != "O'Brien's; DROP TABLE events; --" {
+		t.Errorf("readHistory() = %+v, %d skipped", events, skipped)
+	}
+
+	out, err := runSQLite(path, true, "SELECT band, freq FROM events WHERE type = 'band-change';\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != `[{"band":"20m","freq":14074000.0}]` {
+		t.Errorf("band-change row = %s", got)
+	}
+}
+
+func TestHistoryRefusesOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	os.WriteFile(path, []byte(`{"type":"band-change"}`+"\n"), 0644)
+	if _, err := newHistorySink(path); err == nil || !strings.Contains(err.Error(), "not an SQLite database") {
+		t.Errorf("newHistorySink() error = %v", err)
+	}
+	if _, _, err := readHistory(path); err == nil {
+		t.Error("readHistory() read a file that isn't a database")
+	}
+}
+
+func TestBandSpans(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return t0.Add(time.Duration(min) * time.Minute) }
+	events := []Event{
+		{Type: EventInitialBand, Time: at(0), Band: "40m", Freq: 7074000, Mode: "USB"},
+		{Type: EventTXStart, Time: at(5), Band: "40m", Freq: 7076000, Mode: "USB"},
+		{Type: EventBandChange, Time: at(10), Band: "20m", PrevBand: "40m", Freq: 14074000, Mode: "USB"},
+		{Type: EventModeChange, Time: at(20), Band: "20m", Freq: 14070000, Mode: "BPSK31", PrevMode: "USB"},
+		{Type: EventDisconnected, Time: at(30)},
+		{Type: EventConnected, Time: at(35), Downtime: 300},
+		{Type: EventOutOfBand, Time: at(40), Freq: 116000000},
+		{Type: EventModeChange, Time: at(42), Mode: "USB", PrevMode: "BPSK31"},
+		{Type: EventBandChange, Time: at(45), Band: "20m", PrevBand: "20m", Freq: 14074000, Mode: "USB"},
+		{Type: EventShutdown, Time: at(50), Band: "20m"},
+		{Type: EventInitialBand, Time: at(60), Band: "40m", Freq: 7074000, Mode: "USB", Radio: "B"},
+	}
+	spans := bandSpans(events, at(70))
+
+	var got []string
+	for _, sp := range spans {
+		got = append(got, sp.Radio+sp.Band+"/"+sp.Mode+" "+sp.End.Sub(sp.Start).String())
+	}
+	want := []string{"40m/USB 10m0s", "20m/USB 10m0s", "20m/BPSK31 10m0s", "20m/BPSK31 5m0s", "20m/USB 5m0s", "B40m/USB 10m0s"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("spans = %v, want %v", got, want)
+	}
+	if spans[0].Freq != 7076000 || spans[3].Freq != 14070000 {
+		t.Errorf("spans have the frequencies of other bands: %+v", spans)
+	}
+
+	stats := summarizeBands(spans)
+	if len(stats) != 2 || stats[0].Band != "20m" || stats[0].Time != 30*time.Minute || stats[0].QSYs != 1 || stats[0].LastFreq != 14074000 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats[1].Band != "40m" || stats[1].Time != 20*time.Minute || !stats[1].LastSeen.Equal(at(70)) {
+		t.Errorf("40m stats = %+v", stats[1])
+	}
+
+	clipped := summarizeBands(clipSpans(spans, at(5), at(15)))
+	if len(clipped) != 2 || clipped[0].Time != 5*time.Minute || clipped[1].Time != 5*time.Minute || clipped[0].QSYs+clipped[1].QSYs != 1 {
+		t.Errorf("clipped stats = %+v", clipped)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	if got, _ := parseSince("24h", now); !got.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("parseSince(24h) = %v", got)
+	}
+	if got, _ := parseSince("2026-03-01T00:00:00Z", now); !got.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseSince(RFC 3339) = %v", got)
+	}
+	if got, _ := parseSince("2026-03-01", now); got.Day() != 1 || got.Hour() != 0 {
+		t.Errorf("parseSince(date) = %v", got)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Error("parseSince(yesterday) succeeded")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// HookQueue runs hooks on a pool of workers so that a slow command doesn't
+// hold up polling. With one worker hooks run one at a time in event order;
+// with more they run concurrently.
+type HookQueue struct {
+	dispatcher *Dispatcher
+	size       int
+	// coalesce replaces an event still waiting for a hook with a newer one
+	// for the same radio, so only the latest band change runs.
+	coalesce bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []queuedHook
+	// busy counts the hooks of each event still waiting or running, for
+	// Wait; done is signalled as they finish.
+	busy map[eventKey]int
+	done *sync.Cond
+}
+
+type queuedHook struct {
+	sink Sink
+	ev   Event
+}
+
+// eventKey identifies an event emitted once and passed to several hooks.
+type eventKey struct {
+	typ, radio string
+	time       time.Time
+}
+
+func keyOf(ev Event) eventKey {
+	return eventKey{ev.Type, ev.Radio, ev.Time}
+}
+
+// NewHookQueue starts workers that run hooks queued with Wrap, keeping at
+// most size events waiting.
+func NewHookQueue(dispatcher *Dispatcher, workers, size int, coalesce bool) *HookQueue {
+	q := &HookQueue{dispatcher: dispatcher, size: size, coalesce: coalesce, busy: map[eventKey]int{}}
+	q.cond = sync.NewCond(&q.mu)
+	q.done = sync.NewCond(&q.mu)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Wrap returns a sink that queues events for s instead of handling them
+// before returning.
+func (q *HookQueue) Wrap(s Sink) Sink {
+	return &queuedSink{Sink: s, queue: q}
+}
+
+func (q *HookQueue) enqueue(s Sink, ev Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.coalesce {
+		for i, h := range q.pending {
+			if h.sink == s && h.ev.Radio == ev.Radio {
+				q.finish(h.ev)
+				q.busy[keyOf(ev)]++
+				q.pending[i].ev = ev
+				return
+			}
+		}
+	}
+	if len(q.pending) >= q.size {
+		dropped := q.pending[0]
+		q.pending = q.pending[1:]
+		q.finish(dropped.ev)
+		log.Printf("Hook queue full; dropping %s event for %s", dropped.ev.Type, dropped.sink.Name())
+	}
+	q.busy[keyOf(ev)]++
+	q.pending = append(q.pending, queuedHook{sink: s, ev: ev})
+	q.cond.Signal()
+}
+
+// finish records that a hook of ev has run or been dropped; q.mu is held.
+func (q *HookQueue) finish(ev Event) {
+	key := keyOf(ev)
+	if q.busy[key]--; q.busy[key] <= 0 {
+		delete(q.busy, key)
+	}
+	q.done.Broadcast()
+}
+
+// hold counts work for ev that isn't queued yet, such as an event waiting
+// for a hook's cooldown, until release.
+func (q *HookQueue) hold(ev Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.busy[keyOf(ev)]++
+}
+
+// release ends a hold.
+func (q *HookQueue) release(ev Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finish(ev)
+}
+
+// Wait blocks until the hooks queued for ev have finished, including any
+// waiting for a cooldown, so a sink can
+// act on a band change only once, say, a relay hook has switched the
+// antenna for it.
+func (q *HookQueue) Wait(ev Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.busy[keyOf(ev)] > 0 {
+		q.done.Wait()
+	}
+}
+
+// Len returns the number of events waiting for a worker.
+func (q *HookQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *HookQueue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.cond.Wait()
+		}
+		h := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		q.dispatcher.handle(h.sink, h.ev)
+
+		q.mu.Lock()
+		q.finish(h.ev)
+		q.mu.Unlock()
+	}
+}
+
+// queuedSink hands a sink's events to a HookQueue.
+type queuedSink struct {
+	Sink
+	queue *HookQueue
+}
+
+func (s *queuedSink) Handle(ev Event) error {
+	s.queue.enqueue(s.Sink, ev)
+	return nil
+}
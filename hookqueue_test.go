@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink records events, holding each until released.
+type blockingSink struct {
+	mu      sync.Mutex
+	bands   []string
+	release chan bool
+}
+
+func (s *blockingSink) Name() string        { return "blocking" }
+func (s *blockingSink) Wants(ev Event) bool { return true }
+func (s *blockingSink) Handle(ev Event) error {
+	<-s.release
+	s.mu.Lock()
+	s.bands = append(s.bands, ev.Band)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingSink) handled() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bands...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestHookQueueDoesNotBlockEmit(t *testing.T) {
+	sink := &blockingSink{release: make(chan bool)}
+	dispatcher := NewDispatcher(NewMetrics())
+	q := NewHookQueue(dispatcher, 1, 2, false)
+	dispatcher.Add(q.Wrap(sink))
+
+	// The first event is taken by the worker; the queue holds two more,
+	// dropping the oldest.
+	for _, band := range []string{"40m", "20m", "17m", "15m"} {
+		dispatcher.Emit(Event{Type: EventBandChange, Band: band})
+		if band == "40m" {
+			waitFor(t, func() bool { return q.Len() == 0 })
+		}
+	}
+	close(sink.release)
+	waitFor(t, func() bool { return len(sink.handled()) == 3 })
+	if got := sink.handled(); got[0] != "40m" || got[1] != "17m" || got[2] != "15m" {
+		t.Errorf("handled %v, want 40m 17m 15m in order", got)
+	}
+}
+
+func TestHookQueueCoalesces(t *testing.T) {
+	sink := &blockingSink{release: make(chan bool)}
+	dispatcher := NewDispatcher(NewMetrics())
+	q := NewHookQueue(dispatcher, 1, 8, true)
+	dispatcher.Add(q.Wrap(sink))
+
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "40m"})
+	waitFor(t, func() bool { return q.Len() == 0 })
+	for _, band := range []string{"20m", "17m", "15m"} {
+		dispatcher.Emit(Event{Type: EventBandChange, Band: band})
+	}
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "6m", Radio: "B"})
+	if n := q.Len(); n != 2 {
+		t.Errorf("%d events waiting, want one per radio", n)
+	}
+	close(sink.release)
+	waitFor(t, func() bool { return len(sink.handled()) == 3 })
+	if got := sink.handled(); got[1] != "15m" || got[2] != "6m" {
+		t.Errorf("handled %v, want 40m 15m 6m", got)
+	}
+}
+
+func TestHookQueueWait(t *testing.T) {
+	sink := &blockingSink{release: make(chan bool)}
+	dispatcher := NewDispatcher(NewMetrics())
+	q := NewHookQueue(dispatcher, 1, 8, false)
+	dispatcher.Add(q.Wrap(sink))
+
+	ev := Event{Type: EventBandChange, Band: "40m", Time: time.Now()}
+	dispatcher.Emit(ev)
+	waited := make(chan bool)
+	go func() {
+		q.Wait(ev)
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("returned before the hook ran")
+	case <-time.After(20 * time.Millisecond):
+	}
+	sink.release <- true
+	<-waited
+	if got := sink.handled(); len(got) != 1 {
+		t.Errorf("handled %v", got)
+	}
+	// Nothing is queued for another event.
+	q.Wait(Event{Type: EventBandChange, Band: "20m", Time: time.Now()})
+}
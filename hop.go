@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// HopSlot is a --hop slot: a frequency and optionally a mode.
+type HopSlot struct {
+	Freq float64
+	Mode string
+}
+
+// ParseHopSlot parses a slot such as "14.0956M" or "7.074M FT8".
+func ParseHopSlot(s string) (HopSlot, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return HopSlot{}, fmt.Errorf("hop slot %q: want FREQ [MODE]", s)
+	}
+	freq, err := parseFrequency(fields[0])
+	if err != nil {
+		return HopSlot{}, fmt.Errorf("hop slot %q: %v", s, err)
+	}
+	slot := HopSlot{Freq: freq}
+	if len(fields) == 2 {
+		slot.Mode = fields[1]
+	}
+	return slot, nil
+}
+
+// Hopper rotates a radio through its slots, changing slot at minute
+// boundaries so that every station with the same slots and interval is on
+// the same band at the same time, as WSPR band-hopping expects.
+type Hopper struct {
+	slots []HopSlot
+	// minutes is the slot length and offset is 1 to hop on odd minutes.
+	minutes, offset int64
+	radio           string
+	api             *API
+	dispatcher      *Dispatcher
+	// before and after, if set, run synchronously around each hop, e.g.
+	// to switch antennas before transmitting on the new band.
+	before, after Sink
+
+	current int
+}
+
+// NewHopper parses the --hop slots, hopping every interval on even or odd
+// minutes.
+func NewHopper(specs []string, interval time.Duration, align, radio string, api *API, dispatcher *Dispatcher) (*Hopper, error) {
+	if interval < time.Minute || interval%time.Minute != 0 {
+		return nil, fmt.Errorf("invalid --hop-interval %v, want a whole number of minutes", interval)
+	}
+	h := &Hopper{minutes: int64(interval / time.Minute), radio: radio, api: api, dispatcher: dispatcher, current: -1}
+	switch align {
+	case "even":
+	case "odd":
+		if h.minutes%2 != 0 {
+			return nil, fmt.Errorf("--hop-align odd needs an even --hop-interval")
+		}
+		h.offset = 1
+	default:
+		return nil, fmt.Errorf("invalid --hop-align %q, want even or odd", align)
+	}
+	for _, spec := range specs {
+		slot, err := ParseHopSlot(spec)
+		if err != nil {
+			return nil, err
+		}
+		h.slots = append(h.slots, slot)
+	}
+	return h, nil
+}
+
+// Check returns an error if the hopper's radio isn't monitored.
+func (h *Hopper) Check() error {
+	if _, ok := h.api.rigs[h.radio]; !ok {
+		if h.radio == "" {
+			return fmt.Errorf("--hop with several radios needs --hop-radio")
+		}
+		return fmt.Errorf("unknown --hop-radio %q", h.radio)
+	}
+	return nil
+}
+
+// slotAt returns the slot in use at t, and whether t starts it.
+func (h *Hopper) slotAt(t time.Time) (int, bool) {
+	period := t.Unix()/60 - h.offset
+	return int(period / h.minutes % int64(len(h.slots))), period%h.minutes == 0
+}
+
+// Run moves to the current slot straight away, then hops at the start of
+// every slot.
+func (h *Hopper) Run() {
+	now := time.Now()
+	slot, _ := h.slotAt(now)
+	h.hop(slot, now)
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+		if slot, starts := h.slotAt(next); starts && slot != h.current {
+			h.hop(slot, next)
+		}
+	}
+}
+
+// hop runs the before hook, changes to slot i and runs the after hook,
+// emitting hop-start and hop-done events.
+func (h *Hopper) hop(i int, t time.Time) {
+	slot := h.slots[i]
+	ev := Event{Type: EventHopStart, Time: t, Radio: h.radio, Freq: slot.Freq, Band: frequencyToBand(slot.Freq), Mode: slot.Mode}
+	if h.current >= 0 {
+		ev.PrevBand = frequencyToBand(h.slots[h.current].Freq)
+		ev.PrevMode = h.slots[h.current].Mode
+	}
+	h.current = i
+	h.dispatcher.Emit(ev)
+	if err := h.runHook(h.before, ev); err != nil {
+		// Tuning to a band the antenna wasn't switched for could damage
+		// the rig, so a failed before hook skips the hop.
+		h.done(ev, fmt.Errorf("%s: %v", h.before.Name(), err))
+		return
+	}
+
+	fmt.Printf("Hopping to %s\n", strings.TrimSpace(fmt.Sprintf("%.6f MHz %s", slot.Freq/1000000, slot.Mode)))
+	var err error
+	if dryRun {
+		dryRunf("would tune to %.0f Hz", slot.Freq)
+	} else if _, err = h.api.tune(h.radio, slot.Freq); err == nil && slot.Mode != "" {
+		_, err = h.api.setMode(h.radio, slot.Mode)
+	}
+	h.done(ev, err)
+}
+
+func (h *Hopper) done(ev Event, err error) {
+	ev.Type = EventHopDone
+	if err != nil {
+		log.Printf("Error hopping to %.0f Hz: %v", ev.Freq, err)
+		ev.Error = err.Error()
+	}
+	h.dispatcher.Emit(ev)
+	if err == nil {
+		if err := h.runHook(h.after, ev); err != nil {
+			log.Printf("Error in %s: %v", h.after.Name(), err)
+		}
+	}
+}
+
+// runHook runs a hook, reporting a failure as a hook-failed or
+// hook-timeout event.
+func (h *Hopper) runHook(hook Sink, ev Event) error {
+	if hook == nil {
+		return nil
+	}
+	err := hook.Handle(ev)
+	h.dispatcher.reportHookError(ev, err)
+	return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type hopHook struct {
+	err    error
+	events []Event
+}
+
+func (h *hopHook) Name() string        { return "hook" }
+func (h *hopHook) Wants(ev Event) bool { return true }
+func (h *hopHook) Handle(ev Event) error {
+	h.events = append(h.events, ev)
+	return h.err
+}
+
+func TestHopperSlots(t *testing.T) {
+	slots := []string{"14.0956M", "10.1387M", "7.0386M"}
+	at := func(minute int) time.Time { return time.Date(2024, 1, 3, 12, minute, 0, 0, time.UTC) }
+
+	even, err := NewHopper(slots, 2*time.Minute, "even", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	odd, err := NewHopper(slots, 2*time.Minute, "odd", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		h        *Hopper
+		minute   int
+		slot     int
+		starting bool
+	}{
+		{even, 0, 0, true},
+		{even, 1, 0, false},
+		{even, 2, 1, true},
+		{even, 4, 2, true},
+		{even, 6, 0, true},
+		{odd, 0, 2, false},
+		{odd, 1, 0, true},
+		{odd, 3, 1, true},
+	} {
+		slot, starting := tc.h.slotAt(at(tc.minute))
+		if slot != tc.slot || starting != tc.starting {
+			t.Errorf("offset %d minute %d: slot %d, starting %v; want %d, %v", tc.h.offset, tc.minute, slot, starting, tc.slot, tc.starting)
+		}
+	}
+
+	for _, bad := range []struct {
+		interval time.Duration
+		align    string
+	}{{90 * time.Second, "even"}, {3 * time.Minute, "odd"}, {2 * time.Minute, "late"}} {
+		if _, err := NewHopper(slots, bad.interval, bad.align, "", nil, nil); err == nil {
+			t.Errorf("NewHopper(%v, %q) succeeded", bad.interval, bad.align)
+		}
+	}
+	if _, err := NewHopper([]string{"14.0956M WSPR extra"}, 2*time.Minute, "even", "", nil, nil); err == nil {
+		t.Error("expected an error for a bad slot")
+	}
+}
+
+func TestHopperHop(t *testing.T) {
+	sink := &captureSink{}
+	rig := &fakeModeBackend{fakeBackend: fakeBackend{name: "fake"}}
+	h, err := NewHopper([]string{"14.0956M WSPR", "7.074M FT8"}, 2*time.Minute, "even", "", &API{rigs: map[string]Backend{"": rig}}, NewDispatcher(NewMetrics(), sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Check(); err != nil {
+		t.Fatal(err)
+	}
+	before, after := &hopHook{}, &hopHook{}
+	h.before, h.after = before, after
+
+	h.hop(0, time.Now())
+	h.hop(1, time.Now())
+	if len(rig.sets) != 2 || rig.sets[1] != 7074000 || len(rig.modes) != 2 || rig.modes[1] != "FT8" {
+		t.Errorf("tuned to %v, modes %v", rig.sets, rig.modes)
+	}
+	if len(before.events) != 2 || len(after.events) != 2 {
+		t.Fatalf("before hook ran %d times, after %d", len(before.events), len(after.events))
+	}
+	if ev := before.events[1]; ev.Type != EventHopStart || ev.Band != "40m" || ev.PrevBand != "20m" || ev.Mode != "FT8" {
+		t.Errorf("before hook event = %+v", ev)
+	}
+	if ev := after.events[1]; ev.Type != EventHopDone || ev.Error != "" {
+		t.Errorf("after hook event = %+v", ev)
+	}
+
+	// Without the antenna switched, the rig stays where it was.
+	before.err = errors.New("switch offline")
+	h.hop(0, time.Now())
+	if len(rig.sets) != 2 || len(after.events) != 2 {
+		t.Errorf("hopped after the before hook failed: %v", rig.sets)
+	}
+	if ev := sink.events[len(sink.events)-1]; ev.Type != EventHopDone || ev.Error == "" {
+		t.Errorf("last event = %+v", ev)
+	}
+}
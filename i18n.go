@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Message catalogs are gettext-style .po files of msgid/msgstr pairs,
+// one file per language, keyed by the English message.
+//
+//go:embed locales/*.po
+var localeFiles embed.FS
+
+// catalog maps English messages to the selected language; nil means
+// English.
+var catalog map[string]string
+
+// T returns the translation of a user-facing message, or the message
+// itself when the catalog has none.
+func T(msgid string) string {
+	if msgstr, ok := catalog[msgid]; ok && msgstr != "" {
+		return msgstr
+	}
+	return msgid
+}
+
+// setLocale loads the catalog for a language such as "de" or "de_DE.UTF-8".
+// English, "C" and "POSIX" need no catalog.
+func setLocale(locale string) error {
+	lang := localeLanguage(locale)
+	if lang == "" || lang == "en" || lang == "c" || lang == "posix" {
+		catalog = nil
+		return nil
+	}
+
+	data, err := localeFiles.ReadFile("locales/" + lang + ".po")
+	if err != nil {
+		return fmt.Errorf("no messages for language %q (have %s)", lang, strings.Join(availableLocales(), ", "))
+	}
+	c, err := parsePO(string(data))
+	if err != nil {
+		return fmt.Errorf("locales/%s.po: %v", lang, err)
+	}
+	catalog = c
+	return nil
+}
+
+// localeLanguage reduces a locale such as "pt_BR.UTF-8" to its language.
+func localeLanguage(locale string) string {
+	if i := strings.IndexAny(locale, "_.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
+
+// envLocale returns the locale from the environment in gettext's order of
+// precedence.
+func envLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func availableLocales() []string {
+	langs := []string{"en"}
+	entries, _ := localeFiles.ReadDir("locales")
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".po"))
+	}
+	return langs
+}
+
+// parsePO reads msgid and msgstr entries with quoted strings, which may be
+// continued on following lines. Comments start with #.
+func parsePO(data string) (map[string]string, error) {
+	messages := map[string]string{}
+	var msgid, msgstr *string
+	var id, str string
+	var cur *string
+
+	flush := func() {
+		if msgid != nil && msgstr != nil && *msgid != "" {
+			messages[*msgid] = *msgstr
+		}
+		msgid, msgstr, cur = nil, nil, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "msgid "):
+			flush()
+			id, msgid, cur = "", &id, &id
+			line = strings.TrimPrefix(line, "msgid ")
+		case strings.HasPrefix(line, "msgstr "):
+			if msgid == nil {
+				return nil, fmt.Errorf("line %d: msgstr without msgid", n)
+			}
+			str, msgstr, cur = "", &str, &str
+			line = strings.TrimPrefix(line, "msgstr ")
+		}
+
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: unexpected %q", n, line)
+		}
+		s, err := strconv.Unquote(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid string %s", n, line)
+		}
+		*cur += s
+	}
+	flush()
+	return messages, nil
+}
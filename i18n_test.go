@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParsePO(t *testing.T) {
+	messages, err := parsePO(`# comment
+msgid "Hello %s\n"
+msgstr "Hallo "
+"%s\n"
+
+msgid "Untranslated"
+msgstr ""
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages["Hello %s\n"] != "Hallo %s\n" {
+		t.Errorf("messages = %q", messages)
+	}
+
+	if _, err := parsePO(`msgstr "orphan"`); err == nil {
+		t.Error("msgstr without msgid accepted")
+	}
+	if _, err := parsePO(`msgid unquoted`); err == nil {
+		t.Error("unquoted msgid accepted")
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	defer setLocale("")
+
+	if err := setLocale("de_DE.UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprintf(T("Warning: %s occupies %.0f Hz, more than the %.0f Hz allowed in %s"), "MFSK64", 2000.0, 500.0, "20m-CW")
+	if got != "Warnung: MFSK64 belegt 2000 Hz, mehr als die in 20m-CW erlaubten 500 Hz" {
+		t.Errorf("translated warning = %q", got)
+	}
+	if T("not in the catalog") != "not in the catalog" {
+		t.Error("missing message not passed through")
+	}
+
+	if err := setLocale("C"); err != nil || T("Reconnecting in %v") != "Reconnecting in %v" {
+		t.Errorf("C locale: %v, %q", err, T("Reconnecting in %v"))
+	}
+	if err := setLocale("xx"); err == nil {
+		t.Error("unknown language accepted")
+	}
+}
+
+// TestCatalogsMatchSource checks that every translated message is still
+// used, and keeps its format verbs.
+func TestCatalogsMatchSource(t *testing.T) {
+	var source strings.Builder
+	entries, _ := os.ReadDir(".")
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".go") && !strings.HasSuffix(e.Name(), "_test.go") {
+			data, _ := os.ReadFile(e.Name())
+			source.Write(data)
+		}
+	}
+
+	verb := regexp.MustCompile(`%[-+# 0]*(\[\d+\])?[\d.]*(\[\d+\])?[a-zA-Z]`)
+	for _, lang := range availableLocales()[1:] {
+		data, _ := localeFiles.ReadFile("locales/" + lang + ".po")
+		messages, err := parsePO(string(data))
+		if err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		for id, str := range messages {
+			if !strings.Contains(source.String(), fmt.Sprintf("%q", id)) {
+				t.Errorf("%s: %q is not used in the source", lang, id)
+			}
+			if a, b := len(verb.FindAllString(id, -1)), len(verb.FindAllString(str, -1)); a != b {
+				t.Errorf("%s: %q has %d format verbs, translation %d", lang, id, a, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInfluxPending bounds the points kept while InfluxDB is unreachable;
+// beyond it the oldest are dropped.
+const maxInfluxPending = 10000
+
+// InfluxWriter sends station telemetry as InfluxDB line protocol: a sample
+// of each radio's frequency, band and mode every interval, and a point for
+// every event. Points go to an InfluxDB write URL, a udp://host:port
+// listener or, for "-", standard output.
+type InfluxWriter struct {
+	target string
+	token  string
+	api    *API
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []string
+}
+
+// NewInfluxWriter checks target and returns a writer for it. token, if set,
+// is sent as an InfluxDB 2 API token.
+func NewInfluxWriter(target, token string, api *API) (*InfluxWriter, error) {
+	if target != "-" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "udp") || u.Host == "" {
+			return nil, fmt.Errorf("invalid --influx %q, want an http(s) write URL, udp://host:port or -", target)
+		}
+	}
+	return &InfluxWriter{target: target, token: token, api: api, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (w *InfluxWriter) Name() string { return "influx" }
+
+// Wants every event but the RPC calls made through the proxy, which are
+// too many to be worth graphing.
+func (w *InfluxWriter) Wants(ev Event) bool { return ev.Type != EventRPCCall }
+
+// Handle queues a point for the event, sent with the next samples.
+func (w *InfluxWriter) Handle(ev Event) error {
+	w.queue([]string{influxEventLine(ev)})
+	return nil
+}
+
+func (w *InfluxWriter) queue(lines []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, lines...)
+	if n := len(w.pending) - maxInfluxPending; n > 0 {
+		w.pending = w.pending[n:]
+	}
+}
+
+// Run samples every monitor each interval and sends the samples with the
+// events queued since the last.
+func (w *InfluxWriter) Run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		var samples []string
+		for _, m := range w.api.monitors {
+			if s := m.Status(); !s.Time.IsZero() && !s.Standby {
+				samples = append(samples, influxSampleLine(s))
+			}
+		}
+		w.queue(samples)
+		w.flush()
+	}
+}
+
+// flush sends the queued points, keeping them for the next attempt if
+// they can't be sent.
+func (w *InfluxWriter) flush() {
+	w.mu.Lock()
+	lines := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if len(lines) == 0 {
+		return
+	}
+	if err := w.write(lines); err != nil {
+		log.Printf("Error writing to InfluxDB: %v", err)
+		w.mu.Lock()
+		w.pending = append(lines, w.pending...)
+		w.mu.Unlock()
+		w.queue(nil)
+	}
+}
+
+func (w *InfluxWriter) write(lines []string) error {
+	body := strings.Join(lines, "\n") + "\n"
+	if w.target == "-" {
+		_, err := io.WriteString(os.Stdout, body)
+		return err
+	}
+	if dryRun {
+		dryRunf("would write %d points to %s", len(lines), w.target)
+		return nil
+	}
+	if strings.HasPrefix(w.target, "udp://") {
+		return w.writeUDP(lines)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.target, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s: %s", w.target, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// maxInfluxDatagram keeps UDP writes within what InfluxDB reads at once.
+const maxInfluxDatagram = 8192
+
+// writeUDP sends lines in as few datagrams as fit under maxInfluxDatagram.
+func (w *InfluxWriter) writeUDP(lines []string) error {
+	conn, err := net.Dial("udp", strings.TrimPrefix(w.target, "udp://"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+len(line)+1 > maxInfluxDatagram {
+			if _, err := conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		packet = append(append(packet, line...), '\n')
+	}
+	_, err = conn.Write(packet)
+	return err
+}
+
+// influxSampleLine is the fldigi_rig point for a radio's status.
+func influxSampleLine(s MonitorStatus) string {
+	tags := map[string]string{"radio": s.Radio, "band": s.Band, "mode": s.Mode}
+	fields := []string{"online=" + strconv.FormatBool(s.Error == "")}
+	if s.Error == "" {
+		fields = append(fields, "freq="+strconv.FormatFloat(s.Freq, 'f', -1, 64))
+		if s.Segment != "" {
+			fields = append(fields, "segment="+influxString(s.Segment))
+		}
+	}
+	return influxLine("fldigi_rig", tags, fields, s.Time)
+}
+
+// influxEventLine is the fldigi_event point for an event.
+func influxEventLine(ev Event) string {
+	tags := map[string]string{"type": ev.Type, "radio": ev.Radio, "band": ev.Band}
+	fields := []string{"count=1i"}
+	if ev.Freq != 0 {
+		fields = append(fields, "freq="+strconv.FormatFloat(ev.Freq, 'f', -1, 64))
+	}
+	for _, f := range []struct{ key, value string }{
+		{"prev_band", ev.PrevBand},
+		{"mode", ev.Mode},
+		{"prev_mode", ev.PrevMode},
+		{"segment", ev.Segment},
+		{"rule", ev.Rule},
+		{"command", ev.Command},
+		{"error", ev.Error},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.key+"="+influxString(f.value))
+		}
+	}
+	return influxLine("fldigi_event", tags, fields, ev.Time)
+}
+
+// influxLine formats a point, leaving out empty tags.
+func influxLine(measurement string, tags map[string]string, fields []string, t time.Time) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(influxEscape(measurement))
+	for _, k := range keys {
+		b.WriteString("," + k + "=" + influxEscape(tags[k]))
+	}
+	b.WriteString(" " + strings.Join(fields, ",") + " " + strconv.FormatInt(t.UnixNano(), 10))
+	return b.String()
+}
+
+// influxEscape escapes a measurement name or tag value.
+var influxEscape = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `).Replace
+
+// influxString quotes a string field value. Line protocol has no way to
+// write a newline, so it becomes a space.
+func influxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
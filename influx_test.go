@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxLines(t *testing.T) {
+	when := time.Unix(1772366400, 0)
+	sample := influxSampleLine(MonitorStatus{Radio: "rig 1", Time: when, Freq: 14074000, Band: "20m", Segment: "20m-data", Mode: "USB"})
+	if want := `fldigi_rig,band=20m,mode=USB,radio=rig\ 1 online=true,freq=14074000,segment="20m-data" 1772366400000000000`; sample != want {
+		t.Errorf("sample = %s\nwant     %s", sample, want)
+	}
+	offline := influxSampleLine(MonitorStatus{Time: when, Error: "connection refused"})
+	if want := `fldigi_rig online=false 1772366400000000000`; offline != want {
+		t.Errorf("offline sample = %s", offline)
+	}
+
+	ev := influxEventLine(Event{Type: EventDisconnected, Time: when, Error: "Post \"http://x\": refused\nagain"})
+	if want := `fldigi_event,type=disconnected count=1i,error="Post \"http://x\": refused again" 1772366400000000000`; ev != want {
+		t.Errorf("event = %s\nwant    %s", ev, want)
+	}
+}
+
+func TestInfluxWriterHTTP(t *testing.T) {
+	var bodies []string
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token s3cret" || r.URL.Query().Get("bucket") != "shack" {
+			t.Errorf("request %s with %q", r.URL, r.Header.Get("Authorization"))
+		}
+		if fail {
+			fail = false
+			http.Error(w, "bucket not found", http.StatusNotFound)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewInfluxWriter(srv.URL+"/api/v2/write?org=op&bucket=shack", "s3cret", &API{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Handle(Event{Type: EventBandChange, Band: "20m", Time: time.Unix(1, 0)})
+	w.flush() // fails, so the point is kept
+	w.Handle(Event{Type: EventBandChange, Band: "40m", Time: time.Unix(2, 0)})
+	w.flush()
+	if len(bodies) != 1 || strings.Count(bodies[0], "\n") != 2 || !strings.HasPrefix(bodies[0], "fldigi_event,band=20m,") {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
+
+func TestInfluxWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := NewInfluxWriter("udp://"+conn.LocalAddr().String(), "", &API{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Handle(Event{Type: EventModeChange, Mode: "CW", PrevMode: "USB", Time: time.Unix(1, 0)})
+	w.flush()
+
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "fldigi_event,type=mode-change count=1i,mode=\"CW\",prev_mode=\"USB\" 1000000000\n" {
+		t.Errorf("datagram = %q, %v", buf[:n], err)
+	}
+
+	if _, err := NewInfluxWriter("localhost:8086", "", &API{}); err == nil {
+		t.Error("NewInfluxWriter accepted a target without a scheme")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "sync"
+
+// Interlock tracks which radios are transmitting so band changes on one
+// radio can be held back while another is on the air, keeping antenna and
+// filter relays from switching during its transmission.
+type Interlock struct {
+	mu           sync.Mutex
+	transmitting map[string]bool
+}
+
+func NewInterlock() *Interlock {
+	return &Interlock{transmitting: map[string]bool{}}
+}
+
+// Set records whether a radio is transmitting.
+func (i *Interlock) Set(radio string, tx bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.transmitting[radio] = tx
+}
+
+// OtherTransmitting returns a radio other than the given one that is
+// transmitting, if any.
+func (i *Interlock) OtherTransmitting(radio string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for other, tx := range i.transmitting {
+		if tx && other != radio {
+			return other, true
+		}
+	}
+	return "", false
+}
+
+// isSwitchingEvent reports whether an event type triggers band switching
+// hooks and so is held back by the interlock.
+func isSwitchingEvent(eventType string) bool {
+	switch eventType {
+	case EventInitialBand, EventBandChange, EventSegmentChange:
+		return true
+	}
+	return false
+}
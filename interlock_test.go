@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterlockDefersBandChanges(t *testing.T) {
+	interlock := NewInterlock()
+	m, sink := newTestMonitor()
+	m.radio = "A"
+	m.interlock = interlock
+	m.tx = &fakeTX{state: "RX"}
+	now := time.Now()
+
+	m.observe(14074000, now)
+	if len(sink.events) == 0 {
+		t.Fatal("initial band not emitted while radio B is receiving")
+	}
+	sink.events = nil
+
+	// Radio B keys up: radio A's move to 40m waits for it.
+	interlock.Set("B", true)
+	m.updateTX(0)
+	m.observe(7074000, now)
+	if len(sink.events) != 0 {
+		t.Fatalf("events emitted during B's transmission: %v", sink.types())
+	}
+
+	// Radio A's own transmission doesn't hold back its events.
+	if _, busy := interlock.OtherTransmitting("B"); busy {
+		t.Error("radio B blocked by its own transmission")
+	}
+
+	interlock.Set("B", false)
+	m.updateTX(0)
+	got := sink.types()
+	if len(got) != 2 || got[0] != EventBandChange || got[1] != EventSegmentChange {
+		t.Errorf("released events = %v", got)
+	}
+	if sink.events[0].Band != "40m" || sink.events[0].Radio != "A" {
+		t.Errorf("released %+v", sink.events[0])
+	}
+}
+
+func TestMonitorTXEvents(t *testing.T) {
+	m, sink := newTestMonitor()
+	tx := &fakeTX{state: "RX"}
+	m.tx, m.txEvents = tx, true
+	m.currentBand, m.currentMode = "20m", "BPSK31"
+
+	m.updateTX(14070000)
+	tx.state = "TX"
+	m.updateTX(14070000)
+	m.updateTX(14070000)
+	tx.state = "RX"
+	m.updateTX(14070000)
+
+	got := sink.types()
+	if len(got) != 2 || got[0] != EventTXStart || got[1] != EventTXStop {
+		t.Fatalf("events = %v, want [tx-start tx-stop]", got)
+	}
+	if ev := sink.events[0]; ev.Band != "20m" || ev.Freq != 14070000 || ev.Mode != "BPSK31" {
+		t.Errorf("tx-start = %+v", ev)
+	}
+
+	// A tune carrier is on the air too.
+	m.interlock = NewInterlock()
+	tx.state = "TUNE"
+	m.updateTX(14070000)
+	if got := sink.types(); len(got) != 3 || got[2] != EventTXStart {
+		t.Errorf("events = %v after tuning", got)
+	}
+	if other, busy := m.interlock.OtherTransmitting("B"); !busy || other != m.radio {
+		t.Error("the interlock missed the tune carrier")
+	}
+}
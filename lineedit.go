@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// lineEditor reads lines typed at a terminal in raw mode, with the usual
+// keys for editing, history on the arrow keys and Tab completion.
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+	// complete returns where the word being completed starts in line and
+	// what it may be completed to.
+	complete func(line string) (int, []string)
+}
+
+// readLine reads a line after showing prompt, returning io.EOF for Ctrl-D
+// on an empty line.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var buf, saved []rune
+	pos, hist := 0, len(e.history)
+	tabbed := false
+	for {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		lastTab := tabbed
+		tabbed = false
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(buf)
+			if strings.TrimSpace(line) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+				e.history = append(e.history, line)
+			}
+			return line, nil
+		case 3: // Ctrl-C abandons the line
+			fmt.Fprint(e.out, "^C\r\n")
+			buf, pos, hist = nil, 0, len(e.history)
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 21: // Ctrl-U
+			buf, pos = buf[pos:], 0
+		case 127, 8:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case '\t':
+			buf, pos = e.completeWord(buf, pos, lastTab)
+			tabbed = true
+		case 27:
+			switch e.escape() {
+			case 'A':
+				if hist > 0 {
+					if hist == len(e.history) {
+						saved = buf
+					}
+					hist--
+					buf = []rune(e.history[hist])
+					pos = len(buf)
+				}
+			case 'B':
+				if hist < len(e.history) {
+					hist++
+					if hist == len(e.history) {
+						buf = saved
+					} else {
+						buf = []rune(e.history[hist])
+					}
+					pos = len(buf)
+				}
+			case 'C':
+				pos = min(pos+1, len(buf))
+			case 'D':
+				pos = max(pos-1, 0)
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case '~':
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+	}
+}
+
+// escape reads the rest of an escape sequence, returning the letter of an
+// arrow, Home or End key, or '~' for Delete.
+func (e *lineEditor) escape() rune {
+	if r, _, err := e.in.ReadRune(); err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	r, _, _ := e.in.ReadRune()
+	if r == '3' {
+		if next, _, _ := e.in.ReadRune(); next == '~' {
+			return '~'
+		}
+		return 0
+	}
+	return r
+}
+
+// completeWord completes the word before the cursor as far as its choices
+// agree, adding a space once only one is left, and lists the choices when
+// Tab is pressed again.
+func (e *lineEditor) completeWord(buf []rune, pos int, again bool) ([]rune, int) {
+	if e.complete == nil {
+		return buf, pos
+	}
+	line := string(buf[:pos])
+	start, choices := e.complete(line)
+	if len(choices) == 0 {
+		return buf, pos
+	}
+	common := choices[0]
+	for _, c := range choices[1:] {
+		for !strings.HasPrefix(strings.ToLower(c), strings.ToLower(common)) {
+			_, size := utf8.DecodeLastRuneInString(common)
+			common = common[:len(common)-size]
+		}
+	}
+	if len(choices) == 1 {
+		common += " "
+	}
+	if word := line[start:]; len(common) > len(word) {
+		completed := []rune(line[:start] + common)
+		return append(completed, buf[pos:]...), len(completed)
+	}
+	if again && len(choices) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(choices, "  "))
+	}
+	return buf, pos
+}
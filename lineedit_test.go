@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	keys := strings.Join([]string{
+		"frq\x1b[De\r",               // insert before the cursor
+		"mode\x1b[A\x1b[B x\x7fcw\r", // up then down restores the line
+		"\x1b[A\x1b[A\r",             // history
+		"hello\x03",                  // Ctrl-C abandons the line
+		"m\to\tbp\t\r",               // completion
+		"\x04",
+	}, "")
+	e := &lineEditor{
+		in:  bufio.NewReader(strings.NewReader(keys)),
+		out: io.Discard,
+		complete: func(line string) (int, []string) {
+			start := strings.LastIndex(line, " ") + 1
+			switch line[start:] {
+			case "m":
+				return start, []string{"methods", "mode"}
+			case "mo":
+				return start, []string{"mode"}
+			case "bp":
+				return start, []string{"BPSK31", "BPSK63"}
+			}
+			return start, nil
+		},
+	}
+
+	var lines []string
+	for {
+		line, err := e.readLine("> ")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	want := []string{"freq", "mode cw", "freq", "mode BPSK"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if want := []string{"freq", "mode cw", "freq", "mode BPSK"}; !reflect.DeepEqual(e.history, want) {
+		t.Errorf("history = %q, want %q", e.history, want)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the histogram bucket upper bounds, in seconds, used
+// for sink and rule execution times.
+var durationBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type counterVec struct {
+	help   string
+	label  string
+	values map[string]float64
+}
+
+type gaugeVec struct {
+	help   string
+	label  string
+	values map[string]float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type histogramVec struct {
+	help   string
+	label  string
+	values map[string]*histogram
+}
+
+// Metrics collects counters and histograms and renders them in the
+// Prometheus text exposition format.
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[string]*counterVec
+	histograms map[string]*histogramVec
+	gauges     map[string]*gaugeVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		counters:   map[string]*counterVec{},
+		histograms: map[string]*histogramVec{},
+		gauges:     map[string]*gaugeVec{},
+	}
+
+	m.counter("fldigi_cmd_polls_total", "", "Number of frequency polls.")
+	m.counter("fldigi_cmd_poll_errors_total", "", "Number of failed frequency polls.")
+	m.counter("fldigi_cmd_events_total", "type", "Number of events emitted, by type.")
+
+	for _, kind := range []string{"sink", "rule"} {
+		m.counter("fldigi_cmd_"+kind+"_fired_total", kind, "Number of times a "+kind+" was triggered.")
+		m.counter("fldigi_cmd_"+kind+"_succeeded_total", kind, "Number of successful "+kind+" executions.")
+		m.counter("fldigi_cmd_"+kind+"_failed_total", kind, "Number of failed "+kind+" executions.")
+		m.histograms["fldigi_cmd_"+kind+"_duration_seconds"] = &histogramVec{
+			help:   "Execution time of a " + kind + ".",
+			label:  kind,
+			values: map[string]*histogram{},
+		}
+	}
+
+	m.gauges["fldigi_cmd_frequency_hz"] = &gaugeVec{
+		help:   "Last frequency read from the rig, by radio when several are monitored.",
+		label:  "radio",
+		values: map[string]float64{},
+	}
+	return m
+}
+
+func (m *Metrics) counter(name, label, help string) {
+	m.counters[name] = &counterVec{help: help, label: label, values: map[string]float64{}}
+}
+
+func (m *Metrics) inc(name, labelValue string) {
+	m.counters[name].values[labelValue]++
+}
+
+func (m *Metrics) observe(name, labelValue string, d time.Duration) {
+	vec := m.histograms[name]
+	h, ok := vec.values[labelValue]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		vec.values[labelValue] = h
+	}
+
+	secs := d.Seconds()
+	for i, le := range durationBuckets {
+		if secs <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+// Poll records the outcome of a frequency poll of a radio ("" when only one
+// is monitored).
+func (m *Metrics) Poll(radio string, freq float64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inc("fldigi_cmd_polls_total", "")
+	if err != nil {
+		m.inc("fldigi_cmd_poll_errors_total", "")
+		return
+	}
+	m.gauges["fldigi_cmd_frequency_hz"].values[radio] = freq
+}
+
+// Event records an emitted event.
+func (m *Metrics) Event(eventType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inc("fldigi_cmd_events_total", eventType)
+}
+
+// Sink records a sink execution.
+func (m *Metrics) Sink(name string, err error, d time.Duration) {
+	m.execution("sink", name, err, d)
+}
+
+// Rule records a rule action execution.
+func (m *Metrics) Rule(name string, err error, d time.Duration) {
+	m.execution("rule", name, err, d)
+}
+
+func (m *Metrics) execution(kind, name string, err error, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := "fldigi_cmd_" + kind
+	m.inc(prefix+"_fired_total", name)
+	if err != nil {
+		m.inc(prefix+"_failed_total", name)
+	} else {
+		m.inc(prefix+"_succeeded_total", name)
+	}
+	m.observe(prefix+"_duration_seconds", name, d)
+}
+
+// SinkResult is how often a sink has run and failed.
+type SinkResult struct {
+	Sink   string `json:"sink"`
+	Runs   int    `json:"runs"`
+	Failed int    `json:"failed"`
+}
+
+// SinkResults returns the result counts of every sink that has run, by
+// name.
+func (m *Metrics) SinkResults() []SinkResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fired := m.counters["fldigi_cmd_sink_fired_total"].values
+	failed := m.counters["fldigi_cmd_sink_failed_total"].values
+	results := []SinkResult{}
+	for _, name := range sortedKeys(fired) {
+		results = append(results, SinkResult{Sink: name, Runs: int(fired[name]), Failed: int(failed[name])})
+	}
+	return results
+}
+
+// Write renders all metrics in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, name := range sortedKeys(m.gauges) {
+		vec := m.gauges[name]
+		if len(vec.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, vec.help, name)
+		for _, lv := range sortedKeys(vec.values) {
+			if lv == "" {
+				fmt.Fprintf(w, "%s %g\n", name, vec.values[lv])
+				continue
+			}
+			fmt.Fprintf(w, "%s{%s} %g\n", name, label(vec.label, lv), vec.values[lv])
+		}
+	}
+
+	for _, name := range sortedKeys(m.counters) {
+		vec := m.counters[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, vec.help, name)
+		if vec.label == "" {
+			fmt.Fprintf(w, "%s %g\n", name, vec.values[""])
+			continue
+		}
+		for _, lv := range sortedKeys(vec.values) {
+			fmt.Fprintf(w, "%s{%s} %g\n", name, label(vec.label, lv), vec.values[lv])
+		}
+	}
+
+	for _, name := range sortedKeys(m.histograms) {
+		vec := m.histograms[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, vec.help, name)
+		for _, lv := range sortedKeys(vec.values) {
+			h := vec.values[lv]
+			l := label(vec.label, lv)
+			for i, le := range durationBuckets {
+				fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, l, le, h.counts[i])
+			}
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, l, h.count)
+			fmt.Fprintf(w, "%s_sum{%s} %g\n", name, l, h.sum)
+			fmt.Fprintf(w, "%s_count{%s} %d\n", name, l, h.count)
+		}
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.Write(w)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
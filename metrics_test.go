@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsLabels(t *testing.T) {
+	m := NewMetrics()
+	m.Sink("command", nil, 20*time.Millisecond)
+	m.Sink("command", errors.New("exit status 1"), 2*time.Second)
+	m.Rule(`antenna "A"`, nil, time.Millisecond)
+
+	var buf bytes.Buffer
+	m.Write(&buf)
+	out := buf.String()
+
+	expected := []string{
+		`fldigi_cmd_sink_fired_total{sink="command"} 2`,
+		`fldigi_cmd_sink_succeeded_total{sink="command"} 1`,
+		`fldigi_cmd_sink_failed_total{sink="command"} 1`,
+		`fldigi_cmd_sink_duration_seconds_bucket{sink="command",le="0.05"} 1`,
+		`fldigi_cmd_sink_duration_seconds_bucket{sink="command",le="+Inf"} 2`,
+		`fldigi_cmd_sink_duration_seconds_count{sink="command"} 2`,
+		`fldigi_cmd_rule_fired_total{rule="antenna \"A\""} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics output missing %q", line)
+		}
+	}
+}
+
+type recordingSink struct {
+	events []Event
+}
+
+func (s *recordingSink) Name() string        { return "recording" }
+func (s *recordingSink) Wants(ev Event) bool { return ev.Type == EventBandChange }
+func (s *recordingSink) Handle(ev Event) error {
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func TestDispatcherSkipsUnwantedEvents(t *testing.T) {
+	m := NewMetrics()
+	sink := &recordingSink{}
+	d := NewDispatcher(m, sink)
+
+	d.Emit(Event{Type: EventInitialBand, Band: "20m"})
+	d.Emit(Event{Type: EventBandChange, Band: "40m", PrevBand: "20m"})
+
+	if len(sink.events) != 1 || sink.events[0].Band != "40m" {
+		t.Errorf("sink received %v; want only the band change", sink.events)
+	}
+
+	var buf bytes.Buffer
+	m.Write(&buf)
+	if !strings.Contains(buf.String(), `fldigi_cmd_sink_fired_total{sink="recording"} 1`+"\n") {
+		t.Error("unwanted event counted as sink execution")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed modes.txt
+var modeCatalogData string
+
+// modeBandwidths maps upper-case mode names to their occupied bandwidth in Hz.
+var modeBandwidths = map[string]float64{}
+
+func init() {
+	loadModeCatalog()
+}
+
+func loadModeCatalog() {
+	scanner := bufio.NewScanner(strings.NewReader(modeCatalogData))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip comments and empty lines
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Parse mode:bandwidth format
+		parts := strings.Split(line, ":")
+		if len(parts) != 2 {
+			continue
+		}
+
+		bw, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+
+		modeBandwidths[strings.ToUpper(parts[0])] = bw
+	}
+}
+
+// modeBandwidth returns the occupied bandwidth of a mode in Hz.
+func modeBandwidth(mode string) (float64, bool) {
+	bw, ok := modeBandwidths[strings.ToUpper(strings.TrimSpace(mode))]
+	return bw, ok
+}
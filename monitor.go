@@ -0,0 +1,490 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Monitor polls fldigi and emits events when the band or segment changes.
+type Monitor struct {
+	// radio labels the rig when several are monitored, e.g. "A" and "B".
+	radio string
+
+	backend      Backend
+	getFrequency func() (float64, error)
+	getMode      func() (string, error)
+	interval     time.Duration
+	debouncer    *BandDebouncer
+	segDebouncer *BandDebouncer
+	dispatcher   *Dispatcher
+	metrics      *Metrics
+	privileges   *Privileges
+	rules        *RuleEngine
+	ha           *HANode
+	follower     *Follower
+	civ          *CIVOutput
+	notes        *Notes
+
+	// checkBandwidth warns when the mode is wider than the segment allows;
+	// with inhibit set, transmissions in violation are also aborted.
+	checkBandwidth bool
+	inhibit        TXController
+
+	// interlock holds band changes back while another radio transmits;
+	// tx reports this radio's own TX state to it and, with txEvents, is
+	// watched for tx-start and tx-stop events.
+	interlock *Interlock
+	tx        TXController
+	txEvents  bool
+	onAir     bool
+	deferred  []Event
+
+	// split, if set, is read on each poll for the TX VFO of split and
+	// crossband operation, whose band is classified separately.
+	split       SplitReader
+	txFreq      float64
+	txBand      string
+	txOutOfBand bool
+
+	// conn tracks the link to the rig; failed polls are retried after
+	// backoff delays rather than the polling interval.
+	conn    connState
+	lostAt  time.Time
+	backoff *Backoff
+
+	// fastInterval, if set, is the polling interval used while the
+	// frequency has changed within the last fastHold.
+	fastInterval time.Duration
+	fastHold     time.Duration
+	lastFreq     float64
+	lastChange   time.Time
+
+	// position reports the station's GPS fix; the station's location is
+	// the first of the fences containing it.
+	position func() (lat, lon float64, ok bool)
+	fences   []Geofence
+	location string
+	located  bool
+
+	currentMode    string
+	currentBand    string
+	currentSegment string
+	outOfPrivilege bool
+	outOfBand      bool
+	bandwidthIssue string
+
+	statusMu sync.Mutex
+	status   MonitorStatus
+}
+
+// MonitorStatus is a snapshot of what a monitor last observed, for the
+// control API.
+type MonitorStatus struct {
+	Radio   string    `json:"radio,omitempty"`
+	Time    time.Time `json:"time"`
+	Freq    float64   `json:"freq,omitempty"`
+	Band    string    `json:"band,omitempty"`
+	Segment string    `json:"segment,omitempty"`
+	Mode    string    `json:"mode,omitempty"`
+	Standby bool      `json:"standby,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	// TXFreq and TXBand are the TX VFO's while the rig is operating split.
+	TXFreq float64 `json:"tx_freq,omitempty"`
+	TXBand string  `json:"tx_band,omitempty"`
+	// LastPoll is when the rig last answered.
+	LastPoll time.Time `json:"last_poll"`
+}
+
+// Status returns the monitor's latest status.
+func (m *Monitor) Status() MonitorStatus {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	return m.status
+}
+
+func (m *Monitor) setStatus(freq float64, err error) {
+	status := MonitorStatus{
+		Radio:   m.radio,
+		Time:    time.Now(),
+		Freq:    freq,
+		Band:    m.currentBand,
+		Segment: m.currentSegment,
+		Mode:    m.currentMode,
+		Standby: m.ha != nil && !m.ha.Active(),
+		TXFreq:  m.txFreq,
+		TXBand:  m.txBand,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	m.statusMu.Lock()
+	status.LastPoll = m.status.LastPoll
+	if err == nil && !status.Standby {
+		status.LastPoll = status.Time
+	}
+	m.status = status
+	m.statusMu.Unlock()
+}
+
+func (m *Monitor) Run() {
+	m.printf("Starting fldigi band monitor (interval: %v)\n", m.interval)
+
+	if m.ha != nil {
+		if state, ok := m.ha.Resume(haResumeWait); ok {
+			m.printf("Resuming from the standby's state (%s)\n", state.Band)
+			m.restoreHA(state)
+		}
+	}
+	for {
+		if m.ha != nil && !m.ha.Active() {
+			// Track the primary's view so a takeover continues from it.
+			m.restoreHA(m.ha.State())
+			m.setStatus(0, nil)
+			time.Sleep(m.interval)
+			continue
+		}
+
+		delay := m.poll()
+		if m.ha != nil {
+			m.ha.Publish(m.haState())
+		}
+		time.Sleep(delay)
+	}
+}
+
+// haState returns the state the other HA node needs to carry on from
+// this one.
+func (m *Monitor) haState() HAState {
+	state := HAState{Band: m.currentBand, Segment: m.currentSegment, OutOfBand: m.outOfBand, OutOfPrivilege: m.outOfPrivilege}
+	if m.rules != nil {
+		state.Rules = m.rules.Matched()
+	}
+	return state
+}
+
+// restoreHA carries on from the other HA node's state.
+func (m *Monitor) restoreHA(state HAState) {
+	m.currentBand = state.Band
+	m.currentSegment = state.Segment
+	m.outOfBand = state.OutOfBand
+	m.outOfPrivilege = state.OutOfPrivilege
+	if m.rules != nil {
+		m.rules.Restore(state.Rules)
+	}
+}
+
+// poll reads the rig once and returns the delay before the next poll.
+func (m *Monitor) poll() time.Duration {
+	freq, err := m.getFrequency()
+	m.metrics.Poll(m.radio, freq, err)
+	if err != nil {
+		m.setStatus(0, err)
+		return m.connectionLost(err, time.Now())
+	}
+	m.connectionRestored(time.Now())
+
+	if m.getMode != nil {
+		mode, err := m.getMode()
+		if err != nil {
+			m.logf("Error getting mode: %v", err)
+		} else {
+			if m.currentMode != "" && mode != m.currentMode {
+				m.emit(Event{Type: EventModeChange, Time: time.Now(), Band: m.currentBand, Freq: freq, Mode: mode, PrevMode: m.currentMode})
+			}
+			m.currentMode = mode
+		}
+	}
+
+	if m.follower != nil {
+		m.follower.Follow(freq)
+	}
+	if m.civ != nil {
+		m.civ.Send(freq)
+	}
+
+	if m.tx != nil {
+		m.updateTX(freq)
+	}
+
+	if m.position != nil {
+		m.updateLocation(time.Now())
+	}
+
+	now := time.Now()
+	m.observe(freq, now)
+	if m.split != nil {
+		m.updateSplit(freq, now)
+	}
+	m.setStatus(freq, nil)
+	return m.nextInterval(freq, now)
+}
+
+// nextInterval returns the fast interval while the frequency is moving and
+// the normal one once it has held still for fastHold.
+func (m *Monitor) nextInterval(freq float64, now time.Time) time.Duration {
+	if m.lastFreq != 0 && freq != m.lastFreq {
+		m.lastChange = now
+	}
+	m.lastFreq = freq
+
+	if m.fastInterval > 0 && m.fastInterval < m.interval && !m.lastChange.IsZero() && now.Sub(m.lastChange) < m.fastHold {
+		return m.fastInterval
+	}
+	return m.interval
+}
+
+// observe processes a frequency reading and emits any resulting events.
+func (m *Monitor) observe(freq float64, now time.Time) {
+	band := frequencyToBand(freq)
+	if !m.debouncer.Update(band, now) {
+		return
+	}
+
+	m.checkPrivileges(band, freq, now)
+
+	if m.rules != nil {
+		m.rules.Evaluate(RuleState{Radio: m.radio, Band: band, Freq: freq, Mode: m.currentMode, Time: now, Notes: m.notes.For(band), Location: m.location})
+	}
+
+	if band == "unknown" {
+		if !m.outOfBand {
+			m.outOfBand = true
+			m.printf("Frequency is outside every band (%.3f MHz)\n", freq/1000000)
+			m.emit(Event{Type: EventOutOfBand, Time: now, Freq: freq, PrevBand: m.currentBand, Mode: m.currentMode})
+		}
+		return
+	}
+	// Coming back from outside the bands is a change even to the same band,
+	// as an out-of-band hook may have switched the station away from it.
+	returned := m.outOfBand
+	m.outOfBand = false
+
+	if (band != m.currentBand || returned) && m.currentBand != "" {
+		m.printf("Band changed from %s to %s (%.3f MHz)\n", m.currentBand, band, freq/1000000)
+		notes := m.showNotes(band)
+		m.emit(Event{Type: EventBandChange, Time: now, Band: band, PrevBand: m.currentBand, Freq: freq, Mode: m.currentMode, Notes: notes})
+	} else if m.currentBand == "" {
+		m.printf("Initial band detected: %s (%.3f MHz)\n", band, freq/1000000)
+		notes := m.showNotes(band)
+		m.emit(Event{Type: EventInitialBand, Time: now, Band: band, Freq: freq, Mode: m.currentMode, Notes: notes})
+	}
+	m.currentBand = band
+
+	m.checkSegmentBandwidth(band, freq, now)
+
+	// Hops between sub-band windows are debounced separately so a brief
+	// excursion (e.g. clicking across the waterfall) isn't reported, while a
+	// settled move within the band is.
+	seg, _ := findSegment(freq)
+	if !m.segDebouncer.Update(seg.Name, now) {
+		return
+	}
+
+	if seg.Name != m.currentSegment {
+		var notes []string
+		if seg.Name != "" {
+			m.printf("Segment changed to %s (%.3f MHz)\n", seg.Name, freq/1000000)
+			notes = m.showNotes(seg.Name)
+		}
+		m.emit(Event{
+			Type:        EventSegmentChange,
+			Time:        now,
+			Band:        band,
+			Segment:     seg.Name,
+			PrevSegment: m.currentSegment,
+			SegmentMode: seg.Mode,
+			Freq:        freq,
+			Mode:        m.currentMode,
+			Notes:       notes,
+		})
+		m.currentSegment = seg.Name
+	}
+}
+
+// emit labels an event with the radio and dispatches it, unless the
+// interlock holds it back.
+func (m *Monitor) emit(ev Event) {
+	ev.Radio = m.radio
+	if m.interlock != nil && isSwitchingEvent(ev.Type) {
+		if other, busy := m.interlock.OtherTransmitting(m.radio); busy {
+			m.logf("Deferring %s while radio %s is transmitting", ev.Type, other)
+			m.deferred = append(m.deferred, ev)
+			return
+		}
+	}
+	m.dispatcher.Emit(ev)
+}
+
+// updateTX reads the radio's TX state, emitting tx-start or tx-stop when
+// it changes with txEvents set, and with the interlock, publishes it and
+// releases deferred events once no other radio is transmitting.
+func (m *Monitor) updateTX(freq float64) {
+	state, err := m.tx.GetTRXState()
+	if err != nil {
+		m.logf("Error getting TX state: %v", err)
+	} else {
+		// A tune carrier is on the air as much as a transmission.
+		onAir := state != "RX"
+		if m.interlock != nil {
+			m.interlock.Set(m.radio, onAir)
+		}
+		if m.txEvents && onAir != m.onAir {
+			ev := Event{Type: EventTXStop, Time: time.Now(), Band: m.currentBand, Segment: m.currentSegment, Freq: freq, Mode: m.currentMode}
+			if onAir {
+				ev.Type = EventTXStart
+			}
+			m.emit(ev)
+		}
+		m.onAir = onAir
+	}
+
+	if m.interlock == nil || len(m.deferred) == 0 {
+		return
+	}
+	if _, busy := m.interlock.OtherTransmitting(m.radio); busy {
+		return
+	}
+	m.logf("Releasing %d deferred events", len(m.deferred))
+	for _, ev := range m.deferred {
+		m.dispatcher.Emit(ev)
+	}
+	m.deferred = nil
+}
+
+// updateSplit reads the split state and the TX VFO's band, warning once
+// each time split operation would transmit outside every band or outside
+// the operator's privileges.
+func (m *Monitor) updateSplit(freq float64, now time.Time) {
+	split, txFreq, err := m.split.GetSplit()
+	if err != nil {
+		m.logf("Error getting split state: %v", err)
+		return
+	}
+	if !split {
+		m.txFreq, m.txBand, m.txOutOfBand = 0, "", false
+		return
+	}
+
+	txBand := frequencyToBand(txFreq)
+	outside := txBand == "unknown" || (m.privileges != nil && !m.privileges.Allows(txFreq))
+	if outside && !m.txOutOfBand {
+		if txBand == "unknown" {
+			m.logf("Warning: split TX frequency %.6f MHz is outside every band", txFreq/1000000)
+		} else {
+			m.logf("Warning: split TX frequency %.6f MHz (%s) is outside %s privileges", txFreq/1000000, txBand, m.privileges.Name)
+		}
+		m.emit(Event{Type: EventSplitWarning, Time: now, Band: m.currentBand, Freq: freq, TXBand: txBand, TXFreq: txFreq, Mode: m.currentMode})
+	}
+	m.txFreq, m.txBand, m.txOutOfBand = txFreq, txBand, outside
+}
+
+// updateLocation emits a location-changed event when the GPS position
+// crosses into or out of a geofence. Without a fix the last location holds.
+func (m *Monitor) updateLocation(now time.Time) {
+	lat, lon, ok := m.position()
+	if !ok {
+		return
+	}
+
+	loc := locate(m.fences, lat, lon)
+	if m.located && loc == m.location {
+		return
+	}
+	if loc != "" {
+		m.printf("Location changed to %s (%.5f, %.5f)\n", loc, lat, lon)
+	} else {
+		m.printf("Outside all geofences (%.5f, %.5f)\n", lat, lon)
+	}
+	m.emit(Event{Type: EventLocationChanged, Time: now, Location: loc, PrevLocation: m.location, Lat: lat, Lon: lon})
+	m.location = loc
+	m.located = true
+}
+
+// printf and logf translate the message and prefix it with the radio
+// label, if any.
+func (m *Monitor) printf(format string, args ...interface{}) {
+	fmt.Print(m.prefix() + fmt.Sprintf(T(format), args...))
+}
+
+func (m *Monitor) logf(format string, args ...interface{}) {
+	log.Print(m.prefix() + fmt.Sprintf(T(format), args...))
+}
+
+func (m *Monitor) prefix() string {
+	if m.radio == "" {
+		return ""
+	}
+	return "[" + m.radio + "] "
+}
+
+// showNotes prints and returns the operator's notes for a band or segment.
+func (m *Monitor) showNotes(name string) []string {
+	notes := m.notes.For(name)
+	for _, note := range notes {
+		m.printf("Note (%s): %s\n", name, note)
+	}
+	return notes
+}
+
+// checkPrivileges warns once each time the frequency moves outside the
+// operator's permitted segments.
+func (m *Monitor) checkPrivileges(band string, freq float64, now time.Time) {
+	if m.privileges == nil {
+		return
+	}
+
+	allowed := m.privileges.Allows(freq)
+	if !allowed && !m.outOfPrivilege {
+		m.logf("Warning: %.6f MHz (%s) is outside %s privileges", freq/1000000, band, m.privileges.Name)
+		m.emit(Event{Type: EventPrivilegeWarning, Time: now, Band: band, Freq: freq, Mode: m.currentMode})
+	}
+	m.outOfPrivilege = !allowed
+}
+
+// checkSegmentBandwidth warns once each time the current mode's occupied
+// bandwidth starts exceeding the limit of the segment in use.
+func (m *Monitor) checkSegmentBandwidth(band string, freq float64, now time.Time) {
+	if !m.checkBandwidth {
+		return
+	}
+
+	var issue string
+	seg, ok := findSegment(freq)
+	bw, known := modeBandwidth(m.currentMode)
+	if ok && known && seg.MaxBandwidth > 0 && bw > seg.MaxBandwidth {
+		issue = seg.Name + "/" + m.currentMode
+	}
+
+	if issue != "" && issue != m.bandwidthIssue {
+		m.logf("Warning: %s occupies %.0f Hz, more than the %.0f Hz allowed in %s", m.currentMode, bw, seg.MaxBandwidth, seg.Name)
+		m.emit(Event{
+			Type:      EventBandwidthWarning,
+			Time:      now,
+			Band:      band,
+			Segment:   seg.Name,
+			Freq:      freq,
+			Mode:      m.currentMode,
+			Bandwidth: bw,
+		})
+	}
+	m.bandwidthIssue = issue
+
+	if issue != "" && m.inhibit != nil {
+		state, err := m.inhibit.GetTRXState()
+		if err != nil {
+			m.logf("Error getting TX state: %v", err)
+			return
+		}
+		if state == "TX" && dryRun {
+			dryRunf("would abort the transmission of %s in %s", m.currentMode, seg.Name)
+		} else if state == "TX" {
+			m.logf("Inhibiting transmission of %s in %s", m.currentMode, seg.Name)
+			if err := m.inhibit.AbortTX(); err != nil {
+				m.logf("Error aborting transmission: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type captureSink struct {
+	events []Event
+}
+
+func (s *captureSink) Name() string        { return "capture" }
+func (s *captureSink) Wants(ev Event) bool { return true }
+func (s *captureSink) Handle(ev Event) error {
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *captureSink) types() []string {
+	var types []string
+	for _, ev := range s.events {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+func newTestMonitor() (*Monitor, *captureSink) {
+	sink := &captureSink{}
+	metrics := NewMetrics()
+	return &Monitor{
+		debouncer:    &BandDebouncer{},
+		segDebouncer: &BandDebouncer{},
+		dispatcher:   NewDispatcher(metrics, sink),
+		metrics:      metrics,
+	}, sink
+}
+
+func TestMonitorSegmentChanges(t *testing.T) {
+	m, sink := newTestMonitor()
+	now := time.Now()
+
+	m.observe(14025000, now) // 20m-CW
+	m.observe(14030000, now) // still 20m-CW
+	m.observe(14100000, now) // 20m-data
+	m.observe(7090000, now)  // 40m-data
+
+	want := []string{EventInitialBand, EventSegmentChange, EventSegmentChange, EventBandChange, EventSegmentChange}
+	got := sink.types()
+	if len(got) != len(want) {
+		t.Fatalf("events = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v; want %v", got, want)
+		}
+	}
+
+	last := sink.events[len(sink.events)-1]
+	if last.Segment != "40m-data" || last.PrevSegment != "20m-data" || last.Band != "40m" {
+		t.Errorf("last segment event = %+v", last)
+	}
+}
+
+func TestMonitorSegmentHopDebounce(t *testing.T) {
+	m, sink := newTestMonitor()
+	m.segDebouncer.Readings = 2
+	now := time.Now()
+
+	m.observe(14074000, now) // 20m-FT8
+	m.observe(14074500, now)
+	m.observe(14080500, now) // brief hop to 20m-FT4
+	m.observe(14074800, now) // back to 20m-FT8
+	m.observe(14080500, now) // settled move to 20m-FT4
+	m.observe(14081000, now)
+
+	var hops []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventSegmentChange {
+			hops = append(hops, ev)
+		}
+	}
+
+	if len(hops) != 2 {
+		t.Fatalf("segment changes = %+v; want FT8 then FT4", hops)
+	}
+	if hops[1].Segment != "20m-FT4" || hops[1].PrevSegment != "20m-FT8" || hops[1].SegmentMode != "FT4" {
+		t.Errorf("segment hop = %+v", hops[1])
+	}
+}
+
+func TestMonitorPrivilegeWarnings(t *testing.T) {
+	m, sink := newTestMonitor()
+	m.privileges, _ = LoadLicenseProfile("general")
+	now := time.Now()
+
+	m.observe(14074000, now)
+	m.observe(14010000, now) // Extra-only
+	m.observe(14012000, now) // still outside; no repeat warning
+	m.observe(14074000, now)
+	m.observe(13000000, now) // out of band
+
+	var warnings []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventPrivilegeWarning {
+			warnings = append(warnings, ev)
+		}
+	}
+
+	if len(warnings) != 2 {
+		t.Fatalf("privilege warnings = %+v; want 2", warnings)
+	}
+	if warnings[0].Freq != 14010000 || warnings[1].Band != "unknown" {
+		t.Errorf("privilege warnings = %+v", warnings)
+	}
+}
+
+type fakeTX struct {
+	state   string
+	aborted int
+}
+
+func (f *fakeTX) GetTRXState() (string, error) { return f.state, nil }
+func (f *fakeTX) AbortTX() error {
+	f.aborted++
+	f.state = "RX"
+	return nil
+}
+
+func TestMonitorBandwidthCheck(t *testing.T) {
+	m, sink := newTestMonitor()
+	m.checkBandwidth = true
+	tx := &fakeTX{state: "TX"}
+	m.inhibit = tx
+	now := time.Now()
+
+	m.currentMode = "BPSK31"
+	m.observe(14030000, now) // narrow mode in 20m-CW: fine
+	m.currentMode = "OLIVIA-32-1K"
+	m.observe(14030000, now) // 1 kHz wide in a 500 Hz segment
+	m.observe(14031000, now) // no repeat warning
+	m.observe(14100000, now) // fine in 20m-data
+
+	var warnings []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventBandwidthWarning {
+			warnings = append(warnings, ev)
+		}
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("bandwidth warnings = %+v; want 1", warnings)
+	}
+	if warnings[0].Segment != "20m-CW" || warnings[0].Bandwidth != 1000 {
+		t.Errorf("bandwidth warning = %+v", warnings[0])
+	}
+	if tx.aborted != 1 {
+		t.Errorf("transmission aborted %d times; want 1", tx.aborted)
+	}
+}
+
+func TestMonitorAdaptiveInterval(t *testing.T) {
+	m, _ := newTestMonitor()
+	m.interval = 5 * time.Second
+	m.fastInterval = 500 * time.Millisecond
+	m.fastHold = 10 * time.Second
+
+	start := time.Now()
+	steps := []struct {
+		after time.Duration
+		freq  float64
+		want  time.Duration
+	}{
+		{0, 14070000, 5 * time.Second}, // first reading isn't a change
+		{5 * time.Second, 14074000, 500 * time.Millisecond},
+		{6 * time.Second, 14074000, 500 * time.Millisecond},
+		{14 * time.Second, 14074000, 500 * time.Millisecond},
+		{15 * time.Second, 14074000, 5 * time.Second},
+		{20 * time.Second, 7074000, 500 * time.Millisecond},
+	}
+	for _, s := range steps {
+		if got := m.nextInterval(s.freq, start.Add(s.after)); got != s.want {
+			t.Errorf("after %v at %.0f: interval = %v; want %v", s.after, s.freq, got, s.want)
+		}
+	}
+}
+
+func TestMonitorOutOfBand(t *testing.T) {
+	m, sink := newTestMonitor()
+	now := time.Now()
+
+	m.observe(14074000, now)
+	m.observe(30500000, now) // between 10m and 6m
+	m.observe(31000000, now)
+	m.observe(14074000, now)
+	m.observe(116000000, now) // transverter IF
+
+	var got []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventOutOfBand {
+			got = append(got, ev)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("out-of-band events = %+v, want one each time the frequency leaves the bands", got)
+	}
+	if got[0].Freq != 30500000 || got[0].PrevBand != "20m" || got[1].Freq != 116000000 {
+		t.Errorf("out-of-band events = %+v", got)
+	}
+	if args := commandArgs(got[0]); len(args) != 2 || args[0] != "30500000" || args[1] != "20m" {
+		t.Errorf("command args = %q", args)
+	}
+
+	// Returning to 20m is a band change, so --command switches back.
+	var back []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventBandChange {
+			back = append(back, ev)
+		}
+	}
+	if len(back) != 1 || back[0].Band != "20m" || back[0].PrevBand != "20m" {
+		t.Errorf("band changes = %+v, want the return to 20m", back)
+	}
+}
+
+type fakeSplit struct {
+	split  bool
+	txFreq float64
+}
+
+func (f *fakeSplit) GetSplit() (bool, float64, error) { return f.split, f.txFreq, nil }
+
+func TestMonitorSplit(t *testing.T) {
+	m, sink := newTestMonitor()
+	m.privileges, _ = LoadLicenseProfile("general")
+	split := &fakeSplit{}
+	m.split = split
+	now := time.Now()
+
+	m.observe(7074000, now)
+	m.updateSplit(7074000, now)
+	split.split, split.txFreq = true, 14230000 // crossband, within privileges
+	m.updateSplit(7074000, now)
+	if m.txBand != "20m" {
+		t.Errorf("TX band = %q, want 20m", m.txBand)
+	}
+	split.txFreq = 7301000 // just above 40m
+	m.updateSplit(7074000, now)
+	m.updateSplit(7074000, now) // no repeat warning
+	split.txFreq = 7005000      // Extra-only
+	m.updateSplit(7074000, now)
+	split.split = false
+	m.updateSplit(7074000, now)
+
+	var warnings []Event
+	for _, ev := range sink.events {
+		if ev.Type == EventSplitWarning {
+			warnings = append(warnings, ev)
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("split warnings = %+v, want 1", warnings)
+	}
+	if ev := warnings[0]; ev.TXFreq != 7301000 || ev.TXBand != "unknown" || ev.Band != "40m" || ev.Freq != 7074000 {
+		t.Errorf("split warning = %+v", ev)
+	}
+	if args := commandArgs(warnings[0]); len(args) != 3 || args[0] != "unknown" || args[1] != "7301000" || args[2] != "40m" {
+		t.Errorf("command args = %q", args)
+	}
+	if m.txFreq != 0 || m.txBand != "" {
+		t.Errorf("TX VFO still reported after split ended: %v %q", m.txFreq, m.txBand)
+	}
+}
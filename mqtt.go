@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types used by the publisher.
+const (
+	mqttTypeConnect    = 0x10
+	mqttTypeConnack    = 0x20
+	mqttTypePublish    = 0x30
+	mqttTypeDisconnect = 0xe0
+)
+
+// MQTTMessage is a QoS 0 message to publish to a broker.
+type MQTTMessage struct {
+	Broker   string `json:"broker"` // tcp://host:port
+	Topic    string `json:"topic"`
+	Payload  string `json:"payload"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Retain   bool   `json:"retain,omitempty"`
+}
+
+// mqttPublish connects to the broker, publishes a single QoS 0 message and
+// disconnects. Station automation publishes rarely, so a connection per
+// message keeps things simple.
+func mqttPublish(msg MQTTMessage, clientID string) error {
+	if dryRun {
+		dryRunf("would publish to %s on %s: %s", msg.Topic, msg.Broker, msg.Payload)
+		return nil
+	}
+	addr := msg.Broker
+	if u, err := url.Parse(msg.Broker); err == nil && u.Host != "" {
+		addr = u.Host
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "1883")
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker %s: %v", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if _, err := conn.Write(mqttConnectPacket(clientID, msg.Username, msg.Password)); err != nil {
+		return fmt.Errorf("failed to send MQTT connect: %v", err)
+	}
+
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("failed to read MQTT connack: %v", err)
+	}
+	if ack[0] != mqttTypeConnack || ack[3] != 0 {
+		return fmt.Errorf("MQTT broker refused connection (code %d)", ack[3])
+	}
+
+	if _, err := conn.Write(mqttPublishPacket(msg.Topic, []byte(msg.Payload), msg.Retain)); err != nil {
+		return fmt.Errorf("failed to publish MQTT message: %v", err)
+	}
+
+	conn.Write([]byte{mqttTypeDisconnect, 0})
+	return nil
+}
+
+func mqttConnectPacket(clientID, username, password string) []byte {
+	var body bytes.Buffer
+	mqttWriteString(&body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+
+	flags := byte(0x02) // clean session
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+	body.WriteByte(flags)
+	binary.Write(&body, binary.BigEndian, uint16(30)) // keep alive seconds
+
+	mqttWriteString(&body, clientID)
+	if username != "" {
+		mqttWriteString(&body, username)
+	}
+	if password != "" {
+		mqttWriteString(&body, password)
+	}
+
+	return mqttPacket(mqttTypeConnect, body.Bytes())
+}
+
+func mqttPublishPacket(topic string, payload []byte, retain bool) []byte {
+	var body bytes.Buffer
+	mqttWriteString(&body, topic)
+	body.Write(payload)
+
+	header := byte(mqttTypePublish)
+	if retain {
+		header |= 0x01
+	}
+	return mqttPacket(header, body.Bytes())
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+
+	// Remaining length uses a variable-length encoding of 7 bits per byte.
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+
+	return append(packet, body...)
+}
+
+func mqttWriteString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestMQTTPublish(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan [][]byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		var packets [][]byte
+		for len(packets) < 3 {
+			packet, err := readMQTTPacket(r)
+			if err != nil {
+				break
+			}
+			packets = append(packets, packet)
+			if packet[0] == mqttTypeConnect {
+				conn.Write([]byte{mqttTypeConnack, 2, 0, 0})
+			}
+		}
+		received <- packets
+	}()
+
+	msg := MQTTMessage{Broker: "tcp://" + ln.Addr().String(), Topic: "shack/band", Payload: "20m", Retain: true}
+	if err := mqttPublish(msg, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := <-received
+	if len(packets) != 3 {
+		t.Fatalf("broker received %d packets; want connect, publish, disconnect", len(packets))
+	}
+
+	publish := packets[1]
+	if publish[0] != mqttTypePublish|0x01 {
+		t.Errorf("publish header = %#x; want retained publish", publish[0])
+	}
+	if got := string(publish[2:]); got != "\x00\x0ashack/band20m" {
+		t.Errorf("publish body = %q", got)
+	}
+}
+
+func readMQTTPacket(r *bufio.Reader) ([]byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packet := []byte{header}
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		packet = append(packet, b)
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return append(packet, body...), nil
+}
+
+func TestMQTTRemainingLength(t *testing.T) {
+	packet := mqttPacket(mqttTypePublish, make([]byte, 321))
+	if packet[1] != 0xc1 || packet[2] != 0x02 {
+		t.Errorf("remaining length bytes = %#x %#x; want 0xc1 0x02", packet[1], packet[2])
+	}
+}
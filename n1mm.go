@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// n1mmStale is how long after the last RadioInfo the active radio's is too
+// old to report, a few of the intervals at which N1MM Logger+ repeats its
+// broadcasts.
+const n1mmStale = 60 * time.Second
+
+// n1mmRadioInfo is the part of an N1MM Logger+ RadioInfo datagram the
+// monitor uses. Frequencies are in units of 10 Hz.
+type n1mmRadioInfo struct {
+	XMLName       xml.Name `xml:"RadioInfo"`
+	StationName   string   `xml:"StationName"`
+	RadioNr       int      `xml:"RadioNr"`
+	Freq          float64  `xml:"Freq"`
+	Mode          string   `xml:"Mode"`
+	ActiveRadioNr int      `xml:"ActiveRadioNr"`
+}
+
+// N1MMListener receives the RadioInfo datagrams N1MM Logger+ broadcasts
+// for each radio and reports the frequency and mode of the active radio.
+type N1MMListener struct {
+	conn  *net.UDPConn
+	stale time.Duration
+
+	mu     sync.Mutex
+	radios map[int]n1mmRadioInfo
+	active int
+	heard  time.Time // of the last RadioInfo
+}
+
+// NewN1MMListener listens for N1MM Logger+ broadcasts on host:port.
+func NewN1MMListener(host string, port int) (*N1MMListener, error) {
+	conn, err := listenUDP("N1MM Logger+", host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &N1MMListener{conn: conn, stale: n1mmStale, radios: make(map[int]n1mmRadioInfo)}
+	go n.receive()
+	return n, nil
+}
+
+func (n *N1MMListener) Name() string { return "n1mm" }
+
+func (n *N1MMListener) receive() {
+	buf := make([]byte, 64*1024)
+	for {
+		size, _, err := n.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Printf("Error reading N1MM Logger+ datagram: %v", err)
+			return
+		}
+
+		// Contact, score and spot datagrams arrive on the same port and
+		// fail to unmarshal as RadioInfo.
+		var info n1mmRadioInfo
+		if err := xml.Unmarshal(buf[:size], &info); err != nil {
+			continue
+		}
+		n.update(info)
+	}
+}
+
+func (n *N1MMListener) update(info n1mmRadioInfo) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.radios[info.RadioNr] = info
+	n.heard = time.Now()
+	n.active = info.ActiveRadioNr
+	if _, ok := n.radios[n.active]; !ok {
+		n.active = info.RadioNr
+	}
+}
+
+func (n *N1MMListener) activeRadio() (n1mmRadioInfo, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	info, ok := n.radios[n.active]
+	if !ok {
+		return n1mmRadioInfo{}, fmt.Errorf("no RadioInfo received from N1MM Logger+ yet")
+	}
+	if since := time.Since(n.heard); since > n.stale {
+		return n1mmRadioInfo{}, fmt.Errorf("no RadioInfo received from N1MM Logger+ for %v", since.Round(time.Second))
+	}
+	return info, nil
+}
+
+func (n *N1MMListener) GetFrequency() (float64, error) {
+	info, err := n.activeRadio()
+	if err != nil {
+		return 0, err
+	}
+	return info.Freq * 10, nil
+}
+
+func (n *N1MMListener) GetMode() (string, error) {
+	info, err := n.activeRadio()
+	if err != nil {
+		return "", err
+	}
+	return info.Mode, nil
+}
+
+func (n *N1MMListener) SetFrequency(freq float64) error {
+	return fmt.Errorf("N1MM Logger+ does not support setting the frequency")
+}
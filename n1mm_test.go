@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+const n1mmRadio2 = `<?xml version="1.0" encoding="utf-8"?>
+<RadioInfo>
+  <app>N1MM</app>
+  <StationName>CONTEST-PC</StationName>
+  <RadioNr>2</RadioNr>
+  <Freq>705000</Freq>
+  <TXFreq>705000</TXFreq>
+  <Mode>CW</Mode>
+  <IsTransmitting>False</IsTransmitting>
+  <ActiveRadioNr>1</ActiveRadioNr>
+</RadioInfo>`
+
+const n1mmRadio1 = `<?xml version="1.0" encoding="utf-8"?>
+<RadioInfo>
+  <RadioNr>1</RadioNr>
+  <Freq>1402500</Freq>
+  <Mode>CW</Mode>
+  <ActiveRadioNr>1</ActiveRadioNr>
+</RadioInfo>`
+
+func TestN1MMListener(t *testing.T) {
+	n, err := NewN1MMListener("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.conn.Close()
+
+	conn, err := net.Dial("udp", n.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	waitFor := func(want float64) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if freq, err := n.GetFrequency(); err == nil && freq == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		freq, err := n.GetFrequency()
+		t.Fatalf("GetFrequency() = %v, %v; want %v", freq, err, want)
+	}
+
+	// Until the active radio reports, the only known radio is used.
+	conn.Write([]byte(n1mmRadio2))
+	waitFor(7050000)
+
+	conn.Write([]byte("<contactinfo><call>K1ABC</call></contactinfo>"))
+	conn.Write([]byte(n1mmRadio1))
+	waitFor(14025000)
+
+	// Radio 2 updating doesn't take focus away from radio 1.
+	conn.Write([]byte(n1mmRadio2))
+	time.Sleep(50 * time.Millisecond)
+	waitFor(14025000)
+	if mode, _ := n.GetMode(); mode != "CW" {
+		t.Errorf("GetMode() = %q", mode)
+	}
+
+	// Once the broadcasts stop the last RadioInfo is stale.
+	n.mu.Lock()
+	n.stale = 100 * time.Millisecond
+	n.mu.Unlock()
+	time.Sleep(200 * time.Millisecond)
+	if _, err := n.GetFrequency(); err == nil {
+		t.Error("expected an error for a stale RadioInfo")
+	}
+	conn.Write([]byte(n1mmRadio1))
+	waitFor(14025000)
+}
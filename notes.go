@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notes holds operator notes keyed by band or segment name, read from a file
+// of "name: text" lines. The file is re-read when it changes, so notes can be
+// edited while the monitor is running.
+type Notes struct {
+	path    string
+	modTime time.Time
+	notes   map[string][]string
+}
+
+// LoadNotes reads the notes file at path.
+func LoadNotes(path string) (*Notes, error) {
+	n := &Notes{path: path}
+	if err := n.reload(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *Notes) reload() error {
+	info, err := os.Stat(n.path)
+	if err != nil {
+		return fmt.Errorf("failed to read notes: %v", err)
+	}
+	if !info.ModTime().After(n.modTime) && n.notes != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(n.path)
+	if err != nil {
+		return fmt.Errorf("failed to read notes: %v", err)
+	}
+	n.notes = parseNotes(string(data))
+	n.modTime = info.ModTime()
+	return nil
+}
+
+// parseNotes reads "name: text" lines, skipping comments and lines without
+// a name. A name may have several notes.
+func parseNotes(data string) map[string][]string {
+	notes := make(map[string][]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, text, ok := strings.Cut(line, ":")
+		name, text = strings.TrimSpace(name), strings.TrimSpace(text)
+		if !ok || name == "" || text == "" {
+			continue
+		}
+		notes[name] = append(notes[name], text)
+	}
+
+	return notes
+}
+
+// For returns the notes attached to a band or segment name.
+func (n *Notes) For(name string) []string {
+	if n == nil || name == "" {
+		return nil
+	}
+	if err := n.reload(); err != nil {
+		log.Printf("Error reloading notes: %v", err)
+	}
+	return n.notes[name]
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotesOnBandChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	data := "# station notes\n20m: dipole SWR high above 14.300\n20m: use amp tune preset 3\n20m-CW: narrow filter\nmalformed line\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := LoadNotes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, sink := newTestMonitor()
+	m.notes = notes
+	m.observe(14025000, time.Now())
+
+	if len(sink.events) != 2 {
+		t.Fatalf("events = %v", sink.types())
+	}
+	if got := sink.events[0].Notes; len(got) != 2 || got[1] != "use amp tune preset 3" {
+		t.Errorf("band notes = %q", got)
+	}
+	if got := sink.events[1].Notes; len(got) != 1 || got[0] != "narrow filter" {
+		t.Errorf("segment notes = %q", got)
+	}
+
+	// Edits are picked up without a restart.
+	later := time.Now().Add(time.Second)
+	if err := os.WriteFile(path, []byte("40m: check antenna switch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+	if got := notes.For("40m"); len(got) != 1 || got[0] != "check antenna switch" {
+		t.Errorf("reloaded notes = %q", got)
+	}
+	if got := notes.For("20m"); got != nil {
+		t.Errorf("stale notes = %q", got)
+	}
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotifyFile is the on-disk format of a notifications file.
+type NotifyFile struct {
+	Channels []*NotifyChannel `json:"channels"`
+}
+
+// NotifyChannel is one destination for notifications. Events listed in
+// Urgent are sent as they happen; other events are collected and sent as a
+// summary every Digest interval, or immediately when no digest is set.
+type NotifyChannel struct {
+	Name         string          `json:"name"`
+	Events       []string        `json:"events"`
+	Urgent       []string        `json:"urgent"`
+	Digest       string          `json:"digest"`
+	Message      string          `json:"message"`
+	Email        *EmailConfig    `json:"email"`
+	Webhook      string          `json:"webhook"`
+	Command      string          `json:"command"`
+	Telegram     *TelegramConfig `json:"telegram"`
+	Discord      string          `json:"discord"`
+	Slack        string          `json:"slack"`
+	Pushover     *PushoverConfig `json:"pushover"`
+	Ntfy         *NtfyConfig     `json:"ntfy"`
+	Desktop      bool            `json:"desktop"`
+	Sound        string          `json:"sound"`
+	Speak        bool            `json:"speak"`
+	SpeakCommand string          `json:"speak_command"` // speaks with another program, given the text as its argument
+	Priorities   map[string]int  `json:"priorities"`    // Pushover or ntfy priorities by event type
+
+	digest time.Duration
+}
+
+// EmailConfig describes an SMTP server and the recipients of notifications.
+type EmailConfig struct {
+	SMTP     string   `json:"smtp"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// TelegramConfig is a Telegram bot and the chat it posts notifications to.
+type TelegramConfig struct {
+	Token  string         `json:"token"`
+	ChatID telegramChatID `json:"chat_id"`
+}
+
+// telegramChatID is a chat's numeric ID or a channel's @username; either
+// may be written as a JSON number or string.
+type telegramChatID string
+
+func (id *telegramChatID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = telegramChatID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("chat_id must be a number or string")
+	}
+	*id = telegramChatID(n)
+	return nil
+}
+
+// PushoverConfig is a Pushover application token and the user or group
+// it notifies. Priority runs from -2 (no alert) to 2 (emergency, repeated
+// until acknowledged).
+type PushoverConfig struct {
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Priority int    `json:"priority"`
+	Sound    string `json:"sound"`
+}
+
+// NtfyConfig is an ntfy topic URL, such as https://ntfy.sh/my-shack, with
+// an optional access token. Priority runs from 1 (min) to 5 (max); 0
+// leaves the server's default.
+type NtfyConfig struct {
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Priority int    `json:"priority"`
+}
+
+// The longest messages the chat and push services accept.
+const (
+	maxTelegramMessage = 4096
+	maxDiscordMessage  = 2000
+	maxPushoverTitle   = 250
+	maxPushoverMessage = 1024
+	maxNtfyMessage     = 4096
+)
+
+// The Telegram Bot and Pushover APIs, variables so tests can point them
+// elsewhere.
+var (
+	telegramAPI = "https://api.telegram.org"
+	pushoverAPI = "https://api.pushover.net/1/messages.json"
+)
+
+func LoadNotifyChannels(path string) ([]*NotifyChannel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifications: %v", err)
+	}
+
+	var file NotifyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse notifications %s: %v", path, err)
+	}
+
+	for i, c := range file.Channels {
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("channel-%d", i+1)
+		}
+		if countNotifiers(c) != 1 {
+			return nil, fmt.Errorf("channel %s needs exactly one of email, webhook, command, telegram, discord, slack, pushover, ntfy or desktop, sound and speak", c.Name)
+		}
+		if c.Email != nil && (c.Email.SMTP == "" || c.Email.From == "" || len(c.Email.To) == 0) {
+			return nil, fmt.Errorf("channel %s: email needs smtp, from and to", c.Name)
+		}
+		if c.Telegram != nil && (c.Telegram.Token == "" || c.Telegram.ChatID == "") {
+			return nil, fmt.Errorf("channel %s: telegram needs token and chat_id", c.Name)
+		}
+		if c.Pushover != nil && (c.Pushover.Token == "" || c.Pushover.User == "") {
+			return nil, fmt.Errorf("channel %s: pushover needs token and user", c.Name)
+		}
+		if c.Ntfy != nil && !strings.HasPrefix(c.Ntfy.URL, "http://") && !strings.HasPrefix(c.Ntfy.URL, "https://") {
+			return nil, fmt.Errorf("channel %s: ntfy needs the topic url, e.g. https://ntfy.sh/my-shack", c.Name)
+		}
+		if c.Sound != "" {
+			if _, err := os.Stat(c.Sound); err != nil {
+				return nil, fmt.Errorf("channel %s: sound: %v", c.Name, err)
+			}
+		}
+		if err := c.checkPriorities(); err != nil {
+			return nil, fmt.Errorf("channel %s: %v", c.Name, err)
+		}
+		if c.Digest != "" {
+			if c.digest, err = time.ParseDuration(c.Digest); err != nil || c.digest <= 0 {
+				return nil, fmt.Errorf("channel %s: invalid digest interval %q", c.Name, c.Digest)
+			}
+		}
+	}
+
+	return file.Channels, nil
+}
+
+func countNotifiers(c *NotifyChannel) int {
+	n := 0
+	if c.Email != nil {
+		n++
+	}
+	if c.Webhook != "" {
+		n++
+	}
+	if c.Command != "" {
+		n++
+	}
+	if c.Telegram != nil {
+		n++
+	}
+	if c.Discord != "" {
+		n++
+	}
+	if c.Slack != "" {
+		n++
+	}
+	if c.Pushover != nil {
+		n++
+	}
+	if c.Ntfy != nil {
+		n++
+	}
+	// A desktop notification, sound and speech go together as one local
+	// alert.
+	if c.localAlert() {
+		n++
+	}
+	return n
+}
+
+// checkPriorities checks the channel's priorities are in its service's
+// range.
+func (c *NotifyChannel) checkPriorities() error {
+	min, max := 0, 0
+	var priorities []int
+	switch {
+	case c.Pushover != nil:
+		min, max = -2, 2
+		priorities = append(priorities, c.Pushover.Priority)
+	case c.Ntfy != nil:
+		min, max = 0, 5
+		priorities = append(priorities, c.Ntfy.Priority)
+	default:
+		if len(c.Priorities) > 0 {
+			return fmt.Errorf("priorities need pushover or ntfy")
+		}
+		return nil
+	}
+	for _, p := range c.Priorities {
+		priorities = append(priorities, p)
+	}
+	for _, p := range priorities {
+		if p < min || p > max {
+			return fmt.Errorf("priority %d is outside %d to %d", p, min, max)
+		}
+	}
+	return nil
+}
+
+// priority is the Pushover or ntfy priority for an event type, or for a
+// digest when eventType is "".
+func (c *NotifyChannel) priority(eventType string) int {
+	for t, p := range c.Priorities {
+		if eventType != "" && strings.EqualFold(t, eventType) {
+			return p
+		}
+	}
+	if c.Pushover != nil {
+		return c.Pushover.Priority
+	}
+	if c.Ntfy != nil {
+		return c.Ntfy.Priority
+	}
+	return 0
+}
+
+// send delivers a message about an event type, or a digest when eventType
+// is "", over the channel's transport.
+func (c *NotifyChannel) send(subject, body, eventType string) error {
+	switch {
+	case c.Email != nil:
+		return sendEmail(c.Email, subject, body)
+	case c.Webhook != "":
+		return postJSON(c.Webhook, map[string]string{"subject": subject, "body": body})
+	case c.Telegram != nil:
+		return sendTelegram(c.Telegram, truncateMessage(subject+"\n"+body, maxTelegramMessage))
+	case c.Discord != "":
+		return postJSON(c.Discord, map[string]string{"content": truncateMessage("**"+subject+"**\n"+body, maxDiscordMessage)})
+	case c.Slack != "":
+		return postJSON(c.Slack, map[string]string{"text": "*" + subject + "*\n" + body})
+	case c.Pushover != nil:
+		return sendPushover(c.Pushover, subject, body, c.priority(eventType))
+	case c.Ntfy != nil:
+		return sendNtfy(c.Ntfy, subject, body, c.priority(eventType))
+	case c.localAlert():
+		// A digest is announced by its subject rather than read out.
+		speech := body
+		if eventType == "" {
+			speech = subject
+		}
+		return c.alertDesktop(subject, body, speech)
+	default:
+		return runExternalCommand(c.Command, subject, body)
+	}
+}
+
+func sendEmail(cfg *EmailConfig, subject, body string) error {
+	if dryRun {
+		dryRunf("would email %s: %s", strings.Join(cfg.To, ", "), subject)
+		return nil
+	}
+	host, _, err := net.SplitHostPort(cfg.SMTP)
+	if err != nil {
+		return fmt.Errorf("invalid smtp address %q: %v", cfg.SMTP, err)
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+
+	msg := "From: " + cfg.From + "\r\n" +
+		"To: " + strings.Join(cfg.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+	if err := smtp.SendMail(cfg.SMTP, auth, cfg.From, cfg.To, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// sendTelegram posts text to the bot's chat. Errors leave out the URL,
+// which contains the bot's token.
+func sendTelegram(cfg *TelegramConfig, text string) error {
+	if dryRun {
+		dryRunf("would send to Telegram chat %s: %s", cfg.ChatID, text)
+		return nil
+	}
+	data, _ := json.Marshal(map[string]string{"chat_id": string(cfg.ChatID), "text": text})
+	resp, err := webhookClient.Post(telegramAPI+"/bot"+cfg.Token+"/sendMessage", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send Telegram message: %v", strings.ReplaceAll(err.Error(), cfg.Token, "REDACTED"))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var reply struct {
+			Description string `json:"description"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reply)
+		return fmt.Errorf("Telegram returned %s: %s", resp.Status, reply.Description)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// sendPushover sends a Pushover notification. Emergency notifications are
+// repeated every minute for an hour until acknowledged.
+func sendPushover(cfg *PushoverConfig, title, message string, priority int) error {
+	form := url.Values{
+		"token":    {cfg.Token},
+		"user":     {cfg.User},
+		"title":    {truncateMessage(title, maxPushoverTitle)},
+		"message":  {truncateMessage(message, maxPushoverMessage)},
+		"priority": {strconv.Itoa(priority)},
+	}
+	if priority == 2 {
+		form.Set("retry", "60")
+		form.Set("expire", "3600")
+	}
+	if cfg.Sound != "" {
+		form.Set("sound", cfg.Sound)
+	}
+	if dryRun {
+		dryRunf("would send Pushover notification at priority %d: %s", priority, title)
+		return nil
+	}
+	resp, err := webhookClient.PostForm(pushoverAPI, form)
+	if err != nil {
+		return fmt.Errorf("failed to send Pushover notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var reply struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reply)
+		return fmt.Errorf("Pushover returned %s: %s", resp.Status, strings.Join(reply.Errors, "; "))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// sendNtfy publishes a message to an ntfy topic.
+func sendNtfy(cfg *NtfyConfig, title, message string, priority int) error {
+	if dryRun {
+		dryRunf("would publish to %s at priority %d: %s", cfg.URL, priority, title)
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, strings.NewReader(truncateMessage(message, maxNtfyMessage)))
+	if err != nil {
+		return fmt.Errorf("invalid ntfy url: %v", err)
+	}
+	req.Header.Set("Title", title)
+	if priority > 0 {
+		req.Header.Set("Priority", strconv.Itoa(priority))
+	}
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to ntfy: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ntfy %s returned %s: %s", cfg.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// localAlert reports whether the channel alerts on the computer running
+// fldigi-cmd.
+func (c *NotifyChannel) localAlert() bool {
+	return c.Desktop || c.Sound != "" || c.Speak || c.SpeakCommand != ""
+}
+
+// alertDesktop shows a desktop notification, plays the channel's sound and
+// speaks, whichever are configured, in that order.
+func (c *NotifyChannel) alertDesktop(title, body, speech string) error {
+	if c.Desktop {
+		command, args, env := desktopNotifyCommand(title, body)
+		if err := runExternalCommandEnv(env, command, args...); err != nil {
+			return fmt.Errorf("failed to show desktop notification: %v", err)
+		}
+	}
+	if c.Sound != "" {
+		command, args, env := soundCommand(c.Sound)
+		if err := runExternalCommandEnv(env, command, args...); err != nil {
+			return fmt.Errorf("failed to play %s: %v", c.Sound, err)
+		}
+	}
+	if c.Speak || c.SpeakCommand != "" {
+		command, args, env := speechCommand(speakable(speech))
+		if c.SpeakCommand != "" {
+			command, args, env = c.SpeakCommand, []string{speakable(speech)}, nil
+		}
+		if err := runExternalCommandEnv(env, command, args...); err != nil {
+			return fmt.Errorf("failed to speak: %v", err)
+		}
+	}
+	return nil
+}
+
+// truncateMessage shortens s to at most max characters.
+func truncateMessage(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// notifySink sends a channel's notifications, batching non-urgent events
+// into digests.
+type notifySink struct {
+	channel *NotifyChannel
+
+	mu      sync.Mutex
+	pending []Event
+}
+
+func newNotifySink(c *NotifyChannel) *notifySink {
+	s := &notifySink{channel: c}
+	if c.digest > 0 {
+		go func() {
+			for range time.Tick(c.digest) {
+				if err := s.flush(); err != nil {
+					log.Printf("Error sending %s digest: %v", c.Name, err)
+				}
+			}
+		}()
+	}
+	return s
+}
+
+func (s *notifySink) Name() string { return "notify-" + s.channel.Name }
+
+func (s *notifySink) Wants(ev Event) bool {
+	return len(s.channel.Events) == 0 || containsFold(s.channel.Events, ev.Type)
+}
+
+func (s *notifySink) Handle(ev Event) error {
+	if s.channel.digest == 0 || containsFold(s.channel.Urgent, ev.Type) {
+		return s.channel.send(eventSubject(ev), s.channel.message(ev), ev.Type)
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, ev)
+	s.mu.Unlock()
+	return nil
+}
+
+// flush sends the collected events as one summary.
+func (s *notifySink) flush() error {
+	s.mu.Lock()
+	events := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+	subject, body := s.channel.digestMessage(events)
+	return s.channel.send(subject, body, "")
+}
+
+// message is the text of the notification for ev: the channel's message
+// template, or else the event's fields, or a phrase for one that speaks.
+func (c *NotifyChannel) message(ev Event) string {
+	if c.Message == "" && (c.Speak || c.SpeakCommand != "") {
+		return spokenPhrase(ev)
+	}
+	if c.Message == "" {
+		return formatEvent(ev, false)
+	}
+	return expandTemplate(c.Message, ev)
+}
+
+func eventSubject(ev Event) string {
+	subject := "fldigi-cmd: " + ev.Type
+	if ev.Radio != "" {
+		subject += fmt.Sprintf(T(" on radio %s"), ev.Radio)
+	}
+	if ev.Band != "" {
+		subject += " (" + ev.Band + ")"
+	}
+	return subject
+}
+
+// digestMessage summarizes events: a count per type followed by one line
+// per event.
+func (c *NotifyChannel) digestMessage(events []Event) (string, string) {
+	counts := map[string]int{}
+	for _, ev := range events {
+		counts[ev.Type]++
+	}
+
+	var body strings.Builder
+	for _, t := range sortedKeys(counts) {
+		fmt.Fprintf(&body, "%s: %d\n", t, counts[t])
+	}
+	body.WriteString("\n")
+	for _, ev := range events {
+		body.WriteString(c.message(ev) + "\n")
+	}
+
+	subject := fmt.Sprintf(T("fldigi-cmd digest: %d events"), len(events))
+	return subject, body.String()
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifySinkDigest(t *testing.T) {
+	var messages []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		messages = append(messages, msg)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "notify.json")
+	config := `{"channels": [{"webhook": "` + server.URL + `", "digest": "1h",
+		"events": ["band-change", "privilege-warning"], "urgent": ["privilege-warning"]}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := LoadNotifyChannels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channels[0].Name != "channel-1" || channels[0].digest != time.Hour {
+		t.Errorf("channel = %+v", channels[0])
+	}
+
+	sink := &notifySink{channel: channels[0]}
+	dispatcher := NewDispatcher(NewMetrics(), sink)
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "20m"})
+	dispatcher.Emit(Event{Type: EventSegmentChange, Segment: "20m-FT8"}) // not subscribed
+	dispatcher.Emit(Event{Type: EventPrivilegeWarning, Band: "20m", Freq: 14345000})
+	dispatcher.Emit(Event{Type: EventBandChange, Band: "40m"})
+
+	// Only the urgent warning has gone out so far.
+	if len(messages) != 1 || messages[0]["subject"] != "fldigi-cmd: privilege-warning (20m)" {
+		t.Fatalf("messages = %v", messages)
+	}
+
+	if err := sink.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("messages = %v", messages)
+	}
+	digest := messages[1]
+	if digest["subject"] != "fldigi-cmd digest: 2 events" || !strings.HasPrefix(digest["body"], "band-change: 2\n") {
+		t.Errorf("digest = %v", digest)
+	}
+
+	// Nothing new: no empty digest.
+	sink.flush()
+	if len(messages) != 2 {
+		t.Errorf("empty digest sent")
+	}
+}
+
+func TestLoadNotifyChannelsValidates(t *testing.T) {
+	for _, config := range []string{
+		`{"channels": [{"name": "none"}]}`,
+		`{"channels": [{"webhook": "http://x", "command": "notify-send"}]}`,
+		`{"channels": [{"email": {"smtp": "smtp.example.com:587"}}]}`,
+		`{"channels": [{"command": "notify-send", "digest": "hourly"}]}`,
+		`{"channels": [{"telegram": {"token": "123:abc"}}]}`,
+		`{"channels": [{"discord": "http://x", "slack": "http://y"}]}`,
+		`{"channels": [{"pushover": {"token": "abc"}}]}`,
+		`{"channels": [{"pushover": {"token": "abc", "user": "def", "priority": 3}}]}`,
+		`{"channels": [{"ntfy": {"url": "ntfy.sh/shack"}}]}`,
+		`{"channels": [{"ntfy": {"url": "https://ntfy.sh/shack"}, "priorities": {"disconnected": 6}}]}`,
+		`{"channels": [{"webhook": "http://x", "priorities": {"disconnected": 1}}]}`,
+	} {
+		path := filepath.Join(t.TempDir(), "notify.json")
+		os.WriteFile(path, []byte(config), 0644)
+		if _, err := LoadNotifyChannels(path); err == nil {
+			t.Errorf("LoadNotifyChannels(%s) succeeded", config)
+		}
+	}
+}
+
+func TestNotifyChatTransports(t *testing.T) {
+	type request struct {
+		path string
+		body map[string]string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, request{r.URL.Path, body})
+		if strings.Contains(r.URL.Path, "bad-token") {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok": false, "description": "Unauthorized"}`))
+		}
+	}))
+	defer server.Close()
+	defer func(api string) { telegramAPI = api }(telegramAPI)
+	telegramAPI = server.URL
+
+	path := filepath.Join(t.TempDir(), "notify.json")
+	config := `{"channels": [
+		{"telegram": {"token": "123:abc", "chat_id": -1001234}, "message": "{radio} on {band}"},
+		{"discord": "` + server.URL + `/discord"},
+		{"slack": "` + server.URL + `/slack", "message": "Now on {band}"}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := LoadNotifyChannels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := Event{Type: EventBandChange, Radio: "ic7300", Band: "20m"}
+	for _, c := range channels {
+		if err := newNotifySink(c).Handle(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("requests = %+v", requests)
+	}
+	if r := requests[0]; r.path != "/bot123:abc/sendMessage" || r.body["chat_id"] != "-1001234" ||
+		r.body["text"] != "fldigi-cmd: band-change on radio ic7300 (20m)\nic7300 on 20m" {
+		t.Errorf("telegram request = %+v", r)
+	}
+	if r := requests[1]; r.path != "/discord" || !strings.HasPrefix(r.body["content"], "**fldigi-cmd: band-change on radio ic7300 (20m)**\n") {
+		t.Errorf("discord request = %+v", r)
+	}
+	if r := requests[2]; r.path != "/slack" || r.body["text"] != "*fldigi-cmd: band-change on radio ic7300 (20m)*\nNow on 20m" {
+		t.Errorf("slack request = %+v", r)
+	}
+
+	err = sendTelegram(&TelegramConfig{Token: "bad-token", ChatID: "@shack"}, "test")
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") || strings.Contains(err.Error(), "bad-token") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	if got := truncateMessage("73 de N0CALL", 20); got != "73 de N0CALL" {
+		t.Errorf("short message changed to %q", got)
+	}
+	if got := truncateMessage("73 de N0CALL", 6); got != "73 de…" {
+		t.Errorf("truncateMessage = %q", got)
+	}
+}
+
+func TestNotifyPushTransports(t *testing.T) {
+	var pushover []url.Values
+	var ntfy []*http.Request
+	var ntfyBodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pushover" {
+			r.ParseForm()
+			pushover = append(pushover, r.PostForm)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		ntfy = append(ntfy, r)
+		ntfyBodies = append(ntfyBodies, string(body))
+	}))
+	defer server.Close()
+	defer func(api string) { pushoverAPI = api }(pushoverAPI)
+	pushoverAPI = server.URL + "/pushover"
+
+	path := filepath.Join(t.TempDir(), "notify.json")
+	config := `{"channels": [
+		{"pushover": {"token": "app", "user": "me", "priority": -1}, "priorities": {"disconnected": 2}},
+		{"ntfy": {"url": "` + server.URL + `/my-shack", "token": "tk_secret"}, "priorities": {"disconnected": 5}, "message": "{type} from fldigi"}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := LoadNotifyChannels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range []Event{{Type: EventBandChange, Band: "20m"}, {Type: EventDisconnected, Backend: "fldigi"}} {
+		for _, c := range channels {
+			if err := newNotifySink(c).Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if len(pushover) != 2 || len(ntfy) != 2 {
+		t.Fatalf("pushover = %v, ntfy = %d requests", pushover, len(ntfy))
+	}
+	if p := pushover[0]; p.Get("token") != "app" || p.Get("user") != "me" || p.Get("priority") != "-1" || p.Get("title") != "fldigi-cmd: band-change (20m)" || p.Get("retry") != "" {
+		t.Errorf("band change = %v", p)
+	}
+	if p := pushover[1]; p.Get("priority") != "2" || p.Get("retry") != "60" || p.Get("expire") != "3600" {
+		t.Errorf("disconnection = %v", p)
+	}
+	if r := ntfy[0]; r.URL.Path != "/my-shack" || r.Header.Get("Priority") != "" || r.Header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("band change = %s %v", r.URL.Path, r.Header)
+	}
+	if r := ntfy[1]; r.Header.Get("Priority") != "5" || r.Header.Get("Title") != "fldigi-cmd: disconnected" || ntfyBodies[1] != "disconnected from fldigi" {
+		t.Errorf("disconnection = %v %q", r.Header, ntfyBodies[1])
+	}
+}
+
+func TestNotifyDesktopAndSound(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("notify-send and paplay")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	for _, name := range []string{"notify-send", "paplay"} {
+		script := "#!/bin/sh\necho " + name + " \"$@\" >> " + log + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	sound := filepath.Join(dir, "alert.wav")
+	os.WriteFile(sound, nil, 0644)
+
+	path := filepath.Join(dir, "notify.json")
+	config := `{"channels": [{"desktop": true, "sound": "` + sound + `", "message": "Now on {band}"}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := LoadNotifyChannels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newNotifySink(channels[0]).Handle(Event{Type: EventBandChange, Band: "20m"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(log)
+	want := "notify-send --app-name=fldigi-cmd -- fldigi-cmd: band-change (20m) Now on 20m\npaplay " + sound + "\n"
+	if string(data) != want {
+		t.Errorf("ran %q, want %q", data, want)
+	}
+
+	os.WriteFile(path, []byte(`{"channels": [{"sound": "`+filepath.Join(dir, "missing.wav")+`"}]}`), 0644)
+	if _, err := LoadNotifyChannels(path); err == nil {
+		t.Error("no error for a missing sound file")
+	}
+}
+
+func TestNotifySpeak(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script speech command")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	speak := filepath.Join(dir, "speak.sh")
+	os.WriteFile(speak, []byte("#!/bin/sh\necho \"$1\" >> "+log+"\n"), 0755)
+
+	c := &NotifyChannel{Name: "speech", SpeakCommand: speak}
+	if countNotifiers(c) != 1 {
+		t.Fatalf("speak_command isn't a transport")
+	}
+	sink := newNotifySink(c)
+	sink.Handle(Event{Type: EventBandChange, Band: "20m"})
+	c.Message = "{radio} now on {band}"
+	sink.Handle(Event{Type: EventBandChange, Radio: "ic7300", Band: "6m"})
+	sink.pending = []Event{{Type: EventWatch, Call: "K1ABC"}}
+	c.Message = ""
+	c.digest = time.Hour
+	sink.flush()
+
+	data, _ := os.ReadFile(log)
+	want := "Band changed to twenty meters\nic7300 now on six meters\nfldigi-cmd digest: 1 events\n"
+	if string(data) != want {
+		t.Errorf("spoke %q, want %q", data, want)
+	}
+}
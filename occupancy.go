@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// occupancyRig is what the scanner needs from fldigi: tuning, and the
+// modem's view of the signal at its carrier.
+type occupancyRig interface {
+	GetFrequency() (float64, error)
+	SetFrequency(freq float64) error
+	GetCarrier() (float64, error)
+	GetSideband() (string, error)
+	GetQuality() (float64, error)
+	GetRXData() (string, error)
+}
+
+// Slice is the measured activity of one step of a scan.
+type Slice struct {
+	StartHz float64 `json:"start_hz"`
+	EndHz   float64 `json:"end_hz"`
+	Quality float64 `json:"quality"` // highest modem signal quality seen
+	Chars   int     `json:"chars"`   // decoded characters, excluding spaces
+	Busy    bool    `json:"busy"`
+}
+
+// OccupancyScanner steps the modem across a range of frequencies, dwelling
+// on each slice to measure signal quality and decoded text.
+type OccupancyScanner struct {
+	rig     occupancyRig
+	step    float64 // Hz
+	dwell   time.Duration
+	sample  time.Duration
+	quality float64 // a slice whose quality reaches this is busy
+	chars   int     // as is one decoding at least this many characters
+
+	sleep func(time.Duration)
+}
+
+func NewOccupancyScanner(rig occupancyRig, step float64, dwell time.Duration) *OccupancyScanner {
+	return &OccupancyScanner{rig: rig, step: step, dwell: dwell, sample: 250 * time.Millisecond, quality: 40, chars: 5, sleep: time.Sleep}
+}
+
+// Scan measures every slice between start and end (Hz) by tuning so the
+// modem's signal frequency sits in the middle of the slice, then returns
+// the rig to where it was.
+func (s *OccupancyScanner) Scan(start, end float64) ([]Slice, error) {
+	if s.step <= 0 || end <= start {
+		return nil, fmt.Errorf("invalid scan range")
+	}
+
+	vfo, err := s.rig.GetFrequency()
+	if err != nil {
+		return nil, err
+	}
+	carrier, err := s.rig.GetCarrier()
+	if err != nil {
+		return nil, err
+	}
+	sideband, err := s.rig.GetSideband()
+	if err != nil {
+		return nil, err
+	}
+	defer s.rig.SetFrequency(vfo)
+
+	var slices []Slice
+	for f := start; f < end; f += s.step {
+		slice := Slice{StartHz: f, EndHz: f + s.step}
+		if slice.EndHz > end {
+			slice.EndHz = end
+		}
+
+		// The VFO that puts the signal at the slice centre is the inverse
+		// of signalFrequency.
+		centre := (slice.StartHz + slice.EndHz) / 2
+		tune := centre - carrier
+		if sideband == "LSB" {
+			tune = centre + carrier
+		}
+		if err := s.rig.SetFrequency(tune); err != nil {
+			return slices, err
+		}
+		if err := s.measure(&slice); err != nil {
+			return slices, err
+		}
+		slices = append(slices, slice)
+	}
+	return slices, nil
+}
+
+func (s *OccupancyScanner) measure(slice *Slice) error {
+	// Discard text decoded before the move.
+	if _, err := s.rig.GetRXData(); err != nil {
+		return err
+	}
+
+	for waited := time.Duration(0); waited < s.dwell; waited += s.sample {
+		s.sleep(s.sample)
+
+		quality, err := s.rig.GetQuality()
+		if err != nil {
+			return err
+		}
+		if quality > slice.Quality {
+			slice.Quality = quality
+		}
+
+		text, err := s.rig.GetRXData()
+		if err != nil {
+			return err
+		}
+		for _, r := range text {
+			if !unicode.IsSpace(r) && unicode.IsPrint(r) {
+				slice.Chars++
+			}
+		}
+	}
+
+	slice.Busy = slice.Quality >= s.quality || slice.Chars >= s.chars
+	return nil
+}
+
+// occupancyReport merges adjacent slices of the same state into lines such
+// as "14.070-14.080 busy".
+func occupancyReport(slices []Slice) []string {
+	var lines []string
+	for i := 0; i < len(slices); {
+		j := i
+		for j+1 < len(slices) && slices[j+1].Busy == slices[i].Busy {
+			j++
+		}
+		state := "clear"
+		if slices[i].Busy {
+			state = "busy"
+		}
+		lines = append(lines, fmt.Sprintf("%s-%s %s", formatMHz(slices[i].StartHz), formatMHz(slices[j].EndHz), state))
+		i = j + 1
+	}
+	return lines
+}
+
+// formatMHz renders a frequency in MHz with at least three decimals.
+func formatMHz(hz float64) string {
+	s := strconv.FormatFloat(hz/1000000, 'f', -1, 64)
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		s, dot = s+".", len(s)
+	}
+	for len(s)-dot-1 < 3 {
+		s += "0"
+	}
+	return s
+}
+
+// parseScanRange parses a segment or band name, or a "start-end" range of
+// frequencies in parseFrequency's format.
+func parseScanRange(s string) (start, end float64, err error) {
+	if r, ok := segmentByName(s); ok {
+		return r.StartMHz * 1000000, r.EndMHz * 1000000, nil
+	}
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("unknown segment %q (want a segment or band name, or start-end)", s)
+	}
+	if start, err = parseFrequency(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseFrequency(parts[1]); err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("range %q ends before it starts", s)
+	}
+	return start, end, nil
+}
+
+// runOccupancy implements `fldigi-cmd occupancy <segment|start-end>`: scan
+// a segment and report which parts of it are busy.
+func runOccupancy(args []string) int {
+	fs := flag.NewFlagSet("occupancy", flag.ExitOnError)
+	var host, step string
+	var port, chars int
+	var dwell time.Duration
+	var quality float64
+	var asJSON bool
+	fs.StringVar(&host, "host", "127.0.0.1", "fldigi host")
+	fs.IntVar(&port, "port", defaultPorts["fldigi"], "fldigi XML-RPC port")
+	fs.StringVar(&step, "step", "1k", "width of each slice, e.g. 500 or 1k")
+	fs.DurationVar(&dwell, "dwell", 3*time.Second, "time to listen on each slice")
+	fs.Float64Var(&quality, "quality", 40, "modem signal quality (0-100) at which a slice counts as busy")
+	fs.IntVar(&chars, "chars", 5, "decoded characters at which a slice counts as busy")
+	fs.BoolVar(&asJSON, "json", false, "print every slice as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd occupancy [flags] <segment|start-end>\n")
+		return 2
+	}
+	start, end, err := parseScanRange(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	stepHz, err := parseFrequency(step)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --step: %v\n", err)
+		return 2
+	}
+
+	scanner := NewOccupancyScanner(NewFldigiClient(host, port), stepHz, dwell)
+	scanner.quality, scanner.chars = quality, chars
+	slices, err := scanner.Scan(start, end)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, s := range slices {
+			enc.Encode(s)
+		}
+		return 0
+	}
+	for _, line := range occupancyReport(slices) {
+		fmt.Println(line)
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeOccupancyRig reports a strong signal, or decoded text, when the
+// modem's signal frequency is inside one of its busy ranges.
+type fakeOccupancyRig struct {
+	vfo, carrier float64
+	sideband     string
+	signals      [][2]float64 // strong signals
+	text         [][2]float64 // weak signals that still decode
+	tuned        []float64
+}
+
+func (r *fakeOccupancyRig) GetFrequency() (float64, error) { return r.vfo, nil }
+func (r *fakeOccupancyRig) GetCarrier() (float64, error)   { return r.carrier, nil }
+func (r *fakeOccupancyRig) GetSideband() (string, error)   { return r.sideband, nil }
+func (r *fakeOccupancyRig) SetFrequency(freq float64) error {
+	r.vfo = freq
+	r.tuned = append(r.tuned, freq)
+	return nil
+}
+
+func (r *fakeOccupancyRig) in(ranges [][2]float64) bool {
+	signal := signalFrequency(r.vfo, r.carrier, r.sideband)
+	for _, rg := range ranges {
+		if signal >= rg[0] && signal < rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeOccupancyRig) GetQuality() (float64, error) {
+	if r.in(r.signals) {
+		return 85, nil
+	}
+	return 5, nil
+}
+
+func (r *fakeOccupancyRig) GetRXData() (string, error) {
+	if r.in(r.text) {
+		return "CQ K1", nil
+	}
+	return " ", nil
+}
+
+func TestOccupancyScan(t *testing.T) {
+	rig := &fakeOccupancyRig{
+		vfo: 14070000, carrier: 1500, sideband: "USB",
+		signals: [][2]float64{{14070000, 14072000}},
+		text:    [][2]float64{{14074000, 14075000}},
+	}
+	s := NewOccupancyScanner(rig, 1000, time.Second)
+	s.sleep = func(time.Duration) {}
+
+	slices, err := s.Scan(14070000, 14076000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slices) != 6 {
+		t.Fatalf("got %d slices; want 6", len(slices))
+	}
+	if rig.tuned[0] != 14069000 { // signal at 14.0705 with a 1500 Hz carrier
+		t.Errorf("first slice tuned to %.0f", rig.tuned[0])
+	}
+	if rig.vfo != 14070000 {
+		t.Errorf("VFO left at %.0f; want it restored", rig.vfo)
+	}
+
+	want := []string{"14.070-14.072 busy", "14.072-14.074 clear", "14.074-14.075 busy", "14.075-14.076 clear"}
+	if got := occupancyReport(slices); !reflect.DeepEqual(got, want) {
+		t.Errorf("report = %q; want %q", got, want)
+	}
+}
+
+func TestParseScanRange(t *testing.T) {
+	start, end, err := parseScanRange("20m-PSK")
+	if err != nil || start != 14070000 || end != 14073000 {
+		t.Errorf("parseScanRange(20m-PSK) = %v, %v, %v", start, end, err)
+	}
+	start, end, err = parseScanRange("14.1M-14.112M")
+	if err != nil || start != 14100000 || end != 14112000 {
+		t.Errorf("parseScanRange(14.1M-14.112M) = %v, %v, %v", start, end, err)
+	}
+	if _, _, err := parseScanRange("14.2M-14.1M"); err == nil {
+		t.Error("backwards range accepted")
+	}
+	if got := formatMHz(14105500); got != "14.1055" {
+		t.Errorf("formatMHz(14105500) = %s", got)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OTRSPSink drives an SO2R controller over OTRSP, the Open Two Radio
+// Switching Protocol: on each band change of radio 1 or 2 it sets that
+// radio's AUX output, which the controller decodes to select antennas or
+// filters, and with tx-start events it moves the TX focus to the radio
+// transmitting.
+type OTRSPSink struct {
+	port *outputPort
+	// radios are the labels of radio 1 and radio 2.
+	radios []string
+	aux    map[string]int
+}
+
+// parseOTRSPAux parses --otrsp-aux options of the form BAND=N, overriding
+// the Yaesu BCD band codes sent as AUX values by default.
+func parseOTRSPAux(specs []string) (map[string]int, error) {
+	aux := map[string]int{}
+	for band, code := range yaesuBCD {
+		aux[band] = int(code)
+	}
+	for _, spec := range specs {
+		band, value, ok := strings.Cut(spec, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 0 || n > 255 {
+			return nil, fmt.Errorf("invalid OTRSP AUX value %q, want BAND=N with N from 0 to 255", spec)
+		}
+		if !isBand(band) {
+			return nil, fmt.Errorf("OTRSP AUX value %q: unknown band %s", spec, band)
+		}
+		aux[band] = n
+	}
+	return aux, nil
+}
+
+// NewOTRSPSink opens the serial port, or TCP serial server, at addr for the
+// radios with the given labels, the first being radio 1.
+func NewOTRSPSink(addr string, baud int, radios []string, aux map[string]int) (*OTRSPSink, error) {
+	if len(radios) > 2 {
+		return nil, fmt.Errorf("OTRSP switches two radios, not %d", len(radios))
+	}
+	s := &OTRSPSink{port: newOutputPort(addr, baud), radios: radios, aux: aux}
+	if dryRun {
+		return s, nil
+	}
+	if err := s.port.Open(); err != nil {
+		return nil, fmt.Errorf("OTRSP: %v", err)
+	}
+	return s, nil
+}
+
+func (s *OTRSPSink) Name() string { return "otrsp" }
+
+func (s *OTRSPSink) Wants(ev Event) bool {
+	switch ev.Type {
+	case EventInitialBand, EventBandChange, EventOutOfBand, EventTXStart:
+		return s.number(ev.Radio) > 0
+	}
+	return false
+}
+
+// number returns the OTRSP number of the radio with label, or 0.
+func (s *OTRSPSink) number(label string) int {
+	for i, r := range s.radios {
+		if r == label {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (s *OTRSPSink) Handle(ev Event) error {
+	n := s.number(ev.Radio)
+	var cmd string
+	switch ev.Type {
+	case EventTXStart:
+		cmd = fmt.Sprintf("TX%d", n)
+	case EventOutOfBand:
+		cmd = fmt.Sprintf("AUX%d0", n)
+	default:
+		cmd = fmt.Sprintf("AUX%d%d", n, s.aux[ev.Band])
+	}
+	if dryRun {
+		dryRunf("would send OTRSP %s to %s", cmd, s.port.addr)
+		return nil
+	}
+	return s.port.Write([]byte(cmd + "\r"))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestOTRSPSink(t *testing.T) {
+	aux, err := parseOTRSPAux([]string{"6m=12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := &fakePort{}
+	s := &OTRSPSink{port: &outputPort{addr: "/dev/ttyUSB2", open: func() (io.ReadWriteCloser, error) { return port, nil }}, radios: []string{"A", "B"}, aux: aux}
+
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Radio: "A", Band: "20m"},
+		{Type: EventInitialBand, Radio: "B", Band: "40m"},
+		{Type: EventInitialBand, Radio: "C", Band: "15m"},
+		{Type: EventTXStart, Radio: "B", Band: "40m"},
+		{Type: EventBandChange, Radio: "A", Band: "6m"},
+		{Type: EventSegmentChange, Radio: "A", Band: "6m", Segment: "6m-SSB"},
+		{Type: EventOutOfBand, Radio: "B", Freq: 30500000},
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got, want := port.String(), "AUX15\rAUX23\rTX2\rAUX112\rAUX20\r"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	for _, spec := range []string{"6m", "6m=256", "11m=3"} {
+		if _, err := parseOTRSPAux([]string{spec}); err == nil {
+			t.Errorf("parseOTRSPAux(%q) succeeded", spec)
+		}
+	}
+	if _, err := NewOTRSPSink("/dev/null", 9600, []string{"A", "B", "C"}, aux); err == nil {
+		t.Error("three radios accepted")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// outputPort is the serial port or TCP connection an output driver writes
+// to, and reads replies from. After a failed write it is reopened on the next one, e.g. once a USB
+// adapter is plugged back in or a serial server restarts.
+type outputPort struct {
+	addr string // serial device, or host:port of a TCP serial server
+	conn io.ReadWriteCloser
+	open func() (io.ReadWriteCloser, error)
+}
+
+// newOutputPort returns the port at addr: TCP for host:port, otherwise a
+// serial device opened at baud.
+func newOutputPort(addr string, baud int) *outputPort {
+	p := &outputPort{addr: addr}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		p.open = func() (io.ReadWriteCloser, error) {
+			return net.DialTimeout(tcpNetwork(), addr, dialTimeout)
+		}
+	} else {
+		p.open = func() (io.ReadWriteCloser, error) {
+			return openSerial(addr, baud)
+		}
+	}
+	return p
+}
+
+// Open opens the port if it isn't already.
+func (p *outputPort) Open() error {
+	if p.conn != nil {
+		return nil
+	}
+	conn, err := p.open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", p.addr, err)
+	}
+	p.conn = conn
+	return nil
+}
+
+func (p *outputPort) Write(data []byte) error {
+	if err := p.Open(); err != nil {
+		return err
+	}
+	if _, err := p.conn.Write(data); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return fmt.Errorf("failed to write to %s: %v", p.addr, err)
+	}
+	return nil
+}
+
+// ReadFull reads a reply filling buf, giving up once the device has been
+// silent for a while. The port is reopened after a failed read, dropping a
+// late reply that would otherwise be taken for the next one.
+func (p *outputPort) ReadFull(buf []byte) error {
+	if err := p.Open(); err != nil {
+		return err
+	}
+	if c, ok := p.conn.(interface{ SetReadDeadline(time.Time) error }); ok {
+		c.SetReadDeadline(time.Now().Add(requestTimeout))
+	}
+	if _, err := io.ReadFull(p.conn, buf); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return fmt.Errorf("no reply from %s: %v", p.addr, err)
+	}
+	return nil
+}
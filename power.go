@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PowerProfile is the drive level and front end of the rig on one band,
+// set on each change to the band. Settings left out of the profile are
+// -1 or empty and left alone.
+type PowerProfile struct {
+	Band   string
+	Power  float64 // percent of the rig's full output
+	Att    float64 // dB
+	Preamp float64 // dB
+	CAT    string  // raw CAT command, passed through flrig
+}
+
+// ParsePowerProfile parses a --power-profile option of the form
+// BAND=[POWER][,att=DB][,preamp=DB][,cat=COMMAND], such as "6m=25,att=0"
+// or "2m=10%,preamp=0". The CAT command runs to the end of the option, so
+// it may contain commas.
+func ParsePowerProfile(spec string) (PowerProfile, error) {
+	p := PowerProfile{Power: -1, Att: -1, Preamp: -1}
+	band, rest, ok := strings.Cut(spec, "=")
+	if !ok || band == "" || rest == "" {
+		return p, fmt.Errorf("invalid power profile %q, want BAND=[POWER][,att=DB][,preamp=DB][,cat=COMMAND]", spec)
+	}
+	if !isBand(band) {
+		return p, fmt.Errorf("power profile %q: unknown band %s", spec, band)
+	}
+	p.Band = band
+
+	if i := strings.Index(","+rest, ",cat="); i >= 0 {
+		p.CAT = rest[i+len("cat="):]
+		rest = strings.TrimSuffix(rest[:i], ",")
+	}
+	var items []string
+	if rest != "" {
+		items = strings.Split(rest, ",")
+	}
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			name, value = "power", strings.TrimSuffix(item, "%")
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("power profile %q: invalid %s %q", spec, name, value)
+		}
+		switch name {
+		case "power":
+			if n > 100 {
+				return p, fmt.Errorf("power profile %q: power is a percentage of full output, at most 100", spec)
+			}
+			p.Power = n
+		case "att":
+			p.Att = n
+		case "preamp":
+			p.Preamp = n
+		default:
+			return p, fmt.Errorf("power profile %q: unknown setting %s (want att, preamp or cat)", spec, name)
+		}
+	}
+	return p, nil
+}
+
+// PowerSink applies the power profile of the band a radio changes to.
+type PowerSink struct {
+	profiles map[string]PowerProfile
+	rigs     map[string]Backend
+}
+
+// NewPowerSink parses --power-profile options; radios are added with Add.
+func NewPowerSink(specs []string) (*PowerSink, error) {
+	s := &PowerSink{profiles: map[string]PowerProfile{}, rigs: map[string]Backend{}}
+	for _, spec := range specs {
+		p, err := ParsePowerProfile(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := s.profiles[p.Band]; dup {
+			return nil, fmt.Errorf("duplicate power profile for %s", p.Band)
+		}
+		s.profiles[p.Band] = p
+	}
+	return s, nil
+}
+
+// Add sets the backend of the radio with label, checking that it supports
+// every setting in the profiles. A --verify backend is checked by the one
+// it wraps.
+func (s *PowerSink) Add(label string, rig Backend) error {
+	b := rig
+	if v, ok := rig.(*verifyBackend); ok {
+		b = v.Backend
+	}
+	for _, p := range s.profiles {
+		if _, ok := b.(PowerSetter); p.Power >= 0 && !ok {
+			return fmt.Errorf("the %s backend can't set the power", b.Name())
+		}
+		if _, ok := b.(LevelSetter); (p.Att >= 0 || p.Preamp >= 0) && !ok {
+			return fmt.Errorf("the %s backend can't set the attenuator or preamp", b.Name())
+		}
+		if _, ok := b.(CATSender); p.CAT != "" && !ok {
+			return fmt.Errorf("the %s backend can't send CAT commands", b.Name())
+		}
+	}
+	s.rigs[label] = rig
+	return nil
+}
+
+func (s *PowerSink) Name() string { return "power-profile" }
+
+func (s *PowerSink) Wants(ev Event) bool {
+	if ev.Type != EventBandChange && ev.Type != EventInitialBand {
+		return false
+	}
+	_, ok := s.profiles[ev.Band]
+	return ok
+}
+
+// Handle sets the power before the attenuator, preamp and CAT command, so
+// the drive is down as early as possible.
+func (s *PowerSink) Handle(ev Event) error {
+	p := s.profiles[ev.Band]
+	b, ok := s.rigs[ev.Radio]
+	if !ok {
+		return fmt.Errorf("no rig for radio %q", ev.Radio)
+	}
+	if dryRun {
+		dryRunf("would apply the %s power profile: %s", p.Band, p.describe())
+		return nil
+	}
+
+	if p.Power >= 0 {
+		if err := b.(PowerSetter).SetPower(p.Power); err != nil {
+			return fmt.Errorf("failed to set %s power: %v", p.Band, err)
+		}
+	}
+	for _, level := range []struct {
+		name  string
+		value float64
+	}{{"ATT", p.Att}, {"PREAMP", p.Preamp}} {
+		if level.value < 0 {
+			continue
+		}
+		if err := b.(LevelSetter).SetLevel(level.name, level.value); err != nil {
+			return fmt.Errorf("failed to set %s %s: %v", p.Band, level.name, err)
+		}
+	}
+	if p.CAT != "" {
+		if err := b.(CATSender).SendCAT(p.CAT); err != nil {
+			return fmt.Errorf("failed to send the %s CAT command: %v", p.Band, err)
+		}
+	}
+	return nil
+}
+
+// describe lists the profile's settings, e.g. "power 25%, att 0 dB".
+func (p PowerProfile) describe() string {
+	var parts []string
+	if p.Power >= 0 {
+		parts = append(parts, fmt.Sprintf("power %g%%", p.Power))
+	}
+	if p.Att >= 0 {
+		parts = append(parts, fmt.Sprintf("att %g dB", p.Att))
+	}
+	if p.Preamp >= 0 {
+		parts = append(parts, fmt.Sprintf("preamp %g dB", p.Preamp))
+	}
+	if p.CAT != "" {
+		parts = append(parts, fmt.Sprintf("CAT %q", p.CAT))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,117 @@
+package main
+
+import "testing"
+
+func TestParsePowerProfile(t *testing.T) {
+	for spec, want := range map[string]PowerProfile{
+		"6m=25":                 {Band: "6m", Power: 25, Att: -1, Preamp: -1},
+		"2m=10%,preamp=0":       {Band: "2m", Power: 10, Att: -1, Preamp: 0},
+		"160m=att=12":           {Band: "160m", Power: -1, Att: 12, Preamp: -1},
+		"6m=50,cat=EX0301;EX2,": {Band: "6m", Power: 50, Att: -1, Preamp: -1, CAT: "EX0301;EX2,"},
+		"10m=cat=PA0;":          {Band: "10m", Power: -1, Att: -1, Preamp: -1, CAT: "PA0;"},
+	} {
+		if got, err := ParsePowerProfile(spec); err != nil || got != want {
+			t.Errorf("ParsePowerProfile(%q) = %+v, %v; want %+v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"6m", "6m=", "11m=25", "6m=150", "6m=-5", "6m=25,gain=3"} {
+		if _, err := ParsePowerProfile(spec); err == nil {
+			t.Errorf("ParsePowerProfile(%q) succeeded", spec)
+		}
+	}
+	if _, err := NewPowerSink([]string{"6m=25", "6m=50"}); err == nil {
+		t.Error("duplicate profiles accepted")
+	}
+}
+
+// fakePowerRig records power and level changes.
+type fakePowerRig struct {
+	fakeBackend
+	power  []float64
+	levels map[string]float64
+}
+
+func (r *fakePowerRig) SetPower(percent float64) error {
+	r.power = append(r.power, percent)
+	return nil
+}
+
+func (r *fakePowerRig) SetLevel(name string, value float64) error {
+	r.levels[name] = value
+	return nil
+}
+
+func TestPowerSink(t *testing.T) {
+	s, err := NewPowerSink([]string{"6m=25,att=0", "20m=100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rig := &fakePowerRig{levels: map[string]float64{}}
+	if err := s.Add("", rig); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Band: "20m"},
+		{Type: EventBandChange, Band: "40m"},
+		{Type: EventBandChange, Band: "6m"},
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(rig.power) != 2 || rig.power[0] != 100 || rig.power[1] != 25 {
+		t.Errorf("power set to %v, want [100 25]", rig.power)
+	}
+	if att, ok := rig.levels["ATT"]; !ok || att != 0 || len(rig.levels) != 1 {
+		t.Errorf("levels = %v, want ATT 0", rig.levels)
+	}
+
+	// A CAT command needs a backend that passes them through.
+	s, _ = NewPowerSink([]string{"6m=cat=PA0;"})
+	if err := s.Add("", rig); err == nil {
+		t.Error("added a rig that can't send CAT commands")
+	}
+}
+
+// readingPowerRig reads its power back, or with stuck keeps full power.
+type readingPowerRig struct {
+	fakePowerRig
+	stuck bool
+}
+
+func (r *readingPowerRig) SetPower(percent float64) error {
+	if r.stuck {
+		percent = 100
+	}
+	return r.fakePowerRig.SetPower(percent)
+}
+
+func (r *readingPowerRig) GetPower() (float64, error) { return r.power[len(r.power)-1], nil }
+
+func TestPowerSinkVerify(t *testing.T) {
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), sink)
+	s, _ := NewPowerSink([]string{"6m=25"})
+	rig := &readingPowerRig{fakePowerRig: fakePowerRig{levels: map[string]float64{}}}
+	if err := s.Add("", &verifyBackend{Backend: rig, retries: 1, dispatcher: dispatcher}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := Event{Type: EventBandChange, Band: "6m"}
+	if err := s.Handle(ev); err != nil || len(rig.power) != 1 {
+		t.Errorf("Handle() = %v after setting %v", err, rig.power)
+	}
+	rig.stuck = true
+	if err := s.Handle(ev); err == nil || len(sink.events) != 1 || sink.events[0].Type != EventVerifyFailed || sink.events[0].ReadBack != 100 {
+		t.Errorf("Handle() = %v with events %+v", err, sink.events)
+	}
+
+	// The wrapped backend is the one checked for each setting.
+	s, _ = NewPowerSink([]string{"6m=cat=PA0;"})
+	if err := s.Add("", &verifyBackend{Backend: rig, dispatcher: dispatcher}); err == nil {
+		t.Error("added a rig that can't send CAT commands")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Preset is a named frequency memory, with the mode to switch to.
+type Preset struct {
+	Name string  `json:"name"`
+	Freq float64 `json:"freq"`
+	Mode string  `json:"mode,omitempty"`
+}
+
+// Presets are the --preset memories, in the order given, and the one each
+// radio last went to for cycling through them.
+type Presets struct {
+	mu      sync.Mutex
+	list    []Preset
+	current map[string]int
+}
+
+// ParsePresets parses --preset options of the form NAME=FREQ [MODE], such
+// as "ft8-20m=14.074M USB" or "ft8-20m=14.074 MHz USB".
+func ParsePresets(specs []string) (*Presets, error) {
+	p := &Presets{current: map[string]int{}}
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(value)
+		if !ok || name == "" || len(fields) == 0 {
+			return nil, fmt.Errorf("invalid preset %q, want NAME=FREQ [MODE]", spec)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("preset %q is defined twice", name)
+		}
+		seen[name] = true
+		// A unit may be written apart from the number.
+		if len(fields) > 1 {
+			switch strings.ToLower(fields[1]) {
+			case "mhz":
+				fields = append([]string{fields[0] + "M"}, fields[2:]...)
+			case "khz":
+				fields = append([]string{fields[0] + "k"}, fields[2:]...)
+			case "hz":
+				fields = append([]string{fields[0]}, fields[2:]...)
+			}
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid preset %q, want NAME=FREQ [MODE]", spec)
+		}
+		freq, err := parseFrequency(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("preset %q: %v", name, err)
+		}
+		preset := Preset{Name: name, Freq: freq}
+		if len(fields) == 2 {
+			preset.Mode = fields[1]
+		}
+		p.list = append(p.list, preset)
+	}
+	return p, nil
+}
+
+// List returns the presets in order.
+func (p *Presets) List() []Preset {
+	return append([]Preset(nil), p.list...)
+}
+
+// Find returns the index of the preset called name.
+func (p *Presets) Find(name string) (int, bool) {
+	for i, preset := range p.list {
+		if preset.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Step returns the preset step places on from the one radio last went to,
+// wrapping around; before any, 1 is the first preset and -1 the last.
+func (p *Presets) Step(radio string, step int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i, ok := p.current[radio]
+	if !ok {
+		i = -1
+		if step < 0 {
+			i = 0
+		}
+	}
+	n := len(p.list)
+	return ((i+step)%n + n) % n
+}
+
+// Went records that radio went to preset i.
+func (p *Presets) Went(radio string, i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current[radio] = i
+}
+
+// handlePresets lists the presets; POST /presets?name=ft8-20m[&radio=B]
+// goes to one, and POST /presets?step=1 or step=-1 to the next or
+// previous.
+func (a *API) handlePresets(w http.ResponseWriter, r *http.Request) {
+	if a.presets == nil || len(a.presets.list) == 0 {
+		http.Error(w, "no presets are configured (use --preset)", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, a.presets.List())
+		return
+	}
+
+	query := r.URL.Query()
+	radio := query.Get("radio")
+	var i int
+	if step := query.Get("step"); step != "" {
+		n, err := strconv.Atoi(step)
+		if err != nil {
+			http.Error(w, "invalid step", http.StatusBadRequest)
+			return
+		}
+		i = a.presets.Step(radio, n)
+	} else {
+		var ok bool
+		if i, ok = a.presets.Find(query.Get("name")); !ok {
+			http.Error(w, fmt.Sprintf("unknown preset %q", query.Get("name")), http.StatusNotFound)
+			return
+		}
+	}
+
+	preset := a.presets.list[i]
+	if code, err := a.tune(radio, preset.Freq); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if preset.Mode != "" {
+		if code, err := a.setMode(radio, preset.Mode); err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+	}
+	a.presets.Went(radio, i)
+	writeJSON(w, preset)
+}
+
+// runGoto implements `fldigi-cmd goto [PRESET]`: tune to a preset, or step
+// through them with --next and --prev, or list them without arguments.
+func runGoto(args []string) int {
+	fs := flag.NewFlagSet("goto", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	var radio string
+	var next, prev bool
+	fs.StringVar(&radio, "radio", "", "label of the radio to tune")
+	fs.BoolVar(&next, "next", false, "go to the preset after the last one gone to")
+	fs.BoolVar(&prev, "prev", false, "go to the preset before the last one gone to")
+	fs.Parse(args)
+
+	query := url.Values{}
+	if radio != "" {
+		query.Set("radio", radio)
+	}
+	switch {
+	case fs.NArg() > 1 || (fs.NArg() == 1 && (next || prev)) || (next && prev):
+		fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd goto [--radio label] [PRESET|--next|--prev]\n")
+		return 2
+	case next:
+		query.Set("step", "1")
+	case prev:
+		query.Set("step", "-1")
+	case fs.NArg() == 1:
+		query.Set("name", fs.Arg(0))
+	default:
+		var presets []Preset
+		if err := connect().do(http.MethodGet, "/presets", nil, &presets); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for _, p := range presets {
+			fmt.Println(formatPreset(p))
+		}
+		return 0
+	}
+
+	var preset Preset
+	if err := connect().do(http.MethodPost, "/presets", query, &preset); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Println(formatPreset(preset))
+	return 0
+}
+
+// formatPreset shows a preset as "ft8-20m  14.074000 MHz  USB".
+func formatPreset(p Preset) string {
+	s := fmt.Sprintf("%-12s %10.6f MHz", p.Name, p.Freq/1000000)
+	if p.Mode != "" {
+		s += "  " + p.Mode
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePresets(t *testing.T) {
+	p, err := ParsePresets([]string{"ft8-20m=14.074 MHz USB", "psk-40m=7.070M BPSK31", "wwv=10000 kHz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Preset{{"ft8-20m", 14074000, "USB"}, {"psk-40m", 7070000, "BPSK31"}, {"wwv", 10000000, ""}}
+	if got := p.List(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("presets = %+v", got)
+	}
+
+	for _, specs := range [][]string{
+		{"14.074M"},
+		{"ft8=abc"},
+		{"ft8=14.074M USB extra"},
+		{"ft8=14.074M", "ft8=7.074M"},
+	} {
+		if _, err := ParsePresets(specs); err == nil {
+			t.Errorf("ParsePresets(%q) succeeded", specs)
+		}
+	}
+}
+
+func TestPresetsStep(t *testing.T) {
+	p, _ := ParsePresets([]string{"a=1M", "b=2M", "c=3M"})
+	if i := p.Step("", 1); i != 0 {
+		t.Errorf("first next = %d", i)
+	}
+	if i := p.Step("", -1); i != 2 {
+		t.Errorf("first prev = %d", i)
+	}
+	p.Went("", 2)
+	if i := p.Step("", 1); i != 0 {
+		t.Errorf("next after the last = %d", i)
+	}
+	if i := p.Step("B", 1); i != 0 {
+		t.Errorf("another radio's next = %d", i)
+	}
+}
+
+func TestAPIPresets(t *testing.T) {
+	rig := &fakeModeBackend{fakeBackend: fakeBackend{name: "fake"}}
+	presets, _ := ParsePresets([]string{"ft8-20m=14.074M USB", "ft8-40m=7.074M"})
+	api := &API{rigs: map[string]Backend{"": rig}, presets: presets}
+	post := func(query string) (int, Preset) {
+		rec := httptest.NewRecorder()
+		api.handlePresets(rec, httptest.NewRequest(http.MethodPost, "/presets?"+query, nil))
+		var p Preset
+		json.NewDecoder(rec.Body).Decode(&p)
+		return rec.Code, p
+	}
+
+	if code, p := post("name=ft8-20m"); code != http.StatusOK || p.Freq != 14074000 {
+		t.Errorf("goto ft8-20m = %d, %+v", code, p)
+	}
+	if len(rig.sets) != 1 || rig.sets[0] != 14074000 || len(rig.modes) != 1 || rig.modes[0] != "USB" {
+		t.Errorf("tuned to %v, modes %v", rig.sets, rig.modes)
+	}
+	if code, p := post("step=1"); code != http.StatusOK || p.Name != "ft8-40m" {
+		t.Errorf("next = %d, %+v", code, p)
+	}
+	if len(rig.modes) != 1 {
+		t.Errorf("a preset without a mode set it: %v", rig.modes)
+	}
+	if code, _ := post("name=nope"); code != http.StatusNotFound {
+		t.Errorf("unknown preset = %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	api.handlePresets(rec, httptest.NewRequest(http.MethodGet, "/presets", nil))
+	var list []Preset
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list) != 2 {
+		t.Errorf("list = %+v, %v", list, err)
+	}
+}
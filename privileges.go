@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed privileges.txt
+var privilegesData string
+
+// Privileges is the set of frequency ranges an operator may transmit on.
+type Privileges struct {
+	Name   string
+	Ranges []BandRange
+
+	// Allocated also requires freq to be within a band of the active band
+	// plan, which follows the ITU region with --region auto. Without Ranges
+	// only the allocations are checked.
+	Allocated bool
+}
+
+// Allows reports whether freq (Hz) falls within a permitted range.
+func (p *Privileges) Allows(freq float64) bool {
+	if p.Allocated {
+		if frequencyToBand(freq) == "unknown" {
+			return false
+		}
+		if p.Ranges == nil {
+			return true
+		}
+	}
+
+	freqMHz := freq / 1000000
+
+	for _, r := range p.Ranges {
+		if freqMHz >= r.StartMHz && freqMHz <= r.EndMHz {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRanges reads name:start:end lines, skipping comments and blank or
+// malformed lines.
+func parseRanges(data string) []BandRange {
+	var ranges []BandRange
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			continue
+		}
+
+		startMHz, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+
+		endMHz, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			continue
+		}
+
+		ranges = append(ranges, BandRange{Name: parts[0], StartMHz: startMHz, EndMHz: endMHz})
+	}
+
+	return ranges
+}
+
+// LoadLicenseProfile returns the built-in US privileges for a license class.
+func LoadLicenseProfile(class string) (*Privileges, error) {
+	class = strings.ToLower(class)
+
+	p := &Privileges{Name: class}
+	for _, r := range parseRanges(privilegesData) {
+		if r.Name == class {
+			p.Ranges = append(p.Ranges, r)
+		}
+	}
+
+	if len(p.Ranges) == 0 {
+		return nil, fmt.Errorf("unknown license class %q (want technician, general or extra)", class)
+	}
+	return p, nil
+}
+
+// LoadAllowedSegments reads permitted ranges from a file in the band plan
+// format (name:start_mhz:end_mhz).
+func LoadAllowedSegments(path string) (*Privileges, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed segments: %v", err)
+	}
+
+	p := &Privileges{Name: path, Ranges: parseRanges(string(data))}
+	if len(p.Ranges) == 0 {
+		return nil, fmt.Errorf("no segments found in %s", path)
+	}
+	return p, nil
+}
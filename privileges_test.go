@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLicenseProfiles(t *testing.T) {
+	testCases := []struct {
+		class    string
+		freq     float64
+		expected bool
+	}{
+		{"extra", 14010000, true},
+		{"general", 14010000, false}, // Extra-only CW segment
+		{"general", 14074000, true},
+		{"general", 14200000, false}, // Extra/Advanced phone
+		{"technician", 14074000, false},
+		{"technician", 28400000, true},
+		{"technician", 144200000, true},
+		{"Extra", 13000000, false}, // outside every band
+	}
+
+	for _, tc := range testCases {
+		p, err := LoadLicenseProfile(tc.class)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := p.Allows(tc.freq); result != tc.expected {
+			t.Errorf("%s Allows(%.0f) = %v; want %v", tc.class, tc.freq, result, tc.expected)
+		}
+	}
+
+	if _, err := LoadLicenseProfile("novice"); err == nil {
+		t.Error("LoadLicenseProfile(novice) succeeded; want error")
+	}
+}
+
+func TestLoadAllowedSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowed.txt")
+	data := "# my privileges\n40m:7.0:7.2\nbogus line\n20m:14.0:14.1\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadAllowedSegments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Ranges) != 2 {
+		t.Errorf("loaded %d ranges; want 2", len(p.Ranges))
+	}
+	if !p.Allows(7100000) || p.Allows(7250000) {
+		t.Error("allowed segments not applied")
+	}
+}
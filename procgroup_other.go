@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command; processes it started are left
+// running, as there are no process groups to kill here.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
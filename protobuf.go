@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Protocol Buffers wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoMessage builds an encoded Protocol Buffers message. As in proto3,
+// fields with their zero value are left out.
+type protoMessage []byte
+
+func (m *protoMessage) tag(field, wire int) {
+	*m = binary.AppendUvarint(*m, uint64(field<<3|wire))
+}
+
+func (m *protoMessage) putBytes(field int, b []byte) {
+	m.tag(field, protoBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *protoMessage) putString(field int, s string) {
+	if s != "" {
+		m.putBytes(field, []byte(s))
+	}
+}
+
+func (m *protoMessage) putDouble(field int, v float64) {
+	if v != 0 {
+		m.tag(field, protoFixed64)
+		*m = binary.LittleEndian.AppendUint64(*m, math.Float64bits(v))
+	}
+}
+
+// putInt encodes an int32 or int64 field; negative values take ten bytes.
+func (m *protoMessage) putInt(field int, v int64) {
+	if v != 0 {
+		m.tag(field, protoVarint)
+		*m = binary.AppendUvarint(*m, uint64(v))
+	}
+}
+
+func (m *protoMessage) putBool(field int, v bool) {
+	if v {
+		m.tag(field, protoVarint)
+		*m = append(*m, 1)
+	}
+}
+
+// putMessage encodes an embedded message, even an empty one, as an element
+// of a repeated field needs.
+func (m *protoMessage) putMessage(field int, sub protoMessage) {
+	m.putBytes(field, sub)
+}
+
+// putTime encodes a google.protobuf.Timestamp.
+func (m *protoMessage) putTime(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	var ts protoMessage
+	ts.putInt(1, t.Unix())
+	ts.putInt(2, int64(t.Nanosecond()))
+	m.putMessage(field, ts)
+}
+
+// putMap encodes a map<string, string> field, in key order.
+func (m *protoMessage) putMap(field int, values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry protoMessage
+		entry.putString(1, k)
+		entry.putString(2, values[k])
+		m.putMessage(field, entry)
+	}
+}
+
+// protoField is a field of an encoded message: its number and wire type
+// and either its number value or, if length-delimited, its bytes.
+type protoField struct {
+	num   int
+	wire  int
+	value uint64
+	bytes []byte
+}
+
+// parseProto splits an encoded message into its fields.
+func parseProto(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("truncated protobuf message")
+		}
+		data = data[n:]
+		f := protoField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case protoVarint:
+			if f.value, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("truncated protobuf message")
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return nil, fmt.Errorf("truncated protobuf message")
+			}
+			f.value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return nil, fmt.Errorf("truncated protobuf message")
+			}
+			f.value, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return nil, fmt.Errorf("truncated protobuf message")
+			}
+			f.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", f.wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// double returns a double field's value; a field of another type is 0.
+func (f protoField) double() float64 {
+	if f.wire != protoFixed64 {
+		return 0
+	}
+	return math.Float64frombits(f.value)
+}
+
+// str returns a string field's value.
+func (f protoField) str() string {
+	return string(f.bytes)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProtoMessage(t *testing.T) {
+	var m protoMessage
+	m.putString(1, "A")
+	m.putString(2, "") // left out
+	m.putDouble(3, 14074000)
+	m.putInt(4, -1)
+	m.putBool(5, true)
+	m.putTime(6, time.Unix(1700000000, 5))
+	m.putMap(7, map[string]string{"b": "2", "a": "1"})
+
+	fields, err := parseProto(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 7 {
+		t.Fatalf("got %d fields: %+v", len(fields), fields)
+	}
+	if fields[0].num != 1 || fields[0].str() != "A" {
+		t.Errorf("string field = %+v", fields[0])
+	}
+	if fields[1].num != 3 || fields[1].double() != 14074000 {
+		t.Errorf("double field = %+v", fields[1])
+	}
+	if fields[2].num != 4 || int32(fields[2].value) != -1 {
+		t.Errorf("int field = %+v", fields[2])
+	}
+	if fields[3].num != 5 || fields[3].value != 1 {
+		t.Errorf("bool field = %+v", fields[3])
+	}
+	ts, err := parseProto(fields[4].bytes)
+	if err != nil || len(ts) != 2 || ts[0].value != 1700000000 || ts[1].value != 5 {
+		t.Errorf("timestamp = %+v, %v", ts, err)
+	}
+	if entry, _ := parseProto(fields[5].bytes); len(entry) != 2 || entry[0].str() != "a" || entry[1].str() != "1" {
+		t.Errorf("first map entry = %+v", entry)
+	}
+
+	// The protoc encoding of the same string and double fields.
+	want := []byte{0x0a, 0x01, 'A', 0x19, 0, 0, 0, 0, 0x12, 0xd8, 0x6a, 0x41}
+	if !bytes.HasPrefix(m, want) {
+		t.Errorf("encoding = % x, want prefix % x", []byte(m), want)
+	}
+}
+
+func TestParseProtoTruncated(t *testing.T) {
+	for _, data := range [][]byte{{0x0a, 0x05, 'A'}, {0x19, 0, 0}, {0x08}, {0x0b}} {
+		if _, err := parseProto(data); err == nil {
+			t.Errorf("parseProto(% x) succeeded", data)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RPCCache holds recent responses to read-only XML-RPC calls so that several
+// applications polling fldigi through the proxy result in a single request.
+type RPCCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+func NewRPCCache(ttl time.Duration) *RPCCache {
+	return &RPCCache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+func (c *RPCCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *RPCCache) Put(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{body: body, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *RPCCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cacheEntry{}
+}
+
+// isReadOnly reports whether an XML-RPC method only queries fldigi state.
+func isReadOnly(method string) bool {
+	name := method[strings.LastIndex(method, ".")+1:]
+	return strings.HasPrefix(name, "get") || strings.HasPrefix(name, "list") || name == "methodHelp" || name == "methodSignature"
+}
+
+// isCacheable reports whether a method's response can be shared between
+// callers. The rx/tx data getters return text since the previous call, so
+// each caller must see its own response.
+func isCacheable(method string) bool {
+	switch method {
+	case "rx.get_data", "tx.get_data":
+		return false
+	}
+	return isReadOnly(method)
+}
+
+// Proxy forwards XML-RPC requests from other applications to fldigi through
+// the shared client cache and reports state-changing calls as events.
+type Proxy struct {
+	client     *FldigiClient
+	dispatcher *Dispatcher
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "XML-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var call MethodCall
+	if err := xml.Unmarshal(reqBody, &call); err != nil {
+		http.Error(w, "invalid XML-RPC request", http.StatusBadRequest)
+		return
+	}
+
+	// Key on the re-marshalled call so formatting differences between
+	// clients don't defeat the cache.
+	key, err := xml.Marshal(call)
+	if err != nil {
+		key = reqBody
+	}
+
+	body, err := p.client.exchange(call.Method, string(key), reqBody)
+	if err != nil {
+		log.Printf("Error proxying %s: %v", call.Method, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if !isReadOnly(call.Method) {
+		p.dispatcher.Emit(Event{Type: EventRPCCall, Method: call.Method})
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(body)
+}
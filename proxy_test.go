@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyCachesGetters(t *testing.T) {
+	var upstream int32
+	fldigi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstream, 1)
+		fmt.Fprint(w, `<?xml version="1.0"?><methodResponse><params><param><value><double>14070000</double></value></param></params></methodResponse>`)
+	}))
+	defer fldigi.Close()
+
+	client := NewFldigiClient("127.0.0.1", 0)
+	client.url = fldigi.URL
+	client.cache = NewRPCCache(time.Minute)
+
+	proxy := httptest.NewServer(&Proxy{client: client, dispatcher: NewDispatcher(NewMetrics())})
+	defer proxy.Close()
+
+	call := func(body string) string {
+		resp, err := http.Post(proxy.URL, "text/xml", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	// Another application and our own poller share the cached response.
+	call(`<?xml version="1.0"?>
+<methodCall>
+  <methodName>rig.get_vfo</methodName>
+</methodCall>`)
+	if _, err := client.GetFrequency(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&upstream); n != 1 {
+		t.Errorf("fldigi received %d requests; want 1", n)
+	}
+
+	// A setter is always forwarded and invalidates cached getters.
+	call(`<methodCall><methodName>rig.set_frequency</methodName><params><param><value><double>7070000</double></value></param></params></methodCall>`)
+	if out := call(`<methodCall><methodName>rig.get_vfo</methodName></methodCall>`); !strings.Contains(out, "14070000") {
+		t.Errorf("unexpected proxied response %q", out)
+	}
+	if n := atomic.LoadInt32(&upstream); n != 3 {
+		t.Errorf("fldigi received %d requests; want 3", n)
+	}
+}
+
+func TestIsCacheable(t *testing.T) {
+	testCases := map[string]bool{
+		"rig.get_vfo":        true,
+		"modem.get_carrier":  true,
+		"system.listMethods": true,
+		"rx.get_data":        false,
+		"main.set_frequency": false,
+		"main.tx":            false,
+	}
+
+	for method, expected := range testCases {
+		if result := isCacheable(method); result != expected {
+			t.Errorf("isCacheable(%s) = %v; want %v", method, result, expected)
+		}
+	}
+}
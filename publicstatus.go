@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PublicStatus publishes a read-only, sanitized view of the station for a
+// personal website: the band and mode of each radio, whether it is on the
+// air and the bands recently used. It is served over HTTP and can also be
+// written to a directory for static hosting.
+type PublicStatus struct {
+	title string
+	api   *API
+
+	// freqStep is the resolution in Hz the frequency is shown at; 0 hides
+	// it.
+	freqStep float64
+
+	// dir, if set, receives index.html and status.json whenever the status
+	// changes, after which push is run with the directory as its argument.
+	dir  string
+	push string
+
+	mu     sync.Mutex
+	recent []PublicBand
+	onAir  map[string]bool
+	last   []byte
+}
+
+// PublicSnapshot is the JSON form of the public status.
+type PublicSnapshot struct {
+	Title   string        `json:"title,omitempty"`
+	Updated time.Time     `json:"updated"`
+	Radios  []PublicRadio `json:"radios"`
+	Recent  []PublicBand  `json:"recent"`
+}
+
+type PublicRadio struct {
+	Radio  string  `json:"radio,omitempty"`
+	Online bool    `json:"online"`
+	OnAir  bool    `json:"on_air"`
+	Band   string  `json:"band,omitempty"`
+	Mode   string  `json:"mode,omitempty"`
+	Freq   float64 `json:"freq,omitempty"`
+}
+
+// PublicBand is a band recently used and when it was last used.
+type PublicBand struct {
+	Band string    `json:"band"`
+	Time time.Time `json:"time"`
+}
+
+// maxRecentBands is how many recently used bands the page lists.
+const maxRecentBands = 10
+
+func NewPublicStatus(title string, api *API, freqStep float64) *PublicStatus {
+	return &PublicStatus{title: title, api: api, freqStep: freqStep, onAir: map[string]bool{}}
+}
+
+func (p *PublicStatus) Name() string { return "public-status" }
+
+func (p *PublicStatus) Wants(ev Event) bool {
+	return ev.Type == EventBandChange || ev.Type == EventInitialBand
+}
+
+// Handle moves the event's band to the front of the recent bands.
+func (p *PublicStatus) Handle(ev Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recent := []PublicBand{{Band: ev.Band, Time: ev.Time}}
+	for _, b := range p.recent {
+		if b.Band != ev.Band && len(recent) < maxRecentBands {
+			recent = append(recent, b)
+		}
+	}
+	p.recent = recent
+	return nil
+}
+
+// Snapshot returns the current public status.
+func (p *PublicStatus) Snapshot() PublicSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snap := PublicSnapshot{Title: p.title, Updated: time.Now().UTC(), Recent: append([]PublicBand{}, p.recent...)}
+	for _, m := range p.api.monitors {
+		status := m.Status()
+		radio := PublicRadio{
+			Radio:  status.Radio,
+			Online: status.Error == "" && !status.Time.IsZero(),
+			OnAir:  p.onAir[status.Radio],
+			Band:   status.Band,
+			Mode:   status.Mode,
+		}
+		if p.freqStep > 0 && radio.Online {
+			radio.Freq = math.Round(status.Freq/p.freqStep) * p.freqStep
+		}
+		snap.Radios = append(snap.Radios, radio)
+	}
+	return snap
+}
+
+// Run polls the TX state of each radio every interval and, with a
+// directory set, publishes the page there whenever it has changed.
+func (p *PublicStatus) Run(interval time.Duration) {
+	for {
+		p.pollTX()
+		if p.dir != "" {
+			if err := p.publish(); err != nil {
+				log.Printf("Error publishing status page: %v", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (p *PublicStatus) pollTX() {
+	onAir := map[string]bool{}
+	for _, m := range p.api.monitors {
+		if tx, ok := m.backend.(TXController); ok {
+			state, err := tx.GetTRXState()
+			onAir[m.radio] = err == nil && state != "RX"
+		}
+	}
+	p.mu.Lock()
+	p.onAir = onAir
+	p.mu.Unlock()
+}
+
+// publish writes the page to the directory and runs the push command, if
+// anything but the update time has changed since it last did.
+func (p *PublicStatus) publish() error {
+	snap := p.Snapshot()
+	key := snap
+	key.Updated = time.Time{}
+	current, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, p.last) {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	var page bytes.Buffer
+	if err := publicPage.Execute(&page, snap); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+	for name, content := range map[string][]byte{"status.json": append(data, '\n'), "index.html": page.Bytes()} {
+		// Write then rename so a web server never serves half a file.
+		tmp := filepath.Join(p.dir, "."+name+".tmp")
+		if err := os.WriteFile(tmp, content, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(p.dir, name)); err != nil {
+			return err
+		}
+	}
+	p.last = current
+
+	if p.push != "" {
+		return runExternalCommand(p.push, p.dir)
+	}
+	return nil
+}
+
+// ServeHTTP serves the page at / and its JSON form at /status.json. Both
+// may be fetched from any origin so a website can embed them.
+func (p *PublicStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	switch r.URL.Path {
+	case "/status.json":
+		writeJSON(w, p.Snapshot())
+	case "/", "/index.html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := publicPage.Execute(w, p.Snapshot()); err != nil {
+			log.Printf("Error rendering status page: %v", err)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var publicPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"mhz": formatMHz,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>{{with .Title}}{{.}}{{else}}Station status{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+.on-air { color: #fff; background: #c00; padding: 0 .4em; border-radius: .2em; }
+.offline { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{with .Title}}{{.}}{{else}}Station status{{end}}</h1>
+{{range .Radios}}<p{{if not .Online}} class="offline"{{end}}>
+{{with .Radio}}<strong>Radio {{.}}</strong>: {{end}}{{if .Online}}{{with .Band}}{{.}}{{else}}out of band{{end}}{{with .Mode}} {{.}}{{end}}{{if .Freq}} ({{mhz .Freq}} MHz){{end}}{{if .OnAir}} <span class="on-air">ON AIR</span>{{end}}{{else}}off the air{{end}}
+</p>
+{{end}}{{with .Recent}}<h2>Recent bands</h2>
+<ul>
+{{range .}}<li>{{.Band}}, {{.Time.UTC.Format "2006-01-02 15:04"}} UTC</li>
+{{end}}</ul>
+{{end}}<p><small>Updated {{.Updated.Format "2006-01-02 15:04:05"}} UTC</small></p>
+</body>
+</html>
+`))
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// txBackend is a backend that also reports its TX state.
+type txBackend struct {
+	fakeBackend
+	fakeTX
+}
+
+func TestPublicStatusSanitizes(t *testing.T) {
+	m, _ := newTestMonitor()
+	m.backend = &txBackend{fakeTX: fakeTX{state: "TX"}}
+	m.currentBand, m.currentMode = "20m", "BPSK31"
+	m.setStatus(14070987, nil)
+
+	p := NewPublicStatus("N0CALL", &API{monitors: []*Monitor{m}}, 0)
+	now := time.Now()
+	for _, band := range []string{"40m", "20m", "40m", "15m"} {
+		p.Handle(Event{Type: EventBandChange, Band: band, Time: now})
+	}
+	p.pollTX()
+
+	snap := p.Snapshot()
+	radio := snap.Radios[0]
+	if !radio.Online || !radio.OnAir || radio.Band != "20m" || radio.Mode != "BPSK31" || radio.Freq != 0 {
+		t.Errorf("radio = %+v, want online and on air on 20m BPSK31 with no frequency", radio)
+	}
+	var recent []string
+	for _, b := range snap.Recent {
+		recent = append(recent, b.Band)
+	}
+	if strings.Join(recent, ",") != "15m,40m,20m" {
+		t.Errorf("recent bands = %v, want [15m 40m 20m]", recent)
+	}
+
+	p.freqStep = 1000
+	if freq := p.Snapshot().Radios[0].Freq; freq != 14071000 {
+		t.Errorf("frequency at 1 kHz steps = %v, want 14071000", freq)
+	}
+}
+
+func TestPublicStatusIsReadOnly(t *testing.T) {
+	m, _ := newTestMonitor()
+	p := NewPublicStatus("", &API{monitors: []*Monitor{m}}, 0)
+
+	for path, want := range map[string]int{"/": http.StatusOK, "/status.json": http.StatusOK, "/frequency": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status.json", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestPublicStatusPublish(t *testing.T) {
+	dir := t.TempDir()
+	pushes := filepath.Join(dir, "pushes")
+	script := filepath.Join(dir, "push.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"$1\" >> "+pushes+"\n"), 0755)
+
+	m, _ := newTestMonitor()
+	m.currentBand = "40m"
+	m.setStatus(7074000, nil)
+	p := NewPublicStatus("N0CALL", &API{monitors: []*Monitor{m}}, 0)
+	p.dir = filepath.Join(dir, "site")
+	p.push = script
+
+	for i := 0; i < 2; i++ {
+		if err := p.publish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := os.ReadFile(filepath.Join(p.dir, "index.html"))
+	if err != nil || !strings.Contains(string(page), "40m") || strings.Contains(string(page), "7.074") {
+		t.Errorf("index.html = %q, %v", page, err)
+	}
+	if _, err := os.Stat(filepath.Join(p.dir, "status.json")); err != nil {
+		t.Error(err)
+	}
+	if data, _ := os.ReadFile(pushes); strings.Count(string(data), "\n") != 1 {
+		t.Errorf("pushed %q, want once for an unchanged page", data)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// qsoFields are the fldigi log panel fields read over XML-RPC and the ADIF
+// fields they become.
+var qsoFields = []struct{ method, adif string }{
+	{"log.get_name", "NAME"},
+	{"log.get_rst_in", "RST_RCVD"},
+	{"log.get_rst_out", "RST_SENT"},
+	{"log.get_qth", "QTH"},
+	{"log.get_locator", "GRIDSQUARE"},
+	{"log.get_state", "STATE"},
+	{"log.get_province", "VE_PROV"},
+	{"log.get_country", "COUNTRY"},
+	{"log.get_serial_number", "SRX"},
+	{"log.get_serial_number_sent", "STX"},
+	{"log.get_exchange", "SRX_STRING"},
+	{"log.get_notes", "COMMENT"},
+	{"log.get_time_on", "TIME_ON"},
+	{"log.get_time_off", "TIME_OFF"},
+	{"log.get_frequency", "FREQ"},
+}
+
+// QSOBridge watches fldigi's log panel and emits a qso-logged event for
+// each contact logged there. fldigi clears the panel when a QSO is saved,
+// so a callsign that disappears marks a logged contact, recorded with the
+// fields read while it was there.
+type QSOBridge struct {
+	client     *FldigiClient
+	dispatcher *Dispatcher
+	// last holds the fields read on the previous poll, while a callsign
+	// is entered.
+	last map[string]string
+}
+
+func NewQSOBridge(client *FldigiClient, dispatcher *Dispatcher) *QSOBridge {
+	return &QSOBridge{client: client, dispatcher: dispatcher}
+}
+
+// Run polls the log panel every interval.
+func (b *QSOBridge) Run(interval time.Duration) {
+	for {
+		if err := b.poll(time.Now()); err != nil {
+			log.Printf("Error reading fldigi log fields: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (b *QSOBridge) poll(now time.Time) error {
+	value, err := b.client.Call("log.get_call")
+	if err != nil {
+		return err
+	}
+	call := strings.ToUpper(strings.TrimSpace(value.Text()))
+	if call == "" {
+		if b.last != nil {
+			b.logged(b.last, now)
+			b.last = nil
+		}
+		return nil
+	}
+
+	fields := map[string]string{"CALL": call}
+	for _, f := range qsoFields {
+		// Fields missing from older fldigi versions are left out.
+		if v, err := b.client.Call(f.method); err == nil {
+			if text := strings.TrimSpace(v.Text()); text != "" {
+				fields[f.adif] = text
+			}
+		}
+	}
+	if mode, err := b.client.GetMode(); err == nil && mode != "" {
+		fields["MODE"] = mode
+	}
+	if fields["FREQ"] == "" {
+		if vfo, err := b.client.GetFrequency(); err == nil && vfo > 0 {
+			fields["FREQ"] = strconv.FormatFloat(vfo/1000, 'f', 3, 64)
+		}
+	}
+	b.last = fields
+	return nil
+}
+
+// logged emits the qso-logged event for the fields last read, completing
+// them as an ADIF record.
+func (b *QSOBridge) logged(fields map[string]string, now time.Time) {
+	qso := qsoRecord(fields, now.UTC())
+	freq, _ := strconv.ParseFloat(qso["FREQ"], 64)
+	fmt.Printf("QSO logged in fldigi: %s\n", qso["CALL"])
+	b.dispatcher.Emit(Event{Type: EventQSOLogged, Time: now, Call: qso["CALL"], Band: qso["BAND"], Freq: freq * 1000000, Mode: fields["MODE"], QSO: qso})
+}
+
+// qsoRecord turns fldigi log fields into ADIF fields: the frequency from
+// kHz to MHz, with its band, the mode into ADIF's MODE and SUBMODE, and
+// the dates the times on and off fall on.
+func qsoRecord(fields map[string]string, now time.Time) map[string]string {
+	qso := map[string]string{}
+	for k, v := range fields {
+		qso[k] = v
+	}
+	if khz, err := strconv.ParseFloat(fields["FREQ"], 64); err == nil && khz > 0 {
+		qso["FREQ"] = strconv.FormatFloat(khz/1000, 'f', 6, 64)
+		if band := frequencyToBand(khz * 1000); band != "unknown" {
+			qso["BAND"] = strings.ToLower(band)
+		}
+	} else {
+		delete(qso, "FREQ")
+	}
+	delete(qso, "MODE")
+	if mode, submode := adifMode(fields["MODE"]); mode != "" {
+		qso["MODE"] = mode
+		if submode != "" {
+			qso["SUBMODE"] = submode
+		}
+	}
+
+	off := adifTime(fields["TIME_OFF"])
+	if off == "" {
+		off = now.Format("150405")
+	}
+	qso["TIME_OFF"] = off
+	qso["QSO_DATE_OFF"] = now.Format("20060102")
+	on := adifTime(fields["TIME_ON"])
+	if on == "" {
+		on = off
+	}
+	qso["TIME_ON"] = on
+	// A contact that started before midnight and ended after it began the
+	// day before.
+	date := now
+	if on > off {
+		date = now.AddDate(0, 0, -1)
+	}
+	qso["QSO_DATE"] = date.Format("20060102")
+	return qso
+}
+
+// adifTime normalizes a log time such as 1234, 12:34 or 123456 to ADIF's
+// HHMMSS, or returns "" if it isn't one.
+func adifTime(s string) string {
+	s = strings.ReplaceAll(s, ":", "")
+	if len(s) == 4 {
+		s += "00"
+	}
+	if len(s) != 6 {
+		return ""
+	}
+	if _, err := strconv.Atoi(s); err != nil {
+		return ""
+	}
+	return s
+}
+
+// adifOrder is the order fields are written in an ADIF record; others
+// follow in alphabetical order.
+var adifOrder = []string{"QSO_DATE", "TIME_ON", "QSO_DATE_OFF", "TIME_OFF", "CALL", "BAND", "FREQ", "MODE", "SUBMODE", "RST_SENT", "RST_RCVD"}
+
+// adifRecord formats a record's fields, ending with <EOR>.
+func adifRecord(fields map[string]string) string {
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, k := range adifOrder {
+		b.WriteString(adifField(k, fields[k]))
+		seen[k] = true
+	}
+	var rest []string
+	for k := range fields {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		b.WriteString(adifField(k, fields[k]))
+	}
+	b.WriteString("<EOR>\n")
+	return b.String()
+}
+
+// adifHeader is the header of an ADIF file.
+func adifHeader(comment string, now time.Time) string {
+	return comment + "\n" + adifField("ADIF_VER", "3.1.4") + adifField("PROGRAMID", "fldigi-cmd") + adifField("CREATED_TIMESTAMP", now.UTC().Format("20060102 150405")) + "<EOH>\n"
+}
+
+// adifLogSink appends logged QSOs to an ADIF file, writing the header
+// first if the file is new.
+type adifLogSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newADIFLogSink(path string) (*adifLogSink, error) {
+	s := &adifLogSink{path: path}
+	f, err := s.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ADIF log: %v", err)
+	}
+	f.Close()
+	return s, nil
+}
+
+func (s *adifLogSink) Name() string        { return "qso-adif" }
+func (s *adifLogSink) Wants(ev Event) bool { return ev.Type == EventQSOLogged }
+
+// open opens the file for appending, writing the header if it is empty.
+// It is opened for each QSO so it can be moved or rotated while the daemon
+// runs.
+func (s *adifLogSink) open() (*os.File, error) {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		if _, err := f.WriteString(adifHeader("fldigi-cmd QSO log", time.Now())); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (s *adifLogSink) Handle(ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.open()
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(adifRecord(ev.QSO)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
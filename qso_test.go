@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLogPanel answers fldigi's log.get_* calls from a map of fields.
+type fakeLogPanel struct {
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+func (p *fakeLogPanel) set(fields map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fields = fields
+}
+
+func (p *fakeLogPanel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var call MethodCall
+	xml.NewDecoder(r.Body).Decode(&call)
+	p.mu.Lock()
+	value, ok := p.fields[call.Method]
+	p.mu.Unlock()
+	if !ok && strings.HasPrefix(call.Method, "log.") {
+		value = ""
+	} else if !ok {
+		w.Write([]byte(`<methodResponse><fault><value><string>no such method</string></value></fault></methodResponse>`))
+		return
+	}
+	w.Write([]byte(`<methodResponse><params><param><value><string>` + value + `</string></value></param></params></methodResponse>`))
+}
+
+func TestQSOBridgeLogsClearedContact(t *testing.T) {
+	panel := &fakeLogPanel{}
+	server := httptest.NewServer(panel)
+	defer server.Close()
+	client, err := NewFldigiClientURL(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "fldigi.adi")
+	adif, err := newADIFLogSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics())
+	dispatcher.Add(adif)
+	dispatcher.Add(events)
+	bridge := NewQSOBridge(client, dispatcher)
+
+	now := time.Date(2026, 3, 2, 0, 5, 0, 0, time.UTC)
+	panel.set(map[string]string{"log.get_call": "w1a", "log.get_frequency": "14070.150", "log.get_rst_out": "599", "log.get_time_on": "2358", "modem.get_name": "BPSK31"})
+	bridge.poll(now)
+	panel.set(map[string]string{"log.get_call": "w1aw", "log.get_frequency": "14070.150", "log.get_rst_out": "599", "log.get_rst_in": "579", "log.get_name": "Hiram", "log.get_time_on": "2358", "modem.get_name": "BPSK31"})
+	bridge.poll(now)
+	if len(events.events) != 0 {
+		t.Fatalf("logged %v before the panel was cleared", events.events)
+	}
+	panel.set(map[string]string{})
+	bridge.poll(now)
+	bridge.poll(now)
+
+	if len(events.events) != 1 || events.events[0].Call != "W1AW" || events.events[0].Band != "20m" {
+		t.Fatalf("events = %+v", events.events)
+	}
+	data, _ := os.ReadFile(path)
+	want := "<QSO_DATE:8>20260301 <TIME_ON:6>235800 <QSO_DATE_OFF:8>20260302 <TIME_OFF:6>000500 <CALL:4>W1AW <BAND:3>20m <FREQ:9>14.070150 <MODE:3>PSK <SUBMODE:6>BPSK31 <RST_SENT:3>599 <RST_RCVD:3>579 <NAME:5>Hiram <EOR>\n"
+	if !strings.Contains(string(data), "<EOH>\n") || !strings.HasSuffix(string(data), want) {
+		t.Errorf("ADIF log =\n%s\nwant record\n%s", data, want)
+	}
+}
+
+func TestAdifTime(t *testing.T) {
+	for in, want := range map[string]string{"1234": "123400", "12:34": "123400", "123456": "123456", "": "", "12h": ""} {
+		if got := adifTime(in); got != want {
+			t.Errorf("adifTime(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// qsoForwarder sends each logged QSO to another logger over TCP or UDP,
+// formatted by a template: "adif" for the ADIF record, "n3fjp" for N3FJP's
+// API, or a template of event placeholders such as {qso.CALL}.
+type qsoForwarder struct {
+	network  string
+	addr     string
+	template string
+}
+
+// n3fjpFields are the N3FJP API fields filled from a QSO's ADIF fields.
+var n3fjpFields = []struct{ n3fjp, adif string }{
+	{"fldCall", "CALL"},
+	{"fldFrequency", "FREQ"},
+	{"fldRstS", "RST_SENT"},
+	{"fldRstR", "RST_RCVD"},
+	{"fldNameR", "NAME"},
+	{"fldQTH", "QTH"},
+	{"fldState", "STATE"},
+	{"fldGridR", "GRIDSQUARE"},
+	{"fldCountryWorked", "COUNTRY"},
+	{"fldComments", "COMMENT"},
+}
+
+func newQSOForwarder(target, template string) (*qsoForwarder, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "udp") || u.Port() == "" {
+		return nil, fmt.Errorf("invalid --qso-forward %q, want tcp://host:port or udp://host:port", target)
+	}
+	return &qsoForwarder{network: u.Scheme, addr: u.Host, template: template}, nil
+}
+
+func (f *qsoForwarder) Name() string        { return "qso-forward" }
+func (f *qsoForwarder) Wants(ev Event) bool { return ev.Type == EventQSOLogged }
+
+func (f *qsoForwarder) Handle(ev Event) error {
+	msg := f.format(ev)
+	if dryRun {
+		dryRunf("would send to %s://%s: %q", f.network, f.addr, msg)
+		return nil
+	}
+	conn, err := net.DialTimeout(f.network, f.addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to logger: %v", err)
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("failed to send QSO to logger: %v", err)
+	}
+	return nil
+}
+
+// format renders the QSO as the logger expects it.
+func (f *qsoForwarder) format(ev Event) string {
+	switch f.template {
+	case "", "adif":
+		return adifRecord(ev.QSO)
+	case "n3fjp":
+		return n3fjpCommand(ev.QSO)
+	}
+	s := strings.NewReplacer(`\r`, "\r", `\n`, "\n", `\t`, "\t").Replace(f.template)
+	return expandTemplate(strings.ReplaceAll(s, "{adif}", adifRecord(ev.QSO)), ev)
+}
+
+// n3fjpCommand is the N3FJP API command that logs a QSO, skipping dupes.
+func n3fjpCommand(qso map[string]string) string {
+	strip := strings.NewReplacer("<", "", ">", "")
+	field := func(name, value string) string {
+		if value == "" {
+			return ""
+		}
+		return "<" + name + ">" + strip.Replace(value) + "</" + name + ">"
+	}
+	var b strings.Builder
+	b.WriteString("<CMD><ADDDIRECT><EXCLUDEDUPES>TRUE</EXCLUDEDUPES><STAYOPEN>FALSE</STAYOPEN>")
+	// N3FJP wants bands in metres without the unit, e.g. 20.
+	band := qso["BAND"]
+	if !strings.HasSuffix(band, "cm") && !strings.HasSuffix(band, "mm") {
+		band = strings.TrimSuffix(band, "m")
+	}
+	b.WriteString(field("fldBand", band))
+	// PSK and MFSK contacts are logged by submode, such as BPSK31 or FT4.
+	mode := qso["SUBMODE"]
+	if mode == "" || qso["MODE"] == "SSB" {
+		mode = qso["MODE"]
+	}
+	b.WriteString(field("fldMode", mode))
+	b.WriteString(field("fldDateStr", n3fjpDate(qso["QSO_DATE"])))
+	b.WriteString(field("fldTimeOnStr", n3fjpTime(qso["TIME_ON"])))
+	b.WriteString(field("fldTimeOffStr", n3fjpTime(qso["TIME_OFF"])))
+	for _, f := range n3fjpFields {
+		b.WriteString(field(f.n3fjp, qso[f.adif]))
+	}
+	b.WriteString("</CMD>\r\n")
+	return b.String()
+}
+
+// n3fjpDate turns an ADIF date, 20260301, into N3FJP's 2026/03/01.
+func n3fjpDate(s string) string {
+	if len(s) != 8 {
+		return s
+	}
+	return s[:4] + "/" + s[4:6] + "/" + s[6:]
+}
+
+// n3fjpTime turns an ADIF time, 123456, into N3FJP's 12:34:56.
+func n3fjpTime(s string) string {
+	if len(s) != 6 {
+		return s
+	}
+	return s[:2] + ":" + s[2:4] + ":" + s[4:]
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+var testQSO = map[string]string{"QSO_DATE": "20260301", "TIME_ON": "235800", "QSO_DATE_OFF": "20260302", "TIME_OFF": "000500", "CALL": "W1AW", "BAND": "20m", "FREQ": "14.070150", "MODE": "PSK", "SUBMODE": "BPSK31", "RST_SENT": "599", "NAME": "Hiram <ARRL>"}
+
+func TestQSOForwarderN3FJP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		got <- line
+	}()
+
+	f, err := newQSOForwarder("tcp://"+ln.Addr().String(), "n3fjp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Handle(Event{Type: EventQSOLogged, QSO: testQSO}); err != nil {
+		t.Fatal(err)
+	}
+	want := "<CMD><ADDDIRECT><EXCLUDEDUPES>TRUE</EXCLUDEDUPES><STAYOPEN>FALSE</STAYOPEN><fldBand>20</fldBand><fldMode>BPSK31</fldMode><fldDateStr>2026/03/01</fldDateStr><fldTimeOnStr>23:58:00</fldTimeOnStr><fldTimeOffStr>00:05:00</fldTimeOffStr><fldCall>W1AW</fldCall><fldFrequency>14.070150</fldFrequency><fldRstS>599</fldRstS><fldNameR>Hiram ARRL</fldNameR></CMD>\r\n"
+	select {
+	case line := <-got:
+		if line != want {
+			t.Errorf("sent %q\nwant %q", line, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing sent")
+	}
+}
+
+func TestQSOForwarderTemplate(t *testing.T) {
+	f, err := newQSOForwarder("udp://127.0.0.1:2333", `{call} {qso.band} {qso.mode}/{qso.submode} {freq_mhz}\r\n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := Event{Type: EventQSOLogged, Call: "W1AW", Freq: 14070150, QSO: testQSO}
+	if got := f.format(ev); got != "W1AW 20m PSK/BPSK31 14.070150\r\n" {
+		t.Errorf("format() = %q", got)
+	}
+	f.template = "adif"
+	if got := f.format(ev); got != adifRecord(testQSO) {
+		t.Errorf("adif format() = %q", got)
+	}
+
+	for _, target := range []string{"127.0.0.1:1100", "http://127.0.0.1:1100", "tcp://127.0.0.1"} {
+		if _, err := newQSOForwarder(target, "adif"); err == nil {
+			t.Errorf("newQSOForwarder(%q) succeeded", target)
+		}
+	}
+}
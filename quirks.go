@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed rigs.txt
+var rigQuirksData string
+
+// RigQuirks describes how a rig model differs from an ideal backend.
+type RigQuirks struct {
+	Model string
+
+	// Step is the tuning granularity in Hz.
+	Step float64
+	// Settle is how long the rig needs after a frequency change before it
+	// reports the new frequency.
+	Settle time.Duration
+	// Modes maps upper-case reported mode names to common names.
+	Modes map[string]string
+}
+
+// LoadRigQuirks returns the built-in quirk profile for a rig model.
+func LoadRigQuirks(model string) (*RigQuirks, error) {
+	model = strings.ToLower(model)
+
+	var q *RigQuirks
+	modes := make(map[string]string)
+	specific := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(rigQuirksData))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 4 {
+			continue
+		}
+
+		switch {
+		case parts[0] == "rig" && parts[1] == model:
+			step, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				continue
+			}
+			settle, err := strconv.Atoi(parts[3])
+			if err != nil {
+				continue
+			}
+			q = &RigQuirks{Model: model, Step: step, Settle: time.Duration(settle) * time.Millisecond}
+		case parts[0] == "mode" && parts[1] == "*":
+			modes[strings.ToUpper(parts[2])] = parts[3]
+		case parts[0] == "mode" && parts[1] == model:
+			specific[strings.ToUpper(parts[2])] = parts[3]
+		}
+	}
+
+	if q == nil {
+		return nil, fmt.Errorf("unknown rig model %q", model)
+	}
+
+	// Rig-specific names take precedence over the common mappings.
+	for name, mapped := range specific {
+		modes[name] = mapped
+	}
+	q.Modes = modes
+	return q, nil
+}
+
+// Round rounds freq (Hz) to the rig's tuning step.
+func (q *RigQuirks) Round(freq float64) float64 {
+	if q.Step <= 1 {
+		return math.Round(freq)
+	}
+	return math.Round(freq/q.Step) * q.Step
+}
+
+// Mode maps a reported mode name to its common name.
+func (q *RigQuirks) Mode(mode string) string {
+	if mapped, ok := q.Modes[strings.ToUpper(strings.TrimSpace(mode))]; ok {
+		return mapped
+	}
+	return mode
+}
+
+// quirkBackend applies a rig's quirk profile to a backend.
+type quirkBackend struct {
+	Backend
+	quirks *RigQuirks
+}
+
+func (b *quirkBackend) GetMode() (string, error) {
+	mode, err := b.Backend.GetMode()
+	if err != nil {
+		return "", err
+	}
+	return b.quirks.Mode(mode), nil
+}
+
+// SetFrequency tunes to the nearest step the rig supports and waits for it
+// to settle, so a following read reports the new frequency.
+func (b *quirkBackend) SetFrequency(freq float64) error {
+	if err := b.Backend.SetFrequency(b.quirks.Round(freq)); err != nil {
+		return err
+	}
+	time.Sleep(b.quirks.Settle)
+	return nil
+}
+
+func (b *quirkBackend) SetMode(mode string) error {
+	m, ok := b.Backend.(ModeSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't change the mode", b.Name())
+	}
+	return m.SetMode(mode)
+}
+
+func (b *quirkBackend) SendText(text string) error {
+	s, ok := b.Backend.(TextSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't transmit text", b.Name())
+	}
+	return s.SendText(text)
+}
+
+func (b *quirkBackend) GetSplit() (bool, float64, error) {
+	s, ok := b.Backend.(SplitReader)
+	if !ok {
+		return false, 0, fmt.Errorf("the %s backend does not report split operation", b.Name())
+	}
+	return s.GetSplit()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadRigQuirks(t *testing.T) {
+	q, err := LoadRigQuirks("FT-991A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Step != 10 || q.Settle != 300*time.Millisecond {
+		t.Errorf("quirks = %+v", q)
+	}
+	if got := q.Round(14074004); got != 14074000 {
+		t.Errorf("Round() = %v", got)
+	}
+
+	tests := map[string]string{
+		"DATA-USB": "USB-D", // Yaesu-specific
+		"pktusb":   "USB-D", // common Hamlib name
+		"USB":      "USB",
+	}
+	for reported, want := range tests {
+		if got := q.Mode(reported); got != want {
+			t.Errorf("Mode(%q) = %q; want %q", reported, got, want)
+		}
+	}
+
+	if _, err := LoadRigQuirks("ic-999"); err == nil {
+		t.Error("expected an error for an unknown model")
+	}
+}
+
+func TestQuirkBackend(t *testing.T) {
+	q, err := LoadRigQuirks("k3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Settle = 0
+
+	fake := &fakeBackend{freq: 7074000, mode: "DATA"}
+	b := &quirkBackend{Backend: fake, quirks: q}
+
+	if mode, _ := b.GetMode(); mode != "USB-D" {
+		t.Errorf("GetMode() = %q", mode)
+	}
+	if err := b.SetFrequency(7047123); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.sets) != 1 || fake.sets[0] != 7047120 {
+		t.Errorf("sets = %v", fake.sets)
+	}
+}
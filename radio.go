@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Radio is one of several labelled rigs monitored together, e.g. the A and
+// B radios of an SO2R station.
+type Radio struct {
+	Label   string
+	Backend Backend
+}
+
+// ParseRadio parses a label=host[:port] or label=URL radio using the named
+// backend.
+func ParseRadio(spec, backendName string) (Radio, error) {
+	label, addr, ok := strings.Cut(spec, "=")
+	if !ok || label == "" || addr == "" {
+		return Radio{}, fmt.Errorf("invalid radio %q (want label=host[:port] or label=URL)", spec)
+	}
+
+	var backend Backend
+	if isURL(addr) {
+		var err error
+		if backend, err = NewBackendURL(backendName, addr); err != nil {
+			return Radio{}, err
+		}
+		return Radio{Label: label, Backend: backend}, nil
+	}
+
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return Radio{}, err
+	}
+	backend, err = NewBackend(backendName, host, port)
+	if err != nil {
+		return Radio{}, err
+	}
+	return Radio{Label: label, Backend: backend}, nil
+}
+
+// radioFlag collects repeated --radio options.
+type radioFlag []string
+
+func (f *radioFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *radioFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// knownRadio reports whether label is "" or one of the radios' labels.
+func knownRadio(radios []Radio, label string) bool {
+	if label == "" {
+		return true
+	}
+	for _, r := range radios {
+		if r.Label == label {
+			return true
+		}
+	}
+	return false
+}
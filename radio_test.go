@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRadio(t *testing.T) {
+	r, err := ParseRadio("B=192.168.1.20:7363", "fldigi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Label != "B" || r.Backend.(*FldigiClient).url != "http://192.168.1.20:7363/RPC2" {
+		t.Errorf("ParseRadio() = %+v", r)
+	}
+
+	if r, err := ParseRadio("A=localhost", "rigctld"); err != nil || r.Backend.(*RigctldClient).addr != "localhost:4532" {
+		t.Errorf("ParseRadio() with default port = %+v, %v", r, err)
+	}
+
+	if r, err := ParseRadio("C=https://shack.example.com/fldigi", "fldigi"); err != nil || r.Backend.(*FldigiClient).url != "https://shack.example.com/fldigi" {
+		t.Errorf("ParseRadio() with a URL = %+v, %v", r, err)
+	}
+
+	for _, spec := range []string{"localhost:7362", "=localhost", "A="} {
+		if _, err := ParseRadio(spec, "fldigi"); err == nil {
+			t.Errorf("ParseRadio(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestMonitorRadioLabels(t *testing.T) {
+	a, sink := newTestMonitor()
+	a.radio = "A"
+	b := &Monitor{
+		radio:        "B",
+		debouncer:    &BandDebouncer{},
+		segDebouncer: &BandDebouncer{},
+		dispatcher:   a.dispatcher,
+		metrics:      a.metrics,
+	}
+
+	now := time.Now()
+	a.observe(14074000, now)
+	b.observe(7074000, now)
+
+	// Each radio tracks its own band: neither sees a band change.
+	for _, ev := range sink.events {
+		if ev.Type == EventBandChange {
+			t.Errorf("unexpected band change %+v", ev)
+		}
+	}
+	first, last := sink.events[0], sink.events[len(sink.events)-1]
+	if first.Radio != "A" || first.Band != "20m" || last.Radio != "B" || last.Band != "40m" {
+		t.Errorf("events = %+v", sink.events)
+	}
+
+	if env := commandEnv(last); len(env) != 1 || env[0] != "FLDIGI_CMD_RADIO=B" {
+		t.Errorf("commandEnv() = %v", env)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReceiverSettings are fldigi's receive settings. Settings left out are
+// nil: not read, or left alone when set.
+type ReceiverSettings struct {
+	Squelch      *bool    `json:"squelch,omitempty"`
+	SquelchLevel *float64 `json:"squelch_level,omitempty"`
+	AFC          *bool    `json:"afc,omitempty"`
+	RSID         *bool    `json:"rsid,omitempty"` // RxID
+	TXID         *bool    `json:"txid,omitempty"`
+}
+
+// receiverSettingNames are the names of the settings in the order shown.
+var receiverSettingNames = []string{"squelch", "squelch-level", "afc", "rsid", "txid"}
+
+// isReceiverSetting reports whether name is a receiver setting.
+func isReceiverSetting(name string) bool {
+	for _, n := range receiverSettingNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverRig is a backend with fldigi's receive settings.
+type receiverRig interface {
+	GetReceiver() (ReceiverSettings, error)
+	SetReceiver(s ReceiverSettings) error
+}
+
+// ParseReceiverSettings parses comma-separated NAME=VALUE settings, such
+// as "squelch=on,squelch-level=30,afc=off,rsid=on,txid=off".
+func ParseReceiverSettings(spec string) (ReceiverSettings, error) {
+	var s ReceiverSettings
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return s, fmt.Errorf("invalid receiver setting %q, want NAME=VALUE", item)
+		}
+		if err := s.set(name, strings.TrimSpace(value)); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// set sets the named setting from its text.
+func (s *ReceiverSettings) set(name, value string) error {
+	if name == "squelch-level" {
+		level, err := strconv.ParseFloat(value, 64)
+		if err != nil || level < 0 || level > 100 {
+			return fmt.Errorf("invalid squelch level %q, want 0 to 100", value)
+		}
+		s.SquelchLevel = &level
+		return nil
+	}
+	var on bool
+	switch strings.ToLower(value) {
+	case "on", "true", "1":
+		on = true
+	case "off", "false", "0":
+	default:
+		return fmt.Errorf("invalid %s %q, want on or off", name, value)
+	}
+	switch name {
+	case "squelch":
+		s.Squelch = &on
+	case "afc":
+		s.AFC = &on
+	case "rsid":
+		s.RSID = &on
+	case "txid":
+		s.TXID = &on
+	default:
+		return fmt.Errorf("unknown receiver setting %s (want %s)", name, strings.Join(receiverSettingNames, ", "))
+	}
+	return nil
+}
+
+// Get returns the text of the named setting, "" if it is unset.
+func (s ReceiverSettings) Get(name string) string {
+	onOff := func(b *bool) string {
+		switch {
+		case b == nil:
+			return ""
+		case *b:
+			return "on"
+		}
+		return "off"
+	}
+	switch name {
+	case "squelch":
+		return onOff(s.Squelch)
+	case "squelch-level":
+		if s.SquelchLevel == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*s.SquelchLevel, 'f', -1, 64)
+	case "afc":
+		return onOff(s.AFC)
+	case "rsid":
+		return onOff(s.RSID)
+	case "txid":
+		return onOff(s.TXID)
+	}
+	return ""
+}
+
+// String lists the settings that are set, e.g. "squelch=on afc=off".
+func (s ReceiverSettings) String() string {
+	var parts []string
+	for _, name := range receiverSettingNames {
+		if v := s.Get(name); v != "" {
+			parts = append(parts, name+"="+v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ModeDefaults are the receiver settings applied on switching to a modem.
+type ModeDefaults struct {
+	// Mode is a modem name, or a prefix ending in "*" such as "OLIVIA*".
+	Mode     string
+	Settings ReceiverSettings
+}
+
+// ParseModeDefaults parses a --mode-defaults option of the form
+// MODE:SETTINGS, e.g. "BPSK31:squelch=on,squelch-level=25,afc=on".
+func ParseModeDefaults(spec string) (ModeDefaults, error) {
+	mode, settings, ok := strings.Cut(spec, ":")
+	if !ok || mode == "" || settings == "" {
+		return ModeDefaults{}, fmt.Errorf("invalid mode defaults %q, want MODE:NAME=VALUE,..., e.g. BPSK31:squelch=on,afc=on", spec)
+	}
+	s, err := ParseReceiverSettings(settings)
+	if err != nil {
+		return ModeDefaults{}, fmt.Errorf("mode defaults %q: %v", spec, err)
+	}
+	return ModeDefaults{Mode: mode, Settings: s}, nil
+}
+
+// Matches reports whether the defaults are for the modem named mode.
+func (d ModeDefaults) Matches(mode string) bool {
+	if prefix, ok := strings.CutSuffix(d.Mode, "*"); ok {
+		return strings.HasPrefix(strings.ToUpper(mode), strings.ToUpper(prefix))
+	}
+	return strings.EqualFold(d.Mode, mode)
+}
+
+// ModeDefaultsSink applies a modem's receiver settings when a radio
+// switches to it. An exact mode is preferred to a prefix.
+type ModeDefaultsSink struct {
+	defaults []ModeDefaults
+	rigs     map[string]receiverRig
+}
+
+// NewModeDefaultsSink parses --mode-defaults options; radios are added
+// with Add.
+func NewModeDefaultsSink(specs []string) (*ModeDefaultsSink, error) {
+	s := &ModeDefaultsSink{rigs: map[string]receiverRig{}}
+	for _, spec := range specs {
+		d, err := ParseModeDefaults(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.defaults = append(s.defaults, d)
+	}
+	// Prefixes sort after exact modes, the longest first.
+	sort.SliceStable(s.defaults, func(i, j int) bool {
+		pi, pj := strings.HasSuffix(s.defaults[i].Mode, "*"), strings.HasSuffix(s.defaults[j].Mode, "*")
+		if pi != pj {
+			return pj
+		}
+		return pi && len(s.defaults[i].Mode) > len(s.defaults[j].Mode)
+	})
+	return s, nil
+}
+
+// Add applies the defaults to the radio with label, refusing backends
+// without fldigi's receive settings.
+func (s *ModeDefaultsSink) Add(label string, b Backend) error {
+	rig, ok := b.(receiverRig)
+	if !ok {
+		return fmt.Errorf("the %s backend has no receiver settings", b.Name())
+	}
+	s.rigs[label] = rig
+	return nil
+}
+
+func (s *ModeDefaultsSink) find(mode string) (ModeDefaults, bool) {
+	for _, d := range s.defaults {
+		if d.Matches(mode) {
+			return d, true
+		}
+	}
+	return ModeDefaults{}, false
+}
+
+func (s *ModeDefaultsSink) Name() string { return "mode-defaults" }
+
+func (s *ModeDefaultsSink) Wants(ev Event) bool {
+	if ev.Type != EventModeChange || s.rigs[ev.Radio] == nil {
+		return false
+	}
+	_, ok := s.find(ev.Mode)
+	return ok
+}
+
+func (s *ModeDefaultsSink) Handle(ev Event) error {
+	d, _ := s.find(ev.Mode)
+	if dryRun {
+		dryRunf("would set %s for %s", d.Settings, ev.Mode)
+		return nil
+	}
+	return s.rigs[ev.Radio].SetReceiver(d.Settings)
+}
+
+// handleReceiver returns a radio's receiver settings: GET
+// /receiver[?radio=B]; POST /receiver?afc=on&squelch-level=30[&radio=B]
+// changes them.
+func (a *API) handleReceiver(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	radio := query.Get("radio")
+	var rig receiverRig
+	found := false
+	for _, m := range a.monitors {
+		if m.radio == radio {
+			rig, found = m.backend.(receiverRig)
+			if !found {
+				http.Error(w, fmt.Sprintf("the %s backend has no receiver settings", m.backend.Name()), http.StatusNotImplemented)
+				return
+			}
+		}
+	}
+	if !found {
+		http.Error(w, "unknown radio", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var settings ReceiverSettings
+		for _, name := range receiverSettingNames {
+			if value := query.Get(name); value != "" {
+				if err := settings.set(name, value); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		if err := rig.SetReceiver(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	settings, err := rig.GetReceiver()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, settings)
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestParseReceiverSettings(t *testing.T) {
+	s, err := ParseReceiverSettings("squelch=on, squelch-level=30,afc=off,rsid=1,txid=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.String(); got != "squelch=on squelch-level=30 afc=off rsid=on txid=off" {
+		t.Errorf("settings = %q", got)
+	}
+	for _, spec := range []string{"afc", "afc=maybe", "squelch-level=120", "notch=on"} {
+		if _, err := ParseReceiverSettings(spec); err == nil {
+			t.Errorf("ParseReceiverSettings(%q) succeeded", spec)
+		}
+	}
+}
+
+type fakeReceiver struct {
+	set []ReceiverSettings
+}
+
+func (r *fakeReceiver) GetReceiver() (ReceiverSettings, error) { return ReceiverSettings{}, nil }
+func (r *fakeReceiver) SetReceiver(s ReceiverSettings) error {
+	r.set = append(r.set, s)
+	return nil
+}
+
+func TestModeDefaultsSink(t *testing.T) {
+	s, err := NewModeDefaultsSink([]string{"OLIVIA*:afc=off", "OLIVIA-8-250:squelch=off", "BPSK*:squelch=on", "BPSK31:afc=on,squelch-level=25"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewModeDefaultsSink([]string{"BPSK31"}); err == nil {
+		t.Error("accepted mode defaults without settings")
+	}
+	rig := &fakeReceiver{}
+	s.rigs["A"] = rig
+
+	for _, ev := range []Event{
+		{Type: EventModeChange, Radio: "A", Mode: "BPSK63"},
+		{Type: EventModeChange, Radio: "A", Mode: "BPSK31"},
+		{Type: EventModeChange, Radio: "B", Mode: "BPSK31"},
+		{Type: EventModeChange, Radio: "A", Mode: "Olivia-8-250"},
+		{Type: EventModeChange, Radio: "A", Mode: "Olivia-16-500"},
+		{Type: EventModeChange, Radio: "A", Mode: "RTTY"},
+	} {
+		if s.Wants(ev) {
+			if err := s.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []string{"squelch=on", "squelch-level=25 afc=on", "squelch=off", "afc=off"}
+	if len(rig.set) != len(want) {
+		t.Fatalf("applied %v", rig.set)
+	}
+	for i, w := range want {
+		if got := rig.set[i].String(); got != w {
+			t.Errorf("setting %d = %q, want %q", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// errConnRefused is the error of a connection to an address no one listens
+// on.
+var errConnRefused error = syscall.ECONNREFUSED
@@ -0,0 +1,7 @@
+package main
+
+import "syscall"
+
+// errConnRefused is the error of a connection to an address no one listens
+// on, Winsock's WSAECONNREFUSED.
+var errConnRefused error = syscall.Errno(10061)
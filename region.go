@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fldigi-cmd/sdk"
+)
+
+//go:embed regions.txt
+var regionData string
+
+// regionArea is a coarse lat/lon box belonging to an ITU region.
+type regionArea struct {
+	region                         string
+	latMin, latMax, lonMin, lonMax float64
+}
+
+// regionBand replaces (or with none set, removes) a band of the built-in
+// plan in a region.
+type regionBand struct {
+	region, band     string
+	startMHz, endMHz float64
+	none             bool
+}
+
+func parseRegions(data string) ([]regionArea, []regionBand) {
+	var areas []regionArea
+	var bands []regionBand
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		switch {
+		case parts[0] == "area" && len(parts) == 6:
+			var v [4]float64
+			ok := true
+			for i := range v {
+				f, err := strconv.ParseFloat(parts[i+2], 64)
+				if err != nil {
+					ok = false
+				}
+				v[i] = f
+			}
+			if ok {
+				areas = append(areas, regionArea{parts[1], v[0], v[1], v[2], v[3]})
+			}
+		case len(parts) == 3 && parts[2] == "none":
+			bands = append(bands, regionBand{region: parts[0], band: parts[1], none: true})
+		case len(parts) == 4:
+			start, err1 := strconv.ParseFloat(parts[2], 64)
+			end, err2 := strconv.ParseFloat(parts[3], 64)
+			if err1 == nil && err2 == nil {
+				bands = append(bands, regionBand{region: parts[0], band: parts[1], startMHz: start, endMHz: end})
+			}
+		}
+	}
+	return areas, bands
+}
+
+// detectRegion returns the ITU region ("1", "2" or "3") of a position.
+func detectRegion(lat, lon float64) string {
+	areas, _ := parseRegions(regionData)
+	for _, a := range areas {
+		if lat >= a.latMin && lat <= a.latMax && lon >= a.lonMin && lon < a.lonMax {
+			return a.region
+		}
+	}
+	return ""
+}
+
+// regionBandPlan returns the built-in band plan adjusted to a region's
+// allocations. Segments left outside every band are dropped.
+func regionBandPlan(region string) (*sdk.BandPlan, error) {
+	if region != "1" && region != "2" && region != "3" {
+		return nil, fmt.Errorf("unknown ITU region %q (want 1, 2 or 3)", region)
+	}
+
+	base := sdk.ParseBandPlan(bandPlanData)
+	_, overrides := parseRegions(regionData)
+
+	p := &sdk.BandPlan{}
+	for _, b := range base.Bands {
+		removed := false
+		for _, o := range overrides {
+			if o.region != region || o.band != b.Name {
+				continue
+			}
+			if o.none {
+				removed = true
+			} else {
+				b.StartMHz, b.EndMHz = o.startMHz, o.endMHz
+			}
+		}
+		if !removed {
+			p.Bands = append(p.Bands, b)
+		}
+	}
+
+	for _, seg := range base.Segments {
+		seg.Band = p.Band(seg.StartMHz * 1000000)
+		if seg.Band != "unknown" && p.Band(seg.EndMHz*1000000) == seg.Band {
+			p.Segments = append(p.Segments, seg)
+		}
+	}
+	return p, nil
+}
+
+// RegionSelector follows the station's ITU region from GPS or its
+// locator. A change is held as pending until the operator accepts it, unless
+// autoAccept is set; accepting it switches the band plan, and with it the
+// band allocations privilege checks are made against.
+type RegionSelector struct {
+	position   func() (lat, lon float64, ok bool)
+	autoAccept bool
+	dispatcher *Dispatcher
+
+	mu      sync.Mutex
+	active  string
+	pending string
+}
+
+// RegionStatus reports the active and pending regions for the control API.
+type RegionStatus struct {
+	Active  string `json:"active"`
+	Pending string `json:"pending,omitempty"`
+}
+
+func NewRegionSelector(active string, dispatcher *Dispatcher) *RegionSelector {
+	return &RegionSelector{active: active, dispatcher: dispatcher}
+}
+
+// Check detects the current region and switches to, or proposes, it.
+func (s *RegionSelector) Check() {
+	lat, lon, ok := s.position()
+	if !ok {
+		return
+	}
+	region := detectRegion(lat, lon)
+
+	s.mu.Lock()
+	if region == "" || region == s.active || region == s.pending {
+		if region == s.active {
+			s.pending = ""
+		}
+		s.mu.Unlock()
+		return
+	}
+	if !s.autoAccept {
+		s.pending = region
+		s.mu.Unlock()
+		fmt.Printf("Now in ITU region %s; run \"fldigi-cmd region accept\" to switch band plans\n", region)
+		s.dispatcher.Emit(Event{Type: EventRegionDetected, Region: region, PrevRegion: s.Status().Active, Lat: lat, Lon: lon})
+		return
+	}
+	s.mu.Unlock()
+
+	if err := s.apply(region); err != nil {
+		fmt.Printf("Error switching to ITU region %s: %v\n", region, err)
+	}
+}
+
+// Accept switches to the pending region.
+func (s *RegionSelector) Accept() (string, error) {
+	s.mu.Lock()
+	region := s.pending
+	s.mu.Unlock()
+
+	if region == "" {
+		return "", fmt.Errorf("no region change is pending")
+	}
+	return region, s.apply(region)
+}
+
+func (s *RegionSelector) apply(region string) error {
+	p, err := regionBandPlan(region)
+	if err != nil {
+		return err
+	}
+	setBandPlan(p)
+
+	s.mu.Lock()
+	prev := s.active
+	s.active, s.pending = region, ""
+	s.mu.Unlock()
+
+	fmt.Printf("Switched to the ITU region %s band plan\n", region)
+	s.dispatcher.Emit(Event{Type: EventRegionChanged, Region: region, PrevRegion: prev})
+	return nil
+}
+
+// Run checks the region every interval.
+func (s *RegionSelector) Run(interval time.Duration) {
+	for {
+		s.Check()
+		time.Sleep(interval)
+	}
+}
+
+func (s *RegionSelector) Status() RegionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return RegionStatus{Active: s.active, Pending: s.pending}
+}
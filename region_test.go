@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestDetectRegion(t *testing.T) {
+	tests := []struct {
+		place    string
+		lat, lon float64
+		want     string
+	}{
+		{"London", 51.5, -0.12, "1"},
+		{"Moscow", 55.75, 37.6, "1"},
+		{"Novosibirsk", 55.0, 82.9, "1"},
+		{"New York", 40.7, -74.0, "2"},
+		{"Honolulu", 21.3, -157.8, "2"},
+		{"Tokyo", 35.7, 139.7, "3"},
+		{"Sydney", -33.9, 151.2, "3"},
+	}
+	for _, tt := range tests {
+		if got := detectRegion(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("detectRegion(%s) = %q; want %q", tt.place, got, tt.want)
+		}
+	}
+}
+
+func TestRegionBandPlan(t *testing.T) {
+	p, err := regionBandPlan("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Band(7250000); got != "unknown" {
+		t.Errorf("7.25 MHz in region 1 = %s; want unknown", got)
+	}
+	if got := p.Band(223500000); got != "unknown" {
+		t.Errorf("1.25m allocated in region 1: %s", got)
+	}
+	if seg, ok := p.Segment(7200000); ok {
+		t.Errorf("segment %s kept outside the region 1 40m band", seg.Name)
+	}
+	if seg, _ := p.Segment(7074000); seg.Name != "40m-FT8" {
+		t.Errorf("7.074 MHz segment = %q", seg.Name)
+	}
+
+	if _, err := regionBandPlan("4"); err == nil {
+		t.Error("regionBandPlan(4) succeeded")
+	}
+}
+
+func TestRegionSelectorConfirmation(t *testing.T) {
+	defer loadBandPlan()
+
+	sink := &captureSink{}
+	s := NewRegionSelector("2", NewDispatcher(NewMetrics(), sink))
+	s.position = func() (float64, float64, bool) { return 51.5, -0.12, true }
+	privileges := &Privileges{Name: "ITU region allocation", Allocated: true}
+
+	s.Check()
+	s.Check()
+	if status := s.Status(); status.Active != "2" || status.Pending != "1" {
+		t.Fatalf("status = %+v", status)
+	}
+	if !privileges.Allows(7250000) {
+		t.Error("band plan switched before the change was accepted")
+	}
+
+	if region, err := s.Accept(); err != nil || region != "1" {
+		t.Fatalf("Accept() = %q, %v", region, err)
+	}
+	if privileges.Allows(7250000) {
+		t.Error("7.25 MHz allowed after switching to region 1")
+	}
+	if _, err := s.Accept(); err == nil {
+		t.Error("Accept() with nothing pending succeeded")
+	}
+
+	want := []string{EventRegionDetected, EventRegionChanged}
+	if got := sink.types(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("events = %v; want %v", got, want)
+	}
+	if ev := sink.events[1]; ev.Region != "1" || ev.PrevRegion != "2" {
+		t.Errorf("region-changed event = %+v", ev)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxREPLHistory is how many lines the REPL's history keeps.
+const maxREPLHistory = 1000
+
+// replCommands are the REPL's commands, for help and completion.
+var replCommands = []struct{ name, usage, help string }{
+	{"freq", "freq [FREQ]", "show the frequency, or tune to FREQ in Hz or with a k or M suffix"},
+	{"mode", "mode [NAME]", "show the modem, or switch to NAME, such as bpsk31"},
+	{"send", "send TEXT", "transmit TEXT and return to receive"},
+	{"call", "call METHOD [ARG...]", "call any XML-RPC method, such as main.tune; quote string arguments with spaces"},
+	{"methods", "methods [PREFIX]", "list fldigi's XML-RPC methods"},
+	{"help", "help", "show this help"},
+	{"quit", "quit", "leave, as does Ctrl-D"},
+}
+
+// repl runs the commands typed at the REPL against fldigi.
+type repl struct {
+	fldigi  *FldigiClient
+	methods []string
+	modes   []string
+}
+
+// runREPL implements `fldigi-cmd repl`: an interactive prompt for trying
+// commands and exploring fldigi's XML-RPC API.
+func runREPL(args []string) int {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	var fldigiAddr, historyPath string
+	fs.StringVar(&fldigiAddr, "fldigi", "127.0.0.1:7362", "fldigi XML-RPC address")
+	fs.StringVar(&historyPath, "history-file", defaultREPLHistory(), "file to keep the command history in; empty keeps none")
+	fs.Parse(args)
+
+	host, port, err := splitHostPort(fldigiAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --fldigi %q: %v\n", fldigiAddr, err)
+		return 2
+	}
+	if port == 0 {
+		port = defaultPorts["fldigi"]
+	}
+	r := &repl{fldigi: NewFldigiClient(host, port)}
+	in := bufio.NewReader(os.Stdin)
+	interactive := isTerminal(os.Stdin)
+	editor := &lineEditor{in: in, out: os.Stdout, complete: r.complete}
+	if interactive {
+		editor.history = loadREPLHistory(historyPath)
+		fmt.Printf("fldigi at %s; type help for commands\n", fldigiAddr)
+	}
+
+	for {
+		var line string
+		if interactive {
+			restore, err := makeRaw(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			line, err = editor.readLine("fldigi> ")
+			restore()
+			if err != nil {
+				return 0
+			}
+			if strings.TrimSpace(line) != "" {
+				appendREPLHistory(historyPath, line)
+			}
+		} else {
+			text, err := in.ReadString('\n')
+			if err != nil && text == "" {
+				return 0
+			}
+			line = strings.TrimRight(text, "\r\n")
+		}
+
+		if err := r.exec(line, os.Stdout); err == io.EOF {
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// exec runs one command line, returning io.EOF for quit.
+func (r *repl) exec(line string, out io.Writer) error {
+	name, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimSpace(rest)
+	switch name {
+	case "":
+		return nil
+	case "freq":
+		if rest == "" {
+			freq, err := r.fldigi.GetFrequency()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%.6f MHz %s\n", freq/1000000, frequencyToBand(freq))
+			return nil
+		}
+		freq, err := parseFrequency(rest)
+		if err != nil {
+			return err
+		}
+		return r.fldigi.SetFrequency(freq)
+	case "mode":
+		if rest == "" {
+			mode, err := r.fldigi.GetMode()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, mode)
+			return nil
+		}
+		return r.fldigi.SetMode(r.modeName(rest))
+	case "send":
+		if rest == "" {
+			return fmt.Errorf("usage: send TEXT")
+		}
+		return r.fldigi.SendText(rest)
+	case "call":
+		args := splitREPLArgs(rest)
+		if len(args) == 0 {
+			return fmt.Errorf("usage: call METHOD [ARG...]")
+		}
+		params := make([]Value, len(args)-1)
+		for i, arg := range args[1:] {
+			params[i] = arg
+		}
+		value, err := r.fldigi.Call(args[0].String, params...)
+		if err != nil {
+			return err
+		}
+		if text := formatValue(value, ""); text != "" {
+			fmt.Fprintln(out, text)
+		}
+		return nil
+	case "methods":
+		methods, err := r.methodNames()
+		if err != nil {
+			return err
+		}
+		for _, m := range methods {
+			if strings.HasPrefix(m, rest) {
+				fmt.Fprintln(out, m)
+			}
+		}
+		return nil
+	case "help":
+		for _, c := range replCommands {
+			fmt.Fprintf(out, "  %-22s %s\n", c.usage, c.help)
+		}
+		return nil
+	case "quit", "exit":
+		return io.EOF
+	}
+	return fmt.Errorf("unknown command %q; type help for commands", name)
+}
+
+// modeName is fldigi's name for a modem typed in any case, such as BPSK31
+// for bpsk31.
+func (r *repl) modeName(mode string) string {
+	modes, _ := r.modeNames()
+	for _, m := range modes {
+		if strings.EqualFold(m, mode) {
+			return m
+		}
+	}
+	return mode
+}
+
+// methodNames returns fldigi's XML-RPC methods, asked for once.
+func (r *repl) methodNames() ([]string, error) {
+	if r.methods == nil {
+		value, err := r.fldigi.Call("system.listMethods")
+		if err != nil {
+			return nil, err
+		}
+		r.methods = arrayStrings(value)
+	}
+	return r.methods, nil
+}
+
+// modeNames returns the names of fldigi's modems, asked for once.
+func (r *repl) modeNames() ([]string, error) {
+	if r.modes == nil {
+		value, err := r.fldigi.Call("modem.get_names")
+		if err != nil {
+			return nil, err
+		}
+		r.modes = arrayStrings(value)
+	}
+	return r.modes, nil
+}
+
+// complete returns where the word before the end of line starts and what
+// it may be completed to: a command, a method for call and methods, or a
+// modem for mode.
+func (r *repl) complete(line string) (int, []string) {
+	start := strings.LastIndex(line, " ") + 1
+	word, before := line[start:], strings.Fields(line[:start])
+	var choices []string
+	fold := false
+	switch {
+	case len(before) == 0:
+		for _, c := range replCommands {
+			choices = append(choices, c.name)
+		}
+	case len(before) == 1 && (before[0] == "call" || before[0] == "methods"):
+		choices, _ = r.methodNames()
+	case len(before) == 1 && before[0] == "mode":
+		choices, _ = r.modeNames()
+		fold = true
+	}
+	var matches []string
+	for _, c := range choices {
+		if strings.HasPrefix(c, word) || (fold && strings.HasPrefix(strings.ToLower(c), strings.ToLower(word))) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return start, matches
+}
+
+// splitREPLArgs splits call arguments at spaces, typing each as XML-RPC
+// wants it: integers, doubles and true or false as such, and anything
+// else, or anything in double quotes, as a string.
+func splitREPLArgs(s string) []Value {
+	var args []Value
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			text, rest, _ := strings.Cut(s[1:], `"`)
+			args = append(args, Value{String: text})
+			s = rest
+			continue
+		}
+		arg, rest, _ := strings.Cut(s, " ")
+		args = append(args, xmlrpcArg(arg))
+		s = rest
+	}
+	return args
+}
+
+func xmlrpcArg(arg string) Value {
+	if _, err := strconv.Atoi(arg); err == nil {
+		return Value{Int: arg}
+	}
+	if _, err := strconv.ParseFloat(arg, 64); err == nil {
+		return Value{Double: arg}
+	}
+	switch arg {
+	case "true":
+		return Value{Boolean: "1"}
+	case "false":
+		return Value{Boolean: "0"}
+	}
+	return Value{String: arg}
+}
+
+// formatValue renders an XML-RPC value: a scalar as it is, an array one
+// element per line and a struct one member per line.
+func formatValue(v Value, indent string) string {
+	var lines []string
+	switch {
+	case v.Array != nil:
+		for _, item := range v.Array.Values {
+			lines = append(lines, indent+strings.TrimLeft(formatValue(item, indent+"  "), " "))
+		}
+	case v.Struct != nil:
+		for _, m := range v.Struct.Members {
+			lines = append(lines, indent+m.Name+": "+strings.TrimLeft(formatValue(m.Value, indent+"  "), " "))
+		}
+	default:
+		return indent + v.Text()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// arrayStrings returns the text of an array's elements.
+func arrayStrings(v Value) []string {
+	var strs []string
+	if v.Array == nil {
+		return strs
+	}
+	for _, item := range v.Array.Values {
+		strs = append(strs, item.Text())
+	}
+	return strs
+}
+
+// defaultREPLHistory is ~/.fldigi-cmd_history.
+func defaultREPLHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".fldigi-cmd_history")
+}
+
+// loadREPLHistory returns the last lines of the history file.
+func loadREPLHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > maxREPLHistory {
+		lines = lines[len(lines)-maxREPLHistory:]
+	}
+	return lines
+}
+
+func appendREPLHistory(path, line string) {
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
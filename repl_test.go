@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeFldigiRPC answers XML-RPC calls with canned values by method,
+// recording each request body.
+func fakeFldigiRPC(t *testing.T, values map[string]string) (*FldigiClient, *[]string) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, string(body))
+		value := "<string></string>"
+		for method, v := range values {
+			if strings.Contains(string(body), "<methodName>"+method+"</methodName>") {
+				value = v
+			}
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?><methodResponse><params><param><value>%s</value></param></params></methodResponse>`, value)
+	}))
+	t.Cleanup(server.Close)
+	client := NewFldigiClient("127.0.0.1", 0)
+	client.url = server.URL
+	return client, &calls
+}
+
+func TestREPLExec(t *testing.T) {
+	client, calls := fakeFldigiRPC(t, map[string]string{
+		"rig.get_vfo":        "<double>14074000</double>",
+		"modem.get_names":    "<array><data><value><string>BPSK31</string></value><value><string>CW</string></value></data></array>",
+		"system.listMethods": "<array><data><value>main.tune</value><value>main.tx</value><value>modem.get_names</value></data></array>",
+		"fldigi.version":     "<string>4.2.05</string>",
+		"main.get_status":    "<struct><member><name>trx</name><value><string>RX</string></value></member></struct>",
+	})
+	r := &repl{fldigi: client}
+	run := func(line string) string {
+		var out bytes.Buffer
+		if err := r.exec(line, &out); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		return out.String()
+	}
+
+	if got := run("freq"); got != "14.074000 MHz 20m\n" {
+		t.Errorf("freq = %q", got)
+	}
+	run("freq 7.074M")
+	if last := (*calls)[len(*calls)-1]; !strings.Contains(last, "main.set_frequency") || !strings.Contains(last, "7074000") {
+		t.Errorf("freq request = %q", last)
+	}
+	run("mode bpsk31")
+	if last := (*calls)[len(*calls)-1]; !strings.Contains(last, "modem.set_by_name") || !strings.Contains(last, "<string>BPSK31</string>") {
+		t.Errorf("mode request = %q", last)
+	}
+	if got := run("call fldigi.version"); got != "4.2.05\n" {
+		t.Errorf("call = %q", got)
+	}
+	if got := run("call main.get_status"); got != "trx: RX\n" {
+		t.Errorf("call of a struct = %q", got)
+	}
+	run(`call main.set_frequency 7074000.5 "a b" true 3`)
+	if last := (*calls)[len(*calls)-1]; !strings.Contains(last, "<double>7074000.5</double>") || !strings.Contains(last, "<string>a b</string>") ||
+		!strings.Contains(last, "<boolean>1</boolean>") || !strings.Contains(last, "<i4>3</i4>") {
+		t.Errorf("call request = %q", last)
+	}
+	if got := run("methods main."); got != "main.tune\nmain.tx\n" {
+		t.Errorf("methods = %q", got)
+	}
+
+	if err := r.exec("quit", io.Discard); err != io.EOF {
+		t.Errorf("quit = %v", err)
+	}
+	if err := r.exec("frob", io.Discard); err == nil {
+		t.Error("no error for an unknown command")
+	}
+
+	for _, tc := range []struct {
+		line  string
+		start int
+		want  []string
+	}{
+		{"m", 0, []string{"methods", "mode"}},
+		{"call main.t", 5, []string{"main.tune", "main.tx"}},
+		{"mode bp", 5, []string{"BPSK31"}},
+		{"send cq", 5, nil},
+	} {
+		start, got := r.complete(tc.line)
+		if start != tc.start || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("complete(%q) = %d, %q; want %d, %q", tc.line, start, got, tc.start, tc.want)
+		}
+	}
+}
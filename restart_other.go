@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// restartSelf exits so the service manager can start the daemon again on
+// the bundle now current; the process can't be replaced in place here.
+func restartSelf() {
+	log.Printf("Exiting to restart on the new bundle")
+	os.Exit(3)
+}
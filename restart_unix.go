@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"log"
+	"os"
+	"syscall"
+)
+
+// restartSelf replaces the process with a fresh copy of the daemon, with
+// the same arguments, so it starts on the bundle now current.
+func restartSelf() {
+	exe, err := os.Executable()
+	if err == nil {
+		err = os.Chdir(startDir)
+	}
+	if err == nil {
+		err = syscall.Exec(exe, os.Args, os.Environ())
+	}
+	log.Fatalf("Failed to restart: %v", err)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RigctldClient talks to a Hamlib rigctld daemon over its TCP protocol so
+// the monitor works without fldigi running.
+type RigctldClient struct {
+	addr string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewRigctldClient(host string, port int) *RigctldClient {
+	return &RigctldClient{addr: net.JoinHostPort(host, strconv.Itoa(port))}
+}
+
+func (rc *RigctldClient) Name() string { return "rigctld" }
+
+// command sends a rigctld command and returns its response lines. rigctld
+// answers get commands with one line per value and set commands with a
+// single "RPRT n" line.
+func (rc *RigctldClient) command(cmd string, lines int) ([]string, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.conn == nil {
+		conn, err := net.DialTimeout("tcp", rc.addr, dialTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to rigctld %s: %v", rc.addr, err)
+		}
+		rc.conn = conn
+		rc.reader = bufio.NewReader(conn)
+	}
+
+	start := time.Now()
+	resp, err := rc.roundTrip(cmd, lines)
+	rpcTrace.record(rc.addr, cmd, strings.Join(resp, "\n"), time.Since(start), err)
+	if err != nil {
+		// Drop the connection so the next command reconnects.
+		rc.conn.Close()
+		rc.conn = nil
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rc *RigctldClient) roundTrip(cmd string, lines int) ([]string, error) {
+	rc.conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if _, err := fmt.Fprintf(rc.conn, "%s\n", cmd); err != nil {
+		return nil, fmt.Errorf("failed to send rigctld command: %v", err)
+	}
+
+	var resp []string
+	for len(resp) < lines {
+		line, err := rc.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rigctld response: %v", err)
+		}
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "RPRT ") {
+			code := strings.TrimPrefix(line, "RPRT ")
+			if code != "0" {
+				return nil, fmt.Errorf("rigctld command %q failed: RPRT %s", cmd, code)
+			}
+			break
+		}
+		resp = append(resp, line)
+	}
+	return resp, nil
+}
+
+func (rc *RigctldClient) GetFrequency() (float64, error) {
+	resp, err := rc.command("f", 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) == 0 {
+		return 0, fmt.Errorf("empty frequency response")
+	}
+
+	freq, err := strconv.ParseFloat(resp[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse frequency '%s': %v", resp[0], err)
+	}
+	return freq, nil
+}
+
+// GetMode returns the rig mode, e.g. "USB" or "PKTUSB".
+func (rc *RigctldClient) GetMode() (string, error) {
+	resp, err := rc.command("m", 2)
+	if err != nil {
+		return "", err
+	}
+	if len(resp) == 0 {
+		return "", fmt.Errorf("empty mode response")
+	}
+	return resp[0], nil
+}
+
+func (rc *RigctldClient) SetFrequency(freq float64) error {
+	_, err := rc.command("F "+strconv.FormatFloat(freq, 'f', 0, 64), 1)
+	return err
+}
+
+// SetMode sets the mode, such as "USB", keeping the rig's default passband.
+func (rc *RigctldClient) SetMode(mode string) error {
+	_, err := rc.command("M "+mode+" 0", 1)
+	return err
+}
+
+// GetSplit reads the split state and, when split, the TX frequency.
+func (rc *RigctldClient) GetSplit() (bool, float64, error) {
+	resp, err := rc.command("s", 2)
+	if err != nil {
+		return false, 0, err
+	}
+	if len(resp) == 0 {
+		return false, 0, fmt.Errorf("empty split response")
+	}
+	if strings.TrimSpace(resp[0]) != "1" {
+		return false, 0, nil
+	}
+
+	resp, err = rc.command("i", 1)
+	if err != nil {
+		return false, 0, err
+	}
+	if len(resp) == 0 {
+		return false, 0, fmt.Errorf("empty TX frequency response")
+	}
+	freq, err := strconv.ParseFloat(resp[0], 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to parse TX frequency '%s': %v", resp[0], err)
+	}
+	return true, freq, nil
+}
+
+// SetPower sets the RFPOWER level, which rigctld takes as a fraction of the
+// rig's full output.
+func (rc *RigctldClient) SetPower(percent float64) error {
+	return rc.SetLevel("RFPOWER", percent/100)
+}
+
+// SetLevel sets a rig level such as "ATT" or "PREAMP".
+func (rc *RigctldClient) SetLevel(name string, value float64) error {
+	_, err := rc.command("L "+name+" "+strconv.FormatFloat(value, 'f', -1, 64), 1)
+	return err
+}
+
+// GetPower reads the RFPOWER level back as a percentage.
+func (rc *RigctldClient) GetPower() (float64, error) {
+	level, err := rc.GetLevel("RFPOWER")
+	return level * 100, err
+}
+
+// GetLevel reads a rig level such as "ATT" or "PREAMP".
+func (rc *RigctldClient) GetLevel(name string) (float64, error) {
+	resp, err := rc.command("l "+name, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) == 0 {
+		return 0, fmt.Errorf("empty %s response", name)
+	}
+	level, err := strconv.ParseFloat(resp[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s '%s': %v", name, resp[0], err)
+	}
+	return level, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeRigctld answers rigctld commands from a fixed rig state.
+func fakeRigctld(t *testing.T) (addr string, freq *string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	state := "14074000"
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					cmd := strings.Fields(scanner.Text())
+					switch {
+					case cmd[0] == "f":
+						fmt.Fprintf(conn, "%s\n", state)
+					case cmd[0] == "m":
+						fmt.Fprint(conn, "PKTUSB\n3000\n")
+					case cmd[0] == "s":
+						fmt.Fprint(conn, "1\nVFOB\n")
+					case cmd[0] == "i":
+						fmt.Fprint(conn, "14195000\n")
+					case cmd[0] == "l" && len(cmd) == 2:
+						fmt.Fprint(conn, "0.25\n")
+					case cmd[0] == "L" && len(cmd) == 3:
+						fmt.Fprint(conn, "RPRT 0\n")
+					case cmd[0] == "F" && len(cmd) == 2:
+						state = cmd[1]
+						fmt.Fprint(conn, "RPRT 0\n")
+					default:
+						fmt.Fprint(conn, "RPRT -1\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), &state
+}
+
+func TestRigctldClient(t *testing.T) {
+	addr, _ := fakeRigctld(t)
+	host, port, _ := net.SplitHostPort(addr)
+	var portNum int
+	fmt.Sscan(port, &portNum)
+
+	backend, err := NewBackend("rigctld", host, portNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	freq, err := backend.GetFrequency()
+	if err != nil || freq != 14074000 {
+		t.Fatalf("GetFrequency() = %.0f, %v", freq, err)
+	}
+
+	mode, err := backend.GetMode()
+	if err != nil || mode != "PKTUSB" {
+		t.Fatalf("GetMode() = %q, %v", mode, err)
+	}
+
+	if err := backend.SetFrequency(7074000); err != nil {
+		t.Fatal(err)
+	}
+	freq, err = backend.GetFrequency()
+	if err != nil || freq != 7074000 {
+		t.Errorf("GetFrequency() after set = %.0f, %v", freq, err)
+	}
+
+	rc := backend.(*RigctldClient)
+	if split, tx, err := rc.GetSplit(); err != nil || !split || tx != 14195000 {
+		t.Errorf("GetSplit() = %v, %.0f, %v", split, tx, err)
+	}
+	if err := rc.SetPower(25); err != nil {
+		t.Errorf("SetPower(25) = %v", err)
+	}
+	if power, err := rc.GetPower(); err != nil || power != 25 {
+		t.Errorf("GetPower() = %v, %v", power, err)
+	}
+	if _, err := rc.command("bogus", 1); err == nil {
+		t.Error("failing rigctld command returned no error")
+	}
+}
+
+func TestNewBackendUnknown(t *testing.T) {
+	if _, err := NewBackend("hamlib", "localhost", 0); err == nil {
+		t.Error("NewBackend(hamlib) succeeded; want error")
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rollout applies config bundles pushed with `fldigi-cmd push`. The daemon
+// runs from the bundle in dir/current. A pushed bundle is checked, staged in
+// dir/staged and swapped in, keeping the old one as dir/previous, and the
+// daemon restarts on it. Unless the daemon is healthy on the new config
+// within the grace period, the previous bundle is restored.
+type Rollout struct {
+	dir   string
+	grace time.Duration
+
+	// healthy reports whether the daemon is working on the new config;
+	// restart starts the daemon again on the bundle now current.
+	healthy func() bool
+	restart func()
+
+	mu    sync.Mutex
+	state RolloutState
+}
+
+// RolloutState is saved in dir/rollout.json and served at /rollout.
+type RolloutState struct {
+	ID    string `json:"id,omitempty"`
+	State string `json:"state"` // "pending", "committed" or "rolled-back"
+	Error string `json:"error,omitempty"`
+	// Starts counts the daemon starts on a pending bundle.
+	Starts int `json:"starts,omitempty"`
+}
+
+// maxRolloutStarts is how often the daemon may start on a pending bundle
+// without passing the health check, e.g. because it exited, before the
+// previous bundle is restored at startup.
+const maxRolloutStarts = 2
+
+// startDir is the working directory the daemon was started in, restored
+// before a restart so relative paths in its arguments still resolve.
+var startDir, _ = os.Getwd()
+
+// OpenRollout opens the bundle directory at startup, counting the start
+// against a pending rollout and rolling it back if it has failed too often.
+func OpenRollout(dir string, grace time.Duration) (*Rollout, error) {
+	// The daemon moves into the bundle, so dir mustn't be relative.
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	r := &Rollout{dir: dir, grace: grace, restart: restartSelf}
+	data, err := os.ReadFile(r.path("rollout.json"))
+	if err == nil {
+		if err := json.Unmarshal(data, &r.state); err != nil {
+			return nil, fmt.Errorf("invalid rollout.json: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if r.state.State == "pending" {
+		r.state.Starts++
+		if r.state.Starts > maxRolloutStarts {
+			log.Printf("Bundle %s failed to start %d times", r.state.ID, maxRolloutStarts)
+			return r, r.rollback("the daemon didn't start on the new config")
+		}
+		if err := r.save(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *Rollout) path(name string) string { return filepath.Join(r.dir, name) }
+
+// Current returns the directory of the bundle the daemon runs from.
+func (r *Rollout) Current() string { return r.path("current") }
+
+// Manifest returns the manifest of the current bundle, or nil if nothing has
+// been pushed yet.
+func (r *Rollout) Manifest() (*bundleManifest, error) {
+	data, err := os.ReadFile(filepath.Join(r.Current(), "bundle.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest bundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle.json: %v", err)
+	}
+	return &manifest, nil
+}
+
+// ApplyArgs sets the options of the current bundle that aren't given on
+// the command line and moves into its directory, which the bundle's paths
+// are relative to.
+func (r *Rollout) ApplyArgs(fs *flag.FlagSet, given map[flag.Value]bool) error {
+	manifest, err := r.Manifest()
+	if manifest == nil || err != nil {
+		return err
+	}
+	reset := map[flag.Value]bool{}
+	for i := 0; i+1 < len(manifest.Args); i += 2 {
+		name := strings.TrimLeft(manifest.Args[i], "-")
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("bundle uses unknown option %s", manifest.Args[i])
+		}
+		if given[f.Value] {
+			continue
+		}
+		// A repeatable option from the bundle replaces the list set by
+		// the environment or config file instead of adding to it.
+		if repeatable(f.Value) && !reset[f.Value] {
+			switch list := f.Value.(type) {
+			case *radioFlag:
+				*list = nil
+			case *specFlag:
+				*list = nil
+			}
+			reset[f.Value] = true
+		}
+		if err := f.Value.Set(manifest.Args[i+1]); err != nil {
+			return fmt.Errorf("invalid bundle option --%s %q: %v", name, manifest.Args[i+1], err)
+		}
+	}
+	return os.Chdir(r.Current())
+}
+
+// State returns the state of the latest rollout.
+func (r *Rollout) State() RolloutState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+func (r *Rollout) save() error {
+	data, err := json.MarshalIndent(r.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path("rollout.json"), append(data, '\n'), 0644)
+}
+
+// Apply checks a bundle, stages it and swaps it in. The caller restarts
+// the daemon afterwards.
+func (r *Rollout) Apply(archive []byte, fs *flag.FlagSet) (RolloutState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.State == "pending" {
+		return r.state, fmt.Errorf("bundle %s is still being rolled out", r.state.ID)
+	}
+
+	manifest, headers, files, err := readBundle(bytes.NewReader(archive))
+	if err != nil {
+		return r.state, err
+	}
+	for i := 0; i < len(manifest.Args); i += 2 {
+		if fs.Lookup(strings.TrimLeft(manifest.Args[i], "-")) == nil {
+			return r.state, fmt.Errorf("bundle uses unknown option %s", manifest.Args[i])
+		}
+	}
+
+	staged := r.path("staged")
+	os.RemoveAll(staged)
+	if err := unpackBundle(manifest, headers, files, staged); err != nil {
+		return r.state, err
+	}
+	if err := checkBundleDir(staged, manifest); err != nil {
+		os.RemoveAll(staged)
+		return r.state, err
+	}
+
+	os.RemoveAll(r.path("previous"))
+	if err := os.Rename(r.Current(), r.path("previous")); err != nil && !os.IsNotExist(err) {
+		return r.state, err
+	}
+	if err := os.Rename(staged, r.Current()); err != nil {
+		return r.state, err
+	}
+	r.state = RolloutState{ID: time.Now().UTC().Format("20060102T150405Z"), State: "pending"}
+	return r.state, r.save()
+}
+
+// Watch waits out the grace period of a pending rollout and then commits it
+// or, if the daemon isn't healthy, restores the previous bundle and
+// restarts on it.
+func (r *Rollout) Watch() {
+	if r.State().State != "pending" {
+		return
+	}
+	time.Sleep(r.grace)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.healthy() {
+		log.Printf("Bundle %s is healthy; rollout committed", r.state.ID)
+		r.state.State, r.state.Starts = "committed", 0
+		if err := r.save(); err != nil {
+			log.Printf("Error saving rollout state: %v", err)
+		}
+		os.RemoveAll(r.path("previous"))
+		return
+	}
+
+	log.Printf("Bundle %s is unhealthy after %v; rolling back", r.state.ID, r.grace)
+	if err := r.rollback(fmt.Sprintf("unhealthy %v after the restart", r.grace)); err != nil {
+		log.Printf("Error rolling back: %v", err)
+		return
+	}
+	r.restart()
+}
+
+// rollback restores the previous bundle or, after the first push, the
+// options the daemon was started with.
+func (r *Rollout) rollback(reason string) error {
+	os.RemoveAll(r.Current())
+	if _, err := os.Stat(r.path("previous")); err == nil {
+		if err := os.Rename(r.path("previous"), r.Current()); err != nil {
+			return err
+		}
+	}
+	r.state.State, r.state.Error, r.state.Starts = "rolled-back", reason, 0
+	return r.save()
+}
+
+// handleBundle accepts a pushed bundle, POST /bundle, and restarts the
+// daemon on it; GET /rollout reports how the rollout went.
+func (a *API) handleBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.rollout == nil || a.token == "" {
+		http.Error(w, "pushing bundles needs --bundle-dir and --api-token", http.StatusNotFound)
+		return
+	}
+	archive, err := io.ReadAll(io.LimitReader(r.Body, 64<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	state, err := a.rollout.Apply(archive, flag.CommandLine)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Applied bundle %s; restarting", state.ID)
+	writeJSON(w, state)
+
+	// Give the response time to reach the client first.
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		a.rollout.restart()
+	}()
+}
+
+func (a *API) handleRollout(w http.ResponseWriter, r *http.Request) {
+	if a.rollout == nil {
+		http.Error(w, "the daemon isn't running from --bundle-dir", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, a.rollout.State())
+}
+
+// runPush implements `fldigi-cmd push --target host bundle`: send a bundle
+// archive, or a directory unpacked with `bundle import`, to a remote daemon
+// and wait for its rollout to be committed or rolled back.
+func runPush(args []string) int {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	var target, token string
+	var wait time.Duration
+	fs.StringVar(&target, "target", "", "API address of the daemon to push to, host[:port]")
+	fs.StringVar(&token, "api-token", os.Getenv("FLDIGI_API_TOKEN"), "API token of the target daemon")
+	fs.DurationVar(&wait, "wait", 3*time.Minute, "time to wait for the target to commit or roll back the bundle")
+	fs.Parse(args)
+
+	if target == "" || fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd push --target host[:port] [--api-token token] <bundle.tar.gz|dir>\n")
+		return 2
+	}
+	if token == "" {
+		fmt.Fprintf(os.Stderr, "Error: --api-token or FLDIGI_API_TOKEN is required to push a bundle\n")
+		return 2
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		_, port, _ := net.SplitHostPort(defaultAPIAddr)
+		target = net.JoinHostPort(target, port)
+	}
+
+	archive, err := loadPushBundle(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: bearerTransport{token: token}}
+	c := &apiClient{http: client, base: "http://" + target, addr: target}
+	var state RolloutState
+	if err := c.doBody(http.MethodPost, "/bundle", nil, bytes.NewReader(archive), &state); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Bundle %s applied on %s; waiting for its health check\n", state.ID, target)
+
+	// The target is unreachable while it restarts.
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		time.Sleep(2 * time.Second)
+		var current RolloutState
+		if err := c.do(http.MethodGet, "/rollout", nil, &current); err != nil || current.ID != state.ID {
+			continue
+		}
+		switch current.State {
+		case "committed":
+			fmt.Printf("Bundle %s committed on %s\n", state.ID, target)
+			return 0
+		case "rolled-back":
+			fmt.Fprintf(os.Stderr, "Error: bundle %s rolled back on %s: %s\n", state.ID, target, current.Error)
+			return 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error: bundle %s still pending on %s after %v\n", state.ID, target, wait)
+	return 1
+}
+
+// loadPushBundle reads a bundle archive or packs a bundle directory, and
+// checks it before it is sent.
+func loadPushBundle(path string) ([]byte, error) {
+	var archive []byte
+	var err error
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		archive, err = packBundleDir(path)
+	} else {
+		archive, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, headers, files, err := readBundle(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "fldigi-cmd-push")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := unpackBundle(manifest, headers, files, dir); err != nil {
+		return nil, err
+	}
+	if err := checkBundleDir(dir, manifest); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
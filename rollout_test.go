@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testBundle returns a bundle archive with a rules file whose 20m rule runs
+// the given command.
+func testBundle(t *testing.T, command string) []byte {
+	t.Helper()
+	b := newBundleWriter()
+	b.addText("rules.json", []byte(`{"rules": [{"when": {"bands": ["20m"]}, "actions": [{"command": "`+command+`"}]}]}`))
+	b.manifest.Args = []string{"--rules", "rules.json", "--interval", "2s"}
+	var buf bytes.Buffer
+	if err := b.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func rolloutFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("rules", "", "")
+	fs.String("notify", "", "")
+	fs.String("interval", "5s", "")
+	return fs
+}
+
+func TestRolloutCommitsHealthyBundle(t *testing.T) {
+	dir := t.TempDir()
+	r, err := OpenRollout(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Apply(testBundle(t, "logger one"), rolloutFlags()); err != nil {
+		t.Fatal(err)
+	}
+	state, err := r.Apply(testBundle(t, "logger two"), rolloutFlags())
+	if err == nil {
+		t.Fatalf("applied a bundle while %s was pending", state.ID)
+	}
+
+	// The daemon restarts, then passes its health check.
+	r, err = OpenRollout(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := r.State(); state.State != "pending" || state.Starts != 1 {
+		t.Fatalf("state after restart = %+v", state)
+	}
+	r.healthy = func() bool { return true }
+	r.Watch()
+	if state := r.State(); state.State != "committed" {
+		t.Errorf("state = %+v, want committed", state)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "previous")); err == nil {
+		t.Error("previous bundle kept after the commit")
+	}
+
+	fs := rolloutFlags()
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	if err := r.ApplyArgs(fs, map[flag.Value]bool{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("rules").Value.String(); got != "rules.json" {
+		t.Errorf("--rules = %q", got)
+	}
+	if _, err := os.Stat("rules.json"); err != nil {
+		t.Errorf("not running from the bundle directory: %v", err)
+	}
+}
+
+func TestRolloutRollsBackUnhealthyBundle(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := OpenRollout(dir, 0)
+	r.Apply(testBundle(t, "logger good"), rolloutFlags())
+	r.healthy = func() bool { return true }
+	r.Watch()
+
+	r.Apply(testBundle(t, "logger bad"), rolloutFlags())
+	restarted := false
+	r.healthy = func() bool { return false }
+	r.restart = func() { restarted = true }
+	r.Watch()
+
+	if state := r.State(); state.State != "rolled-back" || !restarted {
+		t.Errorf("state = %+v, restarted %v; want rolled back and restarted", state, restarted)
+	}
+	if data, _ := os.ReadFile(filepath.Join(r.Current(), "rules.json")); !strings.Contains(string(data), "logger good") {
+		t.Errorf("current rules after the rollback:\n%s", data)
+	}
+}
+
+func TestRolloutRollsBackBundleThatKeepsFailing(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := OpenRollout(dir, 0)
+	r.Apply(testBundle(t, "logger good"), rolloutFlags())
+	r.healthy = func() bool { return true }
+	r.Watch()
+	r.Apply(testBundle(t, "logger crash"), rolloutFlags())
+
+	// The daemon exits before the health check on every start.
+	for i := 0; i <= maxRolloutStarts; i++ {
+		if r, _ = OpenRollout(dir, 0); r == nil {
+			t.Fatal("OpenRollout failed")
+		}
+	}
+	if state := r.State(); state.State != "rolled-back" {
+		t.Errorf("state = %+v, want rolled back", state)
+	}
+	if data, _ := os.ReadFile(filepath.Join(r.Current(), "rules.json")); !strings.Contains(string(data), "logger good") {
+		t.Errorf("current rules after the rollback:\n%s", data)
+	}
+}
+
+func TestRolloutRejectsInvalidBundle(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := OpenRollout(dir, 0)
+
+	b := newBundleWriter()
+	b.addText("notify.json", []byte(`[{"name": "mail", "email": {"password": "`+redacted+`"}}]`))
+	b.manifest.Args = []string{"--notify", "notify.json"}
+	var buf bytes.Buffer
+	b.write(&buf)
+	if _, err := r.Apply(buf.Bytes(), rolloutFlags()); err == nil || !strings.Contains(err.Error(), redacted) {
+		t.Errorf("err = %v, want the redacted secret rejected", err)
+	}
+
+	b = newBundleWriter()
+	b.manifest.Args = []string{"--no-such-option", "x"}
+	buf.Reset()
+	b.write(&buf)
+	if _, err := r.Apply(buf.Bytes(), rolloutFlags()); err == nil || !strings.Contains(err.Error(), "unknown option") {
+		t.Errorf("err = %v, want the unknown option rejected", err)
+	}
+	if _, err := os.Stat(r.Current()); err == nil {
+		t.Error("invalid bundle was swapped in")
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	h := requireToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q: %d, want %d", header, rec.Code, want)
+		}
+	}
+}
+
+func TestRolloutRollsBackFirstBundle(t *testing.T) {
+	r, _ := OpenRollout(t.TempDir(), 0)
+	r.Apply(testBundle(t, "logger bad"), rolloutFlags())
+	r.healthy = func() bool { return false }
+	r.restart = func() {}
+	r.Watch()
+
+	if manifest, err := r.Manifest(); manifest != nil || err != nil {
+		t.Errorf("manifest after rolling back the only bundle = %+v, %v; want none", manifest, err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rotctldPort is Hamlib rotctld's default port.
+const rotctldPort = 4533
+
+// RotatorPreset is a stored heading, for a band or a named target such as
+// "EU".
+type RotatorPreset struct {
+	Name      string
+	Azimuth   float64
+	Elevation float64
+}
+
+// ParseRotatorPreset parses a --rotator-preset option of the form
+// NAME=AZIMUTH[/ELEVATION] in degrees, e.g. "6m=135" or "moon=210/35".
+func ParseRotatorPreset(spec string) (RotatorPreset, error) {
+	var p RotatorPreset
+	name, value, ok := strings.Cut(spec, "=")
+	az, el, hasEl := strings.Cut(value, "/")
+	var err error
+	if ok && name != "" {
+		p.Azimuth, err = strconv.ParseFloat(az, 64)
+		if err == nil && hasEl {
+			p.Elevation, err = strconv.ParseFloat(el, 64)
+		}
+	}
+	if !ok || name == "" || err != nil {
+		return p, fmt.Errorf("invalid rotator preset %q, want NAME=AZIMUTH[/ELEVATION], e.g. 6m=135", spec)
+	}
+	if p.Azimuth < 0 || p.Azimuth > 360 || p.Elevation < 0 || p.Elevation > 90 {
+		return p, fmt.Errorf("rotator preset %q: azimuth must be 0-360 and elevation 0-90 degrees", spec)
+	}
+	p.Name = name
+	return p, nil
+}
+
+// RotateAction configures a rotate rule action: a --rotator-preset target,
+// or otherwise an azimuth and elevation in degrees.
+type RotateAction struct {
+	Target    string  `json:"target,omitempty"`
+	Azimuth   float64 `json:"azimuth,omitempty"`
+	Elevation float64 `json:"elevation,omitempty"`
+}
+
+// Rotator points an antenna through Hamlib rotctld, which speaks the same
+// protocol as rigctld. As a sink it turns to the preset of the band one
+// radio changes to, for bands that have one.
+type Rotator struct {
+	rot     *RigctldClient
+	presets map[string]RotatorPreset
+	radio   string
+}
+
+// NewRotator connects to rotctld at addr ("host" or "host:port") with
+// presets parsed from specs, following the radio with label.
+func NewRotator(addr string, specs []string, radio string) (*Rotator, error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		port = rotctldPort
+	}
+	r := &Rotator{rot: NewRigctldClient(host, port), presets: map[string]RotatorPreset{}, radio: radio}
+	for _, spec := range specs {
+		p, err := ParseRotatorPreset(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := r.presets[p.Name]; dup {
+			return nil, fmt.Errorf("duplicate rotator preset %s", p.Name)
+		}
+		r.presets[p.Name] = p
+	}
+	return r, nil
+}
+
+// Point turns the rotator to an azimuth and elevation.
+func (r *Rotator) Point(azimuth, elevation float64) error {
+	if dryRun {
+		dryRunf("would turn the rotator to %.0f° azimuth, %.0f° elevation", azimuth, elevation)
+		return nil
+	}
+	_, err := r.rot.command(fmt.Sprintf("P %.1f %.1f", azimuth, elevation), 1)
+	if err != nil {
+		return fmt.Errorf("failed to turn the rotator: %v", err)
+	}
+	return nil
+}
+
+// rotate runs a rotate rule action.
+func (r *Rotator) rotate(a RotateAction) error {
+	if a.Target == "" {
+		return r.Point(a.Azimuth, a.Elevation)
+	}
+	p, ok := r.presets[a.Target]
+	if !ok {
+		return fmt.Errorf("unknown rotator preset %q", a.Target)
+	}
+	return r.Point(p.Azimuth, p.Elevation)
+}
+
+// runAction returns a rule action runner that handles rotate itself and
+// passes other actions to next.
+func (r *Rotator) runAction(next func(RuleAction, Event) error) func(RuleAction, Event) error {
+	return func(a RuleAction, ev Event) error {
+		if a.Rotate != nil {
+			return r.rotate(*a.Rotate)
+		}
+		return next(a, ev)
+	}
+}
+
+func (r *Rotator) Name() string { return "rotator" }
+
+func (r *Rotator) Wants(ev Event) bool {
+	if ev.Type != EventBandChange && ev.Type != EventInitialBand {
+		return false
+	}
+	_, ok := r.presets[ev.Band]
+	return ok && ev.Radio == r.radio
+}
+
+func (r *Rotator) Handle(ev Event) error {
+	p := r.presets[ev.Band]
+	return r.Point(p.Azimuth, p.Elevation)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// fakeRotctld records the positions rotctld is asked to turn to.
+func fakeRotctld(t *testing.T) (string, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	commands := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			commands <- scanner.Text()
+			fmt.Fprint(conn, "RPRT 0\n")
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestParseRotatorPreset(t *testing.T) {
+	if p, err := ParseRotatorPreset("moon=210/35.5"); err != nil || p != (RotatorPreset{Name: "moon", Azimuth: 210, Elevation: 35.5}) {
+		t.Errorf("ParseRotatorPreset(moon=210/35.5) = %+v, %v", p, err)
+	}
+	for _, spec := range []string{"6m", "=90", "6m=east", "6m=400", "6m=90/95", "6m=90/x"} {
+		if _, err := ParseRotatorPreset(spec); err == nil {
+			t.Errorf("ParseRotatorPreset(%q) succeeded", spec)
+		}
+	}
+	if _, err := NewRotator("127.0.0.1", []string{"6m=90", "6m=135"}, ""); err == nil {
+		t.Error("duplicate presets accepted")
+	}
+}
+
+func TestRotator(t *testing.T) {
+	addr, commands := fakeRotctld(t)
+	r, err := NewRotator(addr, []string{"6m=135", "EU=45/10"}, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Radio: "B", Band: "6m"},
+		{Type: EventBandChange, Radio: "A", Band: "20m"},
+		{Type: EventBandChange, Radio: "A", Band: "6m"},
+	} {
+		if r.Wants(ev) {
+			if err := r.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if cmd := <-commands; cmd != "P 135.0 0.0" {
+		t.Errorf("band change sent %q", cmd)
+	}
+
+	passed := false
+	run := r.runAction(func(RuleAction, Event) error {
+		passed = true
+		return nil
+	})
+	if err := run(RuleAction{Rotate: &RotateAction{Target: "EU"}}, Event{}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-commands; cmd != "P 45.0 10.0" {
+		t.Errorf("rotate to EU sent %q", cmd)
+	}
+	if err := run(RuleAction{Rotate: &RotateAction{Target: "JA"}}, Event{}); err == nil {
+		t.Error("rotated to an unknown preset")
+	}
+	if run(RuleAction{Command: "true"}, Event{}); !passed {
+		t.Error("other actions weren't passed on")
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RulesFile is the on-disk format of a rules file.
+type RulesFile struct {
+	Geofences []Geofence `json:"geofences,omitempty"`
+	Rules     []*Rule    `json:"rules"`
+}
+
+// Rule runs its actions when its conditions become true.
+type Rule struct {
+	Name    string        `json:"name"`
+	When    RuleCondition `json:"when"`
+	Actions []RuleAction  `json:"actions"`
+
+	active bool
+}
+
+// RuleCondition matches station state. Empty fields match anything.
+type RuleCondition struct {
+	Bands  []string `json:"bands,omitempty"`
+	MinMHz float64  `json:"min_mhz,omitempty"`
+	MaxMHz float64  `json:"max_mhz,omitempty"`
+	Modes  []string `json:"modes,omitempty"`
+	Time   string   `json:"time,omitempty"` // local "HH:MM-HH:MM", may wrap midnight
+	Days   []string `json:"days,omitempty"` // "mon", "tue", ...
+
+	// Locations are geofence names; "" matches outside every fence.
+	Locations []string `json:"locations,omitempty"`
+
+	startMin, endMin int
+}
+
+// RuleAction is one of a command, a webhook, an MQTT publish, a move to
+// a clear frequency, a turn of the rotator or a tune-up carrier. String
+// fields may contain {band}, {freq}, {mode} and other event placeholders.
+type RuleAction struct {
+	Command   string           `json:"command,omitempty"`
+	Args      []string         `json:"args,omitempty"`
+	Webhook   string           `json:"webhook,omitempty"`
+	MQTT      *MQTTMessage     `json:"mqtt,omitempty"`
+	FindClear *FindClearAction `json:"find_clear,omitempty"`
+	Rotate    *RotateAction    `json:"rotate,omitempty"`
+	Tune      *TuneAction      `json:"tune,omitempty"`
+}
+
+// RuleState is the station state rules are evaluated against.
+type RuleState struct {
+	Radio string
+	Band  string
+	Freq  float64
+	Mode  string
+	Time  time.Time
+	Notes []string
+
+	Location string
+}
+
+func LoadRules(path string) ([]*Rule, error) {
+	file, err := LoadRulesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// LoadRulesFile loads and validates a rules file, including its geofences.
+func LoadRulesFile(path string) (*RulesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %v", err)
+	}
+
+	var file RulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules %s: %v", path, err)
+	}
+
+	fences := map[string]bool{"": true}
+	for i, f := range file.Geofences {
+		if f.Name == "" {
+			return nil, fmt.Errorf("geofence %d has no name", i+1)
+		}
+		if f.RadiusKm <= 0 {
+			return nil, fmt.Errorf("geofence %s needs a positive radius_km", f.Name)
+		}
+		fences[strings.ToLower(f.Name)] = true
+	}
+
+	for i, r := range file.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(r.Actions) == 0 {
+			return nil, fmt.Errorf("rule %s has no actions", r.Name)
+		}
+		if err := r.When.compile(); err != nil {
+			return nil, fmt.Errorf("rule %s: %v", r.Name, err)
+		}
+		for _, loc := range r.When.Locations {
+			if !fences[strings.ToLower(loc)] {
+				return nil, fmt.Errorf("rule %s: unknown geofence %q", r.Name, loc)
+			}
+		}
+		for _, a := range r.Actions {
+			if countActions(a) != 1 {
+				return nil, fmt.Errorf("rule %s: each action needs exactly one of command, webhook, mqtt, find_clear, rotate or tune", r.Name)
+			}
+			if a.FindClear != nil {
+				if _, _, _, _, err := a.FindClear.settings(); err != nil {
+					return nil, fmt.Errorf("rule %s: %v", r.Name, err)
+				}
+			}
+		}
+	}
+
+	return &file, nil
+}
+
+func countActions(a RuleAction) int {
+	n := 0
+	if a.Command != "" {
+		n++
+	}
+	if a.Webhook != "" {
+		n++
+	}
+	if a.MQTT != nil {
+		n++
+	}
+	if a.FindClear != nil {
+		n++
+	}
+	if a.Rotate != nil {
+		n++
+	}
+	if a.Tune != nil {
+		n++
+	}
+	return n
+}
+
+func (c *RuleCondition) compile() error {
+	if c.Time == "" {
+		return nil
+	}
+
+	parts := strings.Split(c.Time, "-")
+	if len(parts) != 2 {
+		return fmt.Errorf("time %q must be HH:MM-HH:MM", c.Time)
+	}
+
+	var err error
+	if c.startMin, err = parseClock(parts[0]); err != nil {
+		return err
+	}
+	if c.endMin, err = parseClock(parts[1]); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Matches reports whether the condition holds for the given state.
+func (c *RuleCondition) Matches(s RuleState) bool {
+	if len(c.Bands) > 0 && !containsFold(c.Bands, s.Band) {
+		return false
+	}
+
+	freqMHz := s.Freq / 1000000
+	if c.MinMHz != 0 && freqMHz < c.MinMHz {
+		return false
+	}
+	if c.MaxMHz != 0 && freqMHz > c.MaxMHz {
+		return false
+	}
+
+	if len(c.Modes) > 0 && !containsFold(c.Modes, s.Mode) {
+		return false
+	}
+
+	if len(c.Locations) > 0 && !containsFold(c.Locations, s.Location) {
+		return false
+	}
+
+	if len(c.Days) > 0 {
+		day := strings.ToLower(s.Time.Weekday().String()[:3])
+		if !containsFold(c.Days, day) {
+			return false
+		}
+	}
+
+	if c.Time != "" {
+		now := s.Time.Hour()*60 + s.Time.Minute()
+		if c.startMin <= c.endMin {
+			if now < c.startMin || now >= c.endMin {
+				return false
+			}
+		} else if now < c.startMin && now >= c.endMin {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleEngine evaluates rules on every poll and runs the actions of rules
+// whose conditions have just become true.
+type RuleEngine struct {
+	rules      []*Rule
+	metrics    *Metrics
+	dispatcher *Dispatcher
+	run        func(a RuleAction, ev Event) error
+
+	// mu guards the rules' active flags, which the control API reads.
+	mu sync.Mutex
+}
+
+// cloneRules copies rules so that engines for different radios track their
+// own edge state.
+func cloneRules(rules []*Rule) []*Rule {
+	clones := make([]*Rule, len(rules))
+	for i, r := range rules {
+		clone := *r
+		clone.active = false
+		clones[i] = &clone
+	}
+	return clones
+}
+
+func NewRuleEngine(rules []*Rule, metrics *Metrics, dispatcher *Dispatcher) *RuleEngine {
+	return &RuleEngine{rules: rules, metrics: metrics, dispatcher: dispatcher, run: runRuleAction}
+}
+
+// rulesUseLocations reports whether any rule conditions on a geofence.
+func rulesUseLocations(rules []*Rule) bool {
+	for _, r := range rules {
+		if len(r.When.Locations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsMode reports whether any rule conditions on the modem name.
+func (e *RuleEngine) NeedsMode() bool {
+	for _, r := range e.rules {
+		if len(r.When.Modes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *RuleEngine) Evaluate(s RuleState) {
+	for _, r := range e.rules {
+		matched := r.When.Matches(s)
+
+		e.mu.Lock()
+		wasActive := r.active
+		r.active = matched
+		e.mu.Unlock()
+
+		if matched && !wasActive {
+			e.fire(r, s)
+		}
+	}
+}
+
+// RuleStatus reports whether a rule's conditions currently match.
+type RuleStatus struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// Status returns the state of every rule, in file order.
+func (e *RuleEngine) Status() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := make([]RuleStatus, len(e.rules))
+	for i, r := range e.rules {
+		status[i] = RuleStatus{Name: r.Name, Active: r.active}
+	}
+	return status
+}
+
+// Matched returns the names of the rules whose conditions currently match.
+func (e *RuleEngine) Matched() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var names []string
+	for _, r := range e.rules {
+		if r.active {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// Restore marks the named rules as matching, and the rest not, without
+// firing them, e.g. to carry on from another node's state.
+func (e *RuleEngine) Restore(names []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	matched := map[string]bool{}
+	for _, name := range names {
+		matched[name] = true
+	}
+	for _, r := range e.rules {
+		r.active = matched[r.Name]
+	}
+}
+
+func (e *RuleEngine) fire(r *Rule, s RuleState) {
+	ev := Event{Type: EventRuleFired, Time: s.Time, Radio: s.Radio, Band: s.Band, Freq: s.Freq, Mode: s.Mode, Rule: r.Name, Notes: s.Notes, Location: s.Location, Exchange: e.dispatcher.exchangeFields()}
+	fmt.Printf("Rule %s matched (%.3f MHz)\n", r.Name, s.Freq/1000000)
+	e.dispatcher.Emit(ev)
+
+	start := time.Now()
+	var failed error
+	for _, a := range r.Actions {
+		if err := e.run(a, ev); err != nil {
+			log.Printf("Error running action for rule %s: %v", r.Name, err)
+			e.dispatcher.reportHookError(ev, err)
+			failed = err
+		}
+	}
+	e.metrics.Rule(r.Name, failed, time.Since(start))
+}
+
+func runRuleAction(a RuleAction, ev Event) error {
+	switch {
+	case a.Command != "":
+		args := make([]string, len(a.Args))
+		for i, arg := range a.Args {
+			args[i] = expandTemplate(arg, ev)
+		}
+		return runExternalCommandEnv(commandEnv(ev), a.Command, args...)
+	case a.Webhook != "":
+		return postJSON(expandTemplate(a.Webhook, ev), ev)
+	case a.MQTT != nil:
+		msg := *a.MQTT
+		msg.Topic = expandTemplate(msg.Topic, ev)
+		msg.Payload = expandTemplate(msg.Payload, ev)
+		return mqttPublish(msg, "fldigi-cmd")
+	case a.FindClear != nil:
+		return fmt.Errorf("find_clear needs the fldigi backend")
+	case a.Rotate != nil:
+		return fmt.Errorf("rotate needs --rotator")
+	case a.Tune != nil:
+		return fmt.Errorf("tune needs the fldigi backend")
+	}
+	return nil
+}
+
+// expandTemplate substitutes event placeholders such as {band} in s.
+func expandTemplate(s string, ev Event) string {
+	return expandTemplateQuoted(s, ev, func(v string) string { return v })
+}
+
+// expandTemplateQuoted substitutes event placeholders in s, passing each
+// value through quote first. Every placeholder is replaced in one pass, so
+// text a value brings in, such as a logged comment of "{call}", is never
+// expanded itself.
+func expandTemplateQuoted(s string, ev Event, quote func(string) string) string {
+	values := map[string]string{
+		"type":      ev.Type,
+		"band":      ev.Band,
+		"prev_band": ev.PrevBand,
+		"freq":      strconv.FormatFloat(ev.Freq, 'f', 0, 64),
+		"freq_mhz":  strconv.FormatFloat(ev.Freq/1000000, 'f', 6, 64),
+		"mode":      ev.Mode,
+		"segment":   ev.Segment,
+		"rule":      ev.Rule,
+		"radio":     ev.Radio,
+		"notes":     strings.Join(ev.Notes, "; "),
+		"location":  ev.Location,
+		"exchange":  formatExchange(ev.Exchange),
+		"call":      ev.Call,
+	}
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		match := placeholder.FindStringSubmatch(m)
+		switch {
+		case match[1] != "":
+			return quote(ev.Exchange[match[1]])
+		case match[2] != "":
+			return quote(ev.QSO[strings.ToUpper(match[2])])
+		}
+		v, ok := values[match[3]]
+		if !ok {
+			return m
+		}
+		return quote(v)
+	})
+}
+
+// placeholder matches {NAME}, {exchange.NAME} and {qso.FIELD}, an ADIF
+// field of a logged QSO, in rule action templates.
+var placeholder = regexp.MustCompile(`\{(?:exchange\.([a-z][a-z0-9_]*)|qso\.([A-Za-z][A-Za-z0-9_]*)|([a-z_]+))\}`)
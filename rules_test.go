@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRules(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRules(t *testing.T) {
+	path := writeRules(t, `{"rules": [
+		{"name": "night 40m", "when": {"bands": ["40m"], "time": "22:00-06:00"},
+		 "actions": [{"command": "./ant.sh", "args": ["{band}"]}]},
+		{"when": {"min_mhz": 14.07, "max_mhz": 14.1},
+		 "actions": [{"mqtt": {"broker": "tcp://localhost:1883", "topic": "shack/band", "payload": "{band}"}}]}
+	]}`)
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[1].Name != "rule-2" {
+		t.Errorf("LoadRules() = %+v", rules)
+	}
+
+	for _, bad := range []string{
+		`{"rules": [{"name": "empty"}]}`,
+		`{"rules": [{"actions": [{"command": "a", "webhook": "http://b"}]}]}`,
+		`{"rules": [{"when": {"time": "late"}, "actions": [{"command": "a"}]}]}`,
+		`{"rules": [{"actions": [{"find_clear": {"step": -100}}]}]}`,
+		`{"rules": [{"actions": [{"find_clear": {"min_carrier": 2000, "max_carrier": 1000}}]}]}`,
+		`{"rules": [{"actions": [{"find_clear": {"min_carrier": 3000}}]}]}`,
+	} {
+		if _, err := LoadRules(writeRules(t, bad)); err == nil {
+			t.Errorf("LoadRules(%s) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestRuleConditionMatches(t *testing.T) {
+	night := RuleCondition{Bands: []string{"40m"}, Time: "22:00-06:00", Days: []string{"sat", "sun"}}
+	if err := night.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-06-01 is a Saturday.
+	sat := func(hour int) time.Time { return time.Date(2024, 6, 1, hour, 30, 0, 0, time.Local) }
+
+	testCases := []struct {
+		state    RuleState
+		expected bool
+	}{
+		{RuleState{Band: "40m", Freq: 7074000, Time: sat(23)}, true},
+		{RuleState{Band: "40m", Freq: 7074000, Time: sat(3)}, true},
+		{RuleState{Band: "40m", Freq: 7074000, Time: sat(12)}, false},
+		{RuleState{Band: "20m", Freq: 14074000, Time: sat(23)}, false},
+		{RuleState{Band: "40m", Freq: 7074000, Time: sat(23).AddDate(0, 0, 2)}, false}, // Monday
+	}
+
+	for _, tc := range testCases {
+		if result := night.Matches(tc.state); result != tc.expected {
+			t.Errorf("Matches(%s at %v) = %v; want %v", tc.state.Band, tc.state.Time, result, tc.expected)
+		}
+	}
+
+	ft8 := RuleCondition{MinMHz: 14.074, MaxMHz: 14.077, Modes: []string{"ft8"}}
+	if !ft8.Matches(RuleState{Freq: 14075000, Mode: "FT8"}) || ft8.Matches(RuleState{Freq: 14075000, Mode: "BPSK31"}) {
+		t.Error("frequency and mode conditions not applied")
+	}
+}
+
+func TestRuleEngineFiresOnTransition(t *testing.T) {
+	rules, err := LoadRules(writeRules(t, `{"rules": [
+		{"name": "20m", "when": {"bands": ["20m"]}, "actions": [{"command": "./ant.sh", "args": ["{band}", "{freq_mhz}"]}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fired [][]string
+	engine := NewRuleEngine(rules, NewMetrics(), NewDispatcher(NewMetrics()))
+	engine.run = func(a RuleAction, ev Event) error {
+		fired = append(fired, []string{expandTemplate(a.Args[0], ev), expandTemplate(a.Args[1], ev)})
+		return nil
+	}
+
+	now := time.Now()
+	engine.Evaluate(RuleState{Band: "20m", Freq: 14074000, Time: now})
+	engine.Evaluate(RuleState{Band: "20m", Freq: 14075000, Time: now})
+	engine.Evaluate(RuleState{Band: "40m", Freq: 7074000, Time: now})
+	engine.Evaluate(RuleState{Band: "20m", Freq: 14100000, Time: now})
+
+	if len(fired) != 2 {
+		t.Fatalf("rule fired %d times; want 2", len(fired))
+	}
+	if fired[1][0] != "20m" || fired[1][1] != "14.100000" {
+		t.Errorf("expanded args = %v", fired[1])
+	}
+}
+
+func TestLoadRulesGeofences(t *testing.T) {
+	path := writeRules(t, `{"geofences": [{"name": "home", "lat": 51.5, "lon": -0.12, "radius_km": 2}],
+		"rules": [{"when": {"locations": ["Home"]}, "actions": [{"command": "./ant.sh"}]}]}`)
+	file, err := LoadRulesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Geofences) != 1 || !rulesUseLocations(file.Rules) {
+		t.Errorf("LoadRulesFile() = %+v", file)
+	}
+
+	for _, bad := range []string{
+		`{"geofences": [{"lat": 1, "lon": 2, "radius_km": 1}], "rules": []}`,
+		`{"geofences": [{"name": "home", "lat": 1, "lon": 2}], "rules": []}`,
+		`{"rules": [{"when": {"locations": ["away"]}, "actions": [{"command": "a"}]}]}`,
+	} {
+		if _, err := LoadRulesFile(writeRules(t, bad)); err == nil {
+			t.Errorf("LoadRulesFile(%s) succeeded; want error", bad)
+		}
+	}
+}
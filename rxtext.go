@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// RXTextSource delivers decoded receive text from fldigi.
+type RXTextSource interface {
+	Name() string
+	// Read blocks until new receive text is available or the source fails.
+	Read() (string, error)
+	Close() error
+}
+
+// socketTextSource reads the raw receive text stream from fldigi's text
+// socket, which pushes characters as they are decoded.
+type socketTextSource struct {
+	conn net.Conn
+	buf  []byte
+}
+
+func dialSocketTextSource(addr string) (*socketTextSource, error) {
+	conn, err := net.DialTimeout(tcpNetwork(), addr, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to text socket %s: %v", addr, err)
+	}
+	return &socketTextSource{conn: conn, buf: make([]byte, 4096)}, nil
+}
+
+func (s *socketTextSource) Name() string { return "socket" }
+
+func (s *socketTextSource) Read() (string, error) {
+	n, err := s.conn.Read(s.buf)
+	if n > 0 {
+		return string(s.buf[:n]), nil
+	}
+	return "", err
+}
+
+func (s *socketTextSource) Close() error { return s.conn.Close() }
+
+// xmlrpcTextSource polls rx.get_data over XML-RPC.
+type xmlrpcTextSource struct {
+	client   *FldigiClient
+	interval time.Duration
+}
+
+func (s *xmlrpcTextSource) Name() string { return "xmlrpc" }
+
+func (s *xmlrpcTextSource) Read() (string, error) {
+	for {
+		text, err := s.client.GetRXData()
+		if err != nil || text != "" {
+			return text, err
+		}
+		time.Sleep(s.interval)
+	}
+}
+
+func (s *xmlrpcTextSource) Close() error { return nil }
+
+// openRXTextSource selects a receive text source. In "auto" mode the text
+// socket is preferred and XML-RPC polling is used when it is unreachable.
+func openRXTextSource(mode, addr string, client *FldigiClient) (RXTextSource, error) {
+	polled := &xmlrpcTextSource{client: client, interval: time.Second}
+
+	switch mode {
+	case "socket":
+		return dialSocketTextSource(addr)
+	case "xmlrpc":
+		return polled, nil
+	case "auto":
+		src, err := dialSocketTextSource(addr)
+		if err != nil {
+			return polled, nil
+		}
+		return src, nil
+	default:
+		return nil, fmt.Errorf("unknown rx text mode %q", mode)
+	}
+}
+
+// streamRXText feeds receive text to handle until the program exits,
+// reopening the source (and falling back in auto mode) when it fails.
+func streamRXText(mode, addr string, client *FldigiClient, handle func(string)) {
+	var lastSource string
+	for {
+		src, err := openRXTextSource(mode, addr, client)
+		if err != nil {
+			log.Printf("Error opening rx text source: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if src.Name() != lastSource {
+			fmt.Printf("Reading rx text via %s\n", src.Name())
+			lastSource = src.Name()
+		}
+
+		for {
+			text, err := src.Read()
+			if err != nil {
+				log.Printf("Error reading rx text via %s: %v", src.Name(), err)
+				break
+			}
+			handle(text)
+		}
+
+		src.Close()
+		time.Sleep(time.Second)
+	}
+}
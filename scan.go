@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode"
+)
+
+// scanRig is what the scanner needs from fldigi: tuning, and the modem's
+// view of the signal.
+type scanRig interface {
+	SetFrequency(freq float64) error
+	GetQuality() (float64, error)
+	GetRXData() (string, error)
+}
+
+// Scanner steps the rig through a list of frequencies, dwelling on each
+// and stopping where it hears activity.
+type Scanner struct {
+	rig    scanRig
+	freqs  []float64
+	dwell  time.Duration
+	sample time.Duration
+	// quality, if above 0, is the signal quality that counts as activity,
+	// such as fldigi's squelch level; chars, if above 0, is the number of
+	// decoded characters that does.
+	quality float64
+	chars   int
+	// hold, if set, resumes the scan once a frequency has been quiet for
+	// that long instead of stopping there.
+	hold time.Duration
+	out  io.Writer
+
+	sleep func(time.Duration)
+}
+
+// Run scans passes times through the list, or forever for 0, returning the
+// frequency it stopped on for activity, or 0 if it finished.
+func (s *Scanner) Run(passes int) (float64, error) {
+	for pass := 0; passes == 0 || pass < passes; pass++ {
+		for _, freq := range s.freqs {
+			if err := s.rig.SetFrequency(freq); err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(s.out, "%s MHz %s\n", formatMHz(freq), frequencyToBand(freq))
+			active, err := s.listen(freq, s.dwell)
+			if err != nil {
+				return 0, err
+			}
+			if !active {
+				continue
+			}
+			if s.hold == 0 {
+				return freq, nil
+			}
+			// Stay while the frequency is busy.
+			for active {
+				if active, err = s.listen(freq, s.hold); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	return 0, nil
+}
+
+// listen samples the signal for up to d, returning as soon as there is
+// activity.
+func (s *Scanner) listen(freq float64, d time.Duration) (bool, error) {
+	// Discard text decoded before the move.
+	if _, err := s.rig.GetRXData(); err != nil {
+		return false, err
+	}
+	chars := 0
+	for waited := time.Duration(0); waited < d; waited += s.sample {
+		s.sleep(s.sample)
+
+		quality, err := s.rig.GetQuality()
+		if err != nil {
+			return false, err
+		}
+		text, err := s.rig.GetRXData()
+		if err != nil {
+			return false, err
+		}
+		for _, r := range text {
+			if !unicode.IsSpace(r) && unicode.IsPrint(r) {
+				chars++
+			}
+		}
+		if (s.quality > 0 && quality >= s.quality) || (s.chars > 0 && chars >= s.chars) {
+			fmt.Fprintf(s.out, "Activity on %s MHz: quality %.0f, %d chars\n", formatMHz(freq), quality, chars)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scanFrequencies expands the scan list: frequencies as they are, and
+// segments and bands from the band plan stepped through every step Hz.
+func scanFrequencies(args []string, step float64) ([]float64, error) {
+	var freqs []float64
+	for _, arg := range args {
+		if r, ok := segmentByName(arg); ok {
+			for f := r.StartMHz * 1000000; f < r.EndMHz*1000000; f += step {
+				freqs = append(freqs, f)
+			}
+			continue
+		}
+		freq, err := parseFrequency(arg)
+		if err != nil {
+			return nil, fmt.Errorf("unknown frequency or segment %q", arg)
+		}
+		freqs = append(freqs, freq)
+	}
+	return freqs, nil
+}
+
+// runScan implements `fldigi-cmd scan FREQ|SEGMENT...`: step fldigi through
+// frequencies, stopping when the squelch opens or text is decoded.
+func runScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	var host, step string
+	var port, chars, passes int
+	var dwell, hold time.Duration
+	var squelch bool
+	fs.StringVar(&host, "host", "127.0.0.1", "fldigi host")
+	fs.IntVar(&port, "port", defaultPorts["fldigi"], "fldigi XML-RPC port")
+	fs.DurationVar(&dwell, "dwell", 5*time.Second, "time to listen on each frequency")
+	fs.StringVar(&step, "step", "3k", "spacing of the frequencies a segment or band is scanned at")
+	fs.BoolVar(&squelch, "squelch", false, "stop when the signal quality opens fldigi's squelch")
+	fs.IntVar(&chars, "chars", 0, "stop when this many characters have been decoded (0 to not stop for text)")
+	fs.DurationVar(&hold, "hold", 0, "instead of stopping, stay while there is activity and resume once it has been quiet this long")
+	fs.IntVar(&passes, "passes", 0, "times to scan through the list (0 for until interrupted)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd scan [options] FREQ|SEGMENT...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	stepHz, err := parseFrequency(step)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --step: %v\n", err)
+		return 2
+	}
+	freqs, err := scanFrequencies(fs.Args(), stepHz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if !squelch && chars <= 0 {
+		fmt.Fprintf(os.Stderr, "Warning: without --squelch or --chars the scan never stops for activity\n")
+	}
+
+	fldigi := NewFldigiClient(host, port)
+	scanner := &Scanner{rig: fldigi, freqs: freqs, dwell: dwell, sample: 250 * time.Millisecond, chars: chars, hold: hold, out: os.Stdout, sleep: time.Sleep}
+	if squelch {
+		if scanner.quality, err = fldigi.GetSquelchLevel(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	freq, err := scanner.Run(passes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if freq != 0 {
+		fmt.Printf("Stopped on %s MHz\n", formatMHz(freq))
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeScanRig has a signal on busy frequencies for the number of samples
+// given, and decodes text on the text ones.
+type fakeScanRig struct {
+	freq  float64
+	busy  map[float64]int
+	text  map[float64]bool
+	tuned []float64
+}
+
+func (r *fakeScanRig) SetFrequency(freq float64) error {
+	r.freq = freq
+	r.tuned = append(r.tuned, freq)
+	return nil
+}
+
+func (r *fakeScanRig) GetQuality() (float64, error) {
+	if r.busy[r.freq] > 0 {
+		r.busy[r.freq]--
+		return 70, nil
+	}
+	return 3, nil
+}
+
+func (r *fakeScanRig) GetRXData() (string, error) {
+	if r.text[r.freq] {
+		return "CQ", nil
+	}
+	return "", nil
+}
+
+func newTestScanner(rig *fakeScanRig, freqs ...float64) *Scanner {
+	return &Scanner{rig: rig, freqs: freqs, dwell: time.Second, sample: 250 * time.Millisecond, quality: 50, out: io.Discard, sleep: func(time.Duration) {}}
+}
+
+func TestScannerStops(t *testing.T) {
+	rig := &fakeScanRig{busy: map[float64]int{7074000: 100}}
+	s := newTestScanner(rig, 14074000, 7074000, 3573000)
+	freq, err := s.Run(0)
+	if err != nil || freq != 7074000 {
+		t.Errorf("Run() = %v, %v; want 7074000", freq, err)
+	}
+	if len(rig.tuned) != 2 {
+		t.Errorf("tuned to %v", rig.tuned)
+	}
+
+	// Text stops the scan only with --chars.
+	rig = &fakeScanRig{text: map[float64]bool{14074000: true}}
+	s = newTestScanner(rig, 14074000, 7074000)
+	if freq, _ := s.Run(1); freq != 0 {
+		t.Errorf("stopped on %v without --chars", freq)
+	}
+	s.chars = 4
+	if freq, _ := s.Run(1); freq != 14074000 {
+		t.Errorf("stopped on %v with --chars, want 14074000", freq)
+	}
+}
+
+func TestScannerHold(t *testing.T) {
+	// Busy for six samples: each listen returns at its first busy sample,
+	// so the scan stays for six and moves on after the first quiet hold.
+	rig := &fakeScanRig{busy: map[float64]int{14074000: 6}}
+	s := newTestScanner(rig, 14074000, 7074000)
+	s.hold = time.Second
+	freq, err := s.Run(2)
+	if err != nil || freq != 0 {
+		t.Errorf("Run() = %v, %v; want to finish", freq, err)
+	}
+	if want := []float64{14074000, 7074000, 14074000, 7074000}; len(rig.tuned) != len(want) {
+		t.Errorf("tuned to %v, want %v", rig.tuned, want)
+	}
+}
+
+func TestScanFrequencies(t *testing.T) {
+	freqs, err := scanFrequencies([]string{"14.074M", "7074k"}, 3000)
+	if err != nil || len(freqs) != 2 || freqs[0] != 14074000 || freqs[1] != 7074000 {
+		t.Errorf("scanFrequencies() = %v, %v", freqs, err)
+	}
+	r, _ := segmentByName("20m-FT8")
+	freqs, err = scanFrequencies([]string{"20m-FT8"}, 1000)
+	if err != nil || len(freqs) == 0 || freqs[0] != r.StartMHz*1000000 {
+		t.Errorf("scanFrequencies(20m-FT8) = %v, %v", freqs, err)
+	}
+	if _, err := scanFrequencies([]string{"nowhere"}, 1000); err == nil {
+		t.Error("expected an error for an unknown segment")
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of values a cron field matches, one bit each.
+type cronField uint64
+
+var (
+	cronMonths   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ScheduleEntry is a --schedule entry: five cron fields, then the
+// frequency and/or mode to change to and optionally radio=LABEL.
+type ScheduleEntry struct {
+	Spec  string
+	Radio string
+	Freq  float64
+	Mode  string
+
+	minute, hour, dom, month, dow cronField
+	// anyDay is set when either day field is *; otherwise, as in cron, a
+	// day matching either field matches.
+	anyDay bool
+}
+
+// ParseScheduleEntry parses an entry such as "*/10 * * * * 14.0956M" or
+// "0 19 * * wed 3.573M BPSK31 radio=B".
+func ParseScheduleEntry(spec string) (*ScheduleEntry, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("schedule %q: want MINUTE HOUR DAY MONTH WEEKDAY followed by a frequency, a mode or both", spec)
+	}
+	e := &ScheduleEntry{Spec: spec}
+	for i, f := range []struct {
+		field    *cronField
+		min, max int
+		names    []string
+	}{
+		{&e.minute, 0, 59, nil},
+		{&e.hour, 0, 23, nil},
+		{&e.dom, 1, 31, nil},
+		{&e.month, 1, 12, cronMonths},
+		{&e.dow, 0, 7, cronWeekdays},
+	} {
+		var err error
+		if *f.field, err = parseCronField(fields[i], f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", spec, err)
+		}
+	}
+	// Sunday is both 0 and 7.
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+
+	for _, arg := range fields[5:] {
+		// Anything that isn't a frequency is a mode, so modes such as
+		// 8PSK125 can start with a digit.
+		freq, freqErr := parseFrequency(arg)
+		switch {
+		case strings.HasPrefix(arg, "radio="):
+			e.Radio = strings.TrimPrefix(arg, "radio=")
+		case e.Freq == 0 && freqErr == nil:
+			e.Freq = freq
+		case e.Mode == "":
+			e.Mode = arg
+		default:
+			return nil, fmt.Errorf("schedule %q: unexpected %q", spec, arg)
+		}
+	}
+	if e.Freq == 0 && e.Mode == "" {
+		return nil, fmt.Errorf("schedule %q: no frequency or mode to change to", spec)
+	}
+	return e, nil
+}
+
+// parseCronField parses a comma-separated list of *, N, N-M and names,
+// each optionally followed by /STEP.
+func parseCronField(s string, min, max int, names []string) (cronField, error) {
+	value := func(v string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(v, name) {
+				return min + i, nil
+			}
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid value %q, want %d-%d", v, min, max)
+		}
+		return n, nil
+	}
+
+	var field cronField
+	for _, part := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			field |= 1 << v
+		}
+	}
+	return field, nil
+}
+
+// Matches reports whether the entry is due in the minute of t.
+func (e *ScheduleEntry) Matches(t time.Time) bool {
+	has := func(f cronField, v int) bool { return f&(1<<v) != 0 }
+	if !has(e.minute, t.Minute()) || !has(e.hour, t.Hour()) || !has(e.month, int(t.Month())) {
+		return false
+	}
+	dom, dow := has(e.dom, t.Day()), has(e.dow, int(t.Weekday()))
+	if e.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Scheduler changes radios' frequency and mode at the times of its
+// entries, through the API so privileges are checked as for `set`.
+type Scheduler struct {
+	entries    []*ScheduleEntry
+	api        *API
+	dispatcher *Dispatcher
+	location   *time.Location
+}
+
+// NewScheduler parses the --schedule entries, to be run in location.
+func NewScheduler(specs []string, location *time.Location, api *API, dispatcher *Dispatcher) (*Scheduler, error) {
+	s := &Scheduler{api: api, dispatcher: dispatcher, location: location}
+	for _, spec := range specs {
+		e, err := ParseScheduleEntry(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, e)
+	}
+	return s, nil
+}
+
+// Check returns an error for an entry naming a radio that isn't monitored.
+func (s *Scheduler) Check() error {
+	for _, e := range s.entries {
+		if _, ok := s.api.rigs[e.Radio]; !ok {
+			if e.Radio == "" {
+				return fmt.Errorf("schedule %q: with several radios, give radio=LABEL", e.Spec)
+			}
+			return fmt.Errorf("schedule %q: unknown radio %q", e.Spec, e.Radio)
+		}
+	}
+	return nil
+}
+
+// Run fires the entries due at the start of every minute.
+func (s *Scheduler) Run() {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+		s.Fire(next)
+	}
+}
+
+// Fire runs the entries due in the minute of t.
+func (s *Scheduler) Fire(t time.Time) {
+	t = t.In(s.location)
+	for _, e := range s.entries {
+		if e.Matches(t) {
+			s.run(e, t)
+		}
+	}
+}
+
+// run makes an entry's changes and emits a schedule-fired event; the band
+// and mode changes that follow are reported by the monitor as usual.
+func (s *Scheduler) run(e *ScheduleEntry, t time.Time) {
+	ev := Event{Type: EventScheduleFired, Time: t, Radio: e.Radio, Freq: e.Freq, Mode: e.Mode, Rule: e.Spec}
+	if e.Freq != 0 {
+		ev.Band = frequencyToBand(e.Freq)
+	}
+	if dryRun {
+		dryRunf("would change to %s for schedule %s", e.describe(), e.Spec)
+		s.dispatcher.Emit(ev)
+		return
+	}
+	fmt.Printf("Schedule %s: %s\n", e.Spec, e.describe())
+	var err error
+	if e.Freq != 0 {
+		_, err = s.api.tune(e.Radio, e.Freq)
+	}
+	if err == nil && e.Mode != "" {
+		_, err = s.api.setMode(e.Radio, e.Mode)
+	}
+	if err != nil {
+		log.Printf("Error running schedule %s: %v", e.Spec, err)
+		ev.Error = err.Error()
+	}
+	s.dispatcher.Emit(ev)
+}
+
+// describe is what an entry changes to, e.g. "14.095600 MHz BPSK31".
+func (e *ScheduleEntry) describe() string {
+	var parts []string
+	if e.Freq != 0 {
+		parts = append(parts, fmt.Sprintf("%.6f MHz", e.Freq/1000000))
+	}
+	if e.Mode != "" {
+		parts = append(parts, e.Mode)
+	}
+	return strings.Join(parts, " ")
+}
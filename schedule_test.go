@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeModeBackend struct {
+	fakeBackend
+	modes []string
+}
+
+func (b *fakeModeBackend) SetMode(mode string) error {
+	b.modes = append(b.modes, mode)
+	return nil
+}
+
+func TestParseScheduleEntry(t *testing.T) {
+	e, err := ParseScheduleEntry("0 19 * * wed 3.573M BPSK31 radio=B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Freq != 3573000 || e.Mode != "BPSK31" || e.Radio != "B" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e, err := ParseScheduleEntry("*/2 * * * * 8PSK125"); err != nil || e.Freq != 0 || e.Mode != "8PSK125" {
+		t.Errorf("mode-only entry = %+v, %v", e, err)
+	}
+
+	for _, spec := range []string{
+		"0 19 * * wed",            // nothing to change
+		"60 * * * * 14.0956M",     // minute out of range
+		"0 19 * * fri-mon 7.074M", // backwards range
+		"*/0 * * * * 7.074M",      // zero step
+		"0 * * * * 7.074M FT8 extra",
+	} {
+		if _, err := ParseScheduleEntry(spec); err == nil {
+			t.Errorf("ParseScheduleEntry(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestScheduleEntryMatches(t *testing.T) {
+	// 2024-01-03 was a Wednesday.
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"*/10 * * * * 14.0956M", at(3, 12, 20), true},
+		{"*/10 * * * * 14.0956M", at(3, 12, 25), false},
+		{"0 19 * * wed 3.573M", at(3, 19, 0), true},
+		{"0 19 * * wed 3.573M", at(4, 19, 0), false},
+		{"0 19 * jan-mar 3 3.573M", at(3, 19, 0), true},
+		{"0 0 * * 7 7.074M", at(7, 0, 0), true}, // Sunday as 7
+		{"5,35 8-10 * * * 7.074M", at(3, 9, 35), true},
+		{"5,35 8-10 * * * 7.074M", at(3, 11, 5), false},
+		// With both day fields given, either may match, as in cron.
+		{"0 12 1 * fri 7.074M", at(5, 12, 0), true},
+		{"0 12 1 * fri 7.074M", at(1, 12, 0), true},
+		{"0 12 1 * fri 7.074M", at(3, 12, 0), false},
+	} {
+		e, err := ParseScheduleEntry(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Matches(tc.t); got != tc.want {
+			t.Errorf("%q matches %v = %v, want %v", tc.spec, tc.t, got, tc.want)
+		}
+	}
+}
+
+func TestSchedulerFire(t *testing.T) {
+	sink := &captureSink{}
+	rig := &fakeModeBackend{fakeBackend: fakeBackend{name: "fake"}}
+	plain := &fakeBackend{name: "plain"}
+	api := &API{rigs: map[string]Backend{"A": rig, "B": plain}}
+	s, err := NewScheduler([]string{
+		"0 * * * * 14.0956M radio=A",
+		"30 19 * * * 3.573M BPSK31 radio=A",
+		"30 19 * * * CW radio=B",
+	}, time.UTC, api, NewDispatcher(NewMetrics(), sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Fire(time.Date(2024, 1, 3, 19, 30, 0, 0, time.UTC))
+	if len(rig.sets) != 1 || rig.sets[0] != 3573000 || len(rig.modes) != 1 || rig.modes[0] != "BPSK31" {
+		t.Errorf("A tuned to %v, modes %v", rig.sets, rig.modes)
+	}
+	if len(sink.events) != 2 {
+		t.Fatalf("events = %+v", sink.events)
+	}
+	if ev := sink.events[0]; ev.Type != EventScheduleFired || ev.Radio != "A" || ev.Band != "80m" || ev.Mode != "BPSK31" || ev.Error != "" {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev := sink.events[1]; ev.Radio != "B" || ev.Error == "" {
+		t.Errorf("a backend that can't set the mode fired %+v", ev)
+	}
+
+	unknown, _ := NewScheduler([]string{"0 * * * * 7.074M radio=C"}, time.UTC, api, nil)
+	if err := unknown.Check(); err == nil {
+		t.Error("expected an error for an unknown radio")
+	}
+}
@@ -0,0 +1,750 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// This file implements a small Lua subset for event handler scripts:
+//
+//	-- comments
+//	local x = freq / 1000000
+//	if band == "20m" and prev_band ~= "20m" then
+//	    exec("./antenna.sh", band)
+//	elseif x > 50 then
+//	    log("VHF: " .. x)
+//	else
+//	    return
+//	end
+//
+// Values are nil, booleans, numbers and strings. Globals assigned by the
+// script persist between events; locals last for their block.
+
+type scriptToken struct {
+	kind string // "name", "number", "string", "op", "eof"
+	text string
+	num  float64
+	line int
+}
+
+var scriptKeywords = map[string]bool{
+	"if": true, "then": true, "elseif": true, "else": true, "end": true,
+	"local": true, "and": true, "or": true, "not": true, "return": true,
+	"true": true, "false": true, "nil": true,
+}
+
+func tokenizeScript(src string) ([]scriptToken, error) {
+	var tokens []scriptToken
+	line := 1
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isScriptLetter(c):
+			start := i
+			for i < len(src) && (isScriptLetter(src[i]) || isScriptDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, scriptToken{kind: "name", text: src[start:i], line: line})
+		case isScriptDigit(c) || (c == '.' && i+1 < len(src) && isScriptDigit(src[i+1])):
+			start := i
+			for i < len(src) && (isScriptDigit(src[i]) || src[i] == '.' || src[i] == 'e' || src[i] == 'E') {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid number %q", line, src[start:i])
+			}
+			tokens = append(tokens, scriptToken{kind: "number", text: src[start:i], num: num, line: line})
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(src) || src[i] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				if src[i] == c {
+					i++
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+					switch src[i] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[i])
+					}
+					i++
+					continue
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			tokens = append(tokens, scriptToken{kind: "string", text: sb.String(), line: line})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "~=", "<=", ">=", "..", "<", ">", "=", "+", "-", "*", "/", "%", "(", ")", ",", ";"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+			}
+			tokens = append(tokens, scriptToken{kind: "op", text: op, line: line})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, scriptToken{kind: "eof", line: line}), nil
+}
+
+func isScriptLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isScriptDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Syntax tree
+
+type scriptStmt interface{}
+
+type scriptExpr interface{}
+
+type ifStmt struct {
+	conds  []scriptExpr
+	blocks [][]scriptStmt
+	orElse []scriptStmt
+}
+
+type assignStmt struct {
+	name  string
+	local bool
+	value scriptExpr
+	line  int
+}
+
+type callStmt struct {
+	call *callExpr
+}
+
+type returnStmt struct{}
+
+type literalExpr struct {
+	value interface{}
+}
+
+type nameExpr struct {
+	name string
+}
+
+type callExpr struct {
+	name string
+	args []scriptExpr
+	line int
+}
+
+type unaryExpr struct {
+	op      string
+	operand scriptExpr
+	line    int
+}
+
+type binaryExpr struct {
+	op          string
+	left, right scriptExpr
+	line        int
+}
+
+// Parser
+
+type scriptParser struct {
+	tokens []scriptToken
+	pos    int
+}
+
+func (p *scriptParser) peek() scriptToken {
+	return p.tokens[p.pos]
+}
+
+func (p *scriptParser) next() scriptToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *scriptParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == "op" || t.kind == "name") && t.text == text
+}
+
+func (p *scriptParser) expect(text string) error {
+	t := p.next()
+	if (t.kind != "op" && t.kind != "name") || t.text != text {
+		return fmt.Errorf("line %d: expected %q near %q", t.line, text, t.text)
+	}
+	return nil
+}
+
+func (p *scriptParser) block(terminators ...string) ([]scriptStmt, error) {
+	var stmts []scriptStmt
+	for {
+		if p.peek().kind == "eof" {
+			return stmts, nil
+		}
+		for _, term := range terminators {
+			if p.is(term) {
+				return stmts, nil
+			}
+		}
+
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		if p.is(";") {
+			p.next()
+		}
+	}
+}
+
+func (p *scriptParser) statement() (scriptStmt, error) {
+	t := p.next()
+
+	switch {
+	case t.kind == "name" && t.text == "if":
+		stmt := &ifStmt{}
+		for {
+			cond, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("then"); err != nil {
+				return nil, err
+			}
+			body, err := p.block("elseif", "else", "end")
+			if err != nil {
+				return nil, err
+			}
+			stmt.conds = append(stmt.conds, cond)
+			stmt.blocks = append(stmt.blocks, body)
+
+			if !p.is("elseif") {
+				break
+			}
+			p.next()
+		}
+		if p.is("else") {
+			p.next()
+			body, err := p.block("end")
+			if err != nil {
+				return nil, err
+			}
+			stmt.orElse = body
+		}
+		return stmt, p.expect("end")
+
+	case t.kind == "name" && t.text == "local":
+		name := p.next()
+		if name.kind != "name" || scriptKeywords[name.text] {
+			return nil, fmt.Errorf("line %d: expected variable name after local", name.line)
+		}
+		stmt := &assignStmt{name: name.text, local: true, value: &literalExpr{}, line: name.line}
+		if p.is("=") {
+			p.next()
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			stmt.value = value
+		}
+		return stmt, nil
+
+	case t.kind == "name" && t.text == "return":
+		return &returnStmt{}, nil
+
+	case t.kind == "name" && !scriptKeywords[t.text]:
+		if p.is("=") {
+			p.next()
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return &assignStmt{name: t.text, value: value, line: t.line}, nil
+		}
+		if p.is("(") {
+			call, err := p.call(t)
+			if err != nil {
+				return nil, err
+			}
+			return &callStmt{call: call}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("line %d: unexpected %q", t.line, t.text)
+}
+
+func (p *scriptParser) call(name scriptToken) (*callExpr, error) {
+	call := &callExpr{name: name.text, line: name.line}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	return call, p.expect(")")
+}
+
+// Binary operator precedence, lowest first, following Lua.
+var scriptPrecedence = [][]string{
+	{"or"},
+	{"and"},
+	{"<", ">", "<=", ">=", "~=", "=="},
+	{".."},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *scriptParser) expression() (scriptExpr, error) {
+	return p.binary(0)
+}
+
+func (p *scriptParser) binary(level int) (scriptExpr, error) {
+	if level == len(scriptPrecedence) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range scriptPrecedence[level] {
+			if (t.kind == "op" || t.kind == "name") && t.text == op {
+				matched = true
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		p.next()
+
+		// Concatenation is right associative.
+		next := level + 1
+		if t.text == ".." {
+			next = level
+		}
+		right, err := p.binary(next)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right, line: t.line}
+	}
+}
+
+func (p *scriptParser) unary() (scriptExpr, error) {
+	if p.is("not") || p.is("-") {
+		t := p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: t.text, operand: operand, line: t.line}, nil
+	}
+	return p.primary()
+}
+
+func (p *scriptParser) primary() (scriptExpr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case "number":
+		return &literalExpr{value: t.num}, nil
+	case "string":
+		return &literalExpr{value: t.text}, nil
+	case "name":
+		switch t.text {
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "nil":
+			return &literalExpr{}, nil
+		}
+		if scriptKeywords[t.text] {
+			break
+		}
+		if p.is("(") {
+			return p.call(t)
+		}
+		return &nameExpr{name: t.text}, nil
+	case "op":
+		if t.text == "(" {
+			e, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	}
+
+	return nil, fmt.Errorf("line %d: unexpected %q", t.line, t.text)
+}
+
+// Script is a parsed event handler script together with its globals.
+type Script struct {
+	body     []scriptStmt
+	globals  map[string]interface{}
+	builtins map[string]func(args []interface{}) (interface{}, error)
+}
+
+func ParseScript(src string) (*Script, error) {
+	tokens, err := tokenizeScript(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &scriptParser{tokens: tokens}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Script{
+		body:     body,
+		globals:  map[string]interface{}{},
+		builtins: map[string]func(args []interface{}) (interface{}, error){},
+	}, nil
+}
+
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+
+	s, err := ParseScript(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Register makes a Go function callable from the script.
+func (s *Script) Register(name string, fn func(args []interface{}) (interface{}, error)) {
+	s.builtins[name] = fn
+}
+
+// Set assigns a global variable.
+func (s *Script) Set(name string, value interface{}) {
+	s.globals[name] = value
+}
+
+// Get returns a global variable.
+func (s *Script) Get(name string) interface{} {
+	return s.globals[name]
+}
+
+// Run executes the script body once.
+func (s *Script) Run() error {
+	scopes := []map[string]interface{}{{}}
+	_, err := s.exec(s.body, scopes)
+	return err
+}
+
+func (s *Script) exec(stmts []scriptStmt, scopes []map[string]interface{}) (bool, error) {
+	for _, stmt := range stmts {
+		switch st := stmt.(type) {
+		case *returnStmt:
+			return true, nil
+
+		case *assignStmt:
+			value, err := s.eval(st.value, scopes)
+			if err != nil {
+				return false, err
+			}
+			if st.local {
+				scopes[len(scopes)-1][st.name] = value
+				continue
+			}
+			assigned := false
+			for i := len(scopes) - 1; i >= 0; i-- {
+				if _, ok := scopes[i][st.name]; ok {
+					scopes[i][st.name] = value
+					assigned = true
+					break
+				}
+			}
+			if !assigned {
+				s.globals[st.name] = value
+			}
+
+		case *callStmt:
+			if _, err := s.eval(st.call, scopes); err != nil {
+				return false, err
+			}
+
+		case *ifStmt:
+			var body []scriptStmt
+			matched := false
+			for i, cond := range st.conds {
+				value, err := s.eval(cond, scopes)
+				if err != nil {
+					return false, err
+				}
+				if scriptTruthy(value) {
+					body = st.blocks[i]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				body = st.orElse
+			}
+
+			returned, err := s.exec(body, append(scopes, map[string]interface{}{}))
+			if err != nil || returned {
+				return returned, err
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *Script) eval(expr scriptExpr, scopes []map[string]interface{}) (interface{}, error) {
+	switch e := expr.(type) {
+	case *literalExpr:
+		return e.value, nil
+
+	case *nameExpr:
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if v, ok := scopes[i][e.name]; ok {
+				return v, nil
+			}
+		}
+		return s.globals[e.name], nil
+
+	case *callExpr:
+		fn, ok := s.builtins[e.name]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown function %s", e.line, e.name)
+		}
+		args := make([]interface{}, len(e.args))
+		for i, a := range e.args {
+			v, err := s.eval(a, scopes)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		result, err := fn(args)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", e.line, e.name, err)
+		}
+		return result, nil
+
+	case *unaryExpr:
+		v, err := s.eval(e.operand, scopes)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "not" {
+			return !scriptTruthy(v), nil
+		}
+		n, ok := scriptNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("line %d: attempt to negate a %s value", e.line, scriptType(v))
+		}
+		return -n, nil
+
+	case *binaryExpr:
+		left, err := s.eval(e.left, scopes)
+		if err != nil {
+			return nil, err
+		}
+
+		// and/or short-circuit and yield an operand, as in Lua.
+		switch e.op {
+		case "and":
+			if !scriptTruthy(left) {
+				return left, nil
+			}
+			return s.eval(e.right, scopes)
+		case "or":
+			if scriptTruthy(left) {
+				return left, nil
+			}
+			return s.eval(e.right, scopes)
+		}
+
+		right, err := s.eval(e.right, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return scriptBinary(e.op, left, right, e.line)
+	}
+
+	return nil, fmt.Errorf("invalid expression")
+}
+
+func scriptBinary(op string, left, right interface{}, line int) (interface{}, error) {
+	switch op {
+	case "==":
+		return scriptEqual(left, right), nil
+	case "~=":
+		return !scriptEqual(left, right), nil
+	case "..":
+		if !scriptConcatable(left) || !scriptConcatable(right) {
+			return nil, fmt.Errorf("line %d: attempt to concatenate a %s value", line, scriptType(nilIfConcatable(left, right)))
+		}
+		return scriptString(left) + scriptString(right), nil
+	}
+
+	if ls, ok := left.(string); ok {
+		if rs, ok := right.(string); ok {
+			switch op {
+			case "<":
+				return ls < rs, nil
+			case ">":
+				return ls > rs, nil
+			case "<=":
+				return ls <= rs, nil
+			case ">=":
+				return ls >= rs, nil
+			}
+		}
+	}
+
+	l, lok := scriptNumber(left)
+	r, rok := scriptNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("line %d: attempt to perform arithmetic or comparison on %s and %s values", line, scriptType(left), scriptType(right))
+	}
+
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	case "%":
+		return l - math.Floor(l/r)*r, nil
+	case "<":
+		return l < r, nil
+	case ">":
+		return l > r, nil
+	case "<=":
+		return l <= r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("line %d: unknown operator %s", line, op)
+}
+
+func nilIfConcatable(left, right interface{}) interface{} {
+	if !scriptConcatable(left) {
+		return left
+	}
+	return right
+}
+
+func scriptConcatable(v interface{}) bool {
+	switch v.(type) {
+	case string, float64:
+		return true
+	}
+	return false
+}
+
+func scriptTruthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+func scriptEqual(a, b interface{}) bool {
+	return a == b
+}
+
+// scriptNumber converts numbers and numeric strings to float64.
+func scriptNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// scriptString formats a value the way Lua's tostring does.
+func scriptString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1e15 {
+			return strconv.FormatFloat(x, 'f', 0, 64)
+		}
+		return strconv.FormatFloat(x, 'g', 14, 64)
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
+
+func scriptType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	}
+	return "unknown"
+}
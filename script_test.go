@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptControlFlow(t *testing.T) {
+	s, err := ParseScript(`
+-- antenna selection
+local mhz = freq / 1000000
+if band == "20m" and prev_band ~= "20m" then
+    action = "yagi " .. mhz
+elseif mhz > 50 or band == "6m" then
+    action = "vhf"
+else
+    action = "dipole"
+end
+count = (count or 0) + 1
+if not prev_band then
+    return
+end
+seen_prev = true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		band     string
+		prevBand interface{}
+		freq     float64
+		action   string
+	}{
+		{"20m", "40m", 14074000, "yagi 14.074"},
+		{"2m", "20m", 144174000, "vhf"},
+		{"40m", nil, 7074000, "dipole"},
+	}
+
+	for _, tc := range testCases {
+		s.Set("band", tc.band)
+		s.Set("prev_band", tc.prevBand)
+		s.Set("freq", tc.freq)
+		s.Set("seen_prev", nil)
+		if err := s.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Get("action"); got != tc.action {
+			t.Errorf("%s: action = %v; want %s", tc.band, got, tc.action)
+		}
+		if got := s.Get("seen_prev"); (got != nil) != (tc.prevBand != nil) {
+			t.Errorf("%s: return did not stop the script", tc.band)
+		}
+	}
+
+	// Globals persist between runs.
+	if got := s.Get("count"); got != float64(3) {
+		t.Errorf("count = %v; want 3", got)
+	}
+}
+
+func TestScriptBuiltins(t *testing.T) {
+	s, err := ParseScript(`if band == "40m" then tune(freq + 1500, "usb") end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []interface{}
+	s.Register("tune", func(args []interface{}) (interface{}, error) {
+		got = args
+		return nil, nil
+	})
+	s.Set("band", "40m")
+	s.Set("freq", float64(7074000))
+
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != float64(7075500) || got[1] != "usb" {
+		t.Errorf("tune called with %v", got)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	for _, src := range []string{
+		`if band == "20m" then`,
+		`x = = 1`,
+		`local = 3`,
+		`s = "unterminated`,
+	} {
+		if _, err := ParseScript(src); err == nil {
+			t.Errorf("ParseScript(%q) succeeded; want error", src)
+		}
+	}
+
+	s, _ := ParseScript("\n\nx = band + 1")
+	s.Set("band", "20m")
+	if err := s.Run(); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Run() error = %v; want arithmetic error on line 3", err)
+	}
+
+	s, _ = ParseScript(`missing()`)
+	if err := s.Run(); err == nil {
+		t.Error("calling an unknown function succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scriptSink runs an event handler script for every event. The event is
+// exposed as the globals event, band, prev_band, freq, mode, segment,
+// prev_segment, rule, call, notes, backend, radio and location.
+type scriptSink struct {
+	script *Script
+	// exchange is the current event's exchange, for the exchange helper.
+	exchange map[string]string
+}
+
+// finder, if set, backs the find_clear helper.
+func newScriptSink(script *Script, backend Backend, finder *ClearFinder) *scriptSink {
+	sink := &scriptSink{script: script}
+	script.Register("exec", func(args []interface{}) (interface{}, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("missing command")
+		}
+		strs := make([]string, len(args))
+		for i, a := range args {
+			strs[i] = scriptString(a)
+		}
+		if err := runExternalCommand(strs[0], strs[1:]...); err != nil {
+			return false, nil
+		}
+		return true, nil
+	})
+
+	script.Register("http_post", func(args []interface{}) (interface{}, error) {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing url")
+		}
+		var body string
+		if len(args) > 1 {
+			body = scriptString(args[1])
+		}
+		if dryRun {
+			dryRunf("would post to %s: %s", scriptString(args[0]), body)
+			return true, nil
+		}
+		resp, err := webhookClient.Post(scriptString(args[0]), "text/plain", strings.NewReader(body))
+		if err != nil {
+			return false, nil
+		}
+		resp.Body.Close()
+		return resp.StatusCode < 300, nil
+	})
+
+	script.Register("set_freq", func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected a frequency in Hz")
+		}
+		freq, ok := scriptNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("frequency must be a number")
+		}
+		if dryRun {
+			dryRunf("would tune to %.0f Hz", freq)
+			return nil, nil
+		}
+		return nil, backend.SetFrequency(freq)
+	})
+
+	script.Register("find_clear", func(args []interface{}) (interface{}, error) {
+		if finder == nil {
+			return nil, fmt.Errorf("find_clear needs the fldigi backend")
+		}
+		var a FindClearAction
+		for i, p := range []*float64{&a.MinCarrier, &a.MaxCarrier} {
+			if i < len(args) {
+				if n, ok := scriptNumber(args[i]); ok {
+					*p = n
+				}
+			}
+		}
+		carrier, err := finder.Find(a)
+		if err != nil {
+			fmt.Printf("find_clear: %v\n", err)
+			return nil, nil
+		}
+		return carrier, nil
+	})
+
+	script.Register("log", func(args []interface{}) (interface{}, error) {
+		strs := make([]string, len(args))
+		for i, a := range args {
+			strs[i] = scriptString(a)
+		}
+		fmt.Println(strings.Join(strs, "\t"))
+		return nil, nil
+	})
+
+	script.Register("tostring", func(args []interface{}) (interface{}, error) {
+		if len(args) == 0 {
+			return "nil", nil
+		}
+		return scriptString(args[0]), nil
+	})
+
+	script.Register("tonumber", func(args []interface{}) (interface{}, error) {
+		if len(args) == 0 {
+			return nil, nil
+		}
+		if n, ok := scriptNumber(args[0]); ok {
+			return n, nil
+		}
+		return nil, nil
+	})
+
+	script.Register("exchange", func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("exchange needs a field name")
+		}
+		return scriptOptional(sink.exchange[scriptString(args[0])]), nil
+	})
+
+	script.Register("hour", func(args []interface{}) (interface{}, error) {
+		return float64(time.Now().Hour()), nil
+	})
+
+	return sink
+}
+
+func (s *scriptSink) Name() string { return "script" }
+
+func (s *scriptSink) Wants(ev Event) bool { return true }
+
+func (s *scriptSink) Handle(ev Event) error {
+	s.script.Set("event", ev.Type)
+	s.script.Set("band", scriptOptional(ev.Band))
+	s.script.Set("prev_band", scriptOptional(ev.PrevBand))
+	s.script.Set("freq", ev.Freq)
+	s.script.Set("mode", scriptOptional(ev.Mode))
+	s.script.Set("segment", scriptOptional(ev.Segment))
+	s.script.Set("prev_segment", scriptOptional(ev.PrevSegment))
+	s.script.Set("rule", scriptOptional(ev.Rule))
+	s.script.Set("call", scriptOptional(ev.Call))
+	s.script.Set("notes", scriptOptional(strings.Join(ev.Notes, "; ")))
+	s.script.Set("backend", scriptOptional(ev.Backend))
+	s.script.Set("radio", scriptOptional(ev.Radio))
+	s.script.Set("location", scriptOptional(ev.Location))
+	s.exchange = ev.Exchange
+	return s.script.Run()
+}
+
+// scriptOptional maps empty strings to nil so scripts can test presence
+// with `if prev_band then`.
+func scriptOptional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
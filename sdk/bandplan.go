@@ -0,0 +1,138 @@
+package sdk
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+type BandRange struct {
+	Name     string
+	StartMHz float64
+	EndMHz   float64
+}
+
+// Segment is a named sub-band range such as a CW, data or phone segment,
+// optionally hinting at the mode normally used there (e.g. FT8) and limiting
+// the occupied bandwidth of transmissions.
+type Segment struct {
+	Name         string
+	Band         string
+	StartMHz     float64
+	EndMHz       float64
+	Mode         string
+	MaxBandwidth float64 // Hz, 0 for no limit
+}
+
+// BandPlan maps frequencies to band and segment names.
+type BandPlan struct {
+	Bands    []BandRange
+	Segments []Segment
+}
+
+// ParseBandPlan reads the bands.txt format: band:start_mhz:end_mhz lines
+// and segment:name:start_mhz:end_mhz[:mode[:max_bandwidth_hz]] lines, with
+// # comments. Malformed lines are skipped.
+func ParseBandPlan(data string) *BandPlan {
+	plan := &BandPlan{}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip comments and empty lines
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Parse band:start:end or segment:name:start:end[:mode[:bw]] format
+		parts := strings.Split(line, ":")
+		isSegment := parts[0] == "segment" && len(parts) >= 4 && len(parts) <= 6
+		var mode string
+		var maxBandwidth float64
+		if isSegment {
+			if len(parts) >= 5 {
+				mode = parts[4]
+			}
+			if len(parts) == 6 {
+				bw, err := strconv.ParseFloat(parts[5], 64)
+				if err != nil {
+					continue
+				}
+				maxBandwidth = bw
+			}
+			parts = parts[1:4]
+		}
+		if len(parts) != 3 {
+			continue
+		}
+
+		startMHz, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+
+		endMHz, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			continue
+		}
+
+		if isSegment {
+			plan.Segments = append(plan.Segments, Segment{
+				Name:         parts[0],
+				StartMHz:     startMHz,
+				EndMHz:       endMHz,
+				Mode:         mode,
+				MaxBandwidth: maxBandwidth,
+			})
+			continue
+		}
+
+		plan.Bands = append(plan.Bands, BandRange{
+			Name:     parts[0],
+			StartMHz: startMHz,
+			EndMHz:   endMHz,
+		})
+	}
+
+	// Segments may be listed anywhere in the file, so resolve the band
+	// they belong to once all bands are known.
+	for i := range plan.Segments {
+		plan.Segments[i].Band = plan.Band(plan.Segments[i].StartMHz * 1000000)
+	}
+
+	return plan
+}
+
+// Band returns the name of the band containing freq (Hz), or "unknown".
+func (p *BandPlan) Band(freq float64) string {
+	freqMHz := freq / 1000000
+
+	// Check each band in the loaded band plan
+	for _, band := range p.Bands {
+		if freqMHz >= band.StartMHz && freqMHz <= band.EndMHz {
+			return band.Name
+		}
+	}
+
+	return "unknown"
+}
+
+// Segment returns the first segment containing freq (Hz). Segment starts
+// are inclusive and ends exclusive, so adjacent segments don't overlap.
+func (p *BandPlan) Segment(freq float64) (Segment, bool) {
+	freqMHz := freq / 1000000
+
+	for _, seg := range p.Segments {
+		if freqMHz >= seg.StartMHz && freqMHz < seg.EndMHz {
+			return seg, true
+		}
+	}
+
+	return Segment{}, false
+}
+
+// Wavelength returns the free-space wavelength in metres of freq (Hz).
+func Wavelength(freq float64) float64 {
+	return 299792458 / freq
+}
@@ -0,0 +1,85 @@
+// Package sdk provides typed access to the events published by a running
+// fldigi-cmd daemon, together with band plan and Maidenhead grid helpers for
+// writing shack integrations.
+package sdk
+
+import "time"
+
+// Event types published by the daemon.
+const (
+	EventInitialBand      = "initial-band"
+	EventBandChange       = "band-change"
+	EventSegmentChange    = "segment-change"
+	EventPrivilegeWarning = "privilege-warning"
+	EventBandwidthWarning = "bandwidth-warning"
+	EventRuleFired        = "rule-fired"
+	EventWatch            = "watch"
+	EventRPCCall          = "rpc-call"
+	EventBackendChange    = "backend-change"
+	EventVerifyFailed     = "verify-failed"
+	EventConnected        = "connected"
+	EventDisconnected     = "disconnected"
+	EventLocationChanged  = "location-changed"
+	EventRegionDetected   = "region-detected"
+	EventRegionChanged    = "region-changed"
+	EventExchangeChanged  = "exchange-changed"
+	EventHookTimeout      = "hook-timeout"
+	EventHookFailed       = "hook-failed"
+	EventShutdown         = "shutdown"
+	EventOutOfBand        = "out-of-band"
+	EventModeChange       = "mode-change"
+	EventQSOLogged        = "qso-logged"
+	EventDXSpot           = "dx-spot"
+	EventTXStart          = "tx-start"
+	EventTXStop           = "tx-stop"
+	EventScheduleFired    = "schedule-fired"
+	EventHopStart         = "hop-start"
+	EventHopDone          = "hop-done"
+	EventSplitWarning     = "split-warning"
+)
+
+// Event describes something the monitor observed. Fields that don't apply to
+// an event type are left empty.
+type Event struct {
+	Type         string            `json:"type"`
+	Time         time.Time         `json:"time"`
+	Radio        string            `json:"radio,omitempty"`
+	Band         string            `json:"band,omitempty"`
+	PrevBand     string            `json:"prev_band,omitempty"`
+	Freq         float64           `json:"freq,omitempty"`
+	Segment      string            `json:"segment,omitempty"`
+	PrevSegment  string            `json:"prev_segment,omitempty"`
+	SegmentMode  string            `json:"segment_mode,omitempty"`
+	Mode         string            `json:"mode,omitempty"`
+	PrevMode     string            `json:"prev_mode,omitempty"`
+	Bandwidth    float64           `json:"bandwidth,omitempty"`
+	Rule         string            `json:"rule,omitempty"`
+	Call         string            `json:"call,omitempty"`
+	Method       string            `json:"method,omitempty"`
+	Notes        []string          `json:"notes,omitempty"`
+	Backend      string            `json:"backend,omitempty"`
+	PrevBackend  string            `json:"prev_backend,omitempty"`
+	ReadBack     float64           `json:"read_back,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	Downtime     float64           `json:"downtime,omitempty"` // seconds
+	Location     string            `json:"location,omitempty"`
+	PrevLocation string            `json:"prev_location,omitempty"`
+	Lat          float64           `json:"lat,omitempty"`
+	Lon          float64           `json:"lon,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	PrevRegion   string            `json:"prev_region,omitempty"`
+	Exchange     map[string]string `json:"exchange,omitempty"`
+	Command      string            `json:"command,omitempty"`
+	ExitCode     int               `json:"exit_code,omitempty"`
+	Output       string            `json:"output,omitempty"`
+	QSO          map[string]string `json:"qso,omitempty"` // ADIF fields of a logged contact
+	Spotter      string            `json:"spotter,omitempty"`
+	Comment      string            `json:"comment,omitempty"`
+	TXBand       string            `json:"tx_band,omitempty"` // band of the TX VFO when split
+	TXFreq       float64           `json:"tx_freq,omitempty"`
+}
+
+// FreqMHz returns the event frequency in MHz.
+func (e Event) FreqMHz() float64 {
+	return e.Freq / 1000000
+}
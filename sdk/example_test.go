@@ -0,0 +1,52 @@
+package sdk_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"fldigi-cmd/sdk"
+)
+
+func ExampleSubscribe() {
+	// Switch antennas from a separate program, following a daemon started
+	// with --events-addr :9362.
+	err := sdk.Subscribe(context.Background(), "http://localhost:9362/events", func(ev sdk.Event) {
+		if ev.Type == sdk.EventBandChange {
+			fmt.Printf("QSY to %s at %.3f MHz\n", ev.Band, ev.FreqMHz())
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleSubscribeWebSocket() {
+	// The same, over the daemon's WebSocket stream.
+	err := sdk.SubscribeWebSocket(context.Background(), "ws://localhost:9362/events/ws", func(ev sdk.Event) {
+		if ev.Type == sdk.EventTXStart {
+			fmt.Printf("Transmitting on %s\n", ev.Band)
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleParseBandPlan() {
+	plan := sdk.ParseBandPlan(`
+40m:7.0:7.3
+20m:14.0:14.35
+segment:20m-FT8:14.074:14.077:FT8
+`)
+
+	seg, _ := plan.Segment(14075000)
+	fmt.Println(plan.Band(7074000), seg.Name, seg.Band, seg.Mode)
+	// Output: 40m 20m-FT8 20m FT8
+}
+
+func ExampleGridDistance() {
+	km, bearing, _ := sdk.GridDistance("FN31pr", "IO91wm")
+	fmt.Printf("%.0f km at %.0f degrees\n", km, bearing)
+	// Output: 5415 km at 52 degrees
+}
@@ -0,0 +1,133 @@
+package sdk
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0
+
+// GridToLatLon returns the centre of a 2, 4, 6 or 8 character Maidenhead
+// locator.
+func GridToLatLon(grid string) (lat, lon float64, err error) {
+	g := strings.ToUpper(strings.TrimSpace(grid))
+	if len(g) < 2 || len(g) > 8 || len(g)%2 != 0 {
+		return 0, 0, fmt.Errorf("invalid grid locator %q", grid)
+	}
+
+	lonSize, latSize := 20.0, 10.0
+	lon, lat = -180, -90
+
+	for i := 0; i < len(g); i += 2 {
+		var lonIdx, latIdx int
+		var divisions float64
+
+		switch i {
+		case 0: // field: A-R
+			lonIdx, latIdx = int(g[i]-'A'), int(g[i+1]-'A')
+			divisions = 18
+		case 2, 6: // square and extended square: 0-9
+			lonIdx, latIdx = int(g[i]-'0'), int(g[i+1]-'0')
+			divisions = 10
+		case 4: // subsquare: A-X
+			lonIdx, latIdx = int(g[i]-'A'), int(g[i+1]-'A')
+			divisions = 24
+		}
+
+		if lonIdx < 0 || latIdx < 0 || lonIdx >= int(divisions) || latIdx >= int(divisions) {
+			return 0, 0, fmt.Errorf("invalid grid locator %q", grid)
+		}
+
+		if i > 0 {
+			lonSize /= divisions
+			latSize /= divisions
+		}
+		lon += float64(lonIdx) * lonSize
+		lat += float64(latIdx) * latSize
+	}
+
+	return lat + latSize/2, lon + lonSize/2, nil
+}
+
+// LatLonToGrid returns the Maidenhead locator of a position with the given
+// number of characters (2, 4, 6 or 8).
+func LatLonToGrid(lat, lon float64, length int) (string, error) {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return "", fmt.Errorf("position %.4f,%.4f out of range", lat, lon)
+	}
+	if length < 2 || length > 8 || length%2 != 0 {
+		return "", fmt.Errorf("invalid grid length %d", length)
+	}
+
+	// Keep the poles and antimeridian inside the last field.
+	lon = math.Min(lon+180, 359.999999)
+	lat = math.Min(lat+90, 179.999999)
+
+	var sb strings.Builder
+	lonSize, latSize := 20.0, 10.0
+	for i := 0; i < length; i += 2 {
+		var divisions float64
+		var base byte
+		switch i {
+		case 0:
+			base = 'A'
+		case 2, 6:
+			divisions, base = 10, '0'
+		case 4:
+			divisions, base = 24, 'a'
+		}
+		if i > 0 {
+			lonSize /= divisions
+			latSize /= divisions
+		}
+
+		lonIdx := int(lon / lonSize)
+		latIdx := int(lat / latSize)
+		sb.WriteByte(base + byte(lonIdx))
+		sb.WriteByte(base + byte(latIdx))
+		lon -= float64(lonIdx) * lonSize
+		lat -= float64(latIdx) * latSize
+	}
+
+	return sb.String(), nil
+}
+
+// Distance returns the great-circle distance in km between two positions.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1, φ2 := radians(lat1), radians(lat2)
+	dφ := radians(lat2 - lat1)
+	dλ := radians(lon2 - lon1)
+
+	a := math.Sin(dφ/2)*math.Sin(dφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(dλ/2)*math.Sin(dλ/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Bearing returns the initial great-circle bearing in degrees from the first
+// position to the second.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1, φ2 := radians(lat1), radians(lat2)
+	dλ := radians(lon2 - lon1)
+
+	y := math.Sin(dλ) * math.Cos(φ2)
+	x := math.Cos(φ1)*math.Sin(φ2) - math.Sin(φ1)*math.Cos(φ2)*math.Cos(dλ)
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// GridDistance returns the distance in km and bearing in degrees between the
+// centres of two grid locators.
+func GridDistance(from, to string) (km, bearing float64, err error) {
+	lat1, lon1, err := GridToLatLon(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat2, lon2, err := GridToLatLon(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	return Distance(lat1, lon1, lat2, lon2), Bearing(lat1, lon1, lat2, lon2), nil
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
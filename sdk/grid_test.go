@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGridToLatLon(t *testing.T) {
+	testCases := []struct {
+		grid     string
+		lat, lon float64
+	}{
+		{"FN31", 41.5, -73},
+		{"fn31pr", 41.729167, -72.708333},
+		{"JO01", 51.5, 1},
+		{"AA00", -89.5, -179},
+	}
+
+	for _, tc := range testCases {
+		lat, lon, err := GridToLatLon(tc.grid)
+		if err != nil {
+			t.Fatalf("GridToLatLon(%s): %v", tc.grid, err)
+		}
+		if math.Abs(lat-tc.lat) > 1e-5 || math.Abs(lon-tc.lon) > 1e-5 {
+			t.Errorf("GridToLatLon(%s) = %.6f, %.6f; want %.6f, %.6f", tc.grid, lat, lon, tc.lat, tc.lon)
+		}
+	}
+
+	for _, bad := range []string{"", "F", "ZZ00", "FNAA", "FN31p"} {
+		if _, _, err := GridToLatLon(bad); err == nil {
+			t.Errorf("GridToLatLon(%q) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestLatLonToGrid(t *testing.T) {
+	grid, err := LatLonToGrid(41.714775, -72.727260, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid != "FN31pr" {
+		t.Errorf("LatLonToGrid(W1AW) = %s; want FN31pr", grid)
+	}
+
+	grid, _ = LatLonToGrid(90, 180, 4)
+	if grid != "RR99" {
+		t.Errorf("LatLonToGrid(90, 180) = %s; want RR99", grid)
+	}
+}
+
+func TestGridDistance(t *testing.T) {
+	km, bearing, err := GridDistance("FN31", "JO01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km < 5400 || km > 5600 {
+		t.Errorf("FN31 to JO01 distance = %.0f km; want about 5500", km)
+	}
+	if bearing < 45 || bearing > 60 {
+		t.Errorf("FN31 to JO01 bearing = %.0f; want about 52", bearing)
+	}
+}
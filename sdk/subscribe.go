@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StatusError is returned by Subscribe when the server rejects the stream.
+type StatusError struct {
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("event stream returned %s", e.Status)
+}
+
+// Subscribe connects to a daemon's event stream (e.g.
+// http://localhost:9362/events) and calls handle for every event until ctx
+// is cancelled. Dropped connections are retried after a short delay.
+func Subscribe(ctx context.Context, url string, handle func(Event)) error {
+	return SubscribeClient(ctx, http.DefaultClient, url, handle)
+}
+
+// SubscribeClient is like Subscribe but connects with the given client, e.g.
+// one that dials the daemon's Unix-domain socket.
+func SubscribeClient(ctx context.Context, client *http.Client, url string, handle func(Event)) error {
+	for {
+		err := subscribeOnce(ctx, client, url, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// A server that answers but refuses the stream won't change its
+		// mind, so only retry connection failures.
+		if _, ok := err.(*StatusError); ok {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func subscribeOnce(ctx context.Context, client *http.Client, url string, handle func(Event)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Status: resp.Status}
+	}
+
+	// Server-sent events: "data:" lines accumulate until a blank line.
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			var ev Event
+			if err := json.Unmarshal([]byte(data.String()), &ev); err == nil {
+				handle(ev)
+			}
+			data.Reset()
+		}
+	}
+	return scanner.Err()
+}
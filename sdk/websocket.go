@@ -0,0 +1,196 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// websocketGUID is appended to the key to check the server's handshake
+// answer (RFC 6455, section 1.3).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// maxWebsocketMessage bounds the messages read from the server, which are
+// single events.
+const maxWebsocketMessage = 1 << 20
+
+// errWebsocketClosed is returned when the server closes the WebSocket.
+var errWebsocketClosed = errors.New("WebSocket closed by the server")
+
+// SubscribeWebSocket is like Subscribe but reads the daemon's WebSocket
+// stream (e.g. ws://localhost:9362/events/ws, or wss:// over TLS), for
+// networks whose proxies buffer server-sent events.
+func SubscribeWebSocket(ctx context.Context, url string, handle func(Event)) error {
+	for {
+		err := subscribeWebSocketOnce(ctx, url, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, ok := err.(*StatusError); ok {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func subscribeWebSocketOnce(ctx context.Context, rawURL string, handle func(Event)) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	case "wss":
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", host)
+	default:
+		return fmt.Errorf("unsupported WebSocket URL scheme %q, want ws or wss", u.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Cancelling ctx unblocks the reads below.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.URL.Scheme = "http"
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return &StatusError{Status: resp.Status}
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return fmt.Errorf("invalid WebSocket handshake from %s", u.Host)
+	}
+
+	var message []byte
+	for {
+		fin, opcode, payload, err := readFrame(r)
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case wsPing:
+			if err := writeFrame(conn, wsPong, payload); err != nil {
+				return err
+			}
+		case wsClose:
+			writeFrame(conn, wsClose, nil)
+			return errWebsocketClosed
+		case wsText, wsContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebsocketMessage {
+				return fmt.Errorf("WebSocket message of over %d bytes", maxWebsocketMessage)
+			}
+			if !fin {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal(message, &ev); err == nil {
+				handle(ev)
+			}
+			message = message[:0]
+		}
+	}
+}
+
+// readFrame reads one unmasked frame, as servers send them.
+func readFrame(r *bufio.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0F
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxWebsocketMessage {
+		return false, 0, nil, fmt.Errorf("WebSocket frame of %d bytes is too large", n)
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a masked control frame, as clients must send them.
+func writeFrame(conn net.Conn, opcode byte, payload []byte) error {
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := conn.Write(frame)
+	return err
+}
@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeWebSocket(t *testing.T) {
+	pong := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/ws" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+		// A ping, then an event split across two frames.
+		rw.Write([]byte{0x80 | wsPing, 2, 'h', 'i'})
+		msg := `{"type":"band-change","band":"20m"}`
+		rw.Write(append([]byte{wsText, 10}, msg[:10]...))
+		rw.Write(append([]byte{0x80 | wsContinuation, byte(len(msg) - 10)}, msg[10:]...))
+		rw.Flush()
+
+		_, _, payload, err := readMaskedFrame(rw.Reader)
+		if err == nil {
+			pong <- payload
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	got := make(chan Event, 1)
+	go SubscribeWebSocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/events/ws", func(ev Event) { got <- ev })
+
+	select {
+	case ev := <-got:
+		if ev.Type != EventBandChange || ev.Band != "20m" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("no event")
+	}
+	select {
+	case payload := <-pong:
+		if string(payload) != "hi" {
+			t.Errorf("pong = %q", payload)
+		}
+	case <-ctx.Done():
+		t.Fatal("no pong")
+	}
+
+	err := SubscribeWebSocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/missing", func(Event) {})
+	if _, ok := err.(*StatusError); !ok {
+		t.Errorf("refused stream = %v", err)
+	}
+}
+
+// readMaskedFrame reads a short frame from the client, unmasking it.
+func readMaskedFrame(r *bufio.Reader) (bool, byte, []byte, error) {
+	var head [6]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	data := make([]byte, head[1]&0x7F)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range data {
+		data[i] ^= head[2+i%4]
+	}
+	return head[0]&0x80 != 0, head[0] & 0x0F, data, nil
+}
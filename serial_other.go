@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func openSerial(path string, baud int) (*os.File, error) {
+	return nil, fmt.Errorf("serial ports are unsupported on this system")
+}
@@ -0,0 +1,53 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// baudRates are the serial speeds openSerial supports.
+var baudRates = map[int]uint64{
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+// openSerial opens a serial port at baud, 8N1 without flow control or any
+// processing of the bytes written.
+func openSerial(path string, baud int) (*os.File, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	// O_NONBLOCK keeps the open from waiting for carrier detect; Fd puts
+	// the port back into blocking mode.
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	var t syscall.Termios
+	if err := ioctl(f.Fd(), ioctlGetTermios, unsafe.Pointer(&t)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a serial port: %v", path, err)
+	}
+	t.Iflag, t.Oflag, t.Lflag = 0, 0, 0
+	t.Cflag = syscall.CS8 | syscall.CREAD | syscall.CLOCAL
+	// Reads return what has arrived after at most a second of silence, so a
+	// device that doesn't answer can't hang its driver.
+	t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 0, 10
+	setSpeed(&t, speed)
+	if err := ioctl(f.Fd(), ioctlSetTermios, unsafe.Pointer(&t)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to configure %s: %v", path, err)
+	}
+	return f, nil
+}
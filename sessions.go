@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adifModes maps the start of a mode name as fldigi or the rig reports it
+// to the ADIF MODE it belongs to. With submode set the name itself is the
+// ADIF SUBMODE, as with BPSK31; otherwise fldigi's spelling of the variant,
+// such as OLIVIA-8-500, isn't ADIF's and is left out. Other modes are
+// written as read.
+var adifModes = []struct {
+	prefix, mode string
+	submode      bool
+}{
+	{"USB", "SSB", true},
+	{"LSB", "SSB", true},
+	{"BPSK", "PSK", true},
+	{"QPSK", "PSK", true},
+	{"PSK", "PSK", true},
+	{"MFSK", "MFSK", true},
+	{"FT4", "MFSK", true},
+	{"JS8", "MFSK", true},
+	{"THOR", "THOR", true},
+	{"DOMINOEX", "DOMINO", true},
+	{"OLIVIA", "OLIVIA", false},
+	{"CONTESTIA", "CONTESTIA", false},
+}
+
+// adifMode returns the ADIF MODE and SUBMODE for a mode name.
+func adifMode(mode string) (string, string) {
+	mode = strings.ToUpper(strings.TrimSpace(mode))
+	for _, m := range adifModes {
+		if strings.HasPrefix(mode, m.prefix) {
+			if m.submode {
+				return m.mode, mode
+			}
+			return m.mode, ""
+		}
+	}
+	return mode, ""
+}
+
+// adifField formats one ADIF field, or nothing for an empty value.
+func adifField(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf("<%s:%d>%s ", name, len(value), value)
+}
+
+// writeSessionsADIF writes sessions as ADIF records. They have no CALL, as
+// they record time on the air rather than contacts.
+func writeSessionsADIF(w io.Writer, spans []bandSpan, call string, now time.Time) error {
+	var b strings.Builder
+	b.WriteString(adifHeader("fldigi-cmd operating sessions", now))
+	for _, sp := range spans {
+		mode, submode := adifMode(sp.Mode)
+		start, end := sp.Start.UTC(), sp.End.UTC()
+		b.WriteString(adifField("QSO_DATE", start.Format("20060102")))
+		b.WriteString(adifField("TIME_ON", start.Format("150405")))
+		b.WriteString(adifField("QSO_DATE_OFF", end.Format("20060102")))
+		b.WriteString(adifField("TIME_OFF", end.Format("150405")))
+		b.WriteString(adifField("BAND", strings.ToLower(sp.Band)))
+		b.WriteString(adifField("MODE", mode))
+		b.WriteString(adifField("SUBMODE", submode))
+		if sp.Freq != 0 {
+			b.WriteString(adifField("FREQ", strconv.FormatFloat(sp.Freq/1000000, 'f', 6, 64)))
+		}
+		b.WriteString(adifField("STATION_CALLSIGN", call))
+		b.WriteString(adifField("APP_FLDIGI-CMD_RADIO", sp.Radio))
+		b.WriteString(adifField("APP_FLDIGI-CMD_SECONDS", strconv.Itoa(int(end.Sub(start).Round(time.Second).Seconds()))))
+		b.WriteString("<EOR>\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSessionsCSV writes sessions as CSV with times in RFC 3339.
+func writeSessionsCSV(w io.Writer, spans []bandSpan) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"radio", "band", "mode", "start", "end", "seconds", "freq"})
+	for _, sp := range spans {
+		cw.Write([]string{sp.Radio, sp.Band, sp.Mode, sp.Start.UTC().Format(time.RFC3339), sp.End.UTC().Format(time.RFC3339), strconv.Itoa(int(sp.End.Sub(sp.Start).Round(time.Second).Seconds())), strconv.FormatFloat(sp.Freq, 'f', 0, 64)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// runSessions implements `fldigi-cmd sessions`: export the time spent on
+// each band and mode as ADIF or CSV records, to check against a logbook.
+func runSessions(args []string) int {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	path, radio, since := addHistoryFlags(fs)
+	var until, format, output, call string
+	var minDuration time.Duration
+	fs.StringVar(&until, "until", "", "only export time before a duration ago, a date or an RFC 3339 time")
+	fs.StringVar(&format, "format", "adif", "output format: adif or csv")
+	fs.StringVar(&output, "o", "", "file to write to instead of standard output")
+	fs.StringVar(&call, "call", "", "station callsign to put in each ADIF record")
+	fs.DurationVar(&minDuration, "min-duration", 0, "leave out sessions shorter than this, such as bands passed while tuning")
+	fs.Parse(args)
+
+	if format != "adif" && format != "csv" {
+		fmt.Fprintf(os.Stderr, "Error: --format must be adif or csv\n")
+		return 2
+	}
+	now := time.Now()
+	from, err := parseSince(*since, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	to, err := parseSince(until, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	events, err := loadHistory(*path, *radio)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	var spans []bandSpan
+	for _, sp := range clipSpans(bandSpans(events, now), from, to) {
+		if sp.End.Sub(sp.Start) >= minDuration {
+			spans = append(spans, sp)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "csv" {
+		err = writeSessionsCSV(w, spans)
+	} else {
+		err = writeSessionsADIF(w, spans, strings.ToUpper(call), now)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d sessions to %s\n", len(spans), output)
+	}
+	return 0
+}
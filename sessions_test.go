@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdifMode(t *testing.T) {
+	for mode, want := range map[string][2]string{
+		"USB":          {"SSB", "USB"},
+		"BPSK31":       {"PSK", "BPSK31"},
+		"ft4":          {"MFSK", "FT4"},
+		"OLIVIA-8-500": {"OLIVIA", ""},
+		"FT8":          {"FT8", ""},
+		"CW":           {"CW", ""},
+	} {
+		if m, sub := adifMode(mode); m != want[0] || sub != want[1] {
+			t.Errorf("adifMode(%q) = %q, %q, want %q, %q", mode, m, sub, want[0], want[1])
+		}
+	}
+}
+
+func TestWriteSessions(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	spans := []bandSpan{{Radio: "A", Band: "20m", Mode: "USB", Start: start, End: start.Add(90 * time.Minute), Freq: 14250000}}
+
+	var adif strings.Builder
+	if err := writeSessionsADIF(&adif, spans, "N0CALL", start); err != nil {
+		t.Fatal(err)
+	}
+	want := "<QSO_DATE:8>20260301 <TIME_ON:6>120000 <QSO_DATE_OFF:8>20260301 <TIME_OFF:6>133000 <BAND:3>20m <MODE:3>SSB <SUBMODE:3>USB <FREQ:9>14.250000 <STATION_CALLSIGN:6>N0CALL <APP_FLDIGI-CMD_RADIO:1>A <APP_FLDIGI-CMD_SECONDS:4>5400 <EOR>\n"
+	if !strings.Contains(adif.String(), "<EOH>\n") || !strings.HasSuffix(adif.String(), want) {
+		t.Errorf("ADIF =\n%s\nwant record\n%s", adif.String(), want)
+	}
+
+	var csv strings.Builder
+	if err := writeSessionsCSV(&csv, spans); err != nil {
+		t.Fatal(err)
+	}
+	if want := "radio,band,mode,start,end,seconds,freq\nA,20m,USB,2026-03-01T12:00:00Z,2026-03-01T13:30:00Z,5400,14250000\n"; csv.String() != want {
+		t.Errorf("CSV = %q", csv.String())
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix && !windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// shellCommand fails: there is no shell to run a command line with here.
+func shellCommand(line string, ev Event) (string, []string, []string, error) {
+	return "", nil, nil, fmt.Errorf("command lines can't be run on %s", runtime.GOOS)
+}
+
+func setShellCommandLine(cmd *exec.Cmd) {}
+
+// shellQuote double-quotes a value shown as part of a command line.
+func shellQuote(v string) string {
+	return `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+}
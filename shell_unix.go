@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// shellCommand returns the program and arguments that run a command line
+// through the shell, with the event's values substituted for its
+// placeholders, and any environment variables the line needs.
+func shellCommand(line string, ev Event) (string, []string, []string, error) {
+	return "/bin/sh", []string{"-c", expandTemplateQuoted(line, ev, shellQuote)}, nil, nil
+}
+
+// setShellCommandLine does nothing here, where the shell gets its command
+// line as an argument.
+func setShellCommandLine(cmd *exec.Cmd) {}
+
+// shellQuote quotes a value substituted into a shell command line so the
+// shell reads it as one word, whatever it contains.
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
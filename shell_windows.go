@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// shellCommand returns the program and arguments that run a command line
+// through cmd.exe. cmd.exe has no quoting that keeps every value from being
+// parsed, so the event's values are passed in environment variables that
+// the line reads with delayed expansion, which comes after cmd.exe has
+// parsed the line for &, |, % and quotes.
+func shellCommand(line string, ev Event) (string, []string, []string, error) {
+	line, env, err := expandTemplateEnv(line, ev, func(name string) string { return `"!` + name + `!"` })
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return "cmd", []string{"/V:ON", "/S", "/C", line}, env, nil
+}
+
+// setShellCommandLine passes a command line from shellCommand to cmd.exe as
+// it is. Go would quote it for programs that unquote their arguments the
+// usual way, which cmd.exe doesn't.
+func setShellCommandLine(cmd *exec.Cmd) {
+	if len(cmd.Args) == 5 && cmd.Args[0] == "cmd" && cmd.Args[1] == "/V:ON" {
+		cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /V:ON /S /C "` + cmd.Args[4] + `"`}
+	}
+}
+
+// shellQuote double-quotes a value shown as part of a command line, such as
+// the command printed by bundle import.
+func shellQuote(v string) string {
+	return `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+}
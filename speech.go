@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// spokenPhrases are the announcements of events spoken without a message
+// template.
+var spokenPhrases = map[string]string{
+	EventBandChange:       "Band changed to {band}",
+	EventInitialBand:      "On {band}",
+	EventModeChange:       "Mode {mode}",
+	EventWatch:            "Heard {call}",
+	EventConnected:        "Rig connected",
+	EventDisconnected:     "Rig connection lost",
+	EventPrivilegeWarning: "Outside your privileges on {band}",
+	EventOutOfBand:        "Out of band",
+	EventVerifyFailed:     "Rig didn't follow the band change",
+	EventQSOLogged:        "Logged {call}",
+	EventDXSpot:           "{call} spotted on {band}",
+}
+
+// spokenPhrase is the announcement of ev: its phrase, or its type in words.
+func spokenPhrase(ev Event) string {
+	if phrase, ok := spokenPhrases[ev.Type]; ok {
+		return expandTemplate(phrase, ev)
+	}
+	return strings.ReplaceAll(ev.Type, "-", " ")
+}
+
+var (
+	spokenBandName = regexp.MustCompile(`\b(\d+(?:\.\d+)?)(c?m)\b`)
+	spokenDecimal  = regexp.MustCompile(`\b(\d+)\.(\d*[1-9])?0+\b`)
+	spokenUnits    = strings.NewReplacer("MHz", "megahertz", "kHz", "kilohertz", "Hz", "hertz")
+)
+
+// speakable rewrites text for a speech engine: band names such as 20m
+// become "twenty meters", and zeros padding frequencies are dropped.
+func speakable(text string) string {
+	text = spokenBandName.ReplaceAllStringFunc(text, func(band string) string {
+		m := spokenBandName.FindStringSubmatch(band)
+		unit := "meters"
+		if m[2] == "cm" {
+			unit = "centimeters"
+		}
+		return numberWords(m[1]) + " " + unit
+	})
+	text = spokenDecimal.ReplaceAllStringFunc(text, func(n string) string {
+		return strings.TrimSuffix(strings.TrimRight(n, "0"), ".")
+	})
+	return spokenUnits.Replace(text)
+}
+
+var (
+	smallNumbers = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+		"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	tensNumbers = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+)
+
+// numberWords spells out a number such as 160 or 1.25 in words, or returns
+// it as it is if it is too large to spell.
+func numberWords(s string) string {
+	whole, fraction, _ := strings.Cut(s, ".")
+	n, err := strconv.Atoi(whole)
+	if err != nil || n >= 10000 {
+		return s
+	}
+	words := integerWords(n)
+	if fraction != "" {
+		words += " point"
+		for _, d := range fraction {
+			words += " " + smallNumbers[d-'0']
+		}
+	}
+	return words
+}
+
+func integerWords(n int) string {
+	switch {
+	case n < 20:
+		return smallNumbers[n]
+	case n < 100:
+		if n%10 == 0 {
+			return tensNumbers[n/10]
+		}
+		return tensNumbers[n/10] + "-" + smallNumbers[n%10]
+	case n < 1000:
+		if n%100 == 0 {
+			return smallNumbers[n/100] + " hundred"
+		}
+		return smallNumbers[n/100] + " hundred " + integerWords(n%100)
+	}
+	if n%1000 == 0 {
+		return smallNumbers[n/1000] + " thousand"
+	}
+	return smallNumbers[n/1000] + " thousand " + integerWords(n%1000)
+}
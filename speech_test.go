@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestSpeakable(t *testing.T) {
+	for text, want := range map[string]string{
+		"Band changed to 20m":          "Band changed to twenty meters",
+		"On 160m at 1.840000 MHz":      "On one hundred sixty meters at 1.84 megahertz",
+		"QRV 70cm 432.100000 MHz":      "QRV seventy centimeters 432.1 megahertz",
+		"1.25m and 2200m":              "one point two five meters and two thousand two hundred meters",
+		"Tuned to 14.000000 MHz":       "Tuned to 14 megahertz",
+		"Heard K1ABC":                  "Heard K1ABC",
+		"Carrier 1500 Hz":              "Carrier 1500 hertz",
+		"Spotted on 7074.0 kHz by 17m": "Spotted on 7074 kilohertz by seventeen meters",
+	} {
+		if got := speakable(text); got != want {
+			t.Errorf("speakable(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSpokenPhrase(t *testing.T) {
+	if got := speakable(spokenPhrase(Event{Type: EventBandChange, Band: "40m"})); got != "Band changed to forty meters" {
+		t.Errorf("band change = %q", got)
+	}
+	if got := spokenPhrase(Event{Type: EventHookTimeout}); got != "hook timeout" {
+		t.Errorf("hook timeout = %q", got)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The spotting APIs, variables so tests can point them elsewhere.
+var (
+	potaSpotURL = "https://api.pota.app/spot/"
+	sotaSpotURL = "https://api2.sota.org.uk/api/spots"
+)
+
+// selfSpot is a spot of the operator's own activation.
+type selfSpot struct {
+	Call      string
+	Reference string
+	Freq      float64 // Hz
+	Mode      string
+	Comment   string
+}
+
+// potaMode is the mode as POTA spots show it: SSB for either sideband,
+// otherwise the mode as read, such as CW or FT8.
+func potaMode(mode string) string {
+	mode = strings.ToUpper(mode)
+	if mode == "USB" || mode == "LSB" {
+		return "SSB"
+	}
+	return mode
+}
+
+// sotaMode is the SOTAwatch mode: cw, ssb, fm, am or data.
+func sotaMode(mode string) string {
+	switch mode = strings.ToUpper(mode); {
+	case mode == "FM" || mode == "AM":
+		return strings.ToLower(mode)
+	case modeClass(mode) == "cw":
+		return "cw"
+	case modeClass(mode) == "phone":
+		return "ssb"
+	case mode == "":
+		return "other"
+	}
+	return "data"
+}
+
+// potaRequest is the POTA spot request.
+func potaRequest(s selfSpot) (*http.Request, error) {
+	body, _ := json.Marshal(map[string]string{
+		"activator": s.Call,
+		"spotter":   s.Call,
+		"reference": s.Reference,
+		"frequency": strconv.FormatFloat(s.Freq/1000, 'f', 1, 64),
+		"mode":      potaMode(s.Mode),
+		"comments":  s.Comment,
+		"source":    "fldigi-cmd",
+	})
+	req, err := http.NewRequest(http.MethodPost, potaSpotURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// sotaRequest is the SOTAwatch spot request, made with the tokens of a
+// SOTA single sign-on session.
+func sotaRequest(s selfSpot, token, idToken string) (*http.Request, error) {
+	association, summit, ok := strings.Cut(s.Reference, "/")
+	if !ok || association == "" || summit == "" {
+		return nil, fmt.Errorf("invalid summit %q, want a reference such as W7W/LC-001", s.Reference)
+	}
+	body, _ := json.Marshal(map[string]string{
+		"associationCode":   association,
+		"summitCode":        summit,
+		"activatorCallsign": s.Call,
+		"frequency":         strconv.FormatFloat(s.Freq/1000000, 'f', 4, 64),
+		"mode":              sotaMode(s.Mode),
+		"comments":          s.Comment,
+		"type":              "NORMAL",
+	})
+	req, err := http.NewRequest(http.MethodPost, sotaSpotURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("id_token", idToken)
+	return req, nil
+}
+
+// runSpot implements `fldigi-cmd spot`: post a self-spot for a POTA or
+// SOTA activation at the current frequency and mode.
+func runSpot(args []string) int {
+	fs := flag.NewFlagSet("spot", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	forceDirect, connectRig, _ := addDirectFlags(fs)
+	var call, park, summit, comment, mode, radio, sotaToken, sotaIDToken string
+	var freqKHz float64
+	fs.StringVar(&call, "call", os.Getenv("FLDIGI_CALL"), "activator callsign (default $FLDIGI_CALL)")
+	fs.StringVar(&park, "pota", "", "POTA park reference to spot, e.g. K-1234")
+	fs.StringVar(&summit, "sota", "", "SOTA summit reference to spot, e.g. W7W/LC-001")
+	fs.StringVar(&comment, "comment", "", "spot comment")
+	fs.StringVar(&mode, "mode", "", "mode to spot instead of the one read from the rig")
+	fs.Float64Var(&freqKHz, "freq", 0, "frequency in kHz to spot instead of the one read from the rig")
+	fs.StringVar(&radio, "radio", "", "radio whose frequency to spot, with several")
+	fs.StringVar(&sotaToken, "sota-token", os.Getenv("FLDIGI_SOTA_TOKEN"), "SOTA single sign-on access token (default $FLDIGI_SOTA_TOKEN)")
+	fs.StringVar(&sotaIDToken, "sota-id-token", os.Getenv("FLDIGI_SOTA_ID_TOKEN"), "SOTA single sign-on ID token (default $FLDIGI_SOTA_ID_TOKEN)")
+	fs.BoolVar(&dryRun, "dry-run", false, "print the spot instead of posting it")
+	fs.Parse(args)
+
+	if (park == "") == (summit == "") {
+		fmt.Fprintf(os.Stderr, "Error: give one of --pota or --sota\n")
+		return 2
+	}
+	if call == "" {
+		fmt.Fprintf(os.Stderr, "Error: --call or FLDIGI_CALL is required\n")
+		return 2
+	}
+	if summit != "" && !dryRun && (sotaToken == "" || sotaIDToken == "") {
+		fmt.Fprintf(os.Stderr, "Error: --sota needs --sota-token and --sota-id-token\n")
+		return 2
+	}
+
+	s := selfSpot{Call: strings.ToUpper(call), Reference: strings.ToUpper(park + summit), Freq: freqKHz * 1000, Mode: mode, Comment: comment}
+	if s.Freq == 0 || s.Mode == "" {
+		status, err := spotStatus(*forceDirect, connect, connectRig, radio)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if s.Freq == 0 {
+			s.Freq = status.Freq
+		}
+		if s.Mode == "" {
+			s.Mode = status.Mode
+		}
+	}
+
+	var req *http.Request
+	var err error
+	if park != "" {
+		req, err = potaRequest(s)
+	} else {
+		req, err = sotaRequest(s, sotaToken, sotaIDToken)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if dryRun {
+		body, _ := io.ReadAll(req.Body)
+		dryRunf("would post to %s: %s", req.URL, body)
+		return 0
+	}
+	if err := postSpot(req); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Spotted %s at %s on %.1f kHz %s\n", s.Call, s.Reference, s.Freq/1000, s.Mode)
+	return 0
+}
+
+// spotStatus reads the frequency and mode to spot, from the daemon or the
+// rig itself.
+func spotStatus(forceDirect bool, connect func() *apiClient, connectRig func() (Backend, error), radio string) (MonitorStatus, error) {
+	var status []MonitorStatus
+	err := viaDaemon(forceDirect, func() error {
+		return connect().do(http.MethodGet, "/status", nil, &status)
+	}, func() error {
+		s, err := directStatus(connectRig)
+		status = []MonitorStatus{s}
+		return err
+	})
+	if err != nil {
+		return MonitorStatus{}, err
+	}
+	for _, s := range status {
+		if radio != "" && s.Radio != radio {
+			continue
+		}
+		if s.Error != "" {
+			return MonitorStatus{}, fmt.Errorf("can't read the rig: %s", s.Error)
+		}
+		if len(status) > 1 && radio == "" {
+			return MonitorStatus{}, fmt.Errorf("several radios are monitored; choose one with --radio")
+		}
+		return s, nil
+	}
+	return MonitorStatus{}, fmt.Errorf("no radio %q", radio)
+}
+
+func postSpot(req *http.Request) error {
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post spot: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
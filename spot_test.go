@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSpotPOTA(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	defer func(u string) { potaSpotURL = u }(potaSpotURL)
+	potaSpotURL = srv.URL
+
+	if code := runSpot([]string{"--call", "n0call", "--pota", "k-1234", "--freq", "14074", "--mode", "USB", "--comment", "QRT 1500Z"}); code != 0 {
+		t.Fatalf("runSpot() = %d", code)
+	}
+	want := map[string]string{"activator": "N0CALL", "spotter": "N0CALL", "reference": "K-1234", "frequency": "14074.0", "mode": "SSB", "comments": "QRT 1500Z", "source": "fldigi-cmd"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if code := runSpot([]string{"--call", "n0call", "--pota", "K-1234", "--sota", "W7W/LC-001"}); code != 2 {
+		t.Errorf("runSpot() with --pota and --sota = %d", code)
+	}
+}
+
+func TestSotaRequest(t *testing.T) {
+	req, err := sotaRequest(selfSpot{Call: "N0CALL", Reference: "W7W/LC-001", Freq: 14062000, Mode: "CW"}, "access", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]string
+	json.NewDecoder(req.Body).Decode(&body)
+	if body["associationCode"] != "W7W" || body["summitCode"] != "LC-001" || body["frequency"] != "14.0620" || body["mode"] != "cw" {
+		t.Errorf("body = %v", body)
+	}
+	if req.Header.Get("Authorization") != "bearer access" || req.Header.Get("id_token") != "id" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if _, err := sotaRequest(selfSpot{Reference: "LC-001"}, "a", "b"); err == nil {
+		t.Error("sotaRequest accepted a summit without an association")
+	}
+	for mode, want := range map[string]string{"LSB": "ssb", "FM": "fm", "BPSK31": "data", "CW": "cw", "": "other"} {
+		if got := sotaMode(mode); got != want {
+			t.Errorf("sotaMode(%q) = %q, want %q", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// activityStats sums up the spans on a band, a mode or both.
+type activityStats struct {
+	Band     string        `json:"band,omitempty"`
+	Mode     string        `json:"mode,omitempty"`
+	Time     time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+	QSYs     int           `json:"qsys"`
+	LastFreq float64       `json:"last_freq"`
+	LastSeen time.Time     `json:"last_seen"`
+}
+
+// summarize totals the time on and changes to each band, mode, or band and
+// mode pair, busiest first.
+func summarize(spans []bandSpan, byBand, byMode bool) []activityStats {
+	type key struct{ band, mode string }
+	groups := map[key]*activityStats{}
+	var stats []*activityStats
+	for _, sp := range spans {
+		var k key
+		if byBand {
+			k.band = sp.Band
+		}
+		if byMode {
+			k.mode = sp.Mode
+		}
+		a, ok := groups[k]
+		if !ok {
+			a = &activityStats{Band: k.band, Mode: k.mode}
+			groups[k] = a
+			stats = append(stats, a)
+		}
+		a.Time += sp.End.Sub(sp.Start)
+		if sp.QSY {
+			a.QSYs++
+		}
+		if !sp.End.Before(a.LastSeen) {
+			a.LastSeen, a.LastFreq = sp.End, sp.Freq
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Time > stats[j].Time })
+	out := make([]activityStats, len(stats))
+	for i, a := range stats {
+		a.Time = a.Time.Round(time.Second)
+		a.Seconds = a.Time.Seconds()
+		out[i] = *a
+	}
+	return out
+}
+
+// summarizeBands totals the time on and changes to each band.
+func summarizeBands(spans []bandSpan) []activityStats {
+	return summarize(spans, true, false)
+}
+
+// runStats implements `fldigi-cmd stats`: operating time per band and
+// mode over a period, with the number of changes to each band and the
+// frequency last recorded there.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	path, radio, since := addHistoryFlags(fs)
+	var until, by, format string
+	fs.StringVar(&until, "until", "", "only count time before a duration ago, a date or an RFC 3339 time")
+	fs.StringVar(&by, "by", "band", "group by band, mode or band,mode")
+	fs.StringVar(&format, "format", "text", "output format: text, csv or json")
+	fs.Parse(args)
+
+	var byBand, byMode bool
+	switch by {
+	case "band":
+		byBand = true
+	case "mode":
+		byMode = true
+	case "band,mode", "mode,band":
+		byBand, byMode = true, true
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --by must be band, mode or band,mode\n")
+		return 2
+	}
+	if format != "text" && format != "csv" && format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: --format must be text, csv or json\n")
+		return 2
+	}
+	now := time.Now()
+	from, err := parseSince(*since, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	to, err := parseSince(until, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	events, err := loadHistory(*path, *radio)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	// Spans are worked out from the whole history, so the band in use when
+	// the period starts is counted from its start.
+	stats := summarize(clipSpans(bandSpans(events, now), from, to), byBand, byMode)
+
+	switch format {
+	case "json":
+		if stats == nil {
+			stats = []activityStats{}
+		}
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(data))
+	case "csv":
+		writeStatsCSV(os.Stdout, stats)
+	default:
+		if len(stats) == 0 {
+			fmt.Println("No band activity recorded")
+			return 0
+		}
+		for _, line := range statsReport(stats, byBand, byMode) {
+			fmt.Println(line)
+		}
+	}
+	return 0
+}
+
+// statsReport lays out statistics as an aligned table with a total.
+func statsReport(stats []activityStats, byBand, byMode bool) []string {
+	row := func(band, mode string, a activityStats) []string {
+		var r []string
+		if byBand {
+			r = append(r, band)
+		}
+		if byMode {
+			r = append(r, mode)
+		}
+		r = append(r, a.Time.String(), strconv.Itoa(a.QSYs))
+		if !a.LastSeen.IsZero() {
+			r = append(r, fmt.Sprintf("%.6f MHz", a.LastFreq/1000000), a.LastSeen.Local().Format("2006-01-02 15:04"))
+		}
+		return r
+	}
+
+	labels := 0
+	var header []string
+	if byBand {
+		header = append(header, "BAND")
+		labels++
+	}
+	if byMode {
+		header = append(header, "MODE")
+		labels++
+	}
+	rows := [][]string{append(header, "TIME", "QSYS", "RECORDED FREQ", "LAST SEEN")}
+	var total activityStats
+	for _, a := range stats {
+		rows = append(rows, row(a.Band, a.Mode, a))
+		total.Time += a.Time
+		total.QSYs += a.QSYs
+	}
+	if byMode && !byBand {
+		rows = append(rows, row("", "TOTAL", total))
+	} else {
+		rows = append(rows, row("TOTAL", "", total))
+	}
+	return alignColumns(rows, labels)
+}
+
+// alignColumns pads each column to its widest cell. The first labels
+// columns and the last are left-aligned, the numbers between right-aligned.
+func alignColumns(rows [][]string, labels int) []string {
+	var widths []int
+	for _, r := range rows {
+		for i, cell := range r {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	var lines []string
+	for _, r := range rows {
+		var b strings.Builder
+		for i, cell := range r {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			if i < labels || i == len(widths)-1 {
+				fmt.Fprintf(&b, "%-*s", widths[i], cell)
+			} else {
+				fmt.Fprintf(&b, "%*s", widths[i], cell)
+			}
+		}
+		lines = append(lines, strings.TrimRight(b.String(), " "))
+	}
+	return lines
+}
+
+// writeStatsCSV writes statistics as CSV with durations in seconds and
+// times in RFC 3339, for spreadsheets.
+func writeStatsCSV(w io.Writer, stats []activityStats) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"band", "mode", "seconds", "qsys", "last_freq", "last_seen"})
+	for _, a := range stats {
+		cw.Write([]string{a.Band, a.Mode, strconv.FormatFloat(a.Seconds, 'f', 0, 64), strconv.Itoa(a.QSYs), strconv.FormatFloat(a.LastFreq, 'f', 0, 64), a.LastSeen.UTC().Format(time.RFC3339)})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSpans() []bandSpan {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []bandSpan{
+		{Band: "20m", Mode: "USB", Start: t0, End: t0.Add(time.Hour), Freq: 14074000, QSY: true},
+		{Band: "20m", Mode: "CW", Start: t0.Add(time.Hour), End: t0.Add(90 * time.Minute), Freq: 14025000},
+		{Band: "40m", Mode: "USB", Start: t0.Add(90 * time.Minute), End: t0.Add(2 * time.Hour), Freq: 7074000, QSY: true},
+	}
+}
+
+func TestSummarizeByMode(t *testing.T) {
+	stats := summarize(testSpans(), false, true)
+	var got []string
+	for _, a := range stats {
+		got = append(got, a.Mode+" "+a.Time.String())
+	}
+	if want := []string{"USB 1h30m0s", "CW 30m0s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("by mode = %v, want %v", got, want)
+	}
+	if len(summarize(testSpans(), true, true)) != 3 {
+		t.Error("band,mode didn't keep the three pairs apart")
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	lines := statsReport(summarize(testSpans(), true, true), true, true)
+	want := []string{
+		"BAND   MODE    TIME  QSYS  RECORDED FREQ  LAST SEEN",
+		"20m    USB   1h0m0s     1  14.074000 MHz  " + time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC).Local().Format("2006-01-02 15:04"),
+	}
+	if len(lines) != 5 || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("report =\n%s", strings.Join(lines, "\n"))
+	}
+	if !strings.HasPrefix(lines[4], "TOTAL        2h0m0s     2") {
+		t.Errorf("total = %q", lines[4])
+	}
+}
+
+func TestWriteStatsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	writeStatsCSV(&buf, summarize(testSpans(), true, false))
+	want := "band,mode,seconds,qsys,last_freq,last_seen\n20m,,5400,1,14025000,2026-03-01T13:30:00Z\n40m,,1800,1,7074000,2026-03-01T14:00:00Z\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
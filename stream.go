@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// EventStream publishes events to HTTP clients as server-sent events and
+// keeps the most recent ones so clients can catch up on what happened
+// before they connected. Slow clients miss events rather than holding up
+// the monitor.
+type EventStream struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+
+	// history is a ring buffer of the last cap(history) events; next is
+	// where the following event goes once it is full.
+	history []Event
+	next    int
+}
+
+// NewEventStream creates a stream that remembers the last size events.
+func NewEventStream(size int) *EventStream {
+	return &EventStream{
+		subscribers: map[chan Event]struct{}{},
+		history:     make([]Event, 0, size),
+	}
+}
+
+func (s *EventStream) Name() string { return "events" }
+
+func (s *EventStream) Wants(ev Event) bool { return true }
+
+func (s *EventStream) Handle(ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.history) < cap(s.history) {
+		s.history = append(s.history, ev)
+	} else if cap(s.history) > 0 {
+		s.history[s.next] = ev
+		s.next = (s.next + 1) % cap(s.history)
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	return nil
+}
+
+// Recent returns up to n of the latest events, oldest first.
+func (s *EventStream) Recent(n int) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recent(n)
+}
+
+func (s *EventStream) recent(n int) []Event {
+	ordered := append(append([]Event{}, s.history[s.next:]...), s.history[:s.next]...)
+	if n >= 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// subscribe registers a subscriber, queueing the last replay events first so
+// nothing is missed or repeated between the replay and live events.
+func (s *EventStream) subscribe(replay int) chan Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backlog := s.recent(replay)
+	ch := make(chan Event, 64+len(backlog))
+	for _, ev := range backlog {
+		ch <- ev
+	}
+	s.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (s *EventStream) unsubscribe(ch chan Event) {
+	s.mu.Lock()
+	delete(s.subscribers, ch)
+	s.mu.Unlock()
+}
+
+// ServeHTTP streams events, starting with the last ?replay=N recent ones.
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	replay, _ := strconv.Atoi(r.URL.Query().Get("replay"))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := s.subscribe(replay)
+	defer s.unsubscribe(ch)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// recentHandler serves the last ?n=N recent events (all by default) as a
+// JSON array.
+type recentHandler struct {
+	stream *EventStream
+}
+
+func (h recentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := -1
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.stream.Recent(n))
+}
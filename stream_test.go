@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fldigi-cmd/sdk"
+)
+
+func TestEventStreamSubscribe(t *testing.T) {
+	stream := NewEventStream(10)
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan sdk.Event, 1)
+	go sdk.Subscribe(ctx, server.URL, func(ev sdk.Event) {
+		received <- ev
+	})
+
+	// Wait for the subscriber to connect before publishing.
+	for {
+		stream.mu.Lock()
+		n := len(stream.subscribers)
+		stream.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("subscriber never connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stream.Handle(Event{Type: EventBandChange, Band: "20m", PrevBand: "40m", Freq: 14074000})
+
+	select {
+	case ev := <-received:
+		if ev.Type != sdk.EventBandChange || ev.Band != "20m" || ev.FreqMHz() != 14.074 {
+			t.Errorf("received %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("event not received")
+	}
+}
+
+func TestEventStreamHistory(t *testing.T) {
+	stream := NewEventStream(3)
+	for _, band := range []string{"80m", "40m", "20m", "15m"} {
+		stream.Handle(Event{Type: EventBandChange, Band: band})
+	}
+
+	recent := stream.Recent(-1)
+	if len(recent) != 3 || recent[0].Band != "40m" || recent[2].Band != "15m" {
+		t.Errorf("Recent(-1) = %+v", recent)
+	}
+	if recent := stream.Recent(1); len(recent) != 1 || recent[0].Band != "15m" {
+		t.Errorf("Recent(1) = %+v", recent)
+	}
+
+	// A replaying subscriber gets the backlog before live events.
+	ch := stream.subscribe(2)
+	stream.Handle(Event{Type: EventBandChange, Band: "10m"})
+	var bands []string
+	for i := 0; i < 3; i++ {
+		bands = append(bands, (<-ch).Band)
+	}
+	if bands[0] != "20m" || bands[1] != "15m" || bands[2] != "10m" {
+		t.Errorf("replayed %v", bands)
+	}
+}
+
+func TestEventStreamSubscribeWebSocket(t *testing.T) {
+	stream := NewEventStream(10)
+	stream.Handle(Event{Type: EventInitialBand, Band: "40m"})
+	server := httptest.NewServer(websocketHandler{stream})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan sdk.Event, 1)
+	go sdk.SubscribeWebSocket(ctx, "ws://"+server.Listener.Addr().String()+"/events/ws?replay=1", func(ev sdk.Event) {
+		received <- ev
+	})
+
+	select {
+	case ev := <-received:
+		if ev.Type != sdk.EventInitialBand || ev.Band != "40m" {
+			t.Errorf("received %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("event not received")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Supervisor starts fldigi when nothing answers on its XML-RPC port and
+// restarts it with exponential backoff whenever it exits.
+type Supervisor struct {
+	path string
+	args []string
+	addr string
+
+	// minBackoff is the first restart delay, doubled after each quick exit
+	// up to maxBackoff; a process that ran for longer than stable resets it.
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	stable     time.Duration
+	check      time.Duration
+
+	reachable func() bool
+	stop      chan struct{}
+}
+
+func NewSupervisor(path string, args []string, host string, port int) *Supervisor {
+	s := &Supervisor{
+		path:       path,
+		args:       args,
+		addr:       net.JoinHostPort(host, strconv.Itoa(port)),
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		stable:     time.Minute,
+		check:      5 * time.Second,
+		stop:       make(chan struct{}),
+	}
+	s.reachable = s.portOpen
+	return s
+}
+
+func (s *Supervisor) portOpen() bool {
+	conn, err := net.DialTimeout("tcp", s.addr, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// WaitReady waits up to timeout for the XML-RPC port to accept connections.
+func (s *Supervisor) WaitReady(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if s.reachable() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// Run supervises fldigi until Stop is called. An fldigi that is already
+// running and wasn't started here is left alone while it stays reachable.
+func (s *Supervisor) Run() {
+	backoff := s.minBackoff
+	for {
+		if s.reachable() {
+			if !s.sleep(s.check) {
+				return
+			}
+			continue
+		}
+
+		started := time.Now()
+		cmd := exec.Command(s.path, s.args...)
+		if err := cmd.Start(); err != nil {
+			log.Printf("Error starting %s: %v", s.path, err)
+		} else {
+			log.Printf("Started %s (pid %d)", s.path, cmd.Process.Pid)
+			err := cmd.Wait()
+			if err != nil {
+				log.Printf("%s exited: %v", s.path, err)
+			} else {
+				log.Printf("%s exited", s.path)
+			}
+		}
+
+		if time.Since(started) >= s.stable {
+			backoff = s.minBackoff
+		}
+		log.Printf("Restarting %s in %v", s.path, backoff)
+		if !s.sleep(backoff) {
+			return
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// Stop ends supervision; a running fldigi is not killed.
+func (s *Supervisor) Stop() {
+	close(s.stop)
+}
+
+func (s *Supervisor) sleep(d time.Duration) bool {
+	select {
+	case <-s.stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupervisorRestartsWithBackoff(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "starts")
+	s := NewSupervisor("sh", []string{"-c", "echo started >> " + log}, "127.0.0.1", 1)
+	s.minBackoff = 10 * time.Millisecond
+	s.maxBackoff = 40 * time.Millisecond
+	s.reachable = func() bool { return false }
+
+	go s.Run()
+	time.Sleep(300 * time.Millisecond)
+	s.Stop()
+
+	data, _ := os.ReadFile(log)
+	if starts := strings.Count(string(data), "started"); starts < 3 {
+		t.Errorf("started %d times, want at least 3", starts)
+	}
+}
+
+func TestSupervisorLeavesRunningFldigiAlone(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "starts")
+	s := NewSupervisor("sh", []string{"-c", "echo started >> " + log}, "127.0.0.1", 1)
+	s.check = 10 * time.Millisecond
+	s.reachable = func() bool { return true }
+
+	go s.Run()
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+
+	if _, err := os.Stat(log); err == nil {
+		t.Error("started fldigi although it was reachable")
+	}
+}
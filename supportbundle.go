@@ -0,0 +1,333 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rpcTrace, if set, records every request made to the rig.
+var rpcTrace *traceLog
+
+// traceLog is a transcript of rig requests and their responses.
+type traceLog struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// record adds a request to the transcript; it does nothing on a nil log.
+func (t *traceLog) record(addr, request, response string, elapsed time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(&t.buf, "=== %s %s (%v)\n> %s\n", time.Now().UTC().Format(time.RFC3339Nano), addr, elapsed.Round(time.Microsecond), strings.TrimSpace(request))
+	if err != nil {
+		fmt.Fprintf(&t.buf, "! %v\n\n", err)
+		return
+	}
+	fmt.Fprintf(&t.buf, "< %s\n\n", strings.TrimSpace(response))
+}
+
+// capability is one row of the support bundle's capability matrix.
+type capability struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// probeCapabilities tries each read the daemon may make of the rig. Nothing
+// is changed on the rig.
+func probeCapabilities(rig Backend) []capability {
+	var caps []capability
+	check := func(name string, get func() (string, error)) {
+		value, err := get()
+		if err != nil {
+			caps = append(caps, capability{Name: name, Detail: err.Error()})
+			return
+		}
+		caps = append(caps, capability{Name: name, OK: true, Detail: value})
+	}
+	unsupported := func(name string) {
+		caps = append(caps, capability{Name: name, Detail: "not supported by the " + rig.Name() + " backend"})
+	}
+	float := func(get func() (float64, error)) func() (string, error) {
+		return func() (string, error) {
+			v, err := get()
+			return strconv.FormatFloat(v, 'f', -1, 64), err
+		}
+	}
+
+	check("frequency", float(rig.GetFrequency))
+	check("mode", rig.GetMode)
+	if tx, ok := rig.(TXController); ok {
+		check("tx state", tx.GetTRXState)
+	} else {
+		unsupported("tx state")
+	}
+	if fc, ok := rig.(*FldigiClient); ok {
+		check("carrier", float(fc.GetCarrier))
+		check("sideband", fc.GetSideband)
+		check("signal frequency", float(fc.GetSignalFrequency))
+		check("quality", float(fc.GetQuality))
+		check("decoded text", func() (string, error) {
+			text, err := fc.GetRXData()
+			return fmt.Sprintf("%d bytes", len(text)), err
+		})
+	} else {
+		for _, name := range []string{"carrier", "sideband", "signal frequency", "quality", "decoded text"} {
+			unsupported(name)
+		}
+	}
+	return caps
+}
+
+// rigVersion asks fldigi or flrig for its version.
+func rigVersion(rig Backend) (string, error) {
+	switch rig := rig.(type) {
+	case *FldigiClient:
+		v, err := rig.Call("fldigi.version")
+		return v.Text(), err
+	case *FlrigClient:
+		v, err := rig.rpc.Call("main.get_version")
+		return v.Text(), err
+	}
+	return "", fmt.Errorf("the %s backend doesn't report a version", rig.Name())
+}
+
+// supportReport is report.json in a support bundle.
+type supportReport struct {
+	Created      time.Time    `json:"created"`
+	Version      string       `json:"version"`
+	GoVersion    string       `json:"go_version"`
+	OS           string       `json:"os"`
+	Arch         string       `json:"arch"`
+	TimeZone     string       `json:"time_zone"`
+	Backend      string       `json:"backend"`
+	Address      string       `json:"address"`
+	RigVersion   string       `json:"rig_version,omitempty"`
+	RigError     string       `json:"rig_error,omitempty"`
+	Capabilities []capability `json:"capabilities,omitempty"`
+	Daemon       string       `json:"daemon"`
+	Redacted     int          `json:"redacted"`
+}
+
+// buildVersion describes the fldigi-cmd binary, e.g. "(devel) 1a2b3c4".
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision":
+			version += " " + s.Value
+		case s.Key == "vcs.modified" && s.Value == "true":
+			version += " (modified)"
+		}
+	}
+	return version
+}
+
+// secretEnv reports whether an environment variable probably holds a
+// secret, e.g. FLDIGI_API_TOKEN.
+func secretEnv(name string) bool {
+	for _, word := range []string{"TOKEN", "PASSWORD", "SECRET", "KEY"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// addEnvironment bundles the FLDIGI_* environment variables, scrubbed.
+func (b *bundleWriter) addEnvironment(environ []string) {
+	var lines []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "FLDIGI_") {
+			continue
+		}
+		if secretEnv(name) && value != "" {
+			value = redacted
+			b.manifest.Redacted++
+		}
+		lines = append(lines, name+"="+value)
+	}
+	sort.Strings(lines)
+	b.addText("environment.txt", []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// maxSupportLog is how much of the end of each --log file is bundled.
+const maxSupportLog = 1 << 20
+
+// writeZip stores the bundle's files as a zip archive.
+func (b *bundleWriter) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, name := range b.manifest.Files {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.manifest.Created}
+		hdr.SetMode(os.FileMode(b.modes[name]))
+		f, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(b.files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// runSupportBundle implements `fldigi-cmd support-bundle`: collect what a
+// maintainer needs to triage a bug report into one zip, with secrets
+// removed.
+func runSupportBundle(args []string) int {
+	fs := flag.NewFlagSet("support-bundle", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	var out, configPath, backendName, host string
+	var port int
+	var timeout time.Duration
+	var logs radioFlag
+	fs.StringVar(&out, "o", "fldigi-cmd-support.zip", "zip file to write")
+	fs.StringVar(&configPath, "config", os.Getenv("FLDIGI_CONFIG"), "config file to include")
+	fs.StringVar(&backendName, "backend", "", "rig backend to probe (default from FLDIGI_BACKEND or the config file, else fldigi)")
+	fs.StringVar(&host, "host", "", "backend host to probe (default from FLDIGI_HOST or the config file, else 127.0.0.1)")
+	fs.IntVar(&port, "port", 0, "backend port to probe (default from FLDIGI_PORT or the config file, else the backend default)")
+	fs.DurationVar(&timeout, "timeout", 5*time.Second, "time to wait for the rig and the daemon to answer")
+	fs.Var(&logs, "log", "log file of the daemon to include, e.g. its redirected output; repeat for each")
+	fs.Parse(args)
+
+	b := newBundleWriter()
+	var config map[string]interface{}
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read config file: %v\n", err)
+			return 1
+		}
+		json.Unmarshal(data, &config)
+		if err := b.addJSON("config.json", data); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	b.addEnvironment(os.Environ())
+
+	// The rig is probed as the daemon would reach it: flags, then the
+	// environment, then the config file.
+	setting := func(value, name, fallback string) string {
+		if value != "" {
+			return value
+		}
+		if v := os.Getenv(envName(name)); v != "" {
+			return v
+		}
+		if v, ok := config[name]; ok {
+			return fmt.Sprint(v)
+		}
+		return fallback
+	}
+	backendName = setting(backendName, "backend", "fldigi")
+	host = setting(host, "host", "127.0.0.1")
+	if port == 0 {
+		port, _ = strconv.Atoi(setting("", "port", "0"))
+	}
+	if port == 0 {
+		port = defaultPorts[backendName]
+	}
+
+	report := supportReport{
+		Created:   b.manifest.Created,
+		Version:   buildVersion(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		TimeZone:  time.Now().Format("MST -0700"),
+		Backend:   backendName,
+		Address:   fmt.Sprintf("%s:%d", host, port),
+	}
+
+	requestTimeout = timeout
+	rpcTrace = &traceLog{}
+	rig, err := NewBackend(backendName, host, port)
+	if err == nil {
+		report.RigVersion, err = rigVersion(rig)
+		if err != nil {
+			report.RigError = err.Error()
+		}
+		report.Capabilities = probeCapabilities(rig)
+	} else {
+		report.RigError = err.Error()
+	}
+	trace, _ := scrubURLs(rpcTrace.buf.Bytes())
+	b.add("rpc-trace.txt", trace, 0644)
+
+	client := connect()
+	httpClient := *client.http
+	httpClient.Timeout = timeout
+	client.http = &httpClient
+	var status json.RawMessage
+	var events []json.RawMessage
+	if err := client.do(http.MethodGet, "/status", nil, &status); err != nil {
+		report.Daemon = err.Error()
+	} else {
+		report.Daemon = "running at " + client.addr
+		b.addText("daemon-status.json", append(status, '\n'))
+		if err := client.do(http.MethodGet, "/events/recent", url.Values{"n": {"1000"}}, &events); err == nil {
+			var lines []byte
+			for _, ev := range events {
+				lines = append(append(lines, ev...), '\n')
+			}
+			b.addText("events.jsonl", lines)
+		}
+	}
+
+	for _, path := range logs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read log: %v\n", err)
+			return 1
+		}
+		if len(data) > maxSupportLog {
+			data = data[len(data)-maxSupportLog:]
+		}
+		b.addText("logs/"+filepath.Base(path), data)
+	}
+
+	report.Redacted = b.manifest.Redacted
+	data, _ := json.MarshalIndent(report, "", "  ")
+	b.add("report.json", append(data, '\n'), 0644)
+
+	f, err := os.Create(out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := b.writeZip(f); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s (%d files, %d secrets removed); look through it before attaching it to a public issue\n", out, len(b.manifest.Files), b.manifest.Redacted)
+	return 0
+}
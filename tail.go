@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"fldigi-cmd/sdk"
+)
+
+// runTail implements `fldigi-cmd tail`: print the daemon's recent events and
+// optionally follow new ones.
+func runTail(args []string) int {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	var lines int
+	var follow, asJSON bool
+	fs.IntVar(&lines, "n", 20, "number of recent events to show")
+	fs.IntVar(&lines, "lines", 20, "number of recent events to show")
+	fs.BoolVar(&follow, "f", false, "keep printing new events as they happen")
+	fs.BoolVar(&follow, "follow", false, "keep printing new events as they happen")
+	fs.BoolVar(&asJSON, "json", false, "print events as JSON lines")
+	fs.Parse(args)
+
+	client := connect()
+	show := func(ev Event) {
+		fmt.Println(formatEvent(ev, asJSON))
+	}
+
+	if follow {
+		stream := fmt.Sprintf("%s/events?replay=%d", client.base, lines)
+		err := sdk.SubscribeClient(context.Background(), client.http, stream, show)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var events []Event
+	query := url.Values{"n": {strconv.Itoa(lines)}}
+	if err := client.do(http.MethodGet, "/events/recent", query, &events); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, ev := range events {
+		show(ev)
+	}
+	return 0
+}
+
+// formatEvent renders an event as a JSON line or as its time and type
+// followed by the fields that are set, e.g.
+// "12:00:05 band-change band=20m freq=14074000 prev_band=40m".
+func formatEvent(ev Event, asJSON bool) string {
+	data, _ := json.Marshal(ev)
+	if asJSON {
+		return string(data)
+	}
+
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	delete(fields, "type")
+	delete(fields, "time")
+
+	parts := []string{ev.Time.Local().Format("15:04:05"), ev.Type}
+	for _, k := range sortedKeys(fields) {
+		parts = append(parts, k+"="+formatField(fields[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatField(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, item := range v {
+			strs[i] = formatField(item)
+		}
+		return strings.Join(strs, "; ")
+	case map[string]interface{}:
+		strs := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			strs = append(strs, k+":"+formatField(v[k]))
+		}
+		return strings.Join(strs, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
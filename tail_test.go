@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatEvent(t *testing.T) {
+	ev := Event{
+		Type:     EventBandChange,
+		Time:     time.Date(2024, 3, 1, 12, 0, 5, 0, time.Local),
+		Band:     "20m",
+		PrevBand: "40m",
+		Freq:     14074000,
+		Notes:    []string{"dipole", "amp preset 3"},
+	}
+
+	want := "12:00:05 band-change band=20m freq=14074000 notes=dipole; amp preset 3 prev_band=40m"
+	if got := formatEvent(ev, false); got != want {
+		t.Errorf("formatEvent() = %q; want %q", got, want)
+	}
+	if got := formatEvent(ev, true); !strings.HasPrefix(got, `{"type":"band-change"`) {
+		t.Errorf("formatEvent(json) = %q", got)
+	}
+}
+
+func TestFormatEventExchange(t *testing.T) {
+	ev := Event{Type: EventExchangeChanged, Exchange: map[string]string{"section": "NNJ", "county": "ESSX"}}
+	if got := formatEvent(ev, false); !strings.HasSuffix(got, " exchange-changed exchange=county:ESSX,section:NNJ") {
+		t.Errorf("formatEvent() = %q", got)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "syscall"
+
+// The ioctl requests that read and set a terminal's attributes.
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+// setSpeed sets a serial port's input and output speed to a B* constant.
+func setSpeed(t *syscall.Termios, speed uint64) {
+	t.Ispeed, t.Ospeed = speed, speed
+}
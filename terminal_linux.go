@@ -0,0 +1,16 @@
+package main
+
+import "syscall"
+
+// The ioctl requests that read and set a terminal's attributes.
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
+
+// setSpeed sets a serial port's speed to a B* constant, in the baud bits of
+// a Cflag openSerial has cleared.
+func setSpeed(t *syscall.Termios, speed uint64) {
+	t.Cflag |= uint32(speed)
+	t.Ispeed, t.Ospeed = uint32(speed), uint32(speed)
+}
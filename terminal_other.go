@@ -0,0 +1,30 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// terminalSize returns the size given by $COLUMNS and $LINES, or 80 by 24.
+func terminalSize() (int, int) {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	height, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil || height <= 0 {
+		height = 24
+	}
+	return width, height
+}
+
+// isTerminal is false where raw mode isn't supported, so input is read a
+// line at a time.
+func isTerminal(f *os.File) bool { return false }
+
+func makeRaw(f *os.File) (func(), error) {
+	return nil, fmt.Errorf("raw terminal mode is unsupported on this system")
+}
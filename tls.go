@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// rpcTLS configures https XML-RPC endpoints, set from --tls-ca, --tls-cert,
+// --tls-key and --insecure-skip-verify; nil uses the system defaults.
+var rpcTLS *tls.Config
+
+// loadTLSConfig builds the TLS configuration for https endpoints: ca, if
+// set, is a PEM file of certificate authorities to trust instead of the
+// system's; cert and key are a client certificate to present.
+func loadTLSConfig(ca, cert, key string, insecure bool) (*tls.Config, error) {
+	if ca == "" && cert == "" && key == "" && !insecure {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", ca)
+		}
+	}
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return nil, fmt.Errorf("a client certificate needs both --tls-cert and --tls-key")
+		}
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
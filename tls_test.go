@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFldigiClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<methodResponse><params><param><value><string>14074000</string></value></param></params></methodResponse>`))
+	}))
+	defer server.Close()
+	defer func() { rpcTLS = nil }()
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+
+	for _, tc := range []struct {
+		name     string
+		ca       string
+		insecure bool
+		ok       bool
+	}{
+		{"system CAs", "", false, false},
+		{"custom CA", ca, false, true},
+		{"insecure", "", true, true},
+	} {
+		var err error
+		if rpcTLS, err = loadTLSConfig(tc.ca, "", "", tc.insecure); err != nil {
+			t.Fatal(err)
+		}
+		client, err := NewFldigiClientURL(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.GetFrequency(); (err == nil) != tc.ok {
+			t.Errorf("%s: GetFrequency() error = %v, want success %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestLoadTLSConfigErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, nil, 0644)
+
+	if _, err := loadTLSConfig(empty, "", "", false); err == nil {
+		t.Error("loaded a CA file without certificates")
+	}
+	if _, err := loadTLSConfig("", "client.pem", "", false); err == nil {
+		t.Error("loaded a client certificate without a key")
+	}
+	if cfg, err := loadTLSConfig("", "", "", false); cfg != nil || err != nil {
+		t.Errorf("loadTLSConfig() with no options = %v, %v; want defaults", cfg, err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transverter maps a range of the rig's frequencies, the transverter's IF,
+// to the RF band it is converted to.
+type Transverter struct {
+	// Radio is the label of the radio the transverter is on, or "" for
+	// every radio.
+	Radio         string
+	IFLow, IFHigh float64
+	Offset        float64 // RF minus IF
+}
+
+// ParseTransverter parses a --transverter option of the form
+// [LABEL:]IFLOW-IFHIGH=RF, such as "28M-30M=144M" for a 2 m transverter
+// with a 28 MHz IF.
+func ParseTransverter(spec string) (Transverter, error) {
+	var t Transverter
+	rest := spec
+	if label, r, ok := strings.Cut(spec, ":"); ok {
+		t.Radio, rest = label, r
+	}
+	ifRange, rf, ok := strings.Cut(rest, "=")
+	low, high, isRange := strings.Cut(ifRange, "-")
+	if !ok || !isRange {
+		return t, fmt.Errorf("invalid transverter %q, want [LABEL:]IFLOW-IFHIGH=RF, e.g. 28M-30M=144M", spec)
+	}
+	var err error
+	if t.IFLow, err = parseFrequency(low); err != nil {
+		return t, fmt.Errorf("transverter %q: %v", spec, err)
+	}
+	if t.IFHigh, err = parseFrequency(high); err != nil {
+		return t, fmt.Errorf("transverter %q: %v", spec, err)
+	}
+	rfLow, err := parseFrequency(rf)
+	if err != nil {
+		return t, fmt.Errorf("transverter %q: %v", spec, err)
+	}
+	if t.IFHigh <= t.IFLow {
+		return t, fmt.Errorf("transverter %q: the IF range ends before it starts", spec)
+	}
+	t.Offset = rfLow - t.IFLow
+	return t, nil
+}
+
+// transvertersFor returns the transverters on the radio with label.
+func transvertersFor(all []Transverter, label string) []Transverter {
+	var ts []Transverter
+	for _, t := range all {
+		if t.Radio == "" || t.Radio == label {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// transverterRF returns the RF frequency a rig frequency is converted to,
+// or the frequency itself outside every IF.
+func transverterRF(ts []Transverter, freq float64) float64 {
+	for _, t := range ts {
+		if freq >= t.IFLow && freq <= t.IFHigh {
+			return freq + t.Offset
+		}
+	}
+	return freq
+}
+
+// transverterBackend reports and tunes a rig behind transverters in RF
+// frequencies, so bands, segments and hooks follow the real band.
+type transverterBackend struct {
+	Backend
+	transverters []Transverter
+}
+
+func (b *transverterBackend) GetFrequency() (float64, error) {
+	freq, err := b.Backend.GetFrequency()
+	if err != nil {
+		return 0, err
+	}
+	return transverterRF(b.transverters, freq), nil
+}
+
+// SetFrequency tunes the rig to the IF of an RF frequency. Tuning straight
+// to a frequency inside an IF is refused, as the rig would read back as
+// being on the transverter's band.
+func (b *transverterBackend) SetFrequency(freq float64) error {
+	for _, t := range b.transverters {
+		if freq >= t.IFLow+t.Offset && freq <= t.IFHigh+t.Offset {
+			return b.Backend.SetFrequency(freq - t.Offset)
+		}
+	}
+	for _, t := range b.transverters {
+		if freq >= t.IFLow && freq <= t.IFHigh {
+			return fmt.Errorf("%.6f MHz is a transverter IF; tune to the RF frequency instead", freq/1000000)
+		}
+	}
+	return b.Backend.SetFrequency(freq)
+}
+
+func (b *transverterBackend) SetMode(mode string) error {
+	m, ok := b.Backend.(ModeSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't change the mode", b.Name())
+	}
+	return m.SetMode(mode)
+}
+
+func (b *transverterBackend) SendText(text string) error {
+	s, ok := b.Backend.(TextSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't transmit text", b.Name())
+	}
+	return s.SendText(text)
+}
+
+func (b *transverterBackend) GetSplit() (bool, float64, error) {
+	s, ok := b.Backend.(SplitReader)
+	if !ok {
+		return false, 0, fmt.Errorf("the %s backend does not report split operation", b.Name())
+	}
+	split, freq, err := s.GetSplit()
+	if err != nil || !split {
+		return split, freq, err
+	}
+	return true, transverterRF(b.transverters, freq), nil
+}
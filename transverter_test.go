@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestParseTransverter(t *testing.T) {
+	tv, err := ParseTransverter("B:28M-30M=144M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tv.Radio != "B" || tv.IFLow != 28000000 || tv.IFHigh != 30000000 || tv.Offset != 116000000 {
+		t.Errorf("transverter = %+v", tv)
+	}
+	for _, spec := range []string{"28M-30M", "28M=144M", "30M-28M=144M", "28M-30M=abc"} {
+		if _, err := ParseTransverter(spec); err == nil {
+			t.Errorf("ParseTransverter(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestTransverterBackend(t *testing.T) {
+	two, _ := ParseTransverter("28M-30M=144M")
+	seventy, _ := ParseTransverter("A:28M-30M=432M")
+	if ts := transvertersFor([]Transverter{two, seventy}, "B"); len(ts) != 1 || ts[0] != two {
+		t.Errorf("transverters for B = %+v", ts)
+	}
+
+	rig := &fakeBackend{freq: 28174000}
+	b := &transverterBackend{Backend: rig, transverters: []Transverter{two}}
+	if freq, err := b.GetFrequency(); err != nil || freq != 144174000 || frequencyToBand(freq) != "2m" {
+		t.Errorf("GetFrequency() = %v, %v", freq, err)
+	}
+
+	if err := b.SetFrequency(144300000); err != nil || rig.freq != 28300000 {
+		t.Errorf("SetFrequency(144.3M) tuned the rig to %v, %v", rig.freq, err)
+	}
+	// Outside the transverter the rig is tuned directly.
+	if err := b.SetFrequency(14074000); err != nil || rig.freq != 14074000 {
+		t.Errorf("SetFrequency(14.074M) tuned the rig to %v, %v", rig.freq, err)
+	}
+	if freq, _ := b.GetFrequency(); freq != 14074000 {
+		t.Errorf("GetFrequency() on HF = %v", freq)
+	}
+	if err := b.SetFrequency(28500000); err == nil || rig.freq != 14074000 {
+		t.Errorf("tuning into the IF wasn't refused: %v, %v", rig.freq, err)
+	}
+}
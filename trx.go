@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+)
+
+// trxRig is a backend whose transmitter can be keyed and released.
+type trxRig interface {
+	TXController
+	Transmit() error
+	Receive() error
+	Tune() error
+}
+
+// trxStates are the states `fldigi-cmd tx`, `rx`, `tune` and `abort` put
+// the rig in, and the fldigi calls doing so.
+var trxStates = map[string]func(trxRig) error{
+	"tx":    trxRig.Transmit,
+	"rx":    trxRig.Receive,
+	"tune":  trxRig.Tune,
+	"abort": trxRig.AbortTX,
+}
+
+// keysTX reports whether state puts the rig on the air.
+func keysTX(state string) bool {
+	return state == "tx" || state == "tune"
+}
+
+// handleTRX switches a radio between transmit and receive: POST
+// /trx?state=tx|rx|tune|abort[&radio=B][&max_seconds=N], tx and tune
+// returning to receive after max_seconds, or --api-max-seconds without
+// one; max_seconds=0 lifts the limit.
+func (a *API) handleTRX(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	state := query.Get("state")
+	set, ok := trxStates[state]
+	if !ok {
+		http.Error(w, "invalid state, want tx, rx, tune or abort", http.StatusBadRequest)
+		return
+	}
+	limit := a.maxTX
+	if s := query.Get("max_seconds"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid max_seconds", http.StatusBadRequest)
+			return
+		}
+		limit = time.Duration(n) * time.Second
+	}
+
+	radio := query.Get("radio")
+	var m *Monitor
+	for _, candidate := range a.monitors {
+		if candidate.radio == radio {
+			m = candidate
+		}
+	}
+	if m == nil {
+		http.Error(w, "unknown radio", http.StatusNotFound)
+		return
+	}
+	rig, ok := m.backend.(trxRig)
+	if !ok {
+		http.Error(w, fmt.Sprintf("the %s backend can't key the transmitter", m.backend.Name()), http.StatusNotImplemented)
+		return
+	}
+	if freq := m.Status().Freq; keysTX(state) && a.privileges != nil && freq > 0 && !a.privileges.Allows(freq) {
+		http.Error(w, fmt.Sprintf("%.6f MHz is outside %s privileges", freq/1000000, a.privileges.Name), http.StatusForbidden)
+		return
+	}
+
+	if err := set(rig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !keysTX(state) {
+		limit = 0
+	}
+	a.limitTX(radio, rig, limit)
+	writeJSON(w, map[string]string{"state": state})
+}
+
+// limitTX forces radio back to receive after limit, replacing any earlier
+// limit; a limit of 0 just cancels it.
+func (a *API) limitTX(radio string, rig trxRig, limit time.Duration) {
+	a.trxMu.Lock()
+	defer a.trxMu.Unlock()
+
+	if t := a.trxLimits[radio]; t != nil {
+		t.Stop()
+		delete(a.trxLimits, radio)
+	}
+	if limit <= 0 {
+		return
+	}
+	if a.trxLimits == nil {
+		a.trxLimits = map[string]*time.Timer{}
+	}
+	a.trxLimits[radio] = time.AfterFunc(limit, func() {
+		log.Printf("Transmitted for %v, forcing receive", limit)
+		if err := rig.AbortTX(); err != nil {
+			log.Printf("Error: failed to force receive: %v", err)
+		}
+	})
+}
+
+// runTRX returns the `fldigi-cmd tx`, `rx`, `tune` and `abort`
+// subcommands, switching the rig through the daemon or, without one,
+// directly.
+func runTRX(state string) func(args []string) int {
+	return func(args []string) int {
+		fs := flag.NewFlagSet(state, flag.ExitOnError)
+		connect := addAPIFlags(fs)
+		forceDirect, connectRig, allowed := addDirectFlags(fs)
+		var radio string
+		var maxSeconds int
+		fs.StringVar(&radio, "radio", "", "label of the radio to switch")
+		if keysTX(state) {
+			fs.IntVar(&maxSeconds, "max-seconds", 0, "return to receive after this many seconds; 0 leaves it to the daemon's --api-max-seconds or, without a daemon, sets no limit; without a daemon the command waits until then")
+		}
+		fs.Parse(args)
+		if fs.NArg() != 0 || maxSeconds < 0 {
+			if keysTX(state) {
+				fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd %s [--radio label] [--max-seconds N]\n", state)
+			} else {
+				fmt.Fprintf(os.Stderr, "Usage: fldigi-cmd %s [--radio label]\n", state)
+			}
+			return 2
+		}
+
+		query := url.Values{"state": {state}}
+		if radio != "" {
+			query.Set("radio", radio)
+		}
+		if maxSeconds > 0 {
+			query.Set("max_seconds", strconv.Itoa(maxSeconds))
+		}
+		var result map[string]string
+		err := viaDaemon(*forceDirect, func() error {
+			return connect().do(http.MethodPost, "/trx", query, &result)
+		}, func() error {
+			b, err := connectRig()
+			if err != nil {
+				return err
+			}
+			rig, ok := b.(trxRig)
+			if !ok {
+				return fmt.Errorf("the %s backend can't key the transmitter", b.Name())
+			}
+			if keysTX(state) {
+				freq, err := b.GetFrequency()
+				if err != nil {
+					return err
+				}
+				if err := allowed(freq); err != nil {
+					return err
+				}
+			}
+			if err := trxStates[state](rig); err != nil {
+				return err
+			}
+			if maxSeconds == 0 {
+				return nil
+			}
+			// No daemon enforces the limit, so wait for it here, also
+			// returning to receive on ^C.
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			defer signal.Stop(interrupt)
+			select {
+			case <-time.After(time.Duration(maxSeconds) * time.Second):
+			case <-interrupt:
+			}
+			return rig.AbortTX()
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTRXRig is a backend whose transmit state is switched by the trx
+// calls.
+type fakeTRXRig struct {
+	fakeBackend
+	mu    sync.Mutex
+	state string
+}
+
+func (r *fakeTRXRig) set(state string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+	return nil
+}
+
+func (r *fakeTRXRig) GetTRXState() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state, nil
+}
+
+func (r *fakeTRXRig) Transmit() error { return r.set("TX") }
+func (r *fakeTRXRig) Receive() error  { return r.set("RX") }
+func (r *fakeTRXRig) Tune() error     { return r.set("TUNE") }
+func (r *fakeTRXRig) AbortTX() error  { return r.set("RX") }
+
+func TestHandleTRX(t *testing.T) {
+	m, _ := newTestMonitor()
+	m.radio = "A"
+	rig := &fakeTRXRig{state: "RX"}
+	m.backend = rig
+	m.setStatus(14074000, nil)
+	api := &API{monitors: []*Monitor{m}}
+
+	post := func(query url.Values) int {
+		w := httptest.NewRecorder()
+		api.handleTRX(w, httptest.NewRequest(http.MethodPost, "/trx?"+query.Encode(), nil))
+		return w.Code
+	}
+	if code := post(url.Values{"radio": {"A"}, "state": {"tune"}}); code != http.StatusOK || rig.state != "TUNE" {
+		t.Errorf("tune = %d, rig %s", code, rig.state)
+	}
+	if code := post(url.Values{"radio": {"A"}, "state": {"rx"}}); code != http.StatusOK || rig.state != "RX" {
+		t.Errorf("rx = %d, rig %s", code, rig.state)
+	}
+	for _, query := range []url.Values{
+		{"radio": {"A"}, "state": {"ptt"}},
+		{"radio": {"A"}, "state": {"tx"}, "max_seconds": {"soon"}},
+		{"radio": {"B"}, "state": {"tx"}},
+	} {
+		if code := post(query); code == http.StatusOK {
+			t.Errorf("%v succeeded", query)
+		}
+	}
+
+	// Without max_seconds the API's default limit applies.
+	api.maxTX = 10 * time.Millisecond
+	if code := post(url.Values{"radio": {"A"}, "state": {"tx"}}); code != http.StatusOK {
+		t.Errorf("tx = %d", code)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if state, _ := rig.GetTRXState(); state != "RX" {
+		t.Errorf("still %s after the default limit", state)
+	}
+	post(url.Values{"radio": {"A"}, "state": {"tx"}, "max_seconds": {"0"}})
+	time.Sleep(50 * time.Millisecond)
+	if state, _ := rig.GetTRXState(); state != "TX" {
+		t.Errorf("max_seconds=0 forced %s", state)
+	}
+	post(url.Values{"radio": {"A"}, "state": {"rx"}})
+
+	api.privileges, _ = LoadLicenseProfile("technician")
+	if code := post(url.Values{"radio": {"A"}, "state": {"tx"}}); code != http.StatusForbidden || rig.state != "RX" {
+		t.Errorf("tx outside privileges = %d, rig %s", code, rig.state)
+	}
+}
+
+func TestLimitTX(t *testing.T) {
+	rig := &fakeTRXRig{state: "TX"}
+	api := &API{}
+	api.limitTX("", rig, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if state, _ := rig.GetTRXState(); state != "RX" {
+		t.Errorf("still %s after the limit", state)
+	}
+
+	// A later request replaces the limit.
+	rig.Transmit()
+	api.limitTX("", rig, 10*time.Millisecond)
+	api.limitTX("", rig, 0)
+	time.Sleep(50 * time.Millisecond)
+	if state, _ := rig.GetTRXState(); state != "TX" {
+		t.Errorf("cancelled limit forced %s", state)
+	}
+}
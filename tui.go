@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"fldigi-cmd/sdk"
+)
+
+// maxTUIText is how much decoded text the TUI keeps.
+const maxTUIText = 16384
+
+// tui is the state the terminal UI draws: the radios from the daemon, and
+// the signal quality, TX state and decoded text read from fldigi itself.
+type tui struct {
+	mu        sync.Mutex
+	status    []MonitorStatus
+	statusErr string
+	quality   float64 // 0 to 100, or -1 if unknown
+	trx       string
+	text      string
+	events    []Event
+	eventsErr string
+	maxEvents int
+}
+
+// runTUI implements `fldigi-cmd tui`: a full-screen live view of the
+// station for a terminal, such as an SSH session to the shack's Pi.
+func runTUI(args []string) int {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	connect := addAPIFlags(fs)
+	var fldigiAddr string
+	var textPort, replay int
+	var interval time.Duration
+	fs.StringVar(&fldigiAddr, "fldigi", "127.0.0.1:7362", "fldigi XML-RPC address to read the signal quality, TX state and decoded text from")
+	fs.IntVar(&textPort, "text-port", 7342, "fldigi text socket port")
+	fs.IntVar(&replay, "n", 20, "number of recent events to start with")
+	fs.DurationVar(&interval, "interval", time.Second, "how often to refresh the display")
+	fs.Parse(args)
+
+	host, port, err := splitHostPort(fldigiAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --fldigi %q: %v\n", fldigiAddr, err)
+		return 2
+	}
+	if port == 0 {
+		port = defaultPorts["fldigi"]
+	}
+	fldigi := NewFldigiClient(host, port)
+	client := connect()
+
+	t := &tui{quality: -1, maxEvents: 200}
+	go t.followEvents(client, replay)
+	go t.readText(net.JoinHostPort(host, strconv.Itoa(textPort)), fldigi)
+
+	// Draw on the alternate screen, as full-screen programs do, so the
+	// shell's scrollback is left as it was.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.poll(client, fldigi)
+		width, height := terminalSize()
+		fmt.Print(drawScreen(t.render(width, height, time.Now())))
+		select {
+		case <-signals:
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the radios' status from the daemon, or from fldigi when no
+// daemon is running, and fldigi's signal quality and TX state.
+func (t *tui) poll(client *apiClient, fldigi *FldigiClient) {
+	var status []MonitorStatus
+	err := client.do(http.MethodGet, "/status", nil, &status)
+	var noDaemon *noDaemonError
+	if errors.As(err, &noDaemon) {
+		var s MonitorStatus
+		s, err = directStatus(func() (Backend, error) { return fldigi, nil })
+		status = []MonitorStatus{s}
+	}
+	quality, qerr := fldigi.GetQuality()
+	if qerr != nil {
+		quality = -1
+	}
+	trx, _ := fldigi.GetTRXState()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status, t.statusErr = status, ""
+	if err != nil {
+		t.status, t.statusErr = nil, err.Error()
+	}
+	t.quality, t.trx = quality, trx
+}
+
+// followEvents adds the daemon's events as they happen.
+func (t *tui) followEvents(client *apiClient, replay int) {
+	stream := fmt.Sprintf("%s/events?replay=%d", client.base, replay)
+	err := sdk.SubscribeClient(context.Background(), client.http, stream, t.addEvent)
+	t.mu.Lock()
+	t.eventsErr = err.Error()
+	t.mu.Unlock()
+}
+
+func (t *tui) addEvent(ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, ev)
+	if len(t.events) > t.maxEvents {
+		t.events = t.events[len(t.events)-t.maxEvents:]
+	}
+}
+
+// readText adds fldigi's decoded text, reconnecting whenever it fails.
+func (t *tui) readText(addr string, fldigi *FldigiClient) {
+	for {
+		if src, err := openRXTextSource("auto", addr, fldigi); err == nil {
+			for {
+				text, err := src.Read()
+				if err != nil {
+					break
+				}
+				t.addText(text)
+			}
+			src.Close()
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (t *tui) addText(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.text += printableText(text)
+	if len(t.text) > maxTUIText {
+		cut := len(t.text) - maxTUIText
+		for cut < len(t.text) && !utf8.RuneStart(t.text[cut]) {
+			cut++
+		}
+		t.text = t.text[cut:]
+	}
+}
+
+// printableText keeps decoded text from moving the cursor or changing the
+// terminal: line breaks become newlines and other control characters go.
+func printableText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r' || r == '\n':
+			return '\n'
+		case r == '\t':
+			return ' '
+		case r < 0x20 || r == 0x7F || (r >= 0x80 && r < 0xA0):
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// render lays out the screen as lines of at most width characters: the
+// radios and signal at the top, then the decoded text and the event log
+// sharing the rest of the height.
+func (t *tui) render(width, height int, now time.Time) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clock := now.UTC().Format("15:04:05 UTC")
+	lines := []string{"fldigi-cmd" + strings.Repeat(" ", max(1, width-len("fldigi-cmd")-len(clock))) + clock}
+	if t.statusErr != "" {
+		lines = append(lines, "error: "+t.statusErr)
+	}
+	for _, s := range t.status {
+		lines = append(lines, tuiRadioLine(s))
+	}
+	signal := "Signal  " + signalBar(t.quality, 20)
+	if t.trx == "TX" || t.trx == "TUNE" {
+		signal += "  " + t.trx
+	}
+	lines = append(lines, signal, "")
+
+	// Two lines go to the headings of the text and the events.
+	rows := max(2, height-len(lines)-2)
+	textRows, eventRows := rows/2, rows-rows/2
+
+	lines = append(lines, heading("Decoded text", width))
+	text := wrapText(strings.TrimLeft(t.text, "\n"), width)
+	if len(text) > textRows {
+		text = text[len(text)-textRows:]
+	}
+	lines = append(lines, text...)
+	for i := len(text); i < textRows; i++ {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, heading("Events", width))
+	var events []string
+	if t.eventsErr != "" {
+		events = append(events, "error: "+t.eventsErr)
+	}
+	for _, ev := range t.events {
+		events = append(events, formatEvent(ev, false))
+	}
+	if len(events) > eventRows {
+		events = events[len(events)-eventRows:]
+	}
+	lines = append(lines, events...)
+
+	for i, line := range lines {
+		lines[i] = clipText(line, width)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// tuiRadioLine is a radio's frequency, band, segment and mode.
+func tuiRadioLine(s MonitorStatus) string {
+	label := s.Radio
+	if label != "" {
+		label += "  "
+	}
+	switch {
+	case s.Error != "":
+		return label + "error: " + s.Error
+	case s.Standby:
+		return label + "standby"
+	}
+	band := s.Band
+	if band == "" {
+		band = "out of band"
+	}
+	parts := []string{fmt.Sprintf("%.6f MHz", s.Freq/1000000), band}
+	for _, p := range []string{s.Segment, s.Mode} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return label + strings.Join(parts, "  ")
+}
+
+// signalBar draws a quality of 0 to 100 as a bar of width characters.
+func signalBar(quality float64, width int) string {
+	if quality < 0 {
+		return "[" + strings.Repeat(" ", width) + "]  ?"
+	}
+	filled := int(quality/100*float64(width) + 0.5)
+	filled = min(max(filled, 0), width)
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), quality)
+}
+
+// heading is a section heading ruled across the width.
+func heading(title string, width int) string {
+	return "-- " + title + " " + strings.Repeat("-", max(0, width-len(title)-4))
+}
+
+// wrapText breaks text into lines of at most width characters.
+func wrapText(text string, width int) []string {
+	if text == "" {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		runes := []rune(line)
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// clipText cuts s to width characters.
+func clipText(s string, width int) string {
+	if runes := []rune(s); len(runes) > width {
+		return string(runes[:width])
+	}
+	return s
+}
+
+// drawScreen redraws the screen with lines from the top left, clearing
+// what was there before.
+func drawScreen(lines []string) string {
+	return "\x1b[H" + strings.Join(lines, "\x1b[K\r\n") + "\x1b[K\x1b[J"
+}
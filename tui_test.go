@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTUIRender(t *testing.T) {
+	ui := &tui{quality: 54, trx: "TX", maxEvents: 10}
+	ui.status = []MonitorStatus{
+		{Radio: "A", Freq: 14074000, Band: "20m", Segment: "20m-FT8", Mode: "BPSK31"},
+		{Radio: "B", Error: "connection refused"},
+	}
+	ui.addText("CQ CQ DE N0CALL\r\nPSE K\x07")
+	ui.addEvent(Event{Type: EventBandChange, Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local), Band: "20m"})
+
+	lines := ui.render(40, 14, time.Date(2026, 3, 1, 12, 0, 5, 0, time.UTC))
+	want := []string{
+		"fldigi-cmd                  12:00:05 UTC",
+		"A  14.074000 MHz  20m  20m-FT8  BPSK31",
+		"B  error: connection refused",
+		"Signal  [===========         ]  54%  TX",
+		"",
+		"-- Decoded text ------------------------",
+		"CQ CQ DE N0CALL",
+		"PSE K",
+		"",
+		"-- Events ------------------------------",
+		"12:00:00 band-change band=20m",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("render =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, line := range ui.render(20, 5, time.Now()) {
+		if len([]rune(line)) > 20 {
+			t.Errorf("line %q is wider than the terminal", line)
+		}
+	}
+	if n := len(ui.render(20, 5, time.Now())); n > 5 {
+		t.Errorf("%d lines on a 5-line terminal", n)
+	}
+}
+
+func TestPrintableText(t *testing.T) {
+	if got := printableText("RST 599\r\x1b[2Jok\tde\x00"); got != "RST 599\n[2Jok de" {
+		t.Errorf("printableText = %q", got)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("abcdefg\n\nhi", 3)
+	if want := []string{"abc", "def", "g", "", "hi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
+
+func TestSignalBar(t *testing.T) {
+	for _, tc := range []struct {
+		quality float64
+		want    string
+	}{
+		{0, "[     ]   0%"},
+		{100, "[=====] 100%"},
+		{-1, "[     ]  ?"},
+	} {
+		if got := signalBar(tc.quality, 5); got != tc.want {
+			t.Errorf("signalBar(%v) = %q, want %q", tc.quality, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// TuneAction configures a tune rule action: how long the carrier is keyed
+// for the tuner to find a match.
+type TuneAction struct {
+	Duration string `json:"duration,omitempty"` // default "3s"
+}
+
+// tuneRig is what keying a tune-up carrier needs from fldigi.
+type tuneRig interface {
+	TXController
+	Tune() error
+}
+
+// tuneDrive is a rig whose output power can be lowered for the tune
+// carrier and restored afterwards.
+type tuneDrive interface {
+	PowerSetter
+	PowerReader
+}
+
+// Tuner keys fldigi's tune carrier briefly so an automatic antenna tuner
+// can find a match, at low power with the amplifier in standby. It skips
+// frequencies it has already tuned near, and anywhere the station mustn't
+// transmit: outside the band or privileges, while the rig is already
+// transmitting or while another radio is.
+type Tuner struct {
+	rig        tuneRig
+	radio      string
+	privileges *Privileges
+	interlock  *Interlock
+	// span is how far from a tuned frequency, in Hz, the match is taken to
+	// hold.
+	span float64
+	// auto is how long to tune after each band change, or 0 for only on
+	// tune rule actions.
+	auto time.Duration
+	// hooks, if set, are the hook workers whose hooks for a band change
+	// are waited for before tuning.
+	hooks *HookQueue
+	// power is the drive, in percent of full output, the carrier goes out
+	// at, set through drive; 0 leaves the drive alone.
+	power float64
+	drive tuneDrive
+	// amp, if set, is put in standby while the carrier is on.
+	amp *AmplifierSink
+
+	mu    sync.Mutex
+	tuned map[string]float64 // last frequency tuned on each band
+	sleep func(time.Duration)
+	after func(time.Duration) <-chan time.Time
+
+	// cancel ends the tune started by the latest band change or action.
+	startMu sync.Mutex
+	cancel  chan struct{}
+	running sync.WaitGroup
+}
+
+func NewTuner(rig tuneRig, radio string, privileges *Privileges, span float64) *Tuner {
+	return &Tuner{rig: rig, radio: radio, privileges: privileges, span: span, tuned: map[string]float64{}, sleep: time.Sleep, after: time.After}
+}
+
+// skip returns why tuning on band and freq should be skipped, or "".
+func (t *Tuner) skip(band string, freq float64) string {
+	if band == "" || band == "unknown" {
+		return "out of band"
+	}
+	if t.privileges != nil && !t.privileges.Allows(freq) {
+		return "outside your privileges"
+	}
+	if last, ok := t.tuned[band]; ok && math.Abs(freq-last) <= t.span {
+		return fmt.Sprintf("already tuned at %.4f MHz", last/1000000)
+	}
+	if t.interlock != nil {
+		if other, busy := t.interlock.OtherTransmitting(t.radio); busy {
+			return fmt.Sprintf("radio %s is transmitting", other)
+		}
+	}
+	state, err := t.rig.GetTRXState()
+	if err != nil {
+		return fmt.Sprintf("no TX state: %v", err)
+	}
+	if state != "RX" {
+		return "already transmitting"
+	}
+	return ""
+}
+
+// start tunes for d on band and freq on its own goroutine, first ending
+// any tune still waiting or keyed, and with wait set, once wait's hooks
+// have run. Errors are logged.
+func (t *Tuner) start(d time.Duration, band string, freq float64, wait *Event) {
+	t.startMu.Lock()
+	if t.cancel != nil {
+		close(t.cancel)
+	}
+	cancel := make(chan struct{})
+	t.cancel = cancel
+	t.startMu.Unlock()
+
+	t.running.Add(1)
+	go func() {
+		defer t.running.Done()
+		if wait != nil && t.hooks != nil {
+			t.hooks.Wait(*wait)
+		}
+		if err := t.tune(cancel, d, band, freq); err != nil {
+			log.Printf("Error in tune sink: %v", err)
+		}
+	}()
+}
+
+// Tune keys the carrier for d on band and freq, unless it is to be
+// skipped, returning once it is off again.
+func (t *Tuner) Tune(d time.Duration, band string, freq float64) error {
+	return t.tune(nil, d, band, freq)
+}
+
+// tune is Tune, ending the carrier early once cancel is closed.
+func (t *Tuner) tune(cancel <-chan struct{}, d time.Duration, band string, freq float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-cancel:
+		return nil
+	default:
+	}
+	if reason := t.skip(band, freq); reason != "" {
+		log.Printf("Skipped tuning on %.4f MHz: %s", freq/1000000, reason)
+		return nil
+	}
+	if t.power > 0 && t.drive == nil {
+		return fmt.Errorf("not tuning on %.4f MHz: lowering the drive to %g%% needs --tune-rig", freq/1000000, t.power)
+	}
+	if dryRun {
+		dryRunf("would key the tune carrier for %v on %.4f MHz", d, freq/1000000)
+		return nil
+	}
+
+	restore, err := t.prepare()
+	if err != nil {
+		return fmt.Errorf("not tuning on %.4f MHz: %v", freq/1000000, err)
+	}
+	log.Printf("Keying the tune carrier for %v on %.4f MHz", d, freq/1000000)
+	// The other radios hold their band changes back while the carrier is
+	// on, as they would for a transmission.
+	if t.interlock != nil {
+		t.interlock.Set(t.radio, true)
+	}
+	if err := t.rig.Tune(); err != nil {
+		// The carrier may have been keyed regardless.
+		if t.stop() == nil {
+			if t.interlock != nil {
+				t.interlock.Set(t.radio, false)
+			}
+			restore()
+		}
+		return fmt.Errorf("failed to start tuning: %v", err)
+	}
+	cancelled := false
+	select {
+	case <-t.after(d):
+	case <-cancel:
+		cancelled = true
+	}
+	if err := t.stop(); err != nil {
+		// Still possibly on the air, so the interlock stays set and the
+		// drive and amplifier are left as they are.
+		return err
+	}
+	if t.interlock != nil {
+		t.interlock.Set(t.radio, false)
+	}
+	if err := restore(); err != nil {
+		return err
+	}
+	if cancelled {
+		log.Printf("Stopped tuning on %.4f MHz early for a band change", freq/1000000)
+		return nil
+	}
+	t.tuned[band] = freq
+	return nil
+}
+
+// prepare puts the amplifier in standby and lowers the drive for the
+// carrier, returning a function to undo both. On an error nothing is left
+// changed.
+func (t *Tuner) prepare() (restore func() error, err error) {
+	operating := false
+	if t.amp != nil {
+		if operating, err = t.amp.standby(); err != nil {
+			return nil, fmt.Errorf("failed to put the amplifier in standby: %v", err)
+		}
+	}
+	resume := func() error {
+		if !operating {
+			return nil
+		}
+		if err := t.amp.operate(); err != nil {
+			return fmt.Errorf("failed to bring the amplifier back on line after tuning: %v", err)
+		}
+		return nil
+	}
+	if t.power <= 0 {
+		return resume, nil
+	}
+	full, err := t.drive.GetPower()
+	if err == nil {
+		err = t.drive.SetPower(t.power)
+	}
+	if err != nil {
+		resume()
+		return nil, fmt.Errorf("failed to lower the drive: %v", err)
+	}
+	return func() error {
+		if err := t.drive.SetPower(full); err != nil {
+			// With the drive still low the amplifier is safest off line.
+			return fmt.Errorf("failed to restore the drive to %g%% after tuning, the amplifier is left in standby: %v", full, err)
+		}
+		return resume()
+	}, nil
+}
+
+// stopAttempts is how many times unkeying the tune carrier is tried.
+const stopAttempts = 5
+
+// stop unkeys the tune carrier, retrying a second apart so a passing
+// fldigi hiccup doesn't leave it on the air.
+func (t *Tuner) stop() error {
+	var err error
+	for i := 0; i < stopAttempts; i++ {
+		if i > 0 {
+			log.Printf("Error: failed to stop tuning, retrying: %v", err)
+			t.sleep(time.Second)
+		}
+		if err = t.rig.AbortTX(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("the tune carrier may still be keyed, failed to stop it %d times: %v", stopAttempts, err)
+}
+
+// runAction returns a rule action runner that handles tune itself and
+// passes other actions to next. The carrier goes out on its own goroutine,
+// like after a band change.
+func (t *Tuner) runAction(next func(RuleAction, Event) error) func(RuleAction, Event) error {
+	return func(a RuleAction, ev Event) error {
+		if a.Tune == nil {
+			return next(a, ev)
+		}
+		d := 3 * time.Second
+		if a.Tune.Duration != "" {
+			var err error
+			if d, err = time.ParseDuration(a.Tune.Duration); err != nil || d <= 0 {
+				return fmt.Errorf("invalid tune duration %q", a.Tune.Duration)
+			}
+		}
+		t.start(d, ev.Band, ev.Freq, nil)
+		return nil
+	}
+}
+
+func (t *Tuner) Name() string { return "tune" }
+
+func (t *Tuner) Wants(ev Event) bool {
+	return t.auto > 0 && ev.Type == EventBandChange && ev.Radio == t.radio
+}
+
+// Handle tunes after a band change once its hooks, such as an antenna or
+// amplifier relay command, have finished, so the carrier never goes out
+// while they are still switching. The tune runs on its own goroutine, so
+// polling carries on, and the next band change ends it.
+func (t *Tuner) Handle(ev Event) error {
+	t.start(t.auto, ev.Band, ev.Freq, &ev)
+	return nil
+}
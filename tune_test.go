@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTuneRig struct {
+	fakeTX
+	tunes int
+}
+
+func (r *fakeTuneRig) Tune() error {
+	r.tunes++
+	r.state = "TUNE"
+	return nil
+}
+
+// keyedFor returns an after func that ends each carrier at once, adding up
+// the time it would have been keyed.
+func keyedFor(total *time.Duration) func(time.Duration) <-chan time.Time {
+	return func(d time.Duration) <-chan time.Time {
+		*total += d
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+}
+
+func TestTuner(t *testing.T) {
+	rig := &fakeTuneRig{fakeTX: fakeTX{state: "RX"}}
+	var slept time.Duration
+	tuner := NewTuner(rig, "A", nil, 50000)
+	tuner.auto = 2 * time.Second
+	tuner.after = keyedFor(&slept)
+
+	for _, ev := range []Event{
+		{Type: EventInitialBand, Radio: "A", Band: "40m", Freq: 7074000},
+		{Type: EventBandChange, Radio: "B", Band: "20m", Freq: 14074000},
+		{Type: EventBandChange, Radio: "A", Band: "20m", Freq: 14074000},
+		// Still within the span of the match found on 14.074 MHz.
+		{Type: EventBandChange, Radio: "A", Band: "20m", Freq: 14100000},
+		{Type: EventBandChange, Radio: "A", Band: "unknown", Freq: 14400000},
+	} {
+		if tuner.Wants(ev) {
+			if err := tuner.Handle(ev); err != nil {
+				t.Fatal(err)
+			}
+			tuner.running.Wait()
+		}
+	}
+	if rig.tunes != 1 || rig.aborted != 1 || rig.state != "RX" || slept != 2*time.Second {
+		t.Errorf("tuned %d times, stopped %d, state %s, waited %v", rig.tunes, rig.aborted, rig.state, slept)
+	}
+	if err := tuner.Tune(time.Second, "20m", 14300000); err != nil || rig.tunes != 2 {
+		t.Errorf("retune across the band = %v after %d tunes", err, rig.tunes)
+	}
+
+	// Nothing is keyed while another radio or the rig is transmitting.
+	tuner.interlock = NewInterlock()
+	tuner.interlock.Set("B", true)
+	tuner.Tune(time.Second, "15m", 21074000)
+	tuner.interlock.Set("B", false)
+	rig.state = "TX"
+	tuner.Tune(time.Second, "15m", 21074000)
+	if rig.tunes != 2 {
+		t.Errorf("tuned while transmitting")
+	}
+
+	// The other radios are held back for as long as the carrier is on.
+	rig.state = "RX"
+	held := false
+	tuner.after = func(time.Duration) <-chan time.Time {
+		_, held = tuner.interlock.OtherTransmitting("B")
+		return keyedFor(&slept)(0)
+	}
+	if err := tuner.Tune(time.Second, "15m", 21074000); err != nil || !held {
+		t.Errorf("tune = %v, interlock held %v", err, held)
+	}
+	if _, busy := tuner.interlock.OtherTransmitting("B"); busy {
+		t.Error("the interlock is still set after tuning")
+	}
+}
+
+// stuckTuneRig fails to leave the tune state a number of times.
+type stuckTuneRig struct {
+	fakeTuneRig
+	failures int
+}
+
+func (r *stuckTuneRig) AbortTX() error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("timeout")
+	}
+	return r.fakeTuneRig.AbortTX()
+}
+
+func TestTunerRetriesStop(t *testing.T) {
+	rig := &stuckTuneRig{fakeTuneRig: fakeTuneRig{fakeTX: fakeTX{state: "RX"}}, failures: 2}
+	tuner := NewTuner(rig, "", nil, 50000)
+	tuner.sleep = func(time.Duration) {}
+	tuner.after = keyedFor(new(time.Duration))
+	if err := tuner.Tune(time.Second, "40m", 7074000); err != nil || rig.state != "RX" {
+		t.Errorf("tune = %v, left %s", err, rig.state)
+	}
+	rig.failures = stopAttempts
+	if err := tuner.Tune(time.Second, "20m", 14074000); err == nil || !strings.Contains(err.Error(), "still be keyed") {
+		t.Errorf("gave up with %v", err)
+	}
+}
+
+func TestTuneAction(t *testing.T) {
+	rig := &fakeTuneRig{fakeTX: fakeTX{state: "RX"}}
+	tuner := NewTuner(rig, "", nil, 50000)
+	var slept time.Duration
+	tuner.after = keyedFor(&slept)
+	run := tuner.runAction(runRuleAction)
+
+	ev := Event{Band: "40m", Freq: 7074000}
+	err := run(RuleAction{Tune: &TuneAction{}}, ev)
+	tuner.running.Wait()
+	if err != nil || slept != 3*time.Second {
+		t.Errorf("tune action = %v, waited %v", err, slept)
+	}
+	if err := run(RuleAction{Tune: &TuneAction{Duration: "soon"}}, Event{Band: "20m", Freq: 14074000}); err == nil {
+		t.Error("accepted an invalid duration")
+	}
+	if err := runRuleAction(RuleAction{Tune: &TuneAction{}}, ev); err == nil {
+		t.Error("tuned without fldigi")
+	}
+}
+
+// fakeDrive is a rig whose output power is set and read back.
+type fakeDrive struct {
+	power float64
+	set   []float64
+}
+
+func (d *fakeDrive) SetPower(percent float64) error {
+	d.power = percent
+	d.set = append(d.set, percent)
+	return nil
+}
+
+func (d *fakeDrive) GetPower() (float64, error) { return d.power, nil }
+
+func TestTunerLowPower(t *testing.T) {
+	rig := &fakeTuneRig{fakeTX: fakeTX{state: "RX"}}
+	drive := &fakeDrive{power: 100}
+	kpa := &fakeKPA500{operating: true}
+	tuner := NewTuner(rig, "", nil, 50000)
+	tuner.power, tuner.drive = 10, drive
+	tuner.amp = &AmplifierSink{amp: &kpa500{port: testPort(kpa)}, port: testPort(kpa), sleep: func(time.Duration) {}}
+
+	var duringPower float64
+	var duringOperating bool
+	tuner.after = func(time.Duration) <-chan time.Time {
+		duringPower, duringOperating = drive.power, kpa.operating
+		return keyedFor(new(time.Duration))(0)
+	}
+	if err := tuner.Tune(time.Second, "40m", 7074000); err != nil {
+		t.Fatal(err)
+	}
+	if duringPower != 10 || duringOperating {
+		t.Errorf("carrier at %g%% with the amplifier operating %v", duringPower, duringOperating)
+	}
+	if drive.power != 100 || !kpa.operating {
+		t.Errorf("left at %g%% with the amplifier operating %v", drive.power, kpa.operating)
+	}
+
+	// Without a rig to lower the drive through nothing is keyed.
+	tuner.drive = nil
+	if err := tuner.Tune(time.Second, "20m", 14074000); err == nil || rig.tunes != 1 {
+		t.Errorf("tune without a drive = %v after %d tunes", err, rig.tunes)
+	}
+}
+
+func TestTunerAsync(t *testing.T) {
+	rig := &fakeTRXRig{state: "RX"}
+	dispatcher := NewDispatcher(NewMetrics())
+	tuner := NewTuner(rig, "A", nil, 50000)
+	tuner.auto = time.Hour
+	tuner.hooks = NewHookQueue(dispatcher, 1, 10, false)
+
+	// A band change's hooks, here waiting for their cooldown, run before
+	// the carrier.
+	first := Event{Type: EventBandChange, Radio: "A", Band: "40m", Freq: 7074000, Time: time.Now()}
+	tuner.hooks.hold(first)
+	start := time.Now()
+	tuner.Handle(first)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("Handle waited for the tune")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if state, _ := rig.GetTRXState(); state != "RX" {
+		t.Error("keyed before the hooks ran")
+	}
+	tuner.hooks.release(first)
+	waitFor(t, func() bool {
+		state, _ := rig.GetTRXState()
+		return state == "TUNE"
+	})
+
+	// The next band change ends the carrier.
+	tuner.Handle(Event{Type: EventBandChange, Radio: "A", Band: "unknown", Freq: 14400000, Time: time.Now()})
+	tuner.running.Wait()
+	if state, _ := rig.GetTRXState(); state != "RX" {
+		t.Errorf("still %s after the band change", state)
+	}
+	if _, ok := tuner.tuned["40m"]; ok {
+		t.Error("a cut short tune counted as a match")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// maxEventDatagram is the largest UDP payload IPv4 carries.
+const maxEventDatagram = 65507
+
+// udpEventSink sends each event as a JSON datagram to a unicast, broadcast
+// or multicast address, the way WSJT-X and N1MM broadcast their status, so
+// a listener needs nothing more than a UDP socket.
+type udpEventSink struct {
+	addr string
+}
+
+func newUDPEventSink(addr string) (*udpEventSink, error) {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return nil, fmt.Errorf("invalid --udp-events %q, want host:port", addr)
+	}
+	return &udpEventSink{addr: addr}, nil
+}
+
+func (s *udpEventSink) Name() string        { return "udp-events" }
+func (s *udpEventSink) Wants(ev Event) bool { return true }
+
+func (s *udpEventSink) Handle(ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxEventDatagram {
+		return fmt.Errorf("%s event of %d bytes is too large for a datagram", ev.Type, len(data))
+	}
+	if dryRun {
+		dryRunf("would send to udp://%s: %s", s.addr, data)
+		return nil
+	}
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to send event to %s: %v", s.addr, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("failed to send event to %s: %v", s.addr, err)
+	}
+	return nil
+}
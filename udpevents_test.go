@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPEventSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := newUDPEventSink(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Handle(Event{Type: EventBandChange, Band: "20m", PrevBand: "40m", Freq: 14074000}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev Event
+	if err := json.Unmarshal(buf[:n], &ev); err != nil {
+		t.Fatalf("datagram %q isn't JSON: %v", buf[:n], err)
+	}
+	if ev.Type != EventBandChange || ev.Band != "20m" || ev.PrevBand != "40m" || ev.Freq != 14074000 {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestNewUDPEventSinkInvalid(t *testing.T) {
+	for _, addr := range []string{"239.255.42.1", "udp://239.255.42.1:2238", "host:"} {
+		if _, err := newUDPEventSink(addr); err == nil {
+			t.Errorf("no error for %q", addr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// verifyBackend reads back the state after every change and retries, so a
+// rig that ignored or mangled a command is noticed rather than assumed
+// tuned or switched. After the last retry a verify-failed event is
+// emitted.
+type verifyBackend struct {
+	Backend
+	settle     time.Duration
+	retries    int
+	tolerance  float64
+	dispatcher *Dispatcher
+}
+
+func (b *verifyBackend) SetFrequency(freq float64) error {
+	var got float64
+	return b.verify("frequency", func() error {
+		return b.Backend.SetFrequency(freq)
+	}, func() error {
+		var err error
+		if got, err = b.Backend.GetFrequency(); err != nil {
+			return err
+		}
+		if math.Abs(got-freq) > b.tolerance {
+			return fmt.Errorf("frequency read back as %.0f after setting %.0f", got, freq)
+		}
+		return nil
+	}, func() Event {
+		return Event{Freq: freq, ReadBack: got}
+	})
+}
+
+// verify applies set and runs check, which fails if the change didn't read
+// back, after the settle time, up to retries+1 times. The event failed
+// returns describes the change for the verify-failed event.
+func (b *verifyBackend) verify(what string, set, check func() error, failed func() Event) error {
+	var err error
+	for attempt := 0; attempt <= b.retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying %s change on %s (attempt %d)", what, b.Name(), attempt+1)
+		}
+		if err = set(); err != nil {
+			continue
+		}
+		time.Sleep(b.settle)
+		if err = check(); err == nil {
+			return nil
+		}
+	}
+
+	ev := failed()
+	ev.Type, ev.Backend, ev.Error = EventVerifyFailed, b.Name(), err.Error()
+	b.dispatcher.Emit(ev)
+	return fmt.Errorf("failed to verify %s change on %s: %v", what, b.Name(), err)
+}
+
+// SetMode reads the mode back like the frequency. Rigs differ in the case
+// of the names they report, so that is ignored.
+func (b *verifyBackend) SetMode(mode string) error {
+	m, ok := b.Backend.(ModeSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't change the mode", b.Name())
+	}
+	return b.verify("mode", func() error {
+		return m.SetMode(mode)
+	}, func() error {
+		got, err := b.Backend.GetMode()
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(got, mode) {
+			return fmt.Errorf("mode read back as %s after setting %s", got, mode)
+		}
+		return nil
+	}, func() Event {
+		return Event{Mode: mode}
+	})
+}
+
+func (b *verifyBackend) SendText(text string) error {
+	s, ok := b.Backend.(TextSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't transmit text", b.Name())
+	}
+	return s.SendText(text)
+}
+
+func (b *verifyBackend) GetSplit() (bool, float64, error) {
+	s, ok := b.Backend.(SplitReader)
+	if !ok {
+		return false, 0, fmt.Errorf("the %s backend does not report split operation", b.Name())
+	}
+	return s.GetSplit()
+}
+
+// powerTolerance and levelTolerance are how far, in percentage points and
+// dB, a power or level may read back from the one set, as rigs set them in
+// steps.
+const (
+	powerTolerance = 5
+	levelTolerance = 0.5
+)
+
+// SetPower reads the power back on backends that report it.
+func (b *verifyBackend) SetPower(percent float64) error {
+	s, ok := b.Backend.(PowerSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't set the power", b.Name())
+	}
+	r, ok := b.Backend.(PowerReader)
+	if !ok {
+		return s.SetPower(percent)
+	}
+	var got float64
+	return b.verify("power", func() error {
+		return s.SetPower(percent)
+	}, func() error {
+		var err error
+		if got, err = r.GetPower(); err != nil {
+			return err
+		}
+		if math.Abs(got-percent) > powerTolerance {
+			return fmt.Errorf("power read back as %.0f%% after setting %.0f%%", got, percent)
+		}
+		return nil
+	}, func() Event {
+		return Event{ReadBack: got}
+	})
+}
+
+// SetLevel reads the level back on backends that report it.
+func (b *verifyBackend) SetLevel(name string, value float64) error {
+	s, ok := b.Backend.(LevelSetter)
+	if !ok {
+		return fmt.Errorf("the %s backend can't set the attenuator or preamp", b.Name())
+	}
+	r, ok := b.Backend.(LevelReader)
+	if !ok {
+		return s.SetLevel(name, value)
+	}
+	var got float64
+	return b.verify(name, func() error {
+		return s.SetLevel(name, value)
+	}, func() error {
+		var err error
+		if got, err = r.GetLevel(name); err != nil {
+			return err
+		}
+		if math.Abs(got-value) > levelTolerance {
+			return fmt.Errorf("%s read back as %v after setting %v", name, got, value)
+		}
+		return nil
+	}, func() Event {
+		return Event{ReadBack: got}
+	})
+}
+
+// SendCAT passes a raw CAT command through; its effect can't be read back.
+func (b *verifyBackend) SendCAT(cmd string) error {
+	s, ok := b.Backend.(CATSender)
+	if !ok {
+		return fmt.Errorf("the %s backend can't send CAT commands", b.Name())
+	}
+	return s.SendCAT(cmd)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// deafBackend ignores frequency changes, like a rig whose CAT link dropped
+// commands.
+type deafBackend struct {
+	fakeBackend
+	attempts int
+}
+
+func (b *deafBackend) SetFrequency(freq float64) error {
+	b.attempts++
+	return nil
+}
+
+func TestVerifyBackend(t *testing.T) {
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), sink)
+
+	ok := &verifyBackend{Backend: &fakeBackend{name: "fake"}, retries: 2, tolerance: 1, dispatcher: dispatcher}
+	if err := ok.SetFrequency(14074000); err != nil {
+		t.Errorf("SetFrequency() = %v", err)
+	}
+
+	deaf := &deafBackend{fakeBackend: fakeBackend{name: "deaf", freq: 7074000}}
+	failing := &verifyBackend{Backend: deaf, retries: 2, tolerance: 1, dispatcher: dispatcher}
+	if err := failing.SetFrequency(14074000); err == nil {
+		t.Error("expected an error when the frequency doesn't read back")
+	}
+	if deaf.attempts != 3 {
+		t.Errorf("attempts = %d; want 3", deaf.attempts)
+	}
+
+	if len(sink.events) != 1 {
+		t.Fatalf("events = %v", sink.types())
+	}
+	ev := sink.events[0]
+	if ev.Type != EventVerifyFailed || ev.Freq != 14074000 || ev.ReadBack != 7074000 || ev.Backend != "deaf" {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+// modeBackend switches modes, or with stuck set ignores the change.
+type modeBackend struct {
+	fakeBackend
+	stuck    bool
+	attempts int
+}
+
+func (b *modeBackend) SetMode(mode string) error {
+	b.attempts++
+	if !b.stuck {
+		b.mode = strings.ToLower(mode)
+	}
+	return nil
+}
+
+func TestVerifyBackendMode(t *testing.T) {
+	sink := &captureSink{}
+	dispatcher := NewDispatcher(NewMetrics(), sink)
+
+	rig := &modeBackend{fakeBackend: fakeBackend{name: "fake", mode: "LSB"}}
+	b := &verifyBackend{Backend: rig, retries: 1, dispatcher: dispatcher}
+	if err := b.SetMode("USB"); err != nil || rig.attempts != 1 {
+		t.Errorf("SetMode() = %v after %d attempts", err, rig.attempts)
+	}
+
+	rig.stuck, rig.attempts = true, 0
+	if err := b.SetMode("CW"); err == nil || rig.attempts != 2 {
+		t.Errorf("stuck SetMode() = %v after %d attempts", err, rig.attempts)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != EventVerifyFailed || sink.events[0].Mode != "CW" || !strings.Contains(sink.events[0].Error, "usb") {
+		t.Errorf("events = %+v", sink.events)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+)
+
+// watchContext is how much previously received text is kept so callsigns
+// split across reads are still matched.
+const watchContext = 32
+
+// Watchlist matches callsigns of interest in the decoded receive text.
+type Watchlist struct {
+	calls []string
+	tail  string
+}
+
+func NewWatchlist(calls []string) *Watchlist {
+	w := &Watchlist{}
+	for _, c := range calls {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c != "" {
+			w.calls = append(w.calls, c)
+		}
+	}
+	return w
+}
+
+func (w *Watchlist) Empty() bool {
+	return len(w.calls) == 0
+}
+
+// Feed appends newly received text and returns watched callsigns that were
+// completed by it.
+func (w *Watchlist) Feed(text string) []string {
+	text = w.tail + strings.ToUpper(text)
+	fresh := len(w.tail)
+
+	var found []string
+	for _, call := range w.calls {
+		for i := 0; ; {
+			idx := strings.Index(text[i:], call)
+			if idx < 0 {
+				break
+			}
+			start := i + idx
+			end := start + len(call)
+			i = start + 1
+
+			// Only report whole callsigns once their trailing boundary has
+			// been received, so K1AB doesn't match the start of K1ABC, and
+			// only when that boundary is new so matches aren't repeated.
+			if end < fresh || end >= len(text) {
+				continue
+			}
+			if start > 0 && isCallChar(text[start-1]) {
+				continue
+			}
+			if isCallChar(text[end]) {
+				continue
+			}
+			found = append(found, call)
+			break
+		}
+	}
+
+	if len(text) > watchContext {
+		text = text[len(text)-watchContext:]
+	}
+	w.tail = text
+	return found
+}
+
+func isCallChar(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '/'
+}
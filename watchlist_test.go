@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWatchlistFeed(t *testing.T) {
+	w := NewWatchlist([]string{"k1abc", "W1AW"})
+
+	steps := []struct {
+		text string
+		want []string
+	}{
+		{"CQ CQ DE K1A", nil},
+		{"BC K1ABC ", []string{"K1ABC"}},
+		{"PSE K", nil},
+		{"1ABCD W1AW", nil},
+		{"\n", []string{"W1AW"}},
+		{" TU", nil},
+	}
+
+	for i, s := range steps {
+		got := w.Feed(s.text)
+		if !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d: Feed(%q) = %v; want %v", i, s.text, got, s.want)
+		}
+	}
+}
+
+func TestWatchlistEmpty(t *testing.T) {
+	if !NewWatchlist([]string{"", " "}).Empty() {
+		t.Error("watchlist of blank callsigns should be empty")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON posts v as a JSON document to url.
+func postJSON(url string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %v", err)
+	}
+	if dryRun {
+		dryRunf("would post to %s: %s", url, data)
+		return nil
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// webhookSink posts events of one type to a URL as JSON.
+type webhookSink struct {
+	name  string
+	url   string
+	event string
+}
+
+func (s *webhookSink) Name() string { return s.name }
+
+func (s *webhookSink) Wants(ev Event) bool {
+	return ev.Type == s.event && hookWants(ev)
+}
+
+func (s *webhookSink) Handle(ev Event) error {
+	return postJSON(s.url, ev)
+}
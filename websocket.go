@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// websocketGUID is appended to the client's key to prove the server
+// understood the handshake (RFC 6455, section 1.3).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// websocketHandler streams events as JSON text messages over a WebSocket,
+// for clients such as Node-RED that prefer one to server-sent events. Like
+// the event stream it starts with the last ?replay=N recent events.
+type websocketHandler struct {
+	stream *EventStream
+}
+
+func (h websocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || r.Header.Get("Sec-WebSocket-Key") == "" {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	// Browsers let any page open a WebSocket, so refuse other sites' pages.
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin WebSocket refused", http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	replay, _ := strconv.Atoi(r.URL.Query().Get("replay"))
+	ch := h.stream.subscribe(replay)
+	defer h.stream.unsubscribe(ch)
+
+	// The reader answers pings and notices the client going away; frames
+	// are written only by this goroutine, so pongs are passed back to it.
+	pongs := make(chan []byte, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			opcode, payload, err := readWebsocketFrame(rw.Reader)
+			if err != nil || opcode == wsClose {
+				return
+			}
+			if opcode == wsPing {
+				select {
+				case pongs <- payload:
+				default:
+				}
+			}
+		}
+	}()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			writeWebsocketFrame(conn, wsClose, nil)
+			return
+		case payload := <-pongs:
+			err = writeWebsocketFrame(conn, wsPong, payload)
+		case ev := <-ch:
+			data, merr := json.Marshal(ev)
+			if merr != nil {
+				continue
+			}
+			err = writeWebsocketFrame(conn, wsText, data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeWebsocketFrame writes an unmasked, unfragmented frame, as servers
+// send them.
+func writeWebsocketFrame(conn net.Conn, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := conn.Write(append(header, payload...))
+	return err
+}
+
+// maxWebsocketFrame bounds the frames read from clients, which have
+// nothing to send but control frames.
+const maxWebsocketFrame = 4096
+
+// readWebsocketFrame reads one frame from a client, unmasking its payload.
+func readWebsocketFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxWebsocketFrame {
+		return 0, nil, fmt.Errorf("WebSocket frame of %d bytes is too large", n)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dialWebsocket opens a WebSocket to the server at path.
+func dialWebsocket(t *testing.T, server *httptest.Server, path, origin string) (net.Conn, *bufio.Reader, string) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req := "GET " + path + " HTTP/1.1\r\nHost: " + strings.TrimPrefix(server.URL, "http://") +
+		"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+	if origin != "" {
+		req += "Origin: " + origin + "\r\n"
+	}
+	conn.Write([]byte(req + "\r\n"))
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, reader, resp.Status + " " + resp.Header.Get("Sec-WebSocket-Accept")
+}
+
+// maskedFrame is a client frame, which must be masked.
+func maskedFrame(opcode byte, payload string) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i := range payload {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	return frame
+}
+
+func TestWebsocketStream(t *testing.T) {
+	stream := NewEventStream(10)
+	stream.Handle(Event{Type: EventInitialBand, Band: "40m"})
+	server := httptest.NewServer(websocketHandler{stream})
+	defer server.Close()
+
+	conn, reader, status := dialWebsocket(t, server, "/events/ws?replay=1", "")
+	// The accept value for this key is the example in RFC 6455.
+	if status != "101 Switching Protocols s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %q", status)
+	}
+
+	opcode, payload, err := readWebsocketFrame(reader)
+	if err != nil || opcode != wsText || !strings.Contains(string(payload), `"type":"initial-band"`) {
+		t.Fatalf("replayed frame = %x %q %v", opcode, payload, err)
+	}
+	stream.Handle(Event{Type: EventTXStart, Band: "40m"})
+	if _, payload, _ := readWebsocketFrame(reader); !strings.Contains(string(payload), `"type":"tx-start"`) {
+		t.Errorf("live frame = %q", payload)
+	}
+
+	conn.Write(maskedFrame(wsPing, "hi"))
+	if opcode, payload, _ := readWebsocketFrame(reader); opcode != wsPong || string(payload) != "hi" {
+		t.Errorf("ping answered with %x %q", opcode, payload)
+	}
+	conn.Write(maskedFrame(wsClose, ""))
+	if opcode, _, _ := readWebsocketFrame(reader); opcode != wsClose {
+		t.Errorf("close answered with %x", opcode)
+	}
+}
+
+func TestWebsocketRefusesOtherOrigins(t *testing.T) {
+	server := httptest.NewServer(websocketHandler{NewEventStream(10)})
+	defer server.Close()
+	if _, _, status := dialWebsocket(t, server, "/events/ws", "https://evil.example.com"); !strings.HasPrefix(status, "403") {
+		t.Errorf("cross-origin handshake = %q", status)
+	}
+	if _, _, status := dialWebsocket(t, server, "/events/ws", server.URL); !strings.HasPrefix(status, "101") {
+		t.Errorf("same-origin handshake = %q", status)
+	}
+}
+
+func TestWriteWebsocketFrameLengths(t *testing.T) {
+	for _, n := range []int{5, 300, 70000} {
+		client, server := net.Pipe()
+		go func() {
+			writeWebsocketFrame(server, wsText, make([]byte, n))
+			server.Close()
+		}()
+		reader := bufio.NewReader(client)
+		head := make([]byte, 2)
+		reader.Read(head)
+		got := int(head[1])
+		switch got {
+		case 126:
+			ext := make([]byte, 2)
+			reader.Read(ext)
+			got = int(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			reader.Read(ext)
+			got = int(binary.BigEndian.Uint64(ext))
+		}
+		if got != n {
+			t.Errorf("frame of %d bytes has length %d", n, got)
+		}
+		client.Close()
+	}
+}
+
+func TestTokenFromQuery(t *testing.T) {
+	handler := tokenFromQuery(requireToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for path, want := range map[string]int{"/events/ws?token=s3cret": http.StatusOK, "/events/ws?token=wrong": http.StatusUnauthorized, "/events/ws": http.StatusUnauthorized} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// WSJT-X UDP message header magic and the message types we use.
+const (
+	wsjtxMagic      = 0xadbccbda
+	wsjtxTypeStatus = 1
+)
+
+// wsjtxStale is how long after the last datagram the status is too old to
+// report: WSJT-X sends a heartbeat every 15 seconds, so four have been
+// missed.
+const wsjtxStale = 60 * time.Second
+
+// WSJTXListener receives the status datagrams WSJT-X broadcasts to its UDP
+// server address and reports the dial frequency and mode from the latest
+// one. It listens rather than polls, so it can't retune WSJT-X.
+type WSJTXListener struct {
+	conn  *net.UDPConn
+	stale time.Duration
+
+	mu       sync.Mutex
+	received bool
+	heard    time.Time // of the last WSJT-X datagram
+	freq     float64
+	mode     string
+}
+
+// NewWSJTXListener listens for WSJT-X status on host:port.
+func NewWSJTXListener(host string, port int) (*WSJTXListener, error) {
+	conn, err := listenUDP("WSJT-X", host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WSJTXListener{conn: conn, stale: wsjtxStale}
+	go w.receive()
+	return w, nil
+}
+
+func (w *WSJTXListener) Name() string { return "wsjtx" }
+
+func (w *WSJTXListener) receive() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := w.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Printf("Error reading WSJT-X datagram: %v", err)
+			return
+		}
+
+		// Heartbeats and other messages show WSJT-X is still running.
+		if !isWSJTXMessage(buf[:n]) {
+			continue
+		}
+		status, ok := parseWSJTXStatus(buf[:n])
+		w.mu.Lock()
+		w.heard = time.Now()
+		if ok {
+			w.received = true
+			w.freq = status.Freq
+			w.mode = status.Mode
+		}
+		w.mu.Unlock()
+	}
+}
+
+// current returns an error unless the latest status is still current;
+// w.mu must be held.
+func (w *WSJTXListener) current() error {
+	if !w.received {
+		return fmt.Errorf("no status received from WSJT-X yet")
+	}
+	if since := time.Since(w.heard); since > w.stale {
+		return fmt.Errorf("nothing received from WSJT-X for %v", since.Round(time.Second))
+	}
+	return nil
+}
+
+func (w *WSJTXListener) GetFrequency() (float64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.current(); err != nil {
+		return 0, err
+	}
+	return w.freq, nil
+}
+
+func (w *WSJTXListener) GetMode() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.current(); err != nil {
+		return "", err
+	}
+	return w.mode, nil
+}
+
+func (w *WSJTXListener) SetFrequency(freq float64) error {
+	return fmt.Errorf("WSJT-X does not support setting the frequency")
+}
+
+// wsjtxStatus is the part of a WSJT-X Status message the monitor uses.
+type wsjtxStatus struct {
+	ID   string
+	Freq float64
+	Mode string
+}
+
+// isWSJTXMessage reports whether data starts with the WSJT-X magic number.
+func isWSJTXMessage(data []byte) bool {
+	return len(data) >= 4 && binary.BigEndian.Uint32(data) == wsjtxMagic
+}
+
+// parseWSJTXStatus decodes a Status datagram, reporting false for other
+// message types and malformed data. Integers are big-endian and strings are
+// a uint32 length followed by UTF-8 bytes, as written by Qt's QDataStream.
+func parseWSJTXStatus(data []byte) (wsjtxStatus, bool) {
+	r := bytes.NewReader(data)
+
+	var header struct {
+		Magic, Schema, Type uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return wsjtxStatus{}, false
+	}
+	if header.Magic != wsjtxMagic || header.Type != wsjtxTypeStatus {
+		return wsjtxStatus{}, false
+	}
+
+	var status wsjtxStatus
+	var ok bool
+	if status.ID, ok = wsjtxString(r); !ok {
+		return wsjtxStatus{}, false
+	}
+
+	var freq uint64
+	if err := binary.Read(r, binary.BigEndian, &freq); err != nil {
+		return wsjtxStatus{}, false
+	}
+	status.Freq = float64(freq)
+
+	if status.Mode, ok = wsjtxString(r); !ok {
+		return wsjtxStatus{}, false
+	}
+	return status, true
+}
+
+// wsjtxString reads a QDataStream UTF-8 string; 0xffffffff is a null string.
+func wsjtxString(r *bytes.Reader) (string, bool) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", false
+	}
+	if n == 0xffffffff {
+		return "", true
+	}
+	if int64(n) > int64(r.Len()) {
+		return "", false
+	}
+
+	buf := make([]byte, n)
+	r.Read(buf)
+	return string(buf), true
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func wsjtxDatagram(msgType uint32, id string, freq uint64, mode string) []byte {
+	var buf bytes.Buffer
+	writeString := func(s string) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+
+	binary.Write(&buf, binary.BigEndian, []uint32{wsjtxMagic, 2, msgType})
+	writeString(id)
+	binary.Write(&buf, binary.BigEndian, freq)
+	writeString(mode)
+	writeString("K1ABC") // DX call; the rest of the message is ignored
+	return buf.Bytes()
+}
+
+func TestParseWSJTXStatus(t *testing.T) {
+	status, ok := parseWSJTXStatus(wsjtxDatagram(wsjtxTypeStatus, "WSJT-X", 14074000, "FT8"))
+	if !ok || status.ID != "WSJT-X" || status.Freq != 14074000 || status.Mode != "FT8" {
+		t.Errorf("parseWSJTXStatus() = %+v, %v", status, ok)
+	}
+
+	// Heartbeats and truncated datagrams are ignored.
+	if _, ok := parseWSJTXStatus(wsjtxDatagram(0, "WSJT-X", 0, "")); ok {
+		t.Error("heartbeat parsed as status")
+	}
+	if _, ok := parseWSJTXStatus(wsjtxDatagram(wsjtxTypeStatus, "WSJT-X", 14074000, "FT8")[:20]); ok {
+		t.Error("truncated datagram parsed as status")
+	}
+}
+
+func TestWSJTXListener(t *testing.T) {
+	w, err := NewWSJTXListener("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.conn.Close()
+
+	if _, err := w.GetFrequency(); err == nil {
+		t.Error("expected an error before any status is received")
+	}
+
+	conn, err := net.Dial("udp", w.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(wsjtxDatagram(wsjtxTypeStatus, "WSJT-X", 7074000, "FT4"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if freq, err := w.GetFrequency(); err == nil {
+			mode, _ := w.GetMode()
+			if freq != 7074000 || mode != "FT4" {
+				t.Errorf("got %v %q", freq, mode)
+			}
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := w.GetFrequency(); err != nil {
+		t.Fatal("status not received")
+	}
+
+	// Once WSJT-X goes quiet the status is stale, until a heartbeat.
+	w.mu.Lock()
+	w.stale = 100 * time.Millisecond
+	w.mu.Unlock()
+	time.Sleep(200 * time.Millisecond)
+	if _, err := w.GetFrequency(); err == nil {
+		t.Error("expected an error for a stale status")
+	}
+	conn.Write(wsjtxDatagram(0, "WSJT-X", 0, ""))
+	deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if freq, err := w.GetFrequency(); err == nil {
+			if freq != 7074000 {
+				t.Errorf("got %v after a heartbeat", freq)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("heartbeat didn't refresh the status")
+}